@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-backend/config"
+	"go-backend/internal/catalog"
+	"go-backend/internal/database"
+	"go-backend/internal/repository"
+	"go-backend/internal/services"
+	"go-backend/pkg/currency"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage:
+  catalog sync -f <catalog.yaml|catalog.json> [--dry-run]
+  catalog export [-o <file>] [--format yaml|json]
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using system environment variables")
+	}
+
+	switch os.Args[1] {
+	case "sync":
+		runSync(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runSync reconciles the database against a catalog file
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	file := fs.String("f", "", "path to the catalog file")
+	dryRun := fs.Bool("dry-run", false, "print the changes without applying them")
+	fs.Parse(args)
+
+	if *file == "" {
+		log.Fatal("sync requires -f <catalog file>")
+	}
+
+	desired, err := catalog.Load(*file)
+	if err != nil {
+		log.Fatalf("Failed to load catalog: %v", err)
+	}
+
+	db, currencies := openDatabase()
+	planService := newPlanService(db, currencies)
+
+	current, err := planService.GetAllPlansUnpaginated()
+	if err != nil {
+		log.Fatalf("Failed to load plans: %v", err)
+	}
+
+	changes := catalog.Diff(current, desired)
+	changes.Print(os.Stdout)
+
+	if err := changes.Validate(planService); err != nil {
+		log.Fatalf("Catalog is invalid: %v", err)
+	}
+
+	if *dryRun || changes.IsEmpty() {
+		return
+	}
+
+	err = changes.Apply(db, func(tx *gorm.DB) *services.PlanService {
+		return newPlanService(tx, currencies)
+	})
+	if err != nil {
+		log.Fatalf("Failed to apply catalog: %v", err)
+	}
+
+	log.Println("✅ Catalog synced successfully")
+}
+
+// runExport dumps the current plans in catalog format
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "", "write to file instead of stdout")
+	formatFlag := fs.String("format", "", "yaml or json (defaults to the output file extension, then yaml)")
+	fs.Parse(args)
+
+	format := catalog.Format(*formatFlag)
+	if format == "" {
+		format = catalog.FormatYAML
+		if *output != "" {
+			if inferred, err := catalog.FormatFromPath(*output); err == nil {
+				format = inferred
+			}
+		}
+	}
+
+	planService := newPlanService(openDatabase())
+
+	plans, err := planService.GetAllPlansUnpaginated()
+	if err != nil {
+		log.Fatalf("Failed to load plans: %v", err)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := catalog.FromPlans(plans).Encode(out, format); err != nil {
+		log.Fatalf("Failed to write catalog: %v", err)
+	}
+}
+
+// openDatabase connects to the configured database and loads the enabled currencies
+func openDatabase() (*gorm.DB, *currency.Registry) {
+	cfg := config.Load()
+
+	db, err := database.Initialize(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	// Keep SQL logging off stdout so exports stay machine-readable
+	db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+
+	currencies, err := currency.NewRegistry(cfg.Billing.EnabledCurrencies)
+	if err != nil {
+		log.Fatalf("Invalid ENABLED_CURRENCIES: %v", err)
+	}
+	return db, currencies
+}
+
+// newPlanService wires a PlanService against db, which may be a transaction
+func newPlanService(db *gorm.DB, currencies *currency.Registry) *services.PlanService {
+	repos := repository.NewRepositories(db)
+	return services.NewPlanService(repos.Plan, repos.Subscription, repos.Meter, currencies)
+}
@@ -10,11 +10,18 @@ import (
 	"syscall"
 	"time"
 
+	"go-backend/internal/blobstore"
 	"go-backend/internal/database"
 	"go-backend/internal/handlers"
+	"go-backend/internal/invoicepdf"
+	"go-backend/internal/jobs"
+	"go-backend/internal/notification"
+	"go-backend/internal/payment"
 	"go-backend/internal/repository"
 	"go-backend/internal/router"
 	"go-backend/internal/services"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/currency"
 	"go-backend/pkg/utils"
 
 	"go-backend/config"
@@ -42,17 +49,79 @@ func main() {
 		log.Fatalf("Failed to auto-migrate database: %v", err)
 	}
 
+	// Services read the time from the system clock; test clocks replace it
+	// for sandbox organizations
+	clk := clock.System()
+
 	// Initialize JWT manager
 	jwtManager := utils.NewJWTManager(
 		cfg.JWT.SecretKey,
 		cfg.JWT.AccessTokenExpiry,
+		clk,
 	)
 
 	// Initialize repositories
 	repos := repository.NewRepositories(db)
 
+	// Initialize the currency registry
+	currencies, err := currency.NewRegistry(cfg.Billing.EnabledCurrencies)
+	if err != nil {
+		log.Fatalf("Invalid ENABLED_CURRENCIES: %v", err)
+	}
+	if !currency.IsValid(cfg.Billing.ReportingCurrency) {
+		log.Fatalf("Invalid REPORTING_CURRENCY: %q", cfg.Billing.ReportingCurrency)
+	}
+	if !services.IsValidTrialEndAction(cfg.Billing.TrialWithoutPaymentMethod) {
+		log.Fatalf("Invalid TRIAL_WITHOUT_PAYMENT_METHOD: %q", cfg.Billing.TrialWithoutPaymentMethod)
+	}
+	if !services.IsValidQuantityDecreaseMode(cfg.Billing.SeatDecreaseMode) {
+		log.Fatalf("Invalid SEAT_DECREASE_MODE: %q", cfg.Billing.SeatDecreaseMode)
+	}
+	if !services.IsValidDunningSchedule(cfg.Billing.DunningRetryDays) {
+		log.Fatalf("Invalid DUNNING_RETRY_DAYS: %v", cfg.Billing.DunningRetryDays)
+	}
+	if !services.IsValidDunningAction(cfg.Billing.DunningFinalAction) {
+		log.Fatalf("Invalid DUNNING_FINAL_ACTION: %q", cfg.Billing.DunningFinalAction)
+	}
+
 	// Initialize services
-	services := services.NewServices(repos, jwtManager)
+	notifier := notification.New(cfg.Email)
+	gateway, err := payment.New(cfg.Payment)
+	if err != nil {
+		log.Fatalf("Invalid PAYMENT_GATEWAY: %v", err)
+	}
+	if gateway == nil {
+		log.Println("No payment gateway configured; invoices are not charged")
+	}
+	invoicePDF, err := invoicepdf.NewRenderer(cfg.Invoice)
+	if err != nil {
+		log.Fatalf("Invalid invoice PDF settings: %v", err)
+	}
+	blobs, err := blobstore.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+	services := services.NewServices(repos, jwtManager, currencies, cfg.Billing, cfg.Usage, notifier, gateway, invoicePDF, blobs, clk)
+
+	// Pick up plan migrations interrupted by a previous shutdown
+	if err := services.PlanRetirement.ResumeUnfinishedMigrations(); err != nil {
+		log.Printf("Warning: failed to resume plan migrations: %v", err)
+	}
+
+	// Start background billing jobs unless a separate worker runs them
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	scheduler := jobs.NewScheduler(repos.JobLock)
+	if cfg.Billing.JobsInServer {
+		for _, job := range services.Jobs(cfg.Billing.JobInterval) {
+			scheduler.Add(job)
+		}
+	} else {
+		log.Println("Background billing jobs disabled; run cmd/worker to process them")
+	}
+	scheduler.Start(jobsCtx)
+
+	// Start writing batched usage events
+	services.UsagePipeline.Start(context.Background())
 
 	// Initialize handlers
 	handlers := handlers.NewHandlers(services)
@@ -88,6 +157,10 @@ func main() {
 
 	log.Println("🛑 Shutting down server...")
 
+	// Stop background jobs before closing the database
+	stopJobs()
+	scheduler.Wait()
+
 	// Create a deadline for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -98,6 +171,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Write the usage events still queued before closing the database
+	if err := services.UsagePipeline.Close(ctx); err != nil {
+		log.Printf("❌ Usage events dropped on shutdown: %v", err)
+	}
+
 	// Close database connection
 	sqlDB, err := db.DB()
 	if err == nil {
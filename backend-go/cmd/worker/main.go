@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-backend/internal/blobstore"
+	"go-backend/internal/database"
+	"go-backend/internal/invoicepdf"
+	"go-backend/internal/jobs"
+	"go-backend/internal/notification"
+	"go-backend/internal/payment"
+	"go-backend/internal/repository"
+	"go-backend/internal/services"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/currency"
+	"go-backend/pkg/utils"
+
+	"go-backend/config"
+
+	"github.com/joho/godotenv"
+)
+
+// The worker runs the background billing jobs without the HTTP API, so they
+// can be scaled separately from the server. Start the server with
+// BILLING_JOBS_IN_SERVER=false when using it.
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using system environment variables")
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Initialize database
+	db, err := database.Initialize(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	// Auto-migrate database schema
+	if err := database.AutoMigrate(db); err != nil {
+		log.Fatalf("Failed to auto-migrate database: %v", err)
+	}
+
+	// Initialize repositories
+	repos := repository.NewRepositories(db)
+
+	// Initialize the currency registry
+	currencies, err := currency.NewRegistry(cfg.Billing.EnabledCurrencies)
+	if err != nil {
+		log.Fatalf("Invalid ENABLED_CURRENCIES: %v", err)
+	}
+	if !services.IsValidTrialEndAction(cfg.Billing.TrialWithoutPaymentMethod) {
+		log.Fatalf("Invalid TRIAL_WITHOUT_PAYMENT_METHOD: %q", cfg.Billing.TrialWithoutPaymentMethod)
+	}
+	if !services.IsValidQuantityDecreaseMode(cfg.Billing.SeatDecreaseMode) {
+		log.Fatalf("Invalid SEAT_DECREASE_MODE: %q", cfg.Billing.SeatDecreaseMode)
+	}
+	if !services.IsValidDunningSchedule(cfg.Billing.DunningRetryDays) {
+		log.Fatalf("Invalid DUNNING_RETRY_DAYS: %v", cfg.Billing.DunningRetryDays)
+	}
+	if !services.IsValidDunningAction(cfg.Billing.DunningFinalAction) {
+		log.Fatalf("Invalid DUNNING_FINAL_ACTION: %q", cfg.Billing.DunningFinalAction)
+	}
+
+	// Initialize services
+	clk := clock.System()
+	jwtManager := utils.NewJWTManager(cfg.JWT.SecretKey, cfg.JWT.AccessTokenExpiry, clk)
+	notifier := notification.New(cfg.Email)
+	gateway, err := payment.New(cfg.Payment)
+	if err != nil {
+		log.Fatalf("Invalid PAYMENT_GATEWAY: %v", err)
+	}
+	if gateway == nil {
+		log.Println("No payment gateway configured; invoices are not charged")
+	}
+	invoicePDF, err := invoicepdf.NewRenderer(cfg.Invoice)
+	if err != nil {
+		log.Fatalf("Invalid invoice PDF settings: %v", err)
+	}
+	blobs, err := blobstore.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+	services := services.NewServices(repos, jwtManager, currencies, cfg.Billing, cfg.Usage, notifier, gateway, invoicePDF, blobs, clk)
+
+	// Start background billing jobs
+	ctx, stop := context.WithCancel(context.Background())
+	scheduler := jobs.NewScheduler(repos.JobLock)
+	for _, job := range services.Jobs(cfg.Billing.JobInterval) {
+		scheduler.Add(job)
+	}
+	scheduler.Start(ctx)
+
+	log.Printf("⚙️  Billing worker started (interval %s, batch size %d, workers %d)",
+		cfg.Billing.JobInterval, cfg.Billing.BatchSize, cfg.Billing.Workers)
+
+	// Wait for interrupt signal to gracefully shutdown the worker
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("🛑 Shutting down worker...")
+
+	// Let running jobs finish before closing the database
+	stop()
+	scheduler.Wait()
+
+	sqlDB, err := db.DB()
+	if err == nil {
+		sqlDB.Close()
+	}
+
+	log.Println("✅ Worker exited gracefully")
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -18,6 +19,12 @@ type Config struct {
 	Database DatabaseConfig
 	Server   ServerConfig
 	JWT      JWTConfig
+	Billing  BillingConfig
+	Usage    UsageConfig
+	Email    EmailConfig
+	Invoice  InvoiceConfig
+	Storage  StorageConfig
+	Payment  PaymentConfig
 }
 
 // DatabaseConfig holds database configuration
@@ -43,9 +50,75 @@ type ServerConfig struct {
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	SecretKey            string
-	AccessTokenExpiry    time.Duration
-	RefreshTokenExpiry   time.Duration
+	SecretKey          string
+	AccessTokenExpiry  time.Duration
+	RefreshTokenExpiry time.Duration
+}
+
+// BillingConfig holds billing configuration
+type BillingConfig struct {
+	EnabledCurrencies []string // ISO 4217 codes plans may be priced in; empty enables all
+	ReportingCurrency string   // currency analytics and exports are converted into
+
+	TrialWithoutPaymentMethod string        // what happens to trials that end without a payment method: convert, expire or cancel
+	TrialReminderDays         int           // days before a trial ends that the reminder is sent; 0 disables reminders
+	JobInterval               time.Duration // how often background billing jobs run; 0 disables them
+	JobsInServer              bool          // run background billing jobs in the API server; disable when running cmd/worker
+	SeatDecreaseMode          string        // default handling of seat decreases: immediate or period_end
+
+	DunningRetryDays   []int  // days after the first failed payment of an invoice that it is retried, ascending
+	DunningFinalAction string // what happens once every retry failed: cancel, suspend or mark_uncollectible
+
+	ReactivationWindowDays int // days after a subscription ended that it can still be restarted; 0 disables restarts
+
+	BatchSize       int           // subscriptions the billing engine claims per batch
+	Workers         int           // subscriptions the billing engine processes concurrently
+	MaxAttempts     int           // renewal attempts per period before a subscription is moved to past_due
+	RetryBackoff    time.Duration // wait before the first retry of a failed renewal, doubled for every later one
+	RetryBackoffMax time.Duration // longest wait between retries of a failed renewal
+	ClaimStaleness  time.Duration // how long a claim may stay processing before another engine may take it over
+}
+
+// UsageConfig holds usage ingestion configuration
+type UsageConfig struct {
+	QueueSize        int           // events buffered in memory before batch requests are turned away with 429
+	BatchSize        int           // events written to the database per batch
+	FlushInterval    time.Duration // longest an accepted event waits for its batch to fill
+	Writers          int           // batches written concurrently
+	RetryAfter       time.Duration // Retry-After sent with 429 responses
+	MaxRequestEvents int           // events accepted in one batch request
+}
+
+// EmailConfig holds outgoing email configuration
+type EmailConfig struct {
+	SMTPHost     string // empty logs emails instead of sending them
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// InvoiceConfig holds the seller details and branding of invoice PDFs
+type InvoiceConfig struct {
+	SellerName    string
+	SellerAddress string // printed as given; "\n" starts a new line
+	SellerTaxID   string
+	SellerEmail   string
+
+	LogoPath     string // PNG or JPEG printed in the header; empty prints the seller name
+	PrimaryColor string // hex colour of the header and table headings, e.g. #1F2937
+	AccentColor  string // hex colour of the total and payment status
+	Footer       string // printed at the bottom of every page
+}
+
+// StorageConfig holds blob storage configuration
+type StorageConfig struct {
+	BlobDir string // directory of the local blob store, e.g. for cached invoice PDFs
+}
+
+// PaymentConfig holds payment collection configuration
+type PaymentConfig struct {
+	Gateway string // gateway invoices are charged through: simulated; empty disables charging
 }
 
 // Load loads configuration from environment variables
@@ -64,6 +137,29 @@ func Load() *Config {
 	writeTimeout, _ := strconv.Atoi(getEnv("SERVER_WRITE_TIMEOUT", "10"))
 	idleTimeout, _ := strconv.Atoi(getEnv("SERVER_IDLE_TIMEOUT", "60"))
 
+	// Parse billing job settings
+	trialReminderDays, _ := strconv.Atoi(getEnv("TRIAL_REMINDER_DAYS", "3"))
+	jobInterval, _ := time.ParseDuration(getEnv("BILLING_JOB_INTERVAL", "1h"))
+	jobsInServer, _ := strconv.ParseBool(getEnv("BILLING_JOBS_IN_SERVER", "true"))
+	batchSize, _ := strconv.Atoi(getEnv("BILLING_BATCH_SIZE", "100"))
+	workers, _ := strconv.Atoi(getEnv("BILLING_WORKERS", "4"))
+	maxAttempts, _ := strconv.Atoi(getEnv("BILLING_MAX_ATTEMPTS", "3"))
+	retryBackoff, _ := time.ParseDuration(getEnv("BILLING_RETRY_BACKOFF", "15m"))
+	retryBackoffMax, _ := time.ParseDuration(getEnv("BILLING_RETRY_BACKOFF_MAX", "24h"))
+	claimStaleness, _ := time.ParseDuration(getEnv("BILLING_CLAIM_STALENESS", "15m"))
+
+	// Parse dunning settings
+	dunningRetryDays := getEnvInts("DUNNING_RETRY_DAYS", []int{1, 3, 5, 7})
+	reactivationWindowDays, _ := strconv.Atoi(getEnv("REACTIVATION_WINDOW_DAYS", "30"))
+
+	// Parse usage ingestion settings
+	usageQueueSize, _ := strconv.Atoi(getEnv("USAGE_QUEUE_SIZE", "100000"))
+	usageBatchSize, _ := strconv.Atoi(getEnv("USAGE_BATCH_SIZE", "5000"))
+	usageFlushInterval, _ := time.ParseDuration(getEnv("USAGE_FLUSH_INTERVAL", "250ms"))
+	usageWriters, _ := strconv.Atoi(getEnv("USAGE_WRITERS", "4"))
+	usageRetryAfter, _ := time.ParseDuration(getEnv("USAGE_RETRY_AFTER", "2s"))
+	usageMaxRequestEvents, _ := strconv.Atoi(getEnv("USAGE_MAX_REQUEST_EVENTS", "50000"))
+
 	config := &Config{
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -87,6 +183,60 @@ func Load() *Config {
 			AccessTokenExpiry:  accessTokenExp,
 			RefreshTokenExpiry: refreshTokenExp,
 		},
+		Billing: BillingConfig{
+			EnabledCurrencies: getEnvList("ENABLED_CURRENCIES"),
+			ReportingCurrency: getEnv("REPORTING_CURRENCY", "USD"),
+
+			TrialWithoutPaymentMethod: getEnv("TRIAL_WITHOUT_PAYMENT_METHOD", "cancel"),
+			TrialReminderDays:         trialReminderDays,
+			JobInterval:               jobInterval,
+			JobsInServer:              jobsInServer,
+			SeatDecreaseMode:          getEnv("SEAT_DECREASE_MODE", "period_end"),
+
+			DunningRetryDays:   dunningRetryDays,
+			DunningFinalAction: getEnv("DUNNING_FINAL_ACTION", "cancel"),
+
+			ReactivationWindowDays: reactivationWindowDays,
+
+			BatchSize:       batchSize,
+			Workers:         workers,
+			MaxAttempts:     maxAttempts,
+			RetryBackoff:    retryBackoff,
+			RetryBackoffMax: retryBackoffMax,
+			ClaimStaleness:  claimStaleness,
+		},
+		Usage: UsageConfig{
+			QueueSize:        usageQueueSize,
+			BatchSize:        usageBatchSize,
+			FlushInterval:    usageFlushInterval,
+			Writers:          usageWriters,
+			RetryAfter:       usageRetryAfter,
+			MaxRequestEvents: usageMaxRequestEvents,
+		},
+		Email: EmailConfig{
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("EMAIL_FROM", "billing@localhost"),
+		},
+		Invoice: InvoiceConfig{
+			SellerName:    getEnv("INVOICE_SELLER_NAME", "OstoBilling"),
+			SellerAddress: strings.ReplaceAll(getEnv("INVOICE_SELLER_ADDRESS", ""), `\n`, "\n"),
+			SellerTaxID:   getEnv("INVOICE_SELLER_TAX_ID", ""),
+			SellerEmail:   getEnv("INVOICE_SELLER_EMAIL", ""),
+
+			LogoPath:     getEnv("INVOICE_LOGO_PATH", ""),
+			PrimaryColor: getEnv("INVOICE_PRIMARY_COLOR", "#1F2937"),
+			AccentColor:  getEnv("INVOICE_ACCENT_COLOR", "#2563EB"),
+			Footer:       getEnv("INVOICE_FOOTER", "Thank you for your business."),
+		},
+		Storage: StorageConfig{
+			BlobDir: getEnv("BLOB_STORE_DIR", "./data/blobs"),
+		},
+		Payment: PaymentConfig{
+			Gateway: getEnv("PAYMENT_GATEWAY", ""),
+		},
 	}
 
 	return config
@@ -145,4 +295,29 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// getEnvList reads a comma-separated environment variable, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvInts reads a comma-separated list of integers, or returns fallback
+// when the variable is unset. Entries that are not integers are read as 0.
+func getEnvInts(key string, fallback []int) []int {
+	values := getEnvList(key)
+	if len(values) == 0 {
+		return fallback
+	}
+	ints := make([]int, len(values))
+	for i, value := range values {
+		ints[i], _ = strconv.Atoi(value)
+	}
+	return ints
+}
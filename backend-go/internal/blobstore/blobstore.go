@@ -0,0 +1,95 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go-backend/config"
+)
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps generated files, such as rendered invoice PDFs, under
+// slash-separated keys like "invoices/<id>/<version>.pdf"
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+}
+
+// New returns the blob store for the configuration. Only the local
+// filesystem is supported; other backends implement Store.
+func New(cfg config.StorageConfig) (Store, error) {
+	return NewLocalStore(cfg.BlobDir)
+}
+
+// localStore keeps blobs as files below a directory
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore creates a store that keeps blobs as files below dir,
+// creating it if needed
+func NewLocalStore(dir string) (Store, error) {
+	if dir == "" {
+		return nil, errors.New("blob store directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory: %w", err)
+	}
+	return &localStore{dir: dir}, nil
+}
+
+// Get reads the blob stored under key
+func (s *localStore) Get(key string) ([]byte, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put stores data under key, replacing any blob stored there. The file is
+// written under a temporary name and renamed, so readers never see part of it.
+func (s *localStore) Put(key string, data []byte) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// path maps a key to a file below the store directory, rejecting keys that
+// would leave it
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean(key)
+	if key == "" || clean != key || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
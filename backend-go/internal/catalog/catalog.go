@@ -0,0 +1,256 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+	"go-backend/pkg/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies the on-disk encoding of a catalog file
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// Catalog is the declarative description of every plan that should exist
+type Catalog struct {
+	Plans []PlanSpec `json:"plans" yaml:"plans"`
+}
+
+// PlanSpec describes a single plan, keyed by its slug
+type PlanSpec struct {
+	Slug        string   `json:"slug" yaml:"slug"`
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Product     string   `json:"product,omitempty" yaml:"product,omitempty"`
+	Price       Price    `json:"price" yaml:"price"`
+	Currency    string   `json:"currency" yaml:"currency"`
+	Interval    string   `json:"interval" yaml:"interval"`
+	Features    []string `json:"features" yaml:"features"`
+	TrialDays   int      `json:"trial_days" yaml:"trial_days"`
+	IsPopular   bool     `json:"is_popular" yaml:"is_popular"`
+	Active      *bool    `json:"active,omitempty" yaml:"active,omitempty"`
+}
+
+// Price is a plan price written as a decimal number in major units, e.g.
+// 19.99. It is kept as text so no precision is lost on the way to money.Money.
+type Price string
+
+// PriceOf renders an amount as a catalog price
+func PriceOf(m money.Money) Price {
+	return Price(m.Decimal())
+}
+
+// Money parses the price in the given currency
+func (p Price) Money(currency string) (money.Money, error) {
+	return money.Parse(string(p), currency)
+}
+
+// MarshalJSON writes the price as a bare JSON number
+func (p Price) MarshalJSON() ([]byte, error) {
+	if _, err := p.Money(""); err != nil {
+		return nil, err
+	}
+	return []byte(p), nil
+}
+
+// UnmarshalJSON accepts the price as a JSON number or string
+func (p *Price) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*p = Price(number)
+	return nil
+}
+
+// MarshalYAML writes the price as a plain YAML number
+func (p Price) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: string(p)}, nil
+}
+
+// UnmarshalYAML keeps the literal text of the YAML scalar
+func (p *Price) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: price must be a number", node.Line)
+	}
+	*p = Price(node.Value)
+	return nil
+}
+
+// IsActive reports whether the spec wants the plan to be sellable; plans are
+// active unless explicitly switched off
+func (p PlanSpec) IsActive() bool {
+	return p.Active == nil || *p.Active
+}
+
+// FormatFromPath infers the catalog format from a file extension
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported catalog file extension %q", filepath.Ext(path))
+	}
+}
+
+// Load reads and validates a catalog file
+func Load(path string) (*Catalog, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f, format)
+}
+
+// Decode parses a catalog from r in the given format
+func Decode(r io.Reader, format Format) (*Catalog, error) {
+	var cat Catalog
+	switch format {
+	case FormatYAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&cat); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse catalog: %w", err)
+		}
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cat); err != nil {
+			return nil, fmt.Errorf("failed to parse catalog: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported catalog format %q", format)
+	}
+
+	if err := cat.Validate(); err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// Encode writes the catalog to w in the given format
+func (c *Catalog) Encode(w io.Writer, format Format) error {
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	default:
+		return fmt.Errorf("unsupported catalog format %q", format)
+	}
+}
+
+// Validate checks structural rules that span plans; per-plan field rules are
+// delegated to PlanService when the catalog is applied
+func (c *Catalog) Validate() error {
+	seen := make(map[string]bool, len(c.Plans))
+	for i, p := range c.Plans {
+		if p.Slug == "" {
+			return fmt.Errorf("plan #%d: slug is required", i+1)
+		}
+		// Plans are stored under the canonical form of their slug and
+		// product, so any other form would never match its stored plan
+		if slug := utils.GenerateSlug(p.Slug); slug != p.Slug {
+			return fmt.Errorf("plan %q: slug must be written %q", p.Slug, slug)
+		}
+		if product := utils.GenerateSlug(p.Product); p.Product != "" && product != p.Product {
+			return fmt.Errorf("plan %q: product must be written %q", p.Slug, product)
+		}
+		if seen[p.Slug] {
+			return fmt.Errorf("plan %q: duplicate slug", p.Slug)
+		}
+		seen[p.Slug] = true
+		price, err := p.Price.Money(p.Currency)
+		if err != nil {
+			return fmt.Errorf("plan %q: invalid price: %w", p.Slug, err)
+		}
+		if price.IsNegative() {
+			return fmt.Errorf("plan %q: price must not be negative", p.Slug)
+		}
+	}
+	return nil
+}
+
+// FromPlans builds a catalog describing the given plans
+func FromPlans(plans []*models.Plan) *Catalog {
+	cat := &Catalog{Plans: make([]PlanSpec, 0, len(plans))}
+	for _, plan := range plans {
+		cat.Plans = append(cat.Plans, specFromPlan(plan))
+	}
+	sort.Slice(cat.Plans, func(i, j int) bool { return cat.Plans[i].Slug < cat.Plans[j].Slug })
+	return cat
+}
+
+// specFromPlan converts a stored plan into its catalog representation
+func specFromPlan(plan *models.Plan) PlanSpec {
+	active := plan.IsActive
+	product := plan.Product
+	if product == models.DefaultProduct {
+		product = ""
+	}
+	return PlanSpec{
+		Slug:        plan.Slug,
+		Name:        plan.Name,
+		Description: plan.Description,
+		Product:     product,
+		Price:       PriceOf(plan.Price),
+		Currency:    plan.Currency,
+		Interval:    plan.Interval,
+		Features:    decodeFeatures(plan.Features),
+		TrialDays:   plan.TrialDays,
+		IsPopular:   plan.IsPopular,
+		Active:      &active,
+	}
+}
+
+// decodeFeatures reads the plan features column, which holds either a JSON
+// array of strings or an object with a "features" array (seed data format)
+func decodeFeatures(raw string) []string {
+	if raw == "" {
+		return []string{}
+	}
+
+	var list []string
+	if err := json.Unmarshal([]byte(raw), &list); err == nil {
+		return list
+	}
+
+	var wrapped struct {
+		Features []string `json:"features"`
+	}
+	if err := json.Unmarshal([]byte(raw), &wrapped); err == nil && wrapped.Features != nil {
+		return wrapped.Features
+	}
+
+	return []string{}
+}
@@ -0,0 +1,28 @@
+package catalog
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		slug    string
+		product string
+		wantErr bool
+	}{
+		{"canonical", "pro-annual", "", false},
+		{"canonical product", "pro-annual", "analytics", false},
+		{"upper case slug", "Pro-Annual", "", true},
+		{"spaces in slug", "pro annual", "", true},
+		{"non-canonical product", "pro-annual", "Analytics Suite", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := planSpec(tt.slug)
+			spec.Product = tt.product
+			err := (&Catalog{Plans: []PlanSpec{spec}}).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go-backend/internal/models"
+	"go-backend/internal/services"
+	"go-backend/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// ChangeType identifies what a sync will do to a plan
+type ChangeType string
+
+const (
+	ChangeCreate     ChangeType = "create"
+	ChangeUpdate     ChangeType = "update"
+	ChangeDeactivate ChangeType = "deactivate"
+)
+
+// FieldChange records the old and new value of a single plan attribute
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Change is a single reconciliation step
+type Change struct {
+	Type   ChangeType
+	Slug   string
+	Spec   *PlanSpec
+	Plan   *models.Plan
+	Fields []FieldChange
+}
+
+// Plan is the ordered set of changes needed to make the database match a catalog
+type Plan struct {
+	Changes []Change
+}
+
+// IsEmpty reports whether the database already matches the catalog
+func (p *Plan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// Diff computes the changes that reconcile current against desired. Plans
+// missing from the catalog, or marked inactive in it, are deactivated; they
+// are never deleted because subscriptions may still reference them, and
+// applying the deactivation fails while they have subscribers.
+func Diff(current []*models.Plan, desired *Catalog) *Plan {
+	bySlug := make(map[string]*models.Plan, len(current))
+	for _, plan := range current {
+		bySlug[plan.Slug] = plan
+	}
+
+	result := &Plan{}
+	inCatalog := make(map[string]bool, len(desired.Plans))
+
+	for i := range desired.Plans {
+		spec := &desired.Plans[i]
+		inCatalog[spec.Slug] = true
+
+		existing, ok := bySlug[spec.Slug]
+		if !ok {
+			result.Changes = append(result.Changes, Change{Type: ChangeCreate, Slug: spec.Slug, Spec: spec})
+			continue
+		}
+
+		if fields := diffFields(existing, spec); len(fields) > 0 {
+			result.Changes = append(result.Changes, Change{
+				Type:   ChangeUpdate,
+				Slug:   spec.Slug,
+				Spec:   spec,
+				Plan:   existing,
+				Fields: fields,
+			})
+		}
+	}
+
+	for _, plan := range current {
+		if !inCatalog[plan.Slug] && plan.IsActive {
+			result.Changes = append(result.Changes, Change{Type: ChangeDeactivate, Slug: plan.Slug, Plan: plan})
+		}
+	}
+
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		if result.Changes[i].Type != result.Changes[j].Type {
+			return changeOrder(result.Changes[i].Type) < changeOrder(result.Changes[j].Type)
+		}
+		return result.Changes[i].Slug < result.Changes[j].Slug
+	})
+
+	return result
+}
+
+// changeOrder keeps creates first so replacements exist before old plans are switched off
+func changeOrder(t ChangeType) int {
+	switch t {
+	case ChangeCreate:
+		return 0
+	case ChangeUpdate:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// diffFields lists every attribute that differs between a stored plan and its spec
+func diffFields(plan *models.Plan, spec *PlanSpec) []FieldChange {
+	var fields []FieldChange
+	add := func(field, from, to string) {
+		if from != to {
+			fields = append(fields, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	current := specFromPlan(plan)
+	add("name", current.Name, spec.Name)
+	add("description", current.Description, spec.Description)
+	add("product", specProduct(&current), specProduct(spec))
+	add("price", string(current.Price), normalizedPrice(spec))
+	add("currency", current.Currency, spec.Currency)
+	add("interval", current.Interval, spec.Interval)
+	add("features", strings.Join(current.Features, ", "), strings.Join(spec.Features, ", "))
+	add("trial_days", fmt.Sprint(current.TrialDays), fmt.Sprint(spec.TrialDays))
+	add("is_popular", fmt.Sprint(current.IsPopular), fmt.Sprint(spec.IsPopular))
+	add("active", fmt.Sprint(current.IsActive()), fmt.Sprint(spec.IsActive()))
+	return fields
+}
+
+// Print writes a human-readable diff of the plan to w
+func (p *Plan) Print(w io.Writer) {
+	if p.IsEmpty() {
+		fmt.Fprintln(w, "Catalog is in sync, nothing to do.")
+		return
+	}
+
+	counts := map[ChangeType]int{}
+	for _, change := range p.Changes {
+		counts[change.Type]++
+		switch change.Type {
+		case ChangeCreate:
+			fmt.Fprintf(w, "+ create     %s (%s, %s %s %s)\n",
+				change.Slug, change.Spec.Name, normalizedPrice(change.Spec), change.Spec.Currency, change.Spec.Interval)
+		case ChangeUpdate:
+			fmt.Fprintf(w, "~ update     %s\n", change.Slug)
+			for _, field := range change.Fields {
+				fmt.Fprintf(w, "    %s: %q -> %q\n", field.Field, field.From, field.To)
+			}
+		case ChangeDeactivate:
+			fmt.Fprintf(w, "- deactivate %s (not in catalog)\n", change.Slug)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d to create, %d to update, %d to deactivate\n",
+		counts[ChangeCreate], counts[ChangeUpdate], counts[ChangeDeactivate])
+}
+
+// Validate runs every create and update through PlanService validation so a
+// bad catalog is rejected before anything is written
+func (p *Plan) Validate(planService *services.PlanService) error {
+	for _, change := range p.Changes {
+		var err error
+		switch change.Type {
+		case ChangeCreate:
+			err = planService.ValidateCreatePlanRequest(createRequest(change.Spec))
+		case ChangeUpdate:
+			err = planService.ValidateUpdatePlanRequest(updateRequest(change.Spec))
+		}
+		if err != nil {
+			return fmt.Errorf("plan %q: %w", change.Slug, err)
+		}
+	}
+	return nil
+}
+
+// Apply executes the changes through a PlanService in a single transaction,
+// so a change that fails leaves every plan as it was. newPlanService builds
+// the service on the transaction.
+func (p *Plan) Apply(db *gorm.DB, newPlanService func(tx *gorm.DB) *services.PlanService) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return p.apply(newPlanService(tx))
+	})
+}
+
+// apply validates and executes the changes through planService
+func (p *Plan) apply(planService *services.PlanService) error {
+	if err := p.Validate(planService); err != nil {
+		return err
+	}
+
+	for _, change := range p.Changes {
+		switch change.Type {
+		case ChangeCreate:
+			plan, err := planService.CreatePlan(createRequest(change.Spec))
+			if err != nil {
+				return fmt.Errorf("failed to create plan %q: %w", change.Slug, err)
+			}
+			if !change.Spec.IsActive() {
+				if err := planService.DeactivatePlan(plan.ID.String()); err != nil {
+					return fmt.Errorf("failed to deactivate plan %q: %w", change.Slug, err)
+				}
+			}
+		case ChangeUpdate:
+			// Switching a plan off goes through the same guard as removing it
+			req := updateRequest(change.Spec)
+			deactivate := change.Plan.IsActive && !change.Spec.IsActive()
+			if deactivate {
+				req.IsActive = nil
+			}
+			if _, err := planService.UpdatePlan(change.Plan.ID.String(), req); err != nil {
+				return fmt.Errorf("failed to update plan %q: %w", change.Slug, err)
+			}
+			if deactivate {
+				if err := deactivatePlan(planService, change); err != nil {
+					return err
+				}
+			}
+		case ChangeDeactivate:
+			if err := deactivatePlan(planService, change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deactivatePlan stops selling a stored plan. A plan that still has
+// subscribers is refused; it is retired through a plan migration instead,
+// which moves them to another plan first.
+func deactivatePlan(planService *services.PlanService, change Change) error {
+	if err := planService.DeletePlan(change.Plan.ID.String()); err != nil {
+		if err.Error() == "plan has active subscriptions" {
+			return fmt.Errorf("plan %q has subscribers; retire it to migrate them instead", change.Slug)
+		}
+		return fmt.Errorf("failed to deactivate plan %q: %w", change.Slug, err)
+	}
+	return nil
+}
+
+// normalizedPrice renders the spec price at its currency's precision so that
+// "10" and "10.00" compare equal
+func normalizedPrice(spec *PlanSpec) string {
+	price, err := spec.Price.Money(spec.Currency)
+	if err != nil {
+		return string(spec.Price)
+	}
+	return price.Decimal()
+}
+
+// specProduct returns the product line of a spec, which defaults like plans do
+func specProduct(spec *PlanSpec) string {
+	if spec.Product == "" {
+		return models.DefaultProduct
+	}
+	return spec.Product
+}
+
+// specPrice parses a spec price; catalogs are validated on load, so parsing
+// cannot fail here
+func specPrice(spec *PlanSpec) money.Money {
+	price, _ := spec.Price.Money(spec.Currency)
+	return price
+}
+
+// createRequest maps a spec onto the PlanService creation request
+func createRequest(spec *PlanSpec) *services.CreatePlanRequest {
+	return &services.CreatePlanRequest{
+		Name:        spec.Name,
+		Slug:        spec.Slug,
+		Description: spec.Description,
+		Product:     spec.Product,
+		Price:       specPrice(spec),
+		Currency:    spec.Currency,
+		Interval:    spec.Interval,
+		Features:    spec.Features,
+		TrialDays:   spec.TrialDays,
+		IsPopular:   spec.IsPopular,
+	}
+}
+
+// updateRequest maps a spec onto a full PlanService update request
+func updateRequest(spec *PlanSpec) *services.UpdatePlanRequest {
+	active := spec.IsActive()
+	features := spec.Features
+	price := specPrice(spec)
+	product := specProduct(spec)
+	return &services.UpdatePlanRequest{
+		Name:        &spec.Name,
+		Slug:        &spec.Slug,
+		Description: &spec.Description,
+		Product:     &product,
+		Price:       &price,
+		Currency:    &spec.Currency,
+		Interval:    &spec.Interval,
+		Features:    &features,
+		TrialDays:   &spec.TrialDays,
+		IsActive:    &active,
+		IsPopular:   &spec.IsPopular,
+	}
+}
@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"testing"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+)
+
+func storedPlan(slug string, active bool) *models.Plan {
+	return &models.Plan{
+		Slug:        slug,
+		Name:        "Basic",
+		Description: "Everything a small team needs",
+		Product:     models.DefaultProduct,
+		Price:       money.New(1000, "USD"),
+		Currency:    "USD",
+		Interval:    "monthly",
+		Features:    `["Reports"]`,
+		IsActive:    active,
+	}
+}
+
+func planSpec(slug string) PlanSpec {
+	return PlanSpec{
+		Slug:        slug,
+		Name:        "Basic",
+		Description: "Everything a small team needs",
+		Price:       "10",
+		Currency:    "USD",
+		Interval:    "monthly",
+		Features:    []string{"Reports"},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	inactive := false
+	repriced := planSpec("basic")
+	repriced.Price = "12.50"
+	switchedOff := planSpec("basic")
+	switchedOff.Active = &inactive
+
+	tests := []struct {
+		name    string
+		current []*models.Plan
+		desired []PlanSpec
+		want    []ChangeType
+		fields  []string
+	}{
+		{"create", nil, []PlanSpec{planSpec("basic")}, []ChangeType{ChangeCreate}, nil},
+		{"update", []*models.Plan{storedPlan("basic", true)}, []PlanSpec{repriced}, []ChangeType{ChangeUpdate}, []string{"price"}},
+		{"switch off", []*models.Plan{storedPlan("basic", true)}, []PlanSpec{switchedOff}, []ChangeType{ChangeUpdate}, []string{"active"}},
+		{"deactivate", []*models.Plan{storedPlan("basic", true)}, nil, []ChangeType{ChangeDeactivate}, nil},
+		{"already inactive", []*models.Plan{storedPlan("basic", false)}, nil, nil, nil},
+		// "10" and "10.00" are the same price
+		{"no-op", []*models.Plan{storedPlan("basic", true)}, []PlanSpec{planSpec("basic")}, nil, nil},
+		{
+			"creates before deactivations",
+			[]*models.Plan{storedPlan("basic", true)},
+			[]PlanSpec{planSpec("starter")},
+			[]ChangeType{ChangeCreate, ChangeDeactivate},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Diff(tt.current, &Catalog{Plans: tt.desired})
+			if len(plan.Changes) != len(tt.want) {
+				t.Fatalf("got %d changes, want %d: %+v", len(plan.Changes), len(tt.want), plan.Changes)
+			}
+			for i, change := range plan.Changes {
+				if change.Type != tt.want[i] {
+					t.Errorf("change %d is %s, want %s", i, change.Type, tt.want[i])
+				}
+			}
+			if tt.fields == nil {
+				return
+			}
+			fields := plan.Changes[0].Fields
+			if len(fields) != len(tt.fields) {
+				t.Fatalf("got fields %+v, want %v", fields, tt.fields)
+			}
+			for i, field := range fields {
+				if field.Field != tt.fields[i] {
+					t.Errorf("field %d is %s, want %s", i, field.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
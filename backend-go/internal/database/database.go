@@ -69,10 +69,31 @@ func AutoMigrate(db *gorm.DB) error {
 	// Run auto-migration for all models
 	err := db.AutoMigrate(
 		&models.User{},
+		&models.TestClock{},
+		&models.InvoiceSequence{},
 		&models.Organization{},
 		&models.Plan{},
 		&models.Subscription{},
 		&models.Invoice{},
+		&models.InvoiceItem{},
+		&models.PlanMigration{},
+		&models.PlanMigrationItem{},
+		&models.ExchangeRate{},
+		&models.SubscriptionStatusChange{},
+		&models.SubscriptionSchedule{},
+		&models.SubscriptionSchedulePhase{},
+		&models.BillingRun{},
+		&models.BillingRunItem{},
+		&models.Meter{},
+		&models.UsageEvent{},
+		&models.MeteredPrice{},
+		&models.UsageSummary{},
+		&models.UsageAlert{},
+		&models.UsageAlertTrigger{},
+		&models.DunningCase{},
+		&models.PaymentAttempt{},
+		&models.RetentionOffer{},
+		&models.RetentionOfferPresentation{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to run auto-migration: %w", err)
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyticsHandler handles admin analytics and export endpoints
+type AnalyticsHandler struct {
+	reportingService *services.ReportingService
+	clock            clock.Clock
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(reportingService *services.ReportingService, clk clock.Clock) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		reportingService: reportingService,
+		clock:            clk,
+	}
+}
+
+// RegisterRoutes registers analytics routes
+func (h *AnalyticsHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	admin := router.Group("/admin", authMiddleware, middleware.AdminMiddleware())
+	{
+		admin.GET("/analytics", h.GetAnalytics)
+		admin.GET("/analytics/cancellations", h.GetCancellationAnalytics)
+		admin.GET("/exports/invoices", h.ExportInvoices)
+	}
+}
+
+// GetAnalytics returns revenue totals in the reporting currency
+// @Summary Get analytics
+// @Description Invoiced, paid and outstanding revenue converted into the reporting currency at each invoice's issue-date rate (admin only)
+// @Tags analytics
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "First issue date (YYYY-MM-DD); defaults to the start of the month"
+// @Param to query string false "Last issue date (YYYY-MM-DD); defaults to today"
+// @Success 200 {object} utils.APIResponse{data=services.RevenueReport}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/analytics [get]
+func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
+	from, to, ok := h.reportRange(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.reportingService.GetRevenueReport(from, to)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to build analytics", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Analytics retrieved successfully", report)
+}
+
+// GetCancellationAnalytics returns cancellation reasons and retention offer acceptance rates
+// @Summary Get cancellation analytics
+// @Description Subscriptions canceled in the range broken down by cancellation reason, and how often each retention offer shown in the range was accepted (admin only)
+// @Tags analytics
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "First day (YYYY-MM-DD); defaults to the start of the month"
+// @Param to query string false "Last day (YYYY-MM-DD); defaults to today"
+// @Success 200 {object} utils.APIResponse{data=services.CancellationReport}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/analytics/cancellations [get]
+func (h *AnalyticsHandler) GetCancellationAnalytics(c *gin.Context) {
+	from, to, ok := h.reportRange(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.reportingService.GetCancellationReport(from, to)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to build cancellation analytics", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Cancellation analytics retrieved successfully", report)
+}
+
+// ExportInvoices streams invoices as CSV with converted totals
+// @Summary Export invoices
+// @Description CSV export of invoices with totals in the original and reporting currency and the exchange rate used (admin only)
+// @Tags analytics
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First issue date (YYYY-MM-DD); defaults to the start of the month"
+// @Param to query string false "Last issue date (YYYY-MM-DD); defaults to today"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Router /admin/exports/invoices [get]
+func (h *AnalyticsHandler) ExportInvoices(c *gin.Context) {
+	from, to, ok := h.reportRange(c)
+	if !ok {
+		return
+	}
+
+	filename := "invoices-" + from.Format("20060102") + "-" + to.Format("20060102") + ".csv"
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	if err := h.reportingService.ExportInvoicesCSV(c.Writer, from, to); err != nil {
+		// Headers are already sent; abort so the truncated body is not mistaken for success
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// reportRange parses the from/to query parameters, defaulting to the current
+// month so far. The end date is inclusive.
+func (h *AnalyticsHandler) reportRange(c *gin.Context) (time.Time, time.Time, bool) {
+	now := h.clock.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from format. Use YYYY-MM-DD", err)
+			return from, to, false
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to format. Use YYYY-MM-DD", err)
+			return from, to, false
+		}
+		to = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	if to.Before(from) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "to must not be before from", nil)
+		return from, to, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BillingHandler handles billing engine endpoints
+type BillingHandler struct {
+	billingEngine *services.BillingEngine
+}
+
+// NewBillingHandler creates a new billing handler
+func NewBillingHandler(billingEngine *services.BillingEngine) *BillingHandler {
+	return &BillingHandler{
+		billingEngine: billingEngine,
+	}
+}
+
+// RegisterRoutes registers billing engine routes
+func (h *BillingHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	billing := router.Group("/admin/billing", authMiddleware, middleware.AdminMiddleware())
+	{
+		billing.GET("/runs", h.GetRuns)
+		billing.POST("/runs", h.StartRun)
+		billing.GET("/runs/:id", h.GetRun)
+		billing.GET("/runs/:id/items", h.GetRunItems)
+	}
+}
+
+// GetRuns lists billing runs
+// @Summary List billing runs
+// @Description List billing-cycle engine runs, newest first (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.BillingRun}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/billing/runs [get]
+func (h *BillingHandler) GetRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	runs, total, err := h.billingEngine.ListRuns(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get billing runs", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Billing runs retrieved successfully", runs, pagination)
+}
+
+// StartRun starts a billing run
+// @Summary Start billing run
+// @Description Renew, cancel and expire every subscription whose period has ended, in the background (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} utils.APIResponse{data=models.BillingRun}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/billing/runs [post]
+func (h *BillingHandler) StartRun(c *gin.Context) {
+	run, err := h.billingEngine.StartRun()
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to start billing run", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Billing run started", run)
+}
+
+// GetRun gets a billing run
+// @Summary Get billing run
+// @Description Get a billing-cycle engine run with its outcome counts (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Billing run ID"
+// @Success 200 {object} utils.APIResponse{data=models.BillingRun}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/billing/runs/{id} [get]
+func (h *BillingHandler) GetRun(c *gin.Context) {
+	run, err := h.billingEngine.GetRun(c.Param("id"))
+	if err != nil {
+		h.runError(c, err, "Failed to get billing run")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Billing run retrieved successfully", run)
+}
+
+// GetRunItems gets the subscription periods processed by a billing run
+// @Summary Get billing run items
+// @Description Get the per-subscription outcomes of a billing run (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Billing run ID"
+// @Param outcome query string false "Filter by outcome"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.BillingRunItem}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/billing/runs/{id}/items [get]
+func (h *BillingHandler) GetRunItems(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	items, total, err := h.billingEngine.GetRunItems(c.Param("id"), c.Query("outcome"), page, limit)
+	if err != nil {
+		h.runError(c, err, "Failed to get billing run items")
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Billing run items retrieved successfully", items, pagination)
+}
+
+// runError maps billing run lookup errors to responses
+func (h *BillingHandler) runError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid billing run ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid billing run ID", err)
+	case "billing run not found":
+		utils.NotFoundResponse(c, "Billing run not found")
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
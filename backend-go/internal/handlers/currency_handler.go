@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-backend/pkg/currency"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CurrencyHandler handles currency endpoints
+type CurrencyHandler struct {
+	currencies *currency.Registry
+}
+
+// NewCurrencyHandler creates a new currency handler
+func NewCurrencyHandler(currencies *currency.Registry) *CurrencyHandler {
+	return &CurrencyHandler{
+		currencies: currencies,
+	}
+}
+
+// RegisterRoutes registers currency routes
+func (h *CurrencyHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/currencies", h.GetCurrencies)
+}
+
+// GetCurrencies lists the currencies plans can be priced in
+// @Summary Get currencies
+// @Description List the ISO 4217 currencies enabled for sale, with their minor units and symbol
+// @Tags currencies
+// @Produce json
+// @Success 200 {object} utils.APIResponse{data=[]currency.Currency}
+// @Router /currencies [get]
+func (h *CurrencyHandler) GetCurrencies(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Currencies retrieved successfully", h.currencies.Enabled())
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DunningHandler handles dunning endpoints
+type DunningHandler struct {
+	dunningService *services.DunningService
+}
+
+// NewDunningHandler creates a new dunning handler
+func NewDunningHandler(dunningService *services.DunningService) *DunningHandler {
+	return &DunningHandler{
+		dunningService: dunningService,
+	}
+}
+
+// RegisterRoutes registers dunning routes
+func (h *DunningHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	dunning := router.Group("/dunning/organization/:org_id", authMiddleware, middleware.OrganizationMiddleware())
+	{
+		dunning.GET("", h.GetDunningCases)
+	}
+}
+
+// GetDunningCases lists an organization's dunning cases
+// @Summary List dunning cases
+// @Description List the invoices of an organization whose payment failed, newest first, with every payment attempt, when the next retry is due and the final action taken once retries ran out
+// @Tags dunning
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param status query string false "Only cases in this status" Enums(active, recovered, exhausted, closed)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.DunningCase}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /dunning/organization/{org_id} [get]
+func (h *DunningHandler) GetDunningCases(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	cases, total, err := h.dunningService.GetDunningCases(c.Param("org_id"), c.Query("status"), page, limit)
+	if err != nil {
+		switch err.Error() {
+		case "invalid organization ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+		case "invalid dunning status":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid dunning status", err)
+		case "organization not found":
+			utils.NotFoundResponse(c, "Organization not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get dunning cases", err)
+		}
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Dunning cases retrieved successfully", cases, pagination)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/internal/services"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/money"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExchangeRateHandler handles exchange rate endpoints
+type ExchangeRateHandler struct {
+	exchangeRateService *services.ExchangeRateService
+	clock               clock.Clock
+}
+
+// NewExchangeRateHandler creates a new exchange rate handler
+func NewExchangeRateHandler(exchangeRateService *services.ExchangeRateService, clk clock.Clock) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		exchangeRateService: exchangeRateService,
+		clock:               clk,
+	}
+}
+
+// RegisterRoutes registers exchange rate routes
+func (h *ExchangeRateHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	rates := router.Group("/admin/exchange-rates", authMiddleware, middleware.AdminMiddleware())
+	{
+		rates.GET("", h.GetExchangeRates)
+		rates.POST("", h.SetExchangeRates)
+		rates.POST("/import", h.ImportExchangeRates)
+		rates.GET("/convert", h.ConvertAmount)
+	}
+}
+
+// GetExchangeRates lists stored exchange rates
+// @Summary Get exchange rates
+// @Description List daily exchange rates, optionally filtered by pair and date range (admin only)
+// @Tags exchange-rates
+// @Produce json
+// @Security BearerAuth
+// @Param base_currency query string false "Base currency"
+// @Param quote_currency query string false "Quote currency"
+// @Param from query string false "First date (YYYY-MM-DD)"
+// @Param to query string false "Last date (YYYY-MM-DD)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.APIResponse{data=[]models.ExchangeRate}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/exchange-rates [get]
+func (h *ExchangeRateHandler) GetExchangeRates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	filter := repository.ExchangeRateFilter{
+		BaseCurrency:  c.Query("base_currency"),
+		QuoteCurrency: c.Query("quote_currency"),
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+param+" format. Use YYYY-MM-DD", err)
+				return
+			}
+			*target = &date
+		}
+	}
+
+	rates, total, err := h.exchangeRateService.GetRates(filter, page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get exchange rates", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Exchange rates retrieved successfully", rates, pagination)
+}
+
+// SetExchangeRates stores daily rates
+// @Summary Set exchange rates
+// @Description Store daily exchange rates, replacing existing rates for the same pair and date (admin only)
+// @Tags exchange-rates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.SetExchangeRatesRequest true "Rates"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/exchange-rates [post]
+func (h *ExchangeRateHandler) SetExchangeRates(c *gin.Context) {
+	var req services.SetExchangeRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	count, err := h.exchangeRateService.SetRates(req.Rates, models.ExchangeRateSourceAPI)
+	if err != nil {
+		exchangeRateError(c, err, "Failed to store exchange rates")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Exchange rates stored successfully", gin.H{"stored": count})
+}
+
+// ImportExchangeRates loads rates from an uploaded CSV or JSON file
+// @Summary Import exchange rates
+// @Description Import daily rates from a CSV (date,base_currency,quote_currency,rate) or JSON file, sent as multipart field "file" or as the raw request body (admin only)
+// @Tags exchange-rates
+// @Accept multipart/form-data
+// @Accept text/csv
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "File format (csv or json); inferred from the file name or content type when omitted"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/exchange-rates/import [post]
+func (h *ExchangeRateHandler) ImportExchangeRates(c *gin.Context) {
+	format := strings.ToLower(c.Query("format"))
+	var body io.Reader = c.Request.Body
+
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+	if format == "" {
+		switch {
+		case strings.Contains(c.ContentType(), "json"):
+			format = "json"
+		case strings.Contains(c.ContentType(), "csv"):
+			format = "csv"
+		}
+	}
+
+	count, err := h.exchangeRateService.ImportRates(body, format)
+	if err != nil {
+		exchangeRateError(c, err, "Failed to import exchange rates")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Exchange rates imported successfully", gin.H{"stored": count})
+}
+
+// ConvertAmount converts an amount using the rate in effect on a date
+// @Summary Convert amount
+// @Description Convert an amount into another currency at the rate in effect on a date (admin only)
+// @Tags exchange-rates
+// @Produce json
+// @Security BearerAuth
+// @Param amount query string true "Amount in major units, e.g. 19.99"
+// @Param currency query string true "Currency of the amount"
+// @Param to query string false "Target currency; defaults to the reporting currency"
+// @Param date query string false "Date of the rate (YYYY-MM-DD); defaults to today"
+// @Success 200 {object} utils.APIResponse{data=services.Conversion}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/exchange-rates/convert [get]
+func (h *ExchangeRateHandler) ConvertAmount(c *gin.Context) {
+	amount, err := money.Parse(c.Query("amount"), c.Query("currency"))
+	if err != nil || amount.Currency() == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "amount and currency are required", err)
+		return
+	}
+
+	to := c.DefaultQuery("to", h.exchangeRateService.ReportingCurrency())
+	date := h.clock.Now()
+	if value := c.Query("date"); value != "" {
+		if date, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD", err)
+			return
+		}
+	}
+
+	conversion, err := h.exchangeRateService.Convert(amount, to, date)
+	if err != nil {
+		exchangeRateError(c, err, "Failed to convert amount")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Amount converted successfully", conversion)
+}
+
+// exchangeRateError maps exchange rate service errors onto HTTP responses
+func exchangeRateError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidExchangeRate):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid exchange rates", err)
+	case errors.Is(err, services.ErrExchangeRateNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
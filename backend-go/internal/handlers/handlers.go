@@ -6,18 +6,38 @@ import (
 
 // Handlers holds all handler instances
 type Handlers struct {
-	Auth         *AuthHandler
-	Plan         *PlanHandler
-	Subscription *SubscriptionHandler
-	Invoice      *InvoiceHandler
+	Auth            *AuthHandler
+	Plan            *PlanHandler
+	Subscription    *SubscriptionHandler
+	Invoice         *InvoiceHandler
+	Currency        *CurrencyHandler
+	ExchangeRate    *ExchangeRateHandler
+	Analytics       *AnalyticsHandler
+	Billing         *BillingHandler
+	TestClock       *TestClockHandler
+	Metering        *MeteringHandler
+	UsageAlert      *UsageAlertHandler
+	Dunning         *DunningHandler
+	Retention       *RetentionHandler
+	InvoiceSequence *InvoiceSequenceHandler
 }
 
 // NewHandlers creates and initializes all handlers
 func NewHandlers(services *services.Services) *Handlers {
 	return &Handlers{
-		Auth:         NewAuthHandler(services.Auth),
-		Plan:         NewPlanHandler(services.Plan),
-		Subscription: NewSubscriptionHandler(services.Subscription),
-		Invoice:      NewInvoiceHandler(services.Invoice),
+		Auth:            NewAuthHandler(services.Auth),
+		Plan:            NewPlanHandler(services.Plan, services.PlanRetirement),
+		Subscription:    NewSubscriptionHandler(services.Subscription),
+		Invoice:         NewInvoiceHandler(services.Invoice, services.InvoicePDF),
+		Currency:        NewCurrencyHandler(services.Currencies),
+		ExchangeRate:    NewExchangeRateHandler(services.ExchangeRate, services.Clock),
+		Analytics:       NewAnalyticsHandler(services.Reporting, services.Clock),
+		Billing:         NewBillingHandler(services.BillingEngine),
+		TestClock:       NewTestClockHandler(services.TestClock),
+		Metering:        NewMeteringHandler(services.Metering),
+		UsageAlert:      NewUsageAlertHandler(services.UsageAlert),
+		Dunning:         NewDunningHandler(services.Dunning),
+		Retention:       NewRetentionHandler(services.Retention),
+		InvoiceSequence: NewInvoiceSequenceHandler(services.InvoiceSequence),
 	}
-}
\ No newline at end of file
+}
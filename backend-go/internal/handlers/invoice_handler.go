@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,13 +17,15 @@ import (
 
 // InvoiceHandler handles invoice endpoints
 type InvoiceHandler struct {
-	invoiceService *services.InvoiceService
+	invoiceService    *services.InvoiceService
+	invoicePDFService *services.InvoicePDFService
 }
 
 // NewInvoiceHandler creates a new invoice handler
-func NewInvoiceHandler(invoiceService *services.InvoiceService) *InvoiceHandler {
+func NewInvoiceHandler(invoiceService *services.InvoiceService, invoicePDFService *services.InvoicePDFService) *InvoiceHandler {
 	return &InvoiceHandler{
-		invoiceService: invoiceService,
+		invoiceService:    invoiceService,
+		invoicePDFService: invoicePDFService,
 	}
 }
 
@@ -30,9 +35,21 @@ func (h *InvoiceHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware
 	{
 		invoices.GET("", h.GetInvoices)
 		invoices.GET("/:id", h.GetInvoice)
+		invoices.GET("/:id/pdf", h.GetInvoicePDF)
 		invoices.GET("/organization/:org_id", middleware.OrganizationMiddleware(), h.GetInvoicesByOrganization)
 		invoices.GET("/overdue", h.GetOverdueInvoices)
 		invoices.GET("/date-range", h.GetInvoicesByDateRange)
+
+		// Lifecycle (admin only)
+		admin := invoices.Group("", middleware.AdminMiddleware())
+		{
+			admin.PUT("/:id", h.UpdateInvoice)
+			admin.POST("/:id/finalize", h.FinalizeInvoice)
+			admin.POST("/:id/send", h.SendInvoice)
+			admin.POST("/:id/pay", h.PayInvoice)
+			admin.POST("/:id/void", h.VoidInvoice)
+			admin.POST("/:id/mark-uncollectible", h.MarkInvoiceUncollectible)
+		}
 	}
 }
 
@@ -85,7 +102,7 @@ func (h *InvoiceHandler) GetInvoices(c *gin.Context) {
 
 // GetInvoice gets an invoice by ID
 // @Summary Get invoice by ID
-// @Description Get a specific invoice by ID
+// @Description Get a specific invoice by ID with its line items in invoice order
 // @Tags invoices
 // @Accept json
 // @Produce json
@@ -117,6 +134,38 @@ func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Invoice retrieved successfully", invoice)
 }
 
+// GetInvoicePDF downloads an invoice as a PDF
+// @Summary Download invoice PDF
+// @Description Download an invoice as a PDF with the seller details, the customer's billing address and tax ID, line items, tax breakdown, totals and payment status. Drafts are marked as such.
+// @Tags invoices
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/pdf [get]
+func (h *InvoiceHandler) GetInvoicePDF(c *gin.Context) {
+	_, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "Authentication required")
+		return
+	}
+
+	invoice, pdf, err := h.invoicePDFService.GetInvoicePDF(c.Param("id"))
+	if err != nil {
+		invoiceError(c, err, "Failed to render invoice PDF")
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": invoice.InvoiceNumber + ".pdf",
+	}))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
 // GetInvoicesByOrganization gets invoices for an organization
 // @Summary Get organization invoices
 // @Description Get all invoices for an organization
@@ -265,4 +314,198 @@ func (h *InvoiceHandler) GetInvoicesByDateRange(c *gin.Context) {
 	}
 
 	utils.PaginatedSuccessResponse(c, "Invoices retrieved successfully", invoices, pagination)
-}
\ No newline at end of file
+}
+
+// UpdateInvoice edits a draft invoice
+// @Summary Update draft invoice
+// @Description Change the due date, notes or items of a draft invoice. Given items replace all of its items, and the totals are computed from them. Finalized invoices cannot be edited (admin only).
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Param request body services.UpdateInvoiceRequest true "Invoice changes"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id} [put]
+func (h *InvoiceHandler) UpdateInvoice(c *gin.Context) {
+	var req services.UpdateInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	invoice, err := h.invoiceService.UpdateInvoice(c.Param("id"), &req)
+	if err != nil {
+		invoiceError(c, err, "Failed to update invoice")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice updated successfully", invoice)
+}
+
+// FinalizeInvoice finalizes a draft invoice
+// @Summary Finalize invoice
+// @Description Lock a draft invoice and assign its invoice number. It can no longer be edited (admin only).
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/finalize [post]
+func (h *InvoiceHandler) FinalizeInvoice(c *gin.Context) {
+	invoice, err := h.invoiceService.FinalizeInvoice(c.Param("id"))
+	if err != nil {
+		invoiceError(c, err, "Failed to finalize invoice")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice finalized successfully", invoice)
+}
+
+// SendInvoice sends a finalized invoice
+// @Summary Send invoice
+// @Description Email a finalized invoice to its organization (admin only)
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/send [post]
+func (h *InvoiceHandler) SendInvoice(c *gin.Context) {
+	invoice, err := h.invoiceService.SendInvoice(c.Param("id"))
+	if err != nil {
+		invoiceError(c, err, "Failed to send invoice")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice sent successfully", invoice)
+}
+
+// PayInvoice records a manual payment
+// @Summary Record invoice payment
+// @Description Mark a finalized invoice paid with a payment received outside the payment gateway, optionally at paid_at. Any dunning of the invoice ends (admin only).
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Param request body services.PayInvoiceRequest false "Payment"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/pay [post]
+func (h *InvoiceHandler) PayInvoice(c *gin.Context) {
+	var req services.PayInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	invoice, err := h.invoiceService.PayInvoice(c.Param("id"), &req)
+	if err != nil {
+		invoiceError(c, err, "Failed to record invoice payment")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice payment recorded successfully", invoice)
+}
+
+// VoidInvoice voids an invoice
+// @Summary Void invoice
+// @Description Void an unpaid invoice issued in error; it is no longer owed (admin only)
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/void [post]
+func (h *InvoiceHandler) VoidInvoice(c *gin.Context) {
+	invoice, err := h.invoiceService.VoidInvoice(c.Param("id"))
+	if err != nil {
+		invoiceError(c, err, "Failed to void invoice")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice voided successfully", invoice)
+}
+
+// MarkInvoiceUncollectible writes off an invoice
+// @Summary Mark invoice uncollectible
+// @Description Write off a finalized invoice that is not expected to be paid. It can still be paid later (admin only).
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice ID"
+// @Success 200 {object} utils.APIResponse{data=models.Invoice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /invoices/{id}/mark-uncollectible [post]
+func (h *InvoiceHandler) MarkInvoiceUncollectible(c *gin.Context) {
+	invoice, err := h.invoiceService.MarkInvoiceUncollectible(c.Param("id"))
+	if err != nil {
+		invoiceError(c, err, "Failed to mark invoice uncollectible")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice marked uncollectible successfully", invoice)
+}
+
+// invoiceError maps invoice lifecycle errors to responses
+func invoiceError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid invoice ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID", err)
+	case "invoice not found":
+		utils.NotFoundResponse(c, "Invoice not found")
+	case "invoice is not a draft":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Only draft invoices can be edited", err)
+	case "invalid invoice status transition":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invoice status does not allow this change", err)
+	case "invoice status changed concurrently":
+		utils.ErrorResponse(c, http.StatusConflict, "Invoice status changed concurrently", err)
+	case "invoice has no items", "item currency does not match invoice currency",
+		"item discount and tax must not be negative", "invalid item period":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice", err)
+	case "organization has no email address":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Organization has no email address", err)
+	case "payment date is in the future":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Payment date is in the future", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvoiceSequenceHandler handles invoice numbering sequence endpoints
+type InvoiceSequenceHandler struct {
+	invoiceSequenceService *services.InvoiceSequenceService
+}
+
+// NewInvoiceSequenceHandler creates a new invoice sequence handler
+func NewInvoiceSequenceHandler(invoiceSequenceService *services.InvoiceSequenceService) *InvoiceSequenceHandler {
+	return &InvoiceSequenceHandler{
+		invoiceSequenceService: invoiceSequenceService,
+	}
+}
+
+// RegisterRoutes registers invoice sequence routes
+func (h *InvoiceSequenceHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	sequences := router.Group("/admin/invoice-sequences", authMiddleware, middleware.AdminMiddleware())
+	{
+		sequences.GET("", h.GetSequences)
+		sequences.POST("", h.CreateSequence)
+		sequences.GET("/:id", h.GetSequence)
+		sequences.PUT("/:id", h.UpdateSequence)
+		sequences.POST("/:id/default", h.SetDefaultSequence)
+		sequences.POST("/:id/organizations", h.AssignOrganization)
+		sequences.DELETE("/:id/organizations/:org_id", h.UnassignOrganization)
+	}
+}
+
+// GetSequences lists invoice sequences
+// @Summary List invoice sequences
+// @Description List invoice numbering sequences, default first (admin only)
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.InvoiceSequence}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences [get]
+func (h *InvoiceSequenceHandler) GetSequences(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	sequences, total, err := h.invoiceSequenceService.GetSequences(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get invoice sequences", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Invoice sequences retrieved successfully", sequences, pagination)
+}
+
+// CreateSequence creates an invoice sequence
+// @Summary Create invoice sequence
+// @Description Create an invoice numbering sequence. The template combines {prefix}, {yyyy} or {yy} and {number}, the counter zero-padded to padding digits; a sequence reset yearly needs the year in its template (admin only).
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateInvoiceSequenceRequest true "Invoice sequence data"
+// @Success 201 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences [post]
+func (h *InvoiceSequenceHandler) CreateSequence(c *gin.Context) {
+	var req services.CreateInvoiceSequenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	sequence, err := h.invoiceSequenceService.CreateSequence(&req)
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to create invoice sequence")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Invoice sequence created successfully", sequence)
+}
+
+// GetSequence gets an invoice sequence
+// @Summary Get invoice sequence
+// @Description Get an invoice numbering sequence with the organizations it numbers (admin only)
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice sequence ID"
+// @Success 200 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences/{id} [get]
+func (h *InvoiceSequenceHandler) GetSequence(c *gin.Context) {
+	sequence, err := h.invoiceSequenceService.GetSequence(c.Param("id"))
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to get invoice sequence")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice sequence retrieved successfully", sequence)
+}
+
+// UpdateSequence updates an invoice sequence
+// @Summary Update invoice sequence
+// @Description Rename an invoice numbering sequence. Its prefix, template, padding and yearly reset can only change until it numbers its first invoice (admin only).
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice sequence ID"
+// @Param request body services.UpdateInvoiceSequenceRequest true "Invoice sequence update data"
+// @Success 200 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences/{id} [put]
+func (h *InvoiceSequenceHandler) UpdateSequence(c *gin.Context) {
+	var req services.UpdateInvoiceSequenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	sequence, err := h.invoiceSequenceService.UpdateSequence(c.Param("id"), &req)
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to update invoice sequence")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invoice sequence updated successfully", sequence)
+}
+
+// SetDefaultSequence makes an invoice sequence the default
+// @Summary Set default invoice sequence
+// @Description Number the invoices of organizations without a sequence of their own from this sequence (admin only)
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice sequence ID"
+// @Success 200 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences/{id}/default [post]
+func (h *InvoiceSequenceHandler) SetDefaultSequence(c *gin.Context) {
+	sequence, err := h.invoiceSequenceService.SetDefaultSequence(c.Param("id"))
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to set default invoice sequence")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Default invoice sequence set successfully", sequence)
+}
+
+// AssignOrganization numbers an organization's invoices from an invoice sequence
+// @Summary Assign organization to invoice sequence
+// @Description Number an organization's invoices from this sequence from now on; invoices already finalized keep their numbers (admin only)
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice sequence ID"
+// @Param request body services.AssignInvoiceSequenceRequest true "Organization"
+// @Success 200 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences/{id}/organizations [post]
+func (h *InvoiceSequenceHandler) AssignOrganization(c *gin.Context) {
+	var req services.AssignInvoiceSequenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	sequence, err := h.invoiceSequenceService.AssignOrganization(c.Param("id"), &req)
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to assign organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization assigned successfully", sequence)
+}
+
+// UnassignOrganization returns an organization to the default invoice sequence
+// @Summary Unassign organization from invoice sequence
+// @Description Number an organization's invoices from the default sequence again (admin only)
+// @Tags invoice-sequences
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invoice sequence ID"
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} utils.APIResponse{data=models.InvoiceSequence}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/invoice-sequences/{id}/organizations/{org_id} [delete]
+func (h *InvoiceSequenceHandler) UnassignOrganization(c *gin.Context) {
+	sequence, err := h.invoiceSequenceService.UnassignOrganization(c.Param("id"), c.Param("org_id"))
+	if err != nil {
+		h.invoiceSequenceError(c, err, "Failed to unassign organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization unassigned successfully", sequence)
+}
+
+// invoiceSequenceError maps invoice sequence errors to responses
+func (h *InvoiceSequenceHandler) invoiceSequenceError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid invoice sequence ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice sequence ID", err)
+	case "invalid organization ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+	case "invoice sequence prefix is required",
+		"invoice number template must contain {prefix} and {number}",
+		"invoice number template must contain the year to reset yearly",
+		"invoice numbers must not start like a draft's provisional number":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice number format", err)
+	case "invoice sequence not found":
+		utils.NotFoundResponse(c, "Invoice sequence not found")
+	case "organization not found":
+		utils.NotFoundResponse(c, "Organization not found")
+	case "invoice sequence is already in use":
+		utils.ErrorResponse(c, http.StatusConflict, "Invoice sequence is already in use", err)
+	case "organization is not numbered by this invoice sequence":
+		utils.ErrorResponse(c, http.StatusConflict, "Organization is not numbered by this invoice sequence", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
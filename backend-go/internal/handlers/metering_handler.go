@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MeteringHandler handles meter, metered price and usage ingestion endpoints
+type MeteringHandler struct {
+	meteringService *services.MeteringService
+}
+
+// NewMeteringHandler creates a new metering handler
+func NewMeteringHandler(meteringService *services.MeteringService) *MeteringHandler {
+	return &MeteringHandler{
+		meteringService: meteringService,
+	}
+}
+
+// RegisterRoutes registers metering routes
+func (h *MeteringHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	usage := router.Group("/usage", authMiddleware)
+	{
+		usage.POST("/events", h.RecordUsage)
+		usage.POST("/events/batch", h.RecordUsageBatch)
+	}
+
+	meters := router.Group("/admin/meters", authMiddleware, middleware.AdminMiddleware())
+	{
+		meters.GET("", h.GetMeters)
+		meters.POST("", h.CreateMeter)
+		meters.GET("/:id", h.GetMeter)
+		meters.PUT("/:id", h.UpdateMeter)
+		meters.GET("/:id/prices", h.GetMeteredPrices)
+		meters.POST("/:id/prices", h.CreateMeteredPrice)
+	}
+
+	prices := router.Group("/admin/metered-prices", authMiddleware, middleware.AdminMiddleware())
+	{
+		prices.DELETE("/:id", h.DeleteMeteredPrice)
+	}
+}
+
+// RecordUsage ingests a usage event
+// @Summary Record usage event
+// @Description Record a usage event for an organization. The event counts towards the billing period its timestamp falls in, even when that period has already been billed. Sending an event ID again is acknowledged with 200 and duplicate set, and the event is not counted twice.
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.RecordUsageRequest true "Usage event"
+// @Success 201 {object} utils.APIResponse{data=services.RecordUsageResult}
+// @Success 200 {object} utils.APIResponse{data=services.RecordUsageResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/events [post]
+func (h *MeteringHandler) RecordUsage(c *gin.Context) {
+	var req services.RecordUsageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	// Validate user has access to the organization
+	userOrgID, exists := middleware.GetOrganizationID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "Authentication required")
+		return
+	}
+
+	userRole, _ := middleware.GetUserRole(c)
+	if userRole != "admin" && userOrgID != req.OrganizationID {
+		utils.ForbiddenResponse(c, "Access denied for this organization")
+		return
+	}
+
+	result, err := h.meteringService.RecordUsage(&req)
+	if err != nil {
+		h.meteringError(c, err, "Failed to record usage event")
+		return
+	}
+
+	if result.Duplicate {
+		utils.SuccessResponse(c, http.StatusOK, "Usage event already recorded", result)
+		return
+	}
+	utils.SuccessResponse(c, http.StatusCreated, "Usage event recorded successfully", result)
+}
+
+// maxUsageLineSize is the longest line accepted in an NDJSON usage batch
+const maxUsageLineSize = 1 << 20
+
+// RecordUsageBatch ingests many usage events in one request
+// @Summary Record usage events in bulk
+// @Description Queue usage events for writing. The body is either a JSON array of events or, with Content-Type application/x-ndjson, one event per line, which is read as it streams in. Invalid events are rejected individually and the first of them reported; the others are accepted and written within moments. Event IDs already recorded are skipped, so a request can be retried as a whole. When ingestion is saturated the response is 429 with a Retry-After header, and the events accepted before that are reported; retry the request after the given number of seconds. Members may omit organization_id; it defaults to their organization.
+// @Tags metering
+// @Accept json
+// @Accept x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param request body []services.RecordUsageRequest true "Usage events"
+// @Success 202 {object} utils.APIResponse{data=services.BatchUsageResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 413 {object} utils.APIResponse{data=services.BatchUsageResult}
+// @Failure 429 {object} utils.APIResponse{data=services.BatchUsageResult}
+// @Failure 500 {object} utils.APIResponse
+// @Failure 503 {object} utils.APIResponse{data=services.BatchUsageResult}
+// @Router /usage/events/batch [post]
+func (h *MeteringHandler) RecordUsageBatch(c *gin.Context) {
+	userOrgID, exists := middleware.GetOrganizationID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "Authentication required")
+		return
+	}
+
+	// Members may only report usage for their own organization
+	restrictTo := userOrgID
+	if userRole, _ := middleware.GetUserRole(c); userRole == "admin" {
+		restrictTo = ""
+	}
+
+	batch, err := h.meteringService.NewUsageBatch(restrictTo)
+	if err != nil {
+		h.meteringError(c, err, "Failed to record usage events")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType == "application/x-ndjson" {
+		err = readUsageLines(c.Request.Body, batch)
+	} else {
+		err = readUsageArray(c.Request.Body, batch)
+	}
+	if err == nil {
+		err = batch.Flush()
+	}
+
+	if err != nil {
+		switch err.Error() {
+		case "usage ingestion is busy":
+			retryAfter := int(math.Ceil(h.meteringService.RetryAfter().Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			usageBatchError(c, http.StatusTooManyRequests, "Usage ingestion is busy, retry later", err, batch)
+		case "usage ingestion is shutting down":
+			usageBatchError(c, http.StatusServiceUnavailable, "Usage ingestion is shutting down, retry later", err, batch)
+		case "usage batch has too many events":
+			usageBatchError(c, http.StatusRequestEntityTooLarge, "Usage batch has too many events", err, batch)
+		case "invalid usage batch":
+			usageBatchError(c, http.StatusBadRequest, "Invalid request data", err, batch)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to record usage events", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Usage events accepted", batch.Result)
+}
+
+// readUsageArray adds the events of a JSON array to a batch, one at a time
+func readUsageArray(body io.Reader, batch *services.UsageBatch) error {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errors.New("invalid usage batch")
+	}
+
+	for index := 0; decoder.More(); index++ {
+		var req services.RecordUsageRequest
+		if err := decoder.Decode(&req); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return errors.New("invalid usage batch")
+			}
+			// The decoder has moved past the event, so only it is rejected
+			batch.Reject(index, req.EventID, err)
+			continue
+		}
+		if err := batch.Add(index, &req); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return errors.New("invalid usage batch")
+	}
+	return nil
+}
+
+// readUsageLines adds the events of an NDJSON body to a batch as they are
+// read; blank lines are skipped and lines that are not an event are rejected
+func readUsageLines(body io.Reader, batch *services.UsageBatch) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxUsageLineSize)
+
+	index := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req services.RecordUsageRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			batch.Reject(index, req.EventID, err)
+		} else if err := batch.Add(index, &req); err != nil {
+			return err
+		}
+		index++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.New("invalid usage batch")
+	}
+	return nil
+}
+
+// usageBatchError responds with an error and what the batch accepted before
+// it, so callers know which events to send again
+func usageBatchError(c *gin.Context, status int, message string, err error, batch *services.UsageBatch) {
+	c.JSON(status, utils.APIResponse{
+		Success: false,
+		Message: message,
+		Data:    batch.Result,
+		Error:   err.Error(),
+	})
+}
+
+// GetMeters lists meters
+// @Summary List meters
+// @Description List meters by name (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.Meter}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters [get]
+func (h *MeteringHandler) GetMeters(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	meters, total, err := h.meteringService.ListMeters(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get meters", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Meters retrieved successfully", meters, pagination)
+}
+
+// CreateMeter creates a meter
+// @Summary Create meter
+// @Description Create a meter that aggregates usage events by event name: sum, count, max, last or unique_count of a value property, optionally only for events whose properties match a filter (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateMeterRequest true "Meter data"
+// @Success 201 {object} utils.APIResponse{data=models.Meter}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters [post]
+func (h *MeteringHandler) CreateMeter(c *gin.Context) {
+	var req services.CreateMeterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	meter, err := h.meteringService.CreateMeter(&req)
+	if err != nil {
+		h.meteringError(c, err, "Failed to create meter")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Meter created successfully", meter)
+}
+
+// GetMeter gets a meter
+// @Summary Get meter
+// @Description Get a meter by ID (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Meter ID"
+// @Success 200 {object} utils.APIResponse{data=models.Meter}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters/{id} [get]
+func (h *MeteringHandler) GetMeter(c *gin.Context) {
+	meter, err := h.meteringService.GetMeter(c.Param("id"))
+	if err != nil {
+		h.meteringError(c, err, "Failed to get meter")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Meter retrieved successfully", meter)
+}
+
+// UpdateMeter updates a meter
+// @Summary Update meter
+// @Description Rename a meter or deactivate it so it rejects new events; its aggregation cannot change (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Meter ID"
+// @Param request body services.UpdateMeterRequest true "Meter update data"
+// @Success 200 {object} utils.APIResponse{data=models.Meter}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters/{id} [put]
+func (h *MeteringHandler) UpdateMeter(c *gin.Context) {
+	var req services.UpdateMeterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	meter, err := h.meteringService.UpdateMeter(c.Param("id"), &req)
+	if err != nil {
+		h.meteringError(c, err, "Failed to update meter")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Meter updated successfully", meter)
+}
+
+// GetMeteredPrices lists the prices of a meter
+// @Summary List metered prices
+// @Description List the plans that bill a meter and their prices (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Meter ID"
+// @Success 200 {object} utils.APIResponse{data=[]models.MeteredPrice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters/{id}/prices [get]
+func (h *MeteringHandler) GetMeteredPrices(c *gin.Context) {
+	prices, err := h.meteringService.GetMeteredPrices(c.Param("id"))
+	if err != nil {
+		h.meteringError(c, err, "Failed to get metered prices")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Metered prices retrieved successfully", prices)
+}
+
+// CreateMeteredPrice prices a meter on a plan
+// @Summary Create metered price
+// @Description Charge a plan's subscribers unit_amount for every started package_size units a meter records above included_units each period. The usage is invoiced when the period closes (admin only).
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Meter ID"
+// @Param request body services.CreateMeteredPriceRequest true "Metered price data"
+// @Success 201 {object} utils.APIResponse{data=models.MeteredPrice}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/meters/{id}/prices [post]
+func (h *MeteringHandler) CreateMeteredPrice(c *gin.Context) {
+	var req services.CreateMeteredPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	price, err := h.meteringService.CreateMeteredPrice(c.Param("id"), &req)
+	if err != nil {
+		h.meteringError(c, err, "Failed to create metered price")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Metered price created successfully", price)
+}
+
+// DeleteMeteredPrice deletes a metered price
+// @Summary Delete metered price
+// @Description Stop billing a meter on a plan; usage of periods that have not closed is no longer charged (admin only)
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Metered price ID"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/metered-prices/{id} [delete]
+func (h *MeteringHandler) DeleteMeteredPrice(c *gin.Context) {
+	if err := h.meteringService.DeleteMeteredPrice(c.Param("id")); err != nil {
+		h.meteringError(c, err, "Failed to delete metered price")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Metered price deleted successfully", nil)
+}
+
+// meteringError maps metering errors to responses
+func (h *MeteringHandler) meteringError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid meter ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid meter ID", err)
+	case "invalid metered price ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid metered price ID", err)
+	case "invalid plan ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid plan ID", err)
+	case "invalid organization ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+	case "invalid meter aggregation", "meter value property is required", "meter filter values must be strings, numbers or booleans":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid meter", err)
+	case "price currency does not match plan currency", "price must not be negative":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid unit amount", err)
+	case "no active meter for this event", "usage event is missing the meter value property",
+		"usage event value property must be a number", "usage event timestamp is in the future":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid usage event", err)
+	case "meter not found":
+		utils.NotFoundResponse(c, "Meter not found")
+	case "metered price not found":
+		utils.NotFoundResponse(c, "Metered price not found")
+	case "plan not found":
+		utils.NotFoundResponse(c, "Plan not found")
+	case "organization not found":
+		utils.NotFoundResponse(c, "Organization not found")
+	case "plan already has a price for this meter":
+		utils.ErrorResponse(c, http.StatusConflict, "Plan already has a price for this meter", err)
+	case "meter is billed on plans of another product":
+		utils.ErrorResponse(c, http.StatusConflict, "Meter is billed on plans of another product", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
@@ -12,13 +12,15 @@ import (
 
 // PlanHandler handles plan endpoints
 type PlanHandler struct {
-	planService *services.PlanService
+	planService       *services.PlanService
+	retirementService *services.PlanRetirementService
 }
 
 // NewPlanHandler creates a new plan handler
-func NewPlanHandler(planService *services.PlanService) *PlanHandler {
+func NewPlanHandler(planService *services.PlanService, retirementService *services.PlanRetirementService) *PlanHandler {
 	return &PlanHandler{
-		planService: planService,
+		planService:       planService,
+		retirementService: retirementService,
 	}
 }
 
@@ -42,6 +44,15 @@ func (h *PlanHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin
 			admin.POST("/:id/activate", h.ActivatePlan)
 			admin.POST("/:id/deactivate", h.DeactivatePlan)
 			admin.POST("/:id/set-popular", h.SetPopularPlan)
+			admin.GET("/:id/affected-subscriptions", h.GetAffectedSubscriptions)
+			admin.POST("/:id/retire", h.RetirePlan)
+			admin.GET("/migrations", h.GetPlanMigrations)
+			admin.GET("/migrations/:migration_id", h.GetPlanMigration)
+			admin.GET("/migrations/:migration_id/items", h.GetPlanMigrationItems)
+			admin.GET("/migrations/:migration_id/report", h.GetPlanMigrationReport)
+			admin.POST("/migrations/:migration_id/resume", h.ResumePlanMigration)
+			admin.POST("/migrations/:migration_id/retry-failed", h.RetryFailedPlanMigrationItems)
+			admin.POST("/migrations/:migration_id/cancel", h.CancelPlanMigration)
 		}
 	}
 }
@@ -201,6 +212,14 @@ func (h *PlanHandler) CreatePlan(c *gin.Context) {
 			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid currency code", err)
 			return
 		}
+		if err.Error() == "currency is not enabled" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Currency not enabled", err)
+			return
+		}
+		if err.Error() == "price must not be negative" || err.Error() == "price currency does not match plan currency" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid price", err)
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to create plan", err)
 		return
 	}
@@ -240,10 +259,30 @@ func (h *PlanHandler) UpdatePlan(c *gin.Context) {
 			utils.ErrorResponse(c, http.StatusConflict, "Plan name already exists", err)
 			return
 		}
+		if err.Error() == "plan with this slug already exists" {
+			utils.ErrorResponse(c, http.StatusConflict, "Plan slug already exists", err)
+			return
+		}
 		if err.Error() == "invalid currency code" {
 			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid currency code", err)
 			return
 		}
+		if err.Error() == "currency is not enabled" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Currency not enabled", err)
+			return
+		}
+		if err.Error() == "price must not be negative" || err.Error() == "price currency does not match plan currency" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid price", err)
+			return
+		}
+		if err.Error() == "cannot change the product of a plan with subscriptions" {
+			utils.ErrorResponse(c, http.StatusConflict, "Cannot change the product of a plan with subscriptions", err)
+			return
+		}
+		if err.Error() == "cannot change the product of a plan with metered prices" {
+			utils.ErrorResponse(c, http.StatusConflict, "Cannot change the product of a plan with metered prices", err)
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to update plan", err)
 		return
 	}
@@ -263,12 +302,17 @@ func (h *PlanHandler) UpdatePlan(c *gin.Context) {
 // @Failure 401 {object} utils.APIResponse
 // @Failure 403 {object} utils.APIResponse
 // @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
 // @Failure 500 {object} utils.APIResponse
 // @Router /plans/{id} [delete]
 func (h *PlanHandler) DeletePlan(c *gin.Context) {
 	id := c.Param("id")
 
 	if err := h.planService.DeletePlan(id); err != nil {
+		if err.Error() == "plan has active subscriptions" {
+			utils.ErrorResponse(c, http.StatusConflict, "Plan has active subscriptions; retire it with a migration instead", err)
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to delete plan", err)
 		return
 	}
@@ -349,4 +393,311 @@ func (h *PlanHandler) SetPopularPlan(c *gin.Context) {
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Plan set as popular successfully", nil)
-}
\ No newline at end of file
+}
+
+// GetAffectedSubscriptions lists subscriptions that retiring a plan would migrate
+// @Summary List subscriptions affected by plan retirement
+// @Description List the subscriptions a retirement of the plan would migrate: active, trialing, past-due, paused and suspended ones (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Plan ID"
+// @Success 200 {object} utils.APIResponse{data=[]models.Subscription}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/{id}/affected-subscriptions [get]
+func (h *PlanHandler) GetAffectedSubscriptions(c *gin.Context) {
+	id := c.Param("id")
+
+	subscriptions, err := h.retirementService.GetAffectedSubscriptions(id)
+	if err != nil {
+		switch err.Error() {
+		case "invalid plan ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid plan ID", err)
+		case "plan not found":
+			utils.NotFoundResponse(c, "Plan not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get affected subscriptions", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Affected subscriptions retrieved successfully", subscriptions)
+}
+
+// RetirePlan retires a plan and migrates its subscribers in the background
+// @Summary Retire plan
+// @Description Retire a plan and migrate its subscribers to a target plan (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Plan ID"
+// @Param request body services.RetirePlanRequest true "Retirement data"
+// @Success 202 {object} utils.APIResponse{data=models.PlanMigration}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/{id}/retire [post]
+func (h *PlanHandler) RetirePlan(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.RetirePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+
+	migration, err := h.retirementService.RetirePlan(id, &req, userID)
+	if err != nil {
+		switch err.Error() {
+		case "invalid plan ID", "invalid target plan ID", "target plan must differ from the retired plan", "target plan is not active",
+			"plan belongs to a different product":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid retirement request", err)
+		case "plan not found":
+			utils.NotFoundResponse(c, "Plan not found")
+		case "target plan not found":
+			utils.NotFoundResponse(c, "Target plan not found")
+		case "plan already has a migration in progress":
+			utils.ErrorResponse(c, http.StatusConflict, "Plan already has a migration in progress", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to retire plan", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Plan retirement started", migration)
+}
+
+// GetPlanMigrations gets all plan migrations
+// @Summary Get plan migrations
+// @Description Get all plan migrations with pagination (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.PlanMigration}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations [get]
+func (h *PlanHandler) GetPlanMigrations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	migrations, total, err := h.retirementService.GetMigrations(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get plan migrations", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Plan migrations retrieved successfully", migrations, pagination)
+}
+
+// GetPlanMigration gets a plan migration and its progress
+// @Summary Get plan migration
+// @Description Get a plan migration and its progress counters (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Success 200 {object} utils.APIResponse{data=models.PlanMigration}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id} [get]
+func (h *PlanHandler) GetPlanMigration(c *gin.Context) {
+	migration, err := h.retirementService.GetMigration(c.Param("migration_id"))
+	if err != nil {
+		h.migrationError(c, err, "Failed to get plan migration")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Plan migration retrieved successfully", migration)
+}
+
+// GetPlanMigrationItems gets the per-subscription results of a plan migration
+// @Summary Get plan migration items
+// @Description Get per-subscription results of a plan migration (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Param status query string false "Filter by item status"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.PlanMigrationItem}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id}/items [get]
+func (h *PlanHandler) GetPlanMigrationItems(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	items, total, err := h.retirementService.GetMigrationItems(c.Param("migration_id"), c.Query("status"), page, limit)
+	if err != nil {
+		h.migrationError(c, err, "Failed to get plan migration items")
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Plan migration items retrieved successfully", items, pagination)
+}
+
+// GetPlanMigrationReport gets the summary report of a plan migration
+// @Summary Get plan migration report
+// @Description Get the final report of a plan migration (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Success 200 {object} utils.APIResponse{data=services.PlanMigrationReport}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id}/report [get]
+func (h *PlanHandler) GetPlanMigrationReport(c *gin.Context) {
+	report, err := h.retirementService.GetMigrationReport(c.Param("migration_id"))
+	if err != nil {
+		h.migrationError(c, err, "Failed to get plan migration report")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Plan migration report retrieved successfully", report)
+}
+
+// ResumePlanMigration resumes an interrupted plan migration
+// @Summary Resume plan migration
+// @Description Resume processing of an unfinished plan migration (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Success 202 {object} utils.APIResponse{data=models.PlanMigration}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id}/resume [post]
+func (h *PlanHandler) ResumePlanMigration(c *gin.Context) {
+	migration, err := h.retirementService.ResumeMigration(c.Param("migration_id"))
+	if err != nil {
+		h.migrationError(c, err, "Failed to resume plan migration")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Plan migration resumed", migration)
+}
+
+// RetryFailedPlanMigrationItems re-queues the failed items of a plan migration
+// @Summary Retry failed plan migration items
+// @Description Re-queue failed subscriptions of a plan migration (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Success 202 {object} utils.APIResponse{data=models.PlanMigration}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id}/retry-failed [post]
+func (h *PlanHandler) RetryFailedPlanMigrationItems(c *gin.Context) {
+	migration, err := h.retirementService.RetryFailedItems(c.Param("migration_id"))
+	if err != nil {
+		h.migrationError(c, err, "Failed to retry plan migration")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Failed items re-queued", migration)
+}
+
+// CancelPlanMigration cancels a plan migration
+// @Summary Cancel plan migration
+// @Description Stop a plan migration; processed subscriptions keep their outcome (admin only)
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param migration_id path string true "Migration ID"
+// @Success 200 {object} utils.APIResponse{data=models.PlanMigration}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /plans/migrations/{migration_id}/cancel [post]
+func (h *PlanHandler) CancelPlanMigration(c *gin.Context) {
+	migration, err := h.retirementService.CancelMigration(c.Param("migration_id"))
+	if err != nil {
+		h.migrationError(c, err, "Failed to cancel plan migration")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Plan migration canceled", migration)
+}
+
+// migrationError maps plan migration service errors to responses
+func (h *PlanHandler) migrationError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid migration ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid migration ID", err)
+	case "migration not found":
+		utils.NotFoundResponse(c, "Plan migration not found")
+	case "migration is already finished", "migration is canceled":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Plan migration cannot be changed", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
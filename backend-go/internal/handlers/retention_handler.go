@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler handles retention offer endpoints
+type RetentionHandler struct {
+	retentionService *services.RetentionService
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(retentionService *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+	}
+}
+
+// RegisterRoutes registers retention offer routes
+func (h *RetentionHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	admin := router.Group("/admin/retention-offers", authMiddleware, middleware.AdminMiddleware())
+	{
+		admin.GET("", h.GetOffers)
+		admin.POST("", h.CreateOffer)
+		admin.GET("/:id", h.GetOffer)
+		admin.PUT("/:id", h.UpdateOffer)
+		admin.DELETE("/:id", h.DeleteOffer)
+	}
+}
+
+// GetOffers lists retention offers
+// @Summary List retention offers
+// @Description List the retention offers shown to customers about to cancel (admin only)
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.RetentionOffer}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/retention-offers [get]
+func (h *RetentionHandler) GetOffers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	offers, total, err := h.retentionService.GetOffers(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get retention offers", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Retention offers retrieved successfully", offers, pagination)
+}
+
+// CreateOffer creates a retention offer
+// @Summary Create retention offer
+// @Description Create an offer shown to customers about to cancel: a percentage off for a number of months, or a downgrade to another plan of the same product at renewal. Limit it to one product or to some cancellation reasons (admin only).
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateRetentionOfferRequest true "Retention offer"
+// @Success 201 {object} utils.APIResponse{data=models.RetentionOffer}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/retention-offers [post]
+func (h *RetentionHandler) CreateOffer(c *gin.Context) {
+	var req services.CreateRetentionOfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	offer, err := h.retentionService.CreateOffer(&req)
+	if err != nil {
+		h.retentionError(c, err, "Failed to create retention offer")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Retention offer created successfully", offer)
+}
+
+// GetOffer gets a retention offer
+// @Summary Get retention offer
+// @Description Get a retention offer by ID (admin only)
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Retention offer ID"
+// @Success 200 {object} utils.APIResponse{data=models.RetentionOffer}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/retention-offers/{id} [get]
+func (h *RetentionHandler) GetOffer(c *gin.Context) {
+	offer, err := h.retentionService.GetOffer(c.Param("id"))
+	if err != nil {
+		h.retentionError(c, err, "Failed to get retention offer")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Retention offer retrieved successfully", offer)
+}
+
+// UpdateOffer updates a retention offer
+// @Summary Update retention offer
+// @Description Change a retention offer's name, description, targeted cancellation reasons or active flag. What the offer gives cannot change once created (admin only).
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Retention offer ID"
+// @Param request body services.UpdateRetentionOfferRequest true "Retention offer changes"
+// @Success 200 {object} utils.APIResponse{data=models.RetentionOffer}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/retention-offers/{id} [put]
+func (h *RetentionHandler) UpdateOffer(c *gin.Context) {
+	var req services.UpdateRetentionOfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	offer, err := h.retentionService.UpdateOffer(c.Param("id"), &req)
+	if err != nil {
+		h.retentionError(c, err, "Failed to update retention offer")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Retention offer updated successfully", offer)
+}
+
+// DeleteOffer deletes a retention offer
+// @Summary Delete retention offer
+// @Description Delete a retention offer. How often it was shown and accepted stays in the cancellation analytics (admin only).
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Retention offer ID"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/retention-offers/{id} [delete]
+func (h *RetentionHandler) DeleteOffer(c *gin.Context) {
+	if err := h.retentionService.DeleteOffer(c.Param("id")); err != nil {
+		h.retentionError(c, err, "Failed to delete retention offer")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Retention offer deleted successfully", nil)
+}
+
+// retentionError maps retention offer errors to responses
+func (h *RetentionHandler) retentionError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid retention offer ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid retention offer ID", err)
+	case "invalid plan ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid plan ID", err)
+	case "invalid retention offer type", "invalid cancellation reason", "discount offers cannot have a plan",
+		"discount offers require a percentage and a duration", "downgrade offers cannot have a discount",
+		"downgrade offers require a plan", "plan belongs to a different product":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid retention offer", err)
+	case "retention offer not found":
+		utils.NotFoundResponse(c, "Retention offer not found")
+	case "plan not found":
+		utils.NotFoundResponse(c, "Plan not found")
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"go-backend/internal/middleware"
 	"go-backend/internal/services"
@@ -30,10 +33,26 @@ func (h *SubscriptionHandler) RegisterRoutes(router *gin.RouterGroup, authMiddle
 		subscriptions.GET("", h.GetSubscriptions)
 		subscriptions.POST("", h.CreateSubscription)
 		subscriptions.GET("/organization/:org_id", middleware.OrganizationMiddleware(), h.GetSubscriptionsByOrganization)
-		subscriptions.GET("/organization/:org_id/active", middleware.OrganizationMiddleware(), h.GetActiveSubscription)
+		subscriptions.GET("/organization/:org_id/active", middleware.OrganizationMiddleware(), h.GetActiveSubscriptions)
+		subscriptions.GET("/organization/:org_id/products/:product", middleware.OrganizationMiddleware(), h.GetActiveSubscriptionForProduct)
+		subscriptions.PUT("/organization/:org_id/billing-anchor", middleware.OrganizationMiddleware(), h.UpdateOrganizationBillingAnchor)
 		subscriptions.GET("/:id", h.GetSubscription)
 		subscriptions.POST("/:id/cancel", h.CancelSubscription)
+		subscriptions.POST("/:id/retention-offers", h.GetRetentionOffers)
+		subscriptions.POST("/:id/retention-offers/:offer_id/accept", h.AcceptRetentionOffer)
 		subscriptions.POST("/:id/renew", h.RenewSubscription)
+		subscriptions.POST("/:id/change-plan", h.ChangePlan)
+		subscriptions.POST("/:id/quantity", h.UpdateQuantity)
+		subscriptions.PUT("/:id/billing-anchor", h.ChangeBillingAnchor)
+		subscriptions.POST("/:id/pause", h.PauseSubscription)
+		subscriptions.POST("/:id/resume", h.ResumeSubscription)
+		subscriptions.POST("/:id/reactivate", h.ReactivateSubscription)
+		subscriptions.GET("/:id/history", h.GetStatusHistory)
+		subscriptions.GET("/:id/usage", h.GetCurrentUsage)
+		subscriptions.GET("/:id/schedule", h.GetSchedule)
+		subscriptions.POST("/:id/schedule", h.CreateSchedule)
+		subscriptions.PUT("/:id/schedule", h.AmendSchedule)
+		subscriptions.POST("/:id/schedule/release", h.ReleaseSchedule)
 	}
 }
 
@@ -81,10 +100,12 @@ func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
 			utils.NotFoundResponse(c, "Plan not found")
 		case "plan is not active":
 			utils.ErrorResponse(c, http.StatusBadRequest, "Plan is not active", err)
-		case "organization already has an active subscription":
-			utils.ErrorResponse(c, http.StatusConflict, "Organization already has an active subscription", err)
+		case "organization already has a subscription to this product":
+			utils.ErrorResponse(c, http.StatusConflict, "Organization already has a subscription to this product", err)
 		case "invalid plan interval":
 			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid plan interval", err)
+		case "invalid billing anchor day", "invalid billing timezone", "invalid billing time":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid billing anchor", err)
 		default:
 			utils.InternalServerErrorResponse(c, "Failed to create subscription", err)
 		}
@@ -199,30 +220,69 @@ func (h *SubscriptionHandler) GetSubscriptionsByOrganization(c *gin.Context) {
 	utils.PaginatedSuccessResponse(c, "Subscriptions retrieved successfully", subscriptions, pagination)
 }
 
-// GetActiveSubscription gets the active subscription for an organization
-// @Summary Get active subscription
-// @Description Get the active subscription for an organization
+// GetActiveSubscriptions gets the active subscriptions of an organization
+// @Summary Get active subscriptions
+// @Description Get the active subscriptions of an organization, one per product line
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param org_id path string true "Organization ID"
-// @Success 200 {object} utils.APIResponse{data=services.SubscriptionResponse}
+// @Success 200 {object} utils.APIResponse{data=services.OrganizationSubscriptionsResponse}
+// @Failure 400 {object} utils.APIResponse
 // @Failure 401 {object} utils.APIResponse
 // @Failure 403 {object} utils.APIResponse
 // @Failure 404 {object} utils.APIResponse
 // @Failure 500 {object} utils.APIResponse
 // @Router /subscriptions/organization/{org_id}/active [get]
-func (h *SubscriptionHandler) GetActiveSubscription(c *gin.Context) {
+func (h *SubscriptionHandler) GetActiveSubscriptions(c *gin.Context) {
 	orgID := c.Param("org_id")
 
-	response, err := h.subscriptionService.GetActiveSubscription(orgID)
+	response, err := h.subscriptionService.GetActiveSubscriptions(orgID)
 	if err != nil {
-		if err.Error() == "no active subscription found" {
+		switch err.Error() {
+		case "invalid organization ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+		case "organization not found":
+			utils.NotFoundResponse(c, "Organization not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get active subscriptions", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Active subscriptions retrieved successfully", response)
+}
+
+// GetActiveSubscriptionForProduct gets the active subscription of an organization to a product
+// @Summary Get active subscription for product
+// @Description Get the active subscription of an organization to a product line
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param product path string true "Product"
+// @Success 200 {object} utils.APIResponse{data=services.SubscriptionResponse}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/organization/{org_id}/products/{product} [get]
+func (h *SubscriptionHandler) GetActiveSubscriptionForProduct(c *gin.Context) {
+	orgID := c.Param("org_id")
+
+	response, err := h.subscriptionService.GetActiveSubscriptionForProduct(orgID, c.Param("product"))
+	if err != nil {
+		switch err.Error() {
+		case "invalid organization ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+		case "no active subscription found":
 			utils.NotFoundResponse(c, "No active subscription found")
-			return
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get active subscription", err)
 		}
-		utils.InternalServerErrorResponse(c, "Failed to get active subscription", err)
 		return
 	}
 
@@ -251,40 +311,49 @@ func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
 
 // CancelSubscription cancels a subscription
 // @Summary Cancel subscription
-// @Description Cancel a subscription
+// @Description Cancel a subscription now or at the end of its period, recording the customer's reason and comments. Retention offers shown for the subscription and not accepted are recorded as declined.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Subscription ID"
-// @Param request body CancelSubscriptionRequest true "Cancellation data"
+// @Param request body services.CancelSubscriptionRequest true "Cancellation data"
 // @Success 200 {object} utils.APIResponse
 // @Failure 400 {object} utils.APIResponse
 // @Failure 401 {object} utils.APIResponse
 // @Failure 403 {object} utils.APIResponse
 // @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
 // @Failure 500 {object} utils.APIResponse
 // @Router /subscriptions/{id}/cancel [post]
 func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
 	id := c.Param("id")
 
-	var req struct {
-		Immediate bool `json:"immediate"`
-	}
-
+	var req services.CancelSubscriptionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
 		return
 	}
 
-	// TODO: Add authorization check to ensure user can cancel this subscription
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
 
-	if err := h.subscriptionService.CancelSubscription(id, req.Immediate); err != nil {
-		if err.Error() == "subscription is already canceled" {
+	if err := h.subscriptionService.CancelSubscription(id, &req); err != nil {
+		switch err.Error() {
+		case "invalid cancellation reason":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cancellation reason", err)
+		case "subscription is already canceled":
 			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription is already canceled", err)
-			return
+		case "subscription has already ended", "invalid subscription status transition":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription cannot be canceled in its current status", err)
+		case "subscription status changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription status changed concurrently", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to cancel subscription", err)
 		}
-		utils.InternalServerErrorResponse(c, "Failed to cancel subscription", err)
 		return
 	}
 
@@ -296,9 +365,105 @@ func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, message, nil)
 }
 
+// GetRetentionOffers gets the offers to show before a subscription is canceled
+// @Summary Get retention offers
+// @Description Get the retention offers for a customer about to cancel a subscription for the given reason, such as a discount for some months or a downgrade to a cheaper plan. The offers are recorded as shown; accept one instead of canceling, or cancel to decline them. Subscriptions that are not active or trialing, or are already set to cancel, get none.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.RetentionOffersRequest true "Cancellation reason"
+// @Success 200 {object} utils.APIResponse{data=[]models.RetentionOffer}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/retention-offers [post]
+func (h *SubscriptionHandler) GetRetentionOffers(c *gin.Context) {
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	var req services.RetentionOffersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	offers, err := h.subscriptionService.GetRetentionOffers(c.Param("id"), &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription ID", err)
+		case "invalid cancellation reason":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cancellation reason", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get retention offers", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Retention offers retrieved successfully", offers)
+}
+
+// AcceptRetentionOffer keeps a subscription on a retention offer instead of canceling it
+// @Summary Accept retention offer
+// @Description Accept a retention offer shown for a subscription instead of canceling it. A discount applies to the invoices of the periods starting in the offer's number of months after the current period; a downgrade moves the subscription to the offer's plan at renewal. The other offers shown are recorded as declined.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param offer_id path string true "Retention offer ID"
+// @Success 200 {object} utils.APIResponse{data=models.Subscription}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/retention-offers/{offer_id}/accept [post]
+func (h *SubscriptionHandler) AcceptRetentionOffer(c *gin.Context) {
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	subscription, err := h.subscriptionService.AcceptRetentionOffer(c.Param("id"), c.Param("offer_id"))
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription ID", err)
+		case "invalid retention offer ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid retention offer ID", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "retention offer not found":
+			utils.NotFoundResponse(c, "Retention offer not found")
+		case "retention offer was not presented":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Retention offer was not presented for this subscription", err)
+		case "retention offer is not available", "only active subscriptions can change plan",
+			"cannot change currency while the subscription has a credit balance":
+			utils.ErrorResponse(c, http.StatusConflict, "Retention offer is no longer available", err)
+		case "retention offer was already answered":
+			utils.ErrorResponse(c, http.StatusConflict, "Retention offer was already answered", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to accept retention offer", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Retention offer accepted successfully", subscription)
+}
+
 // RenewSubscription renews a subscription
 // @Summary Renew subscription
-// @Description Renew a subscription for the next period
+// @Description Renew a subscription whose current period has ended for the next period
 // @Tags subscriptions
 // @Accept json
 // @Produce json
@@ -309,25 +474,704 @@ func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
 // @Failure 401 {object} utils.APIResponse
 // @Failure 403 {object} utils.APIResponse
 // @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
 // @Failure 500 {object} utils.APIResponse
 // @Router /subscriptions/{id}/renew [post]
 func (h *SubscriptionHandler) RenewSubscription(c *gin.Context) {
 	id := c.Param("id")
 
-	// TODO: Add authorization check to ensure user can renew this subscription
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
 
 	if err := h.subscriptionService.RenewSubscription(id); err != nil {
-		if err.Error() == "only active subscriptions can be renewed" {
-			utils.ErrorResponse(c, http.StatusBadRequest, "Only active subscriptions can be renewed", err)
+		if err.Error() == "only active or past-due subscriptions can be renewed" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Only active or past-due subscriptions can be renewed", err)
+			return
+		}
+		if err.Error() == "subscription is set to cancel at period end" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription is set to cancel at period end", err)
+			return
+		}
+		if err.Error() == "current period has not ended yet" {
+			utils.ErrorResponse(c, http.StatusConflict, "Current period has not ended yet", err)
 			return
 		}
 		if err.Error() == "invalid plan interval" {
 			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid plan interval", err)
 			return
 		}
+		if err.Error() == "subscription changed concurrently" {
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to renew subscription", err)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Subscription renewed successfully", nil)
 }
+
+// ChangePlan upgrades or downgrades a subscription
+// @Summary Change subscription plan
+// @Description Move a subscription to another plan immediately with proration, at the end of the current period, or immediately without proration. Set preview to get the amounts without applying the change.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.ChangePlanRequest true "Plan change data"
+// @Success 200 {object} utils.APIResponse{data=services.PlanChangeResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/change-plan [post]
+func (h *SubscriptionHandler) ChangePlan(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	result, err := h.subscriptionService.ChangePlan(id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "invalid plan ID", "invalid plan change mode", "invalid plan interval":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "plan not found":
+			utils.NotFoundResponse(c, "Plan not found")
+		case "plan is not active":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Plan is not active", err)
+		case "plan belongs to a different product":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Plan belongs to a different product", err)
+		case "only active subscriptions can change plan":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Only active subscriptions can change plan", err)
+		case "subscription is already on this plan":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription is already on this plan", err)
+		case "subscription will not renew":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription will not renew", err)
+		case "cannot prorate between plans with different currencies":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Cannot prorate between plans with different currencies", err)
+		case "cannot change currency while the subscription has a credit balance":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Cannot change currency while the subscription has a credit balance", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to change plan", err)
+		}
+		return
+	}
+
+	message := "Plan changed successfully"
+	switch {
+	case result.Preview:
+		message = "Plan change preview"
+	case result.Mode == services.PlanChangeAtPeriodEnd:
+		message = "Plan will change at the end of the current period"
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, result)
+}
+
+// UpdateQuantity changes the number of seats of a subscription
+// @Summary Update subscription quantity
+// @Description Change the number of seats billed. Added seats are charged for the rest of the current period; removed seats are credited now (decrease_mode immediate) or dropped at the next renewal (period_end). Set preview to get the amounts without applying the change.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.UpdateQuantityRequest true "Quantity change data"
+// @Success 200 {object} utils.APIResponse{data=services.QuantityChangeResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/quantity [post]
+func (h *SubscriptionHandler) UpdateQuantity(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.UpdateQuantityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	result, err := h.subscriptionService.UpdateQuantity(id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "invalid quantity decrease mode", "quantity must be at least 1":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "only active subscriptions can change quantity":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Only active subscriptions can change quantity", err)
+		case "subscription already has this quantity":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription already has this quantity", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to update quantity", err)
+		}
+		return
+	}
+
+	message := "Quantity updated successfully"
+	switch {
+	case result.Preview:
+		message = "Quantity change preview"
+	case result.Scheduled:
+		message = "Quantity will change at the end of the current period"
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, result)
+}
+
+// ChangeBillingAnchor aligns a subscription's billing periods to a new anchor
+// @Summary Change subscription billing anchor
+// @Description Align billing periods to a day of the month (1-31, clamped to shorter months) at a time of day in a time zone; day 0 removes the alignment. Active subscriptions start a new period now ending on the anchor: unused time is credited and the shorter first period is charged prorated. Set preview to get the amounts without applying the change.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.ChangeBillingAnchorRequest true "Billing anchor data"
+// @Success 200 {object} utils.APIResponse{data=services.BillingAnchorChangeResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/billing-anchor [put]
+func (h *SubscriptionHandler) ChangeBillingAnchor(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.ChangeBillingAnchorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	result, err := h.subscriptionService.ChangeBillingAnchor(id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "invalid billing anchor day", "invalid billing timezone", "invalid billing time":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "only active subscriptions can change billing anchor":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Only active subscriptions can change billing anchor", err)
+		case "subscription already uses this billing anchor":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription already uses this billing anchor", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to change billing anchor", err)
+		}
+		return
+	}
+
+	message := "Billing anchor changed successfully"
+	if result.Preview {
+		message = "Billing anchor change preview"
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, result)
+}
+
+// UpdateOrganizationBillingAnchor sets the default billing anchor of an organization
+// @Summary Update organization billing anchor
+// @Description Set the billing anchor new subscriptions of the organization start with. With apply_to_existing the organization's active and trialing subscriptions are re-anchored with proration; subscriptions that cannot be re-anchored are listed under failed.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param request body services.OrganizationBillingAnchorRequest true "Billing anchor data"
+// @Success 200 {object} utils.APIResponse{data=services.OrganizationBillingAnchorResult}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/organization/{org_id}/billing-anchor [put]
+func (h *SubscriptionHandler) UpdateOrganizationBillingAnchor(c *gin.Context) {
+	orgID := c.Param("org_id")
+
+	var req services.OrganizationBillingAnchorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	result, err := h.subscriptionService.UpdateOrganizationBillingAnchor(orgID, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid organization ID", "invalid billing anchor day", "invalid billing timezone", "invalid billing time":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "organization not found":
+			utils.NotFoundResponse(c, "Organization not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to update billing anchor", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Billing anchor updated successfully", result)
+}
+
+// PauseSubscription pauses a subscription
+// @Summary Pause subscription
+// @Description Pause an active subscription, suspending its entitlements. Optionally resume automatically at resume_at. invoice_behavior controls open invoices of the current period (void, keep_as_draft, mark_uncollectible); resume_policy controls the billing period on resume (shift, reset).
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.PauseSubscriptionRequest false "Pause options"
+// @Success 200 {object} utils.APIResponse{data=models.Subscription}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/pause [post]
+func (h *SubscriptionHandler) PauseSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.PauseSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	subscription, err := h.subscriptionService.PauseSubscription(id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "resume date must be in the future", "invalid pause invoice behavior":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "only active subscriptions can be paused", "invalid subscription status transition":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Only active subscriptions can be paused", err)
+		case "subscription status changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription status changed concurrently", err)
+		case "invoice status changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Invoice status changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to pause subscription", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription paused successfully", subscription)
+}
+
+// ResumeSubscription resumes a paused subscription
+// @Summary Resume subscription
+// @Description Resume a paused subscription. policy overrides the resume policy chosen when pausing (shift extends the current period by the paused time, reset starts and invoices a new period).
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.ResumeSubscriptionRequest false "Resume options"
+// @Success 200 {object} utils.APIResponse{data=models.Subscription}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/resume [post]
+func (h *SubscriptionHandler) ResumeSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	var req services.ResumeSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	subscription, err := h.subscriptionService.ResumeSubscription(id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "invalid resume policy", "invalid plan interval":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "subscription is not paused", "invalid subscription status transition":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription is not paused", err)
+		case "subscription status changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription status changed concurrently", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to resume subscription", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription resumed successfully", subscription)
+}
+
+// ReactivateSubscription undoes the cancellation of a subscription
+// @Summary Reactivate subscription
+// @Description Undo a cancellation. A subscription set to cancel at period end continues as if it had not been canceled. A canceled or expired subscription that ended within the reactivation window is restarted with its history and billing anchor: it resumes a trial that has not run out or a period paid before an immediate cancellation, and otherwise starts and invoices a new period.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} utils.APIResponse{data=models.Subscription}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/reactivate [post]
+func (h *SubscriptionHandler) ReactivateSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	if !h.authorizeSubscription(c, id) {
+		return
+	}
+
+	subscription, err := h.subscriptionService.ReactivateSubscription(id)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID", "invalid plan interval":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		case "subscription is not canceled", "invalid subscription status transition":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription is not canceled", err)
+		case "subscription status changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription status changed concurrently", err)
+		case "subscription changed concurrently":
+			utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+		case "reactivation window has passed":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Reactivation window has passed", err)
+		case "plan is not active":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Plan is not active", err)
+		case "subscription has unpaid invoices":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Subscription has unpaid invoices", err)
+		case "organization already has a subscription to this product":
+			utils.ErrorResponse(c, http.StatusConflict, "Organization already has a subscription to this product", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to reactivate subscription", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription reactivated successfully", subscription)
+}
+
+// GetStatusHistory gets the status transitions of a subscription
+// @Summary Get subscription status history
+// @Description Get every status transition of a subscription with the event that caused it, oldest first
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.SubscriptionStatusChange}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/history [get]
+func (h *SubscriptionHandler) GetStatusHistory(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	changes, total, err := h.subscriptionService.GetStatusHistory(c.Param("id"), page, limit)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription ID", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get subscription history", err)
+		}
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Subscription history retrieved successfully", changes, pagination)
+}
+
+// GetCurrentUsage gets the metered usage of a subscription's current period
+// @Summary Get current period usage
+// @Description Get the usage each metered price of the subscription's plan has recorded so far in the current billing period, and what it would be billed at period close
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} utils.APIResponse{data=services.UsageReport}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/usage [get]
+func (h *SubscriptionHandler) GetCurrentUsage(c *gin.Context) {
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	report, err := h.subscriptionService.GetCurrentUsage(c.Param("id"))
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription ID", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get subscription usage", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription usage retrieved successfully", report)
+}
+
+// GetSchedule gets the active schedule of a subscription
+// @Summary Get subscription schedule
+// @Description Get the active schedule of a subscription with its phases
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} utils.APIResponse{data=models.SubscriptionSchedule}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/schedule [get]
+func (h *SubscriptionHandler) GetSchedule(c *gin.Context) {
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	schedule, err := h.subscriptionService.GetSchedule(c.Param("id"))
+	if err != nil {
+		scheduleError(c, err, "Failed to get subscription schedule")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription schedule retrieved successfully", schedule)
+}
+
+// CreateSchedule creates a schedule for a subscription
+// @Summary Create subscription schedule
+// @Description Attach an ordered list of phases to a subscription. The first phase starts immediately; later phases start automatically when the previous one ends.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.CreateScheduleRequest true "Schedule phases"
+// @Success 201 {object} utils.APIResponse{data=models.SubscriptionSchedule}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/schedule [post]
+func (h *SubscriptionHandler) CreateSchedule(c *gin.Context) {
+	var req services.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	schedule, err := h.subscriptionService.CreateSchedule(c.Param("id"), &req)
+	if err != nil {
+		scheduleError(c, err, "Failed to create subscription schedule")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Subscription schedule created successfully", schedule)
+}
+
+// AmendSchedule replaces the remaining phases of a schedule
+// @Summary Amend subscription schedule
+// @Description Replace the current phase and all later phases of the active schedule. The current phase keeps its start date; changed terms apply immediately.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Param request body services.AmendScheduleRequest true "Schedule phases"
+// @Success 200 {object} utils.APIResponse{data=models.SubscriptionSchedule}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/schedule [put]
+func (h *SubscriptionHandler) AmendSchedule(c *gin.Context) {
+	var req services.AmendScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	schedule, err := h.subscriptionService.AmendSchedule(c.Param("id"), &req)
+	if err != nil {
+		scheduleError(c, err, "Failed to amend subscription schedule")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription schedule amended successfully", schedule)
+}
+
+// ReleaseSchedule releases a subscription from its schedule
+// @Summary Release subscription schedule
+// @Description Stop the active schedule. The subscription keeps its current terms and renews normally.
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} utils.APIResponse{data=models.SubscriptionSchedule}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /subscriptions/{id}/schedule/release [post]
+func (h *SubscriptionHandler) ReleaseSchedule(c *gin.Context) {
+	if !h.authorizeSubscription(c, c.Param("id")) {
+		return
+	}
+
+	schedule, err := h.subscriptionService.ReleaseSchedule(c.Param("id"))
+	if err != nil {
+		scheduleError(c, err, "Failed to release subscription schedule")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Subscription schedule released successfully", schedule)
+}
+
+// scheduleError maps subscription schedule errors onto HTTP responses
+func scheduleError(c *gin.Context, err error, fallback string) {
+	switch msg := err.Error(); {
+	case msg == "subscription not found":
+		utils.NotFoundResponse(c, "Subscription not found")
+	case msg == "plan not found":
+		utils.NotFoundResponse(c, "Plan not found")
+	case msg == "subscription has no active schedule":
+		utils.NotFoundResponse(c, "Subscription has no active schedule")
+	case msg == "subscription already has an active schedule":
+		utils.ErrorResponse(c, http.StatusConflict, "Subscription already has an active schedule", err)
+	case msg == "subscription changed concurrently":
+		utils.ErrorResponse(c, http.StatusConflict, "Subscription changed concurrently", err)
+	case msg == "invalid subscription ID", msg == "invalid plan ID", msg == "invalid plan interval",
+		msg == "plan is not active", msg == "only active subscriptions can be scheduled",
+		msg == "subscription is set to cancel at period end", msg == "current phase must end in the future",
+		msg == "schedule phases must use the subscription's currency", msg == "plan belongs to a different product",
+		strings.HasPrefix(msg, "phase "):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid schedule", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
+
+// authorizeSubscription checks that the caller is an admin or belongs to the
+// organization that owns the subscription, responding to the request if not
+func (h *SubscriptionHandler) authorizeSubscription(c *gin.Context, subscriptionID string) bool {
+	userOrgID, exists := middleware.GetOrganizationID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "Authentication required")
+		return false
+	}
+
+	if userRole, _ := middleware.GetUserRole(c); userRole == "admin" {
+		return true
+	}
+
+	subscription, err := h.subscriptionService.GetSubscription(subscriptionID)
+	if err != nil {
+		switch err.Error() {
+		case "invalid subscription ID":
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription ID", err)
+		case "subscription not found":
+			utils.NotFoundResponse(c, "Subscription not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to get subscription", err)
+		}
+		return false
+	}
+
+	if subscription.OrganizationID.String() != userOrgID {
+		utils.ForbiddenResponse(c, "Access denied for this subscription")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestClockHandler handles test clock endpoints
+type TestClockHandler struct {
+	testClockService *services.TestClockService
+}
+
+// NewTestClockHandler creates a new test clock handler
+func NewTestClockHandler(testClockService *services.TestClockService) *TestClockHandler {
+	return &TestClockHandler{
+		testClockService: testClockService,
+	}
+}
+
+// RegisterRoutes registers test clock routes
+func (h *TestClockHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	testClocks := router.Group("/admin/test-clocks", authMiddleware, middleware.AdminMiddleware())
+	{
+		testClocks.GET("", h.GetTestClocks)
+		testClocks.POST("", h.CreateTestClock)
+		testClocks.GET("/:id", h.GetTestClock)
+		testClocks.DELETE("/:id", h.DeleteTestClock)
+		testClocks.POST("/:id/advance", h.AdvanceTestClock)
+		testClocks.POST("/:id/organizations", h.AttachOrganization)
+	}
+}
+
+// GetTestClocks lists test clocks
+// @Summary List test clocks
+// @Description List test clocks, newest first (admin only)
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.TestClock}
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks [get]
+func (h *TestClockHandler) GetTestClocks(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	testClocks, total, err := h.testClockService.ListTestClocks(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get test clocks", err)
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Test clocks retrieved successfully", testClocks, pagination)
+}
+
+// CreateTestClock creates a test clock
+// @Summary Create test clock
+// @Description Create a simulated clock frozen at frozen_time, now by default (admin only)
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.CreateTestClockRequest true "Test clock data"
+// @Success 201 {object} utils.APIResponse{data=models.TestClock}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks [post]
+func (h *TestClockHandler) CreateTestClock(c *gin.Context) {
+	var req services.CreateTestClockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	testClock, err := h.testClockService.CreateTestClock(&req)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to create test clock", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Test clock created successfully", testClock)
+}
+
+// GetTestClock gets a test clock
+// @Summary Get test clock
+// @Description Get a test clock with its sandbox organizations (admin only)
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Test clock ID"
+// @Success 200 {object} utils.APIResponse{data=models.TestClock}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks/{id} [get]
+func (h *TestClockHandler) GetTestClock(c *gin.Context) {
+	testClock, err := h.testClockService.GetTestClock(c.Param("id"))
+	if err != nil {
+		h.testClockError(c, err, "Failed to get test clock")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Test clock retrieved successfully", testClock)
+}
+
+// DeleteTestClock deletes a test clock
+// @Summary Delete test clock
+// @Description Delete a test clock that has no organizations attached (admin only)
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Test clock ID"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks/{id} [delete]
+func (h *TestClockHandler) DeleteTestClock(c *gin.Context) {
+	if err := h.testClockService.DeleteTestClock(c.Param("id")); err != nil {
+		h.testClockError(c, err, "Failed to delete test clock")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Test clock deleted successfully", nil)
+}
+
+// AdvanceTestClock moves a test clock forward
+// @Summary Advance test clock
+// @Description Move a test clock forward to frozen_time, at most two years at once. Trials, renewals, schedules, resumes and overdue invoices of its organizations are processed as they would have been in real time (admin only).
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Test clock ID"
+// @Param request body services.AdvanceTestClockRequest true "Target time"
+// @Success 200 {object} utils.APIResponse{data=models.TestClock}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks/{id}/advance [post]
+func (h *TestClockHandler) AdvanceTestClock(c *gin.Context) {
+	var req services.AdvanceTestClockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	testClock, err := h.testClockService.AdvanceTestClock(c.Param("id"), &req)
+	if err != nil {
+		h.testClockError(c, err, "Failed to advance test clock")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Test clock advanced successfully", testClock)
+}
+
+// AttachOrganization puts an organization on a test clock
+// @Summary Attach organization to test clock
+// @Description Make an organization without subscriptions a sandbox organization that runs on the test clock (admin only)
+// @Tags test-clocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Test clock ID"
+// @Param request body services.AttachOrganizationRequest true "Organization"
+// @Success 200 {object} utils.APIResponse{data=models.TestClock}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 409 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /admin/test-clocks/{id}/organizations [post]
+func (h *TestClockHandler) AttachOrganization(c *gin.Context) {
+	var req services.AttachOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	testClock, err := h.testClockService.AttachOrganization(c.Param("id"), &req)
+	if err != nil {
+		h.testClockError(c, err, "Failed to attach organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization attached successfully", testClock)
+}
+
+// testClockError maps test clock errors to responses
+func (h *TestClockHandler) testClockError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid test clock ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid test clock ID", err)
+	case "invalid organization ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+	case "test clock can only move forward", "test clock cannot advance more than two years at once":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid target time", err)
+	case "test clock not found":
+		utils.NotFoundResponse(c, "Test clock not found")
+	case "organization not found":
+		utils.NotFoundResponse(c, "Organization not found")
+	case "test clock is already advancing":
+		utils.ErrorResponse(c, http.StatusConflict, "Test clock is already advancing", err)
+	case "test clock still has organizations":
+		utils.ErrorResponse(c, http.StatusConflict, "Test clock still has organizations", err)
+	case "organization is already attached to a test clock":
+		utils.ErrorResponse(c, http.StatusConflict, "Organization is already attached to a test clock", err)
+	case "organization already has subscriptions":
+		utils.ErrorResponse(c, http.StatusConflict, "Organization already has subscriptions", err)
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-backend/internal/middleware"
+	"go-backend/internal/services"
+	"go-backend/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageAlertHandler handles usage alert and usage limit endpoints
+type UsageAlertHandler struct {
+	usageAlertService *services.UsageAlertService
+}
+
+// NewUsageAlertHandler creates a new usage alert handler
+func NewUsageAlertHandler(usageAlertService *services.UsageAlertService) *UsageAlertHandler {
+	return &UsageAlertHandler{
+		usageAlertService: usageAlertService,
+	}
+}
+
+// RegisterRoutes registers usage alert routes
+func (h *UsageAlertHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	usage := router.Group("/usage/organization/:org_id", authMiddleware, middleware.OrganizationMiddleware())
+	{
+		usage.GET("/alerts", h.GetAlerts)
+		usage.POST("/alerts", h.CreateAlert)
+		usage.PUT("/alerts/:alert_id", h.UpdateAlert)
+		usage.DELETE("/alerts/:alert_id", h.DeleteAlert)
+		usage.GET("/alert-triggers", h.GetTriggers)
+		usage.GET("/limits", h.CheckLimits)
+	}
+}
+
+// GetAlerts lists an organization's usage alerts
+// @Summary List usage alerts
+// @Description List the usage and spend alerts of an organization
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} utils.APIResponse{data=[]models.UsageAlert}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/alerts [get]
+func (h *UsageAlertHandler) GetAlerts(c *gin.Context) {
+	alerts, err := h.usageAlertService.GetAlerts(c.Param("org_id"))
+	if err != nil {
+		h.usageAlertError(c, err, "Failed to get usage alerts")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Usage alerts retrieved successfully", alerts)
+}
+
+// CreateAlert creates a usage alert
+// @Summary Create usage alert
+// @Description Warn the organization by email as the usage of a meter, or the projected invoice amount, in the current billing period reaches percentages of a limit. Each threshold is announced once per period. With hard_cap set, the limits check reports the limit as reached once usage hits 100%.
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param request body services.CreateUsageAlertRequest true "Usage alert"
+// @Success 201 {object} utils.APIResponse{data=models.UsageAlert}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/alerts [post]
+func (h *UsageAlertHandler) CreateAlert(c *gin.Context) {
+	var req services.CreateUsageAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	alert, err := h.usageAlertService.CreateAlert(c.Param("org_id"), &req)
+	if err != nil {
+		h.usageAlertError(c, err, "Failed to create usage alert")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Usage alert created successfully", alert)
+}
+
+// UpdateAlert updates a usage alert
+// @Summary Update usage alert
+// @Description Change a usage alert's name, limit, thresholds, hard cap or active flag. Thresholds already announced in the current period are not announced again.
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param alert_id path string true "Usage alert ID"
+// @Param request body services.UpdateUsageAlertRequest true "Usage alert changes"
+// @Success 200 {object} utils.APIResponse{data=models.UsageAlert}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/alerts/{alert_id} [put]
+func (h *UsageAlertHandler) UpdateAlert(c *gin.Context) {
+	var req services.UpdateUsageAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	alert, err := h.usageAlertService.UpdateAlert(c.Param("org_id"), c.Param("alert_id"), &req)
+	if err != nil {
+		h.usageAlertError(c, err, "Failed to update usage alert")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Usage alert updated successfully", alert)
+}
+
+// DeleteAlert deletes a usage alert
+// @Summary Delete usage alert
+// @Description Delete a usage alert. The thresholds it announced stay listed.
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param alert_id path string true "Usage alert ID"
+// @Success 200 {object} utils.APIResponse
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/alerts/{alert_id} [delete]
+func (h *UsageAlertHandler) DeleteAlert(c *gin.Context) {
+	if err := h.usageAlertService.DeleteAlert(c.Param("org_id"), c.Param("alert_id")); err != nil {
+		h.usageAlertError(c, err, "Failed to delete usage alert")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Usage alert deleted successfully", nil)
+}
+
+// GetTriggers lists the thresholds an organization's alerts crossed
+// @Summary List triggered usage alerts
+// @Description List the alert thresholds an organization crossed, newest first, with the usage or spend at the time
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} utils.PaginatedResponse{data=[]models.UsageAlertTrigger}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/alert-triggers [get]
+func (h *UsageAlertHandler) GetTriggers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	triggers, total, err := h.usageAlertService.GetTriggers(c.Param("org_id"), page, limit)
+	if err != nil {
+		h.usageAlertError(c, err, "Failed to get triggered usage alerts")
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	pagination := utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	utils.PaginatedSuccessResponse(c, "Triggered usage alerts retrieved successfully", triggers, pagination)
+}
+
+// CheckLimits reports an organization's usage against its alert limits
+// @Summary Check usage limits
+// @Description Entitlement check for metered usage: how far the organization is towards the limit of each active alert in the current billing period, and whether a hard-capped limit has been reached
+// @Tags usage-alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} utils.APIResponse{data=services.UsageLimitsResponse}
+// @Failure 400 {object} utils.APIResponse
+// @Failure 401 {object} utils.APIResponse
+// @Failure 403 {object} utils.APIResponse
+// @Failure 404 {object} utils.APIResponse
+// @Failure 500 {object} utils.APIResponse
+// @Router /usage/organization/{org_id}/limits [get]
+func (h *UsageAlertHandler) CheckLimits(c *gin.Context) {
+	limits, err := h.usageAlertService.CheckLimits(c.Param("org_id"))
+	if err != nil {
+		h.usageAlertError(c, err, "Failed to check usage limits")
+		return
+	}
+
+	message := "Usage is within limits"
+	if limits.LimitReached {
+		message = "Usage limit reached"
+	}
+	utils.SuccessResponse(c, http.StatusOK, message, limits)
+}
+
+// usageAlertError maps usage alert errors to responses
+func (h *UsageAlertHandler) usageAlertError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "invalid organization ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID", err)
+	case "invalid usage alert ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid usage alert ID", err)
+	case "invalid meter ID":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid meter ID", err)
+	case "invalid alert type", "usage alerts require a meter", "usage alerts cannot have a currency",
+		"spend alerts cannot have a meter", "spend alerts require a currency", "invalid currency",
+		"alert limit must be a positive number", "alert thresholds must be between 1 and 1000 percent":
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid usage alert", err)
+	case "organization not found":
+		utils.NotFoundResponse(c, "Organization not found")
+	case "usage alert not found":
+		utils.NotFoundResponse(c, "Usage alert not found")
+	case "meter not found":
+		utils.NotFoundResponse(c, "Meter not found")
+	default:
+		utils.InternalServerErrorResponse(c, fallback, err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package ingest buffers usage events in memory and writes them to the
+// database in large batches. Producers hand events to a bounded queue and are
+// turned away when it is full, so a slow database slows ingestion down
+// instead of exhausting memory; writers drain the queue concurrently.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/clock"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxRetryBackoff caps the wait between attempts to write a failed batch
+const maxRetryBackoff = 30 * time.Second
+
+var (
+	// ErrQueueFull is returned when the queue has no room for the events
+	ErrQueueFull = errors.New("usage ingestion queue is full")
+
+	// ErrClosed is returned once the pipeline no longer accepts events
+	ErrClosed = errors.New("usage ingestion pipeline is closed")
+)
+
+// Writer stores a batch of usage events, skipping event IDs an organization
+// already reported, and returns how many events were stored
+type Writer interface {
+	WriteEvents(ctx context.Context, events []*models.UsageEvent) (int64, error)
+}
+
+// Config sizes a pipeline
+type Config struct {
+	QueueSize     int           // events buffered before producers are turned away
+	BatchSize     int           // events written per batch
+	FlushInterval time.Duration // longest an event waits for its batch to fill
+	Writers       int           // batches written concurrently
+	RetryBackoff  time.Duration // first wait after a failed write; doubles on every failure
+}
+
+// Stats counts the events a pipeline has handled
+type Stats struct {
+	Pending    int64 `json:"pending"`    // accepted and not yet written
+	Written    int64 `json:"written"`    // stored in the database
+	Duplicates int64 `json:"duplicates"` // skipped because their event ID was already stored
+	Rejected   int64 `json:"rejected"`   // refused by the database for their content, such as out-of-range values
+	Dropped    int64 `json:"dropped"`    // lost because the pipeline stopped before they could be written
+}
+
+// Pipeline is a bounded in-process queue of usage events drained by batch writers
+type Pipeline struct {
+	writer Writer
+	config Config
+	clock  clock.Clock
+	queue  chan []*models.UsageEvent
+
+	pending    atomic.Int64
+	written    atomic.Int64
+	duplicates atomic.Int64
+	rejected   atomic.Int64
+	dropped    atomic.Int64
+
+	mu     sync.RWMutex // orders Submit against Close
+	closed bool
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPipeline creates a pipeline, filling in defaults for unset sizes
+func NewPipeline(writer Writer, config Config, clk clock.Clock) *Pipeline {
+	if config.BatchSize < 1 {
+		config.BatchSize = 1000
+	}
+	if config.QueueSize < config.BatchSize {
+		config.QueueSize = config.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 250 * time.Millisecond
+	}
+	if config.Writers < 1 {
+		config.Writers = 1
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+
+	return &Pipeline{
+		writer: writer,
+		config: config,
+		clock:  clk,
+		// Every queued slice holds at least one event, so sends never block
+		queue: make(chan []*models.UsageEvent, config.QueueSize),
+	}
+}
+
+// Config returns the sizes the pipeline runs with
+func (p *Pipeline) Config() Config {
+	return p.config
+}
+
+// Start launches the writers. Canceling ctx abandons batches that are still
+// being retried; use Close to stop after the queue has drained.
+func (p *Pipeline) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	for i := 0; i < p.config.Writers; i++ {
+		p.wg.Add(1)
+		go p.run(ctx)
+	}
+}
+
+// Submit queues events for writing. Either all of them are queued or, when
+// the queue lacks room for all of them, none are and ErrQueueFull is returned.
+func (p *Pipeline) Submit(events []*models.UsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	n := int64(len(events))
+	if p.pending.Add(n) > int64(p.config.QueueSize) {
+		p.pending.Add(-n)
+		return ErrQueueFull
+	}
+	p.queue <- events
+	return nil
+}
+
+// Stats returns the pipeline's counters
+func (p *Pipeline) Stats() Stats {
+	return Stats{
+		Pending:    p.pending.Load(),
+		Written:    p.written.Load(),
+		Duplicates: p.duplicates.Load(),
+		Rejected:   p.rejected.Load(),
+		Dropped:    p.dropped.Load(),
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be written.
+// When ctx ends first, writes still failing are abandoned and ctx's error is
+// returned once the writers have stopped.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if p.cancel != nil {
+			p.cancel()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// run collects queued events into batches and writes each batch when it is
+// full or its oldest event has waited FlushInterval
+func (p *Pipeline) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	batch := make([]*models.UsageEvent, 0, p.config.BatchSize)
+	timer := time.NewTimer(p.config.FlushInterval)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
+			return
+		}
+		p.write(ctx, batch)
+		p.pending.Add(-int64(len(batch)))
+		batch = make([]*models.UsageEvent, 0, p.config.BatchSize)
+	}
+
+	for {
+		select {
+		case events, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(p.config.FlushInterval)
+			}
+			batch = append(batch, events...)
+			if len(batch) >= p.config.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// write stores a batch, retrying with backoff until it succeeds or ctx ends.
+// Rows are stamped with the time of the write, which is what tells usage that
+// arrived after its period was billed apart from usage billed with it. A
+// batch the database refuses for its content would fail on every attempt, so
+// it is split to find the events at fault instead of being retried.
+func (p *Pipeline) write(ctx context.Context, batch []*models.UsageEvent) {
+	backoff := p.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		now := p.clock.Now()
+		for _, event := range batch {
+			event.CreatedAt = now
+		}
+
+		written, err := p.writer.WriteEvents(ctx, batch)
+		if err == nil {
+			p.written.Add(written)
+			p.duplicates.Add(int64(len(batch)) - written)
+			return
+		}
+
+		if ctx.Err() != nil {
+			log.Printf("Dropped %d usage events: %v", len(batch), err)
+			p.dropped.Add(int64(len(batch)))
+			return
+		}
+		if isPermanent(err) {
+			p.reject(ctx, batch, err)
+			return
+		}
+		log.Printf("Failed to write %d usage events (attempt %d), retrying in %s: %v", len(batch), attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Dropped %d usage events: %v", len(batch), ctx.Err())
+			p.dropped.Add(int64(len(batch)))
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// reject handles a batch the database refused for its content. The batch is
+// halved and each half written on its own until the events at fault are
+// isolated and dropped, so one bad event does not hold up the others.
+func (p *Pipeline) reject(ctx context.Context, batch []*models.UsageEvent, err error) {
+	if len(batch) == 1 {
+		event := batch[0]
+		log.Printf("Rejected usage event %q of organization %s: %v", event.EventID, event.OrganizationID, err)
+		p.rejected.Add(1)
+		return
+	}
+	half := len(batch) / 2
+	p.write(ctx, batch[:half])
+	p.write(ctx, batch[half:])
+}
+
+// isPermanent reports whether a write failed because of the data written
+// rather than the state of the database. PostgreSQL reports invalid values
+// (class 22, data exception) and violated constraints (class 23, integrity
+// constraint violation) this way; retrying them fails the same way.
+func isPermanent(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
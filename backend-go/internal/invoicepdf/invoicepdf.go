@@ -0,0 +1,585 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-backend/config"
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// layoutVersion changes with the layout, so PDFs cached with an older
+// layout are rendered again
+const layoutVersion = "1"
+
+// Page geometry in millimetres (A4 portrait)
+const (
+	pageMargin   = 15.0
+	contentWidth = 180.0
+	footerMargin = 25.0
+	lineHeight   = 4.5
+)
+
+const (
+	longDate  = "January 2, 2006"
+	shortDate = "Jan 2, 2006"
+)
+
+// column is a column of the line item table
+type column struct {
+	title string
+	width float64
+	align string
+}
+
+var itemColumns = []column{
+	{"Description", 70, "L"},
+	{"Qty", 14, "R"},
+	{"Unit price", 28, "R"},
+	{"Discount", 22, "R"},
+	{"Tax", 22, "R"},
+	{"Amount", 24, "R"},
+}
+
+// rgb is a colour of the branding
+type rgb struct {
+	r, g, b int
+}
+
+var (
+	textColor  = rgb{31, 41, 55}
+	mutedColor = rgb{107, 114, 128}
+	ruleColor  = rgb{229, 231, 235}
+	white      = rgb{255, 255, 255}
+)
+
+// Renderer renders invoices as PDF documents with the configured seller
+// details and branding. It only uses the PDF core fonts, so no font files or
+// external programs are needed.
+type Renderer struct {
+	cfg      config.InvoiceConfig
+	logo     []byte
+	logoType string
+	primary  rgb
+	accent   rgb
+	version  string
+
+	uncompressed bool // leave page streams readable, for golden files
+}
+
+// NewRenderer creates a renderer, reading the logo and checking the colours
+// of the branding
+func NewRenderer(cfg config.InvoiceConfig) (*Renderer, error) {
+	r := &Renderer{cfg: cfg}
+
+	var err error
+	if r.primary, err = parseColor(cfg.PrimaryColor); err != nil {
+		return nil, fmt.Errorf("invalid primary colour: %w", err)
+	}
+	if r.accent, err = parseColor(cfg.AccentColor); err != nil {
+		return nil, fmt.Errorf("invalid accent colour: %w", err)
+	}
+
+	if cfg.LogoPath != "" {
+		if r.logo, err = os.ReadFile(cfg.LogoPath); err != nil {
+			return nil, fmt.Errorf("failed to read logo: %w", err)
+		}
+		switch {
+		case bytes.HasPrefix(r.logo, []byte("\x89PNG")):
+			r.logoType = "PNG"
+		case bytes.HasPrefix(r.logo, []byte("\xFF\xD8")):
+			r.logoType = "JPG"
+		default:
+			return nil, errors.New("logo must be a PNG or JPEG image")
+		}
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{layoutVersion, cfg.SellerName, cfg.SellerAddress, cfg.SellerTaxID, cfg.SellerEmail,
+		cfg.PrimaryColor, cfg.AccentColor, cfg.Footer} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(r.logo)
+	r.version = hex.EncodeToString(hash.Sum(nil))[:12]
+
+	return r, nil
+}
+
+// Version identifies the layout, seller details and branding; two renderers
+// with the same version render an invoice identically
+func (r *Renderer) Version() string {
+	return r.version
+}
+
+// Render writes an invoice as a PDF document. Its organization and items
+// must be loaded; address is the organization's billing address, if any.
+// The output only depends on its input, so it can be cached.
+func (r *Renderer) Render(w io.Writer, invoice *models.Invoice, address *models.BillingAddress) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	doc := &document{
+		Renderer: r,
+		pdf:      pdf,
+		tr:       pdf.UnicodeTranslatorFromDescriptor(""),
+		invoice:  invoice,
+		address:  address,
+	}
+
+	// Fixed dates and object order make the output reproducible
+	issued := invoice.IssueDate
+	if invoice.FinalizedAt != nil {
+		issued = *invoice.FinalizedAt
+	}
+	pdf.SetCreationDate(issued)
+	pdf.SetModificationDate(issued)
+	pdf.SetCatalogSort(true)
+	pdf.SetCompression(!r.uncompressed)
+
+	pdf.SetTitle(doc.tr("Invoice "+invoice.InvoiceNumber), false)
+	pdf.SetAuthor(doc.tr(r.cfg.SellerName), false)
+	pdf.SetCreator("go-backend", false)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, footerMargin)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(doc.footer)
+
+	pdf.AddPage()
+	doc.header()
+	doc.parties()
+	if err := doc.items(); err != nil {
+		return err
+	}
+	if err := doc.summary(); err != nil {
+		return err
+	}
+	doc.notes()
+
+	return pdf.Output(w)
+}
+
+// document is an invoice being rendered
+type document struct {
+	*Renderer
+	pdf     *fpdf.Fpdf
+	tr      func(string) string // converts UTF-8 to the code page of the core fonts
+	invoice *models.Invoice
+	address *models.BillingAddress
+}
+
+// header prints the logo or seller name, the invoice number and dates and
+// the payment status
+func (d *document) header() {
+	pdf := d.pdf
+	top := pdf.GetY()
+
+	if d.logo != nil {
+		options := fpdf.ImageOptions{ImageType: d.logoType}
+		pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(d.logo))
+		pdf.ImageOptions("logo", pageMargin, top, 0, 16, false, options, 0, "")
+	} else {
+		d.font("B", 18, d.primary)
+		pdf.SetXY(pageMargin, top)
+		pdf.CellFormat(95, 10, d.tr(d.cfg.SellerName), "", 0, "L", false, 0, "")
+	}
+
+	right := pageMargin + contentWidth - 85
+	pdf.SetXY(right, top)
+	d.font("B", 22, d.primary)
+	pdf.CellFormat(85, 10, "INVOICE", "", 2, "R", false, 0, "")
+
+	d.font("", 9, textColor)
+	pdf.CellFormat(85, lineHeight, d.tr("Invoice number: "+d.invoice.InvoiceNumber), "", 2, "R", false, 0, "")
+	pdf.CellFormat(85, lineHeight, "Issue date: "+d.invoice.IssueDate.Format(longDate), "", 2, "R", false, 0, "")
+	pdf.CellFormat(85, lineHeight, "Due date: "+d.invoice.DueDate.Format(longDate), "", 2, "R", false, 0, "")
+
+	d.font("B", 10, d.accent)
+	pdf.CellFormat(85, 6, d.tr(paymentStatus(d.invoice)), "", 2, "R", false, 0, "")
+
+	y := math.Max(pdf.GetY(), top+18) + 4
+	d.rule(y, d.primary)
+	pdf.SetY(y + 6)
+}
+
+// parties prints the seller and the billed organization side by side
+func (d *document) parties() {
+	top := d.pdf.GetY()
+
+	seller := append(strings.Split(d.cfg.SellerAddress, "\n"), taxID(d.cfg.SellerTaxID), d.cfg.SellerEmail)
+	sellerEnd := d.party(pageMargin, top, "FROM", d.cfg.SellerName, seller)
+
+	org := d.invoice.Organization
+	var customer []string
+	if d.address != nil {
+		if d.address.CompanyName != "" && d.address.CompanyName != org.Name {
+			customer = append(customer, d.address.CompanyName)
+		}
+		customer = append(customer, d.address.ContactName, d.address.GetFullAddress(), taxID(d.address.TaxID))
+	}
+	customer = append(customer, org.Email)
+	customerEnd := d.party(pageMargin+contentWidth/2+5, top, "BILL TO", org.Name, customer)
+
+	d.pdf.SetY(math.Max(sellerEnd, customerEnd) + 8)
+}
+
+// party prints a titled name and address block at x, y and returns where it ends
+func (d *document) party(x, y float64, title, name string, lines []string) float64 {
+	pdf := d.pdf
+	width := contentWidth/2 - 5
+
+	pdf.SetXY(x, y)
+	d.font("B", 8, mutedColor)
+	pdf.CellFormat(width, lineHeight, title, "", 2, "L", false, 0, "")
+	d.font("B", 10, textColor)
+	pdf.CellFormat(width, 5, d.tr(name), "", 2, "L", false, 0, "")
+
+	d.font("", 9, textColor)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, wrapped := range d.wrap(line, width) {
+			pdf.CellFormat(width, lineHeight, wrapped, "", 2, "L", false, 0, "")
+		}
+	}
+	return pdf.GetY()
+}
+
+// items prints the line item table, repeating its heading on every page
+func (d *document) items() error {
+	pdf := d.pdf
+
+	d.font("", 8, mutedColor)
+	pdf.CellFormat(contentWidth, lineHeight, "Amounts in "+d.invoice.Currency, "", 1, "R", false, 0, "")
+	d.itemHeading()
+
+	for _, item := range d.invoice.Items {
+		d.font("", 9, textColor)
+		description := d.wrap(item.Description, itemColumns[0].width-2)
+		height := float64(len(description))*lineHeight + 2
+		period := itemPeriod(item)
+		if period != "" {
+			height += 4
+		}
+
+		if pdf.GetY()+height > d.pageBottom() {
+			pdf.AddPage()
+			d.itemHeading()
+			d.font("", 9, textColor)
+		}
+
+		top := pdf.GetY() + 1
+		for i, line := range description {
+			pdf.SetXY(pageMargin, top+float64(i)*lineHeight)
+			pdf.CellFormat(itemColumns[0].width, lineHeight, line, "", 0, "L", false, 0, "")
+		}
+		if period != "" {
+			d.font("", 7.5, mutedColor)
+			pdf.SetXY(pageMargin, top+float64(len(description))*lineHeight)
+			pdf.CellFormat(itemColumns[0].width, 4, period, "", 0, "L", false, 0, "")
+			d.font("", 9, textColor)
+		}
+
+		values := []string{
+			strconv.Itoa(item.Quantity),
+			d.amount(item.UnitPrice).Decimal(),
+			d.amount(item.DiscountAmount).Decimal(),
+			d.amount(item.TaxAmount).Decimal(),
+			d.amount(item.Amount).Decimal(),
+		}
+		x := pageMargin + itemColumns[0].width
+		for i, value := range values {
+			col := itemColumns[i+1]
+			pdf.SetXY(x, top)
+			pdf.CellFormat(col.width, lineHeight, value, "", 0, col.align, false, 0, "")
+			x += col.width
+		}
+
+		d.rule(top+height-1, ruleColor)
+		pdf.SetY(top + height - 1)
+	}
+
+	pdf.Ln(6)
+	return pdf.Error()
+}
+
+// itemHeading prints the heading row of the line item table
+func (d *document) itemHeading() {
+	pdf := d.pdf
+	d.font("B", 9, white)
+	pdf.SetFillColor(d.primary.r, d.primary.g, d.primary.b)
+	for i, col := range itemColumns {
+		ln := 0
+		if i == len(itemColumns)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(col.width, 7, col.title, "", ln, col.align, true, 0, "")
+	}
+}
+
+// summary prints the tax breakdown on the left and the totals on the right
+func (d *document) summary() error {
+	pdf := d.pdf
+	invoice := d.invoice
+	if pdf.GetY()+40 > d.pageBottom() {
+		pdf.AddPage()
+	}
+	top := pdf.GetY()
+
+	breakdownEnd := top
+	if !invoice.TaxAmount.IsZero() {
+		rates, err := taxBreakdown(invoice)
+		if err != nil {
+			return err
+		}
+
+		pdf.SetXY(pageMargin, top)
+		d.font("B", 9, textColor)
+		pdf.CellFormat(90, 6, "Tax breakdown", "", 2, "L", false, 0, "")
+		d.font("B", 8, mutedColor)
+		pdf.CellFormat(24, lineHeight, "Rate", "B", 0, "L", false, 0, "")
+		pdf.CellFormat(33, lineHeight, "Taxable amount", "B", 0, "R", false, 0, "")
+		pdf.CellFormat(33, lineHeight, "Tax", "B", 1, "R", false, 0, "")
+		d.font("", 9, textColor)
+		for _, rate := range rates {
+			pdf.CellFormat(24, lineHeight, rate.label(), "", 0, "L", false, 0, "")
+			pdf.CellFormat(33, lineHeight, rate.taxable.String(), "", 0, "R", false, 0, "")
+			pdf.CellFormat(33, lineHeight, rate.tax.String(), "", 1, "R", false, 0, "")
+		}
+		breakdownEnd = pdf.GetY()
+	}
+
+	x := pageMargin + contentWidth - 80
+	pdf.SetXY(x, top)
+	d.total("Subtotal", d.amount(invoice.Subtotal).String(), false)
+	if !invoice.DiscountAmount.IsZero() {
+		d.total("Discount", d.amount(invoice.DiscountAmount).Neg().String(), false)
+	}
+	d.total("Tax", d.amount(invoice.TaxAmount).String(), false)
+	pdf.SetDrawColor(d.primary.r, d.primary.g, d.primary.b)
+	pdf.Line(x, pdf.GetY()+1, x+80, pdf.GetY()+1)
+	pdf.SetY(pdf.GetY() + 2)
+	pdf.SetX(x)
+	d.total("Total", d.amount(invoice.Total).String(), true)
+
+	amountDue := d.amount(invoice.Total)
+	switch invoice.Status {
+	case models.InvoiceStatusPaid:
+		d.total("Amount paid", amountDue.String(), false)
+		amountDue = money.Zero(invoice.Currency)
+	case models.InvoiceStatusVoid:
+		amountDue = money.Zero(invoice.Currency)
+	}
+	d.total("Amount due", amountDue.String(), false)
+
+	pdf.SetY(math.Max(breakdownEnd, pdf.GetY()) + 8)
+	return pdf.Error()
+}
+
+// total prints a row of the totals block at the current position
+func (d *document) total(label, value string, emphasis bool) {
+	pdf := d.pdf
+	x := pdf.GetX()
+	if emphasis {
+		d.font("B", 11, d.accent)
+	} else {
+		d.font("", 9, textColor)
+	}
+	pdf.CellFormat(40, 6, label, "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, 6, value, "", 1, "R", false, 0, "")
+	pdf.SetX(x)
+}
+
+// notes prints the invoice notes, if any
+func (d *document) notes() {
+	if strings.TrimSpace(d.invoice.Notes) == "" {
+		return
+	}
+	pdf := d.pdf
+	d.font("B", 9, textColor)
+	pdf.CellFormat(contentWidth, 6, "Notes", "", 1, "L", false, 0, "")
+	d.font("", 9, textColor)
+	for _, line := range d.wrap(d.invoice.Notes, contentWidth) {
+		if pdf.GetY()+lineHeight > d.pageBottom() {
+			pdf.AddPage()
+			d.font("", 9, textColor)
+		}
+		pdf.CellFormat(contentWidth, lineHeight, line, "", 1, "L", false, 0, "")
+	}
+}
+
+// footer prints the branding footer and page number on every page
+func (d *document) footer() {
+	pdf := d.pdf
+	pdf.SetY(-(footerMargin - 7))
+	d.rule(pdf.GetY()-2, ruleColor)
+	d.font("", 8, mutedColor)
+	if d.cfg.Footer != "" {
+		pdf.CellFormat(contentWidth, 4, d.tr(d.cfg.Footer), "", 1, "C", false, 0, "")
+	}
+	pdf.CellFormat(contentWidth, 4, d.tr(fmt.Sprintf("%s - Page %d of {nb}", d.invoice.InvoiceNumber, pdf.PageNo())),
+		"", 0, "C", false, 0, "")
+}
+
+// amount attaches the invoice currency to an amount that does not carry one
+func (d *document) amount(m money.Money) money.Money {
+	return m.Bind(d.invoice.Currency)
+}
+
+// font selects a core font style, size and colour
+func (d *document) font(style string, size float64, color rgb) {
+	d.pdf.SetFont("Helvetica", style, size)
+	d.pdf.SetTextColor(color.r, color.g, color.b)
+}
+
+// rule draws a horizontal line across the page at y
+func (d *document) rule(y float64, color rgb) {
+	d.pdf.SetDrawColor(color.r, color.g, color.b)
+	d.pdf.SetLineWidth(0.2)
+	d.pdf.Line(pageMargin, y, pageMargin+contentWidth, y)
+}
+
+// pageBottom is the lowest position content may reach above the footer
+func (d *document) pageBottom() float64 {
+	_, height := d.pdf.GetPageSize()
+	return height - footerMargin
+}
+
+// wrap converts text to the core font code page and breaks it into lines
+// that fit width in the current font
+func (d *document) wrap(text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(d.tr(text), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && d.pdf.GetStringWidth(candidate) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// taxRate is a line of the tax breakdown: the lines taxed at one rate
+type taxRate struct {
+	basisPoints int64
+	taxable     money.Money
+	tax         money.Money
+}
+
+// label renders the rate as a percentage, e.g. "7.25%"
+func (t taxRate) label() string {
+	return strconv.FormatFloat(float64(t.basisPoints)/100, 'f', -1, 64) + "%"
+}
+
+// taxBreakdown groups an invoice's lines by their effective tax rate, the
+// tax of a line over its amount after discount
+func taxBreakdown(invoice *models.Invoice) ([]taxRate, error) {
+	byRate := make(map[int64]*taxRate)
+	for _, item := range invoice.Items {
+		tax := item.TaxAmount.Bind(invoice.Currency)
+		taxable, err := item.Amount.Bind(invoice.Currency).Sub(item.DiscountAmount.Bind(invoice.Currency))
+		if err != nil {
+			return nil, err
+		}
+		var basisPoints int64
+		if !taxable.IsZero() {
+			basisPoints = int64(math.Round(float64(tax.Amount()) * 10000 / float64(taxable.Amount())))
+		}
+
+		rate, ok := byRate[basisPoints]
+		if !ok {
+			rate = &taxRate{
+				basisPoints: basisPoints,
+				taxable:     money.Zero(invoice.Currency),
+				tax:         money.Zero(invoice.Currency),
+			}
+			byRate[basisPoints] = rate
+		}
+		if rate.taxable, err = rate.taxable.Add(taxable); err != nil {
+			return nil, err
+		}
+		if rate.tax, err = rate.tax.Add(tax); err != nil {
+			return nil, err
+		}
+	}
+
+	rates := make([]taxRate, 0, len(byRate))
+	for _, rate := range byRate {
+		rates = append(rates, *rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].basisPoints > rates[j].basisPoints })
+	return rates, nil
+}
+
+// paymentStatus describes where an invoice stands with its payment
+func paymentStatus(invoice *models.Invoice) string {
+	switch invoice.Status {
+	case models.InvoiceStatusDraft:
+		return "DRAFT - not yet issued"
+	case models.InvoiceStatusPaid:
+		if invoice.PaidAt != nil {
+			return "PAID on " + invoice.PaidAt.Format(longDate)
+		}
+		return "PAID"
+	case models.InvoiceStatusOverdue:
+		return "OVERDUE - was due " + invoice.DueDate.Format(longDate)
+	case models.InvoiceStatusVoid:
+		return "VOID - nothing is owed"
+	case models.InvoiceStatusUncollectible:
+		return "UNPAID - written off"
+	default:
+		return "DUE " + invoice.DueDate.Format(longDate)
+	}
+}
+
+// itemPeriod describes the service period a line bills, if any
+func itemPeriod(item models.InvoiceItem) string {
+	if item.PeriodStart == nil || item.PeriodEnd == nil {
+		return ""
+	}
+	period := item.PeriodStart.Format(shortDate) + " - " + item.PeriodEnd.Format(shortDate)
+	if item.Proration {
+		period += " (prorated)"
+	}
+	return period
+}
+
+// taxID labels a tax ID, or returns nothing when there is none
+func taxID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "Tax ID: " + id
+}
+
+// parseColor parses a hex colour such as #1F2937
+func parseColor(value string) (rgb, error) {
+	hexValue := strings.TrimPrefix(value, "#")
+	if len(hexValue) != 6 {
+		return rgb{}, fmt.Errorf("%q is not a #RRGGBB colour", value)
+	}
+	v, err := strconv.ParseUint(hexValue, 16, 32)
+	if err != nil {
+		return rgb{}, fmt.Errorf("%q is not a #RRGGBB colour", value)
+	}
+	return rgb{int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF)}, nil
+}
@@ -0,0 +1,297 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-backend/config"
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// update rewrites the golden files: go test ./internal/invoicepdf -update
+var update = flag.Bool("update", false, "rewrite golden files")
+
+var defaultBranding = config.InvoiceConfig{
+	SellerName:    "OstoBilling Ltd",
+	SellerAddress: "1 Market Street\nLondon EC1A 1AA\nUnited Kingdom",
+	SellerTaxID:   "GB123456789",
+	SellerEmail:   "billing@ostobilling.example",
+	PrimaryColor:  "#1F2937",
+	AccentColor:   "#2563EB",
+	Footer:        "Thank you for your business.",
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(year int, month time.Month, day int) *time.Time {
+	t := date(year, month, day)
+	return &t
+}
+
+func usd(value string) money.Money {
+	return money.MustParse(value, "USD")
+}
+
+// openInvoice is a finalized subscription invoice with a proration, a
+// discount and two tax rates
+func openInvoice() (*models.Invoice, *models.BillingAddress) {
+	invoice := &models.Invoice{
+		ID:             uuid.MustParse("6f1c9a52-3d0e-4a7b-9b61-2f0d8e4c7a10"),
+		InvoiceNumber:  "INV-2026-000042",
+		Status:         models.InvoiceStatusOpen,
+		Subtotal:       usd("149.00"),
+		DiscountAmount: usd("10.00"),
+		TaxAmount:      usd("27.80"),
+		Total:          usd("166.80"),
+		Currency:       "USD",
+		IssueDate:      date(2026, 3, 1),
+		DueDate:        date(2026, 3, 31),
+		FinalizedAt:    datePtr(2026, 3, 1),
+		Organization: models.Organization{
+			Name:  "Acme Corporation",
+			Email: "ap@acme.example",
+		},
+		Items: []models.InvoiceItem{
+			{
+				Description:    "Pro - monthly subscription",
+				Quantity:       5,
+				UnitPrice:      usd("25.00"),
+				Amount:         usd("125.00"),
+				DiscountAmount: usd("10.00"),
+				TaxAmount:      usd("23.00"),
+				PeriodStart:    datePtr(2026, 3, 1),
+				PeriodEnd:      datePtr(2026, 4, 1),
+			},
+			{
+				Description: "Unused time on Starter after a plan change in the middle of the billing period",
+				Quantity:    1,
+				UnitPrice:   usd("-10.00"),
+				Amount:      usd("-10.00"),
+				TaxAmount:   usd("-2.00"),
+				PeriodStart: datePtr(2026, 2, 15),
+				PeriodEnd:   datePtr(2026, 3, 1),
+				Proration:   true,
+			},
+			{
+				Description: "API requests (34,000 over the included units)",
+				Quantity:    34,
+				UnitPrice:   usd("1.00"),
+				Amount:      usd("34.00"),
+				TaxAmount:   usd("6.80"),
+			},
+		},
+	}
+	address := &models.BillingAddress{
+		CompanyName:  "Acme Corp. Europe GmbH",
+		ContactName:  "Jürgen Müller",
+		AddressLine1: "Bahnhofstraße 12",
+		AddressLine2: "3. Stock",
+		City:         "Zürich",
+		PostalCode:   "8001",
+		Country:      "Switzerland",
+		TaxID:        "CHE-123.456.789 MWST",
+	}
+	return invoice, address
+}
+
+// paidInvoice is a paid invoice without a billing address or tax, with notes
+func paidInvoice() *models.Invoice {
+	eur := func(value string) money.Money { return money.MustParse(value, "EUR") }
+	return &models.Invoice{
+		ID:            uuid.MustParse("0b7e3f4d-8c21-4f5a-a1d9-6c3e2b9f8a44"),
+		InvoiceNumber: "EU-26-0007",
+		Status:        models.InvoiceStatusPaid,
+		Subtotal:      eur("49.00"),
+		TaxAmount:     eur("0.00"),
+		Total:         eur("49.00"),
+		Currency:      "EUR",
+		IssueDate:     date(2026, 1, 10),
+		DueDate:       date(2026, 2, 9),
+		FinalizedAt:   datePtr(2026, 1, 10),
+		PaidAt:        datePtr(2026, 1, 12),
+		Notes:         "Reverse charge: VAT to be accounted for by the recipient.\nPaid by bank transfer, reference EU-26-0007.",
+		Organization: models.Organization{
+			Name:  "Café Société SARL",
+			Email: "compta@cafe-societe.example",
+		},
+		Items: []models.InvoiceItem{{
+			Description: "Team - monthly subscription",
+			Quantity:    1,
+			UnitPrice:   eur("49.00"),
+			Amount:      eur("49.00"),
+			PeriodStart: datePtr(2026, 1, 10),
+			PeriodEnd:   datePtr(2026, 2, 10),
+		}},
+	}
+}
+
+// longInvoice is an overdue invoice with enough lines to fill several pages
+func longInvoice() (*models.Invoice, *models.BillingAddress) {
+	invoice, address := openInvoice()
+	invoice.InvoiceNumber = "INV-2026-000043"
+	invoice.Status = models.InvoiceStatusOverdue
+	invoice.DiscountAmount = usd("0.00")
+	invoice.TaxAmount = usd("0.00")
+	invoice.Items = nil
+
+	subtotal := usd("0.00")
+	for i := 1; i <= 60; i++ {
+		amount := usd(fmt.Sprintf("%d.50", i))
+		invoice.Items = append(invoice.Items, models.InvoiceItem{
+			Description: fmt.Sprintf("Usage of meter %d", i),
+			Quantity:    1,
+			UnitPrice:   amount,
+			Amount:      amount,
+		})
+		subtotal, _ = subtotal.Add(amount)
+	}
+	invoice.Subtotal = subtotal
+	invoice.Total = subtotal
+	return invoice, address
+}
+
+func TestRenderGolden(t *testing.T) {
+	branded := defaultBranding
+	branded.LogoPath = filepath.Join("testdata", "logo.png")
+	branded.PrimaryColor = "#0F766E"
+	branded.AccentColor = "#B45309"
+	branded.Footer = "Questions? Write to billing@ostobilling.example - bank details on ostobilling.example/pay"
+
+	open, openAddress := openInvoice()
+	long, longAddress := longInvoice()
+
+	tests := []struct {
+		name     string
+		branding config.InvoiceConfig
+		invoice  *models.Invoice
+		address  *models.BillingAddress
+	}{
+		{"open", defaultBranding, open, openAddress},
+		{"paid_branded", branded, paidInvoice(), nil},
+		{"overdue_multipage", defaultBranding, long, longAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer, err := NewRenderer(tt.branding)
+			if err != nil {
+				t.Fatalf("NewRenderer: %v", err)
+			}
+			renderer.uncompressed = true
+
+			var out bytes.Buffer
+			if err := renderer.Render(&out, tt.invoice, tt.address); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".pdf")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s; run with -update and review the new file if the change is intended", golden)
+			}
+		})
+	}
+}
+
+func TestRenderIsReproducible(t *testing.T) {
+	renderer, err := NewRenderer(defaultBranding)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoice, address := openInvoice()
+	var first, second bytes.Buffer
+	if err := renderer.Render(&first, invoice, address); err != nil {
+		t.Fatal(err)
+	}
+	if err := renderer.Render(&second, invoice, address); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("rendering the same invoice twice gave different output")
+	}
+}
+
+func TestVersionFollowsBranding(t *testing.T) {
+	base, err := NewRenderer(defaultBranding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := NewRenderer(defaultBranding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Version() != same.Version() {
+		t.Error("identical branding gave different versions")
+	}
+
+	changed := defaultBranding
+	changed.Footer = "New footer"
+	other, err := NewRenderer(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Version() == other.Version() {
+		t.Error("changing the footer kept the version")
+	}
+}
+
+func TestNewRendererRejectsInvalidBranding(t *testing.T) {
+	tests := map[string]func(cfg *config.InvoiceConfig){
+		"primary colour": func(cfg *config.InvoiceConfig) { cfg.PrimaryColor = "teal" },
+		"accent colour":  func(cfg *config.InvoiceConfig) { cfg.AccentColor = "#12345" },
+		"missing logo":   func(cfg *config.InvoiceConfig) { cfg.LogoPath = filepath.Join("testdata", "missing.png") },
+		"logo format":    func(cfg *config.InvoiceConfig) { cfg.LogoPath = "invoicepdf.go" },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultBranding
+			modify(&cfg)
+			if _, err := NewRenderer(cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTaxBreakdown(t *testing.T) {
+	invoice, _ := openInvoice()
+	rates, err := taxBreakdown(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		label, taxable, tax string
+	}{
+		{"20%", "139.00 USD", "27.80 USD"},
+	}
+	if len(rates) != len(want) {
+		t.Fatalf("got %d rates, want %d", len(rates), len(want))
+	}
+	for i, w := range want {
+		if rates[i].label() != w.label || rates[i].taxable.String() != w.taxable || rates[i].tax.String() != w.tax {
+			t.Errorf("rate %d = %s %s %s, want %s %s %s", i,
+				rates[i].label(), rates[i].taxable, rates[i].tax, w.label, w.taxable, w.tax)
+		}
+	}
+}
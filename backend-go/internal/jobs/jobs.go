@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run on a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+	// Exclusive jobs run on one replica at a time; a replica skips a run
+	// while another holds the job's lock
+	Exclusive bool
+}
+
+// Locker takes the locks of exclusive jobs. TryLock reports false when the
+// lock is held elsewhere; release gives up a lock that was acquired.
+type Locker interface {
+	TryLock(name string) (release func(), acquired bool, err error)
+}
+
+// Scheduler runs background jobs until its context is canceled
+type Scheduler struct {
+	jobs   []Job
+	locker Locker
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates an empty scheduler that locks exclusive jobs with locker
+func NewScheduler(locker Locker) *Scheduler {
+	return &Scheduler{locker: locker}
+}
+
+// Add registers a job; jobs with a non-positive interval are ignored
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		log.Printf("Job %s disabled", job.Name)
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job once and then on its interval until ctx is canceled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				s.run(job)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+}
+
+// Wait blocks until every job has stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// run executes a job, logging failures and recovering from panics so one
+// bad run does not stop the schedule. An exclusive job is skipped when its
+// lock cannot be taken.
+func (s *Scheduler) run(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	if job.Exclusive {
+		release, acquired, err := s.locker.TryLock("job:" + job.Name)
+		if err != nil {
+			log.Printf("Job %s failed to take its lock: %v", job.Name, err)
+			return
+		}
+		if !acquired {
+			return
+		}
+		defer release()
+	}
+
+	if err := job.Run(); err != nil {
+		log.Printf("Job %s failed: %v", job.Name, err)
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// BillingAnchor aligns billing periods to a calendar day and time of day. It
+// is embedded in organizations, as the default for their new subscriptions,
+// and in subscriptions.
+type BillingAnchor struct {
+	Day      int    `gorm:"default:0" json:"day"` // day of month periods start on, clamped to shorter months; 0 disables alignment
+	Time     string `json:"time,omitempty"`       // HH:MM periods start at; empty means midnight
+	Timezone string `json:"timezone,omitempty"`   // IANA time zone of Day and Time; empty means UTC
+}
+
+// IsSet checks if periods are aligned to the anchor
+func (a BillingAnchor) IsSet() bool {
+	return a.Day > 0
+}
+
+// Location returns the time zone the anchor is expressed in
+func (a BillingAnchor) Location() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(a.Timezone)
+}
+
+// Clock returns the hour and minute periods start at
+func (a BillingAnchor) Clock() (hour, minute int, err error) {
+	if a.Time == "" {
+		return 0, 0, nil
+	}
+	t, err := time.Parse("15:04", a.Time)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Billing run statuses
+const (
+	BillingRunStatusRunning   = "running"
+	BillingRunStatusCompleted = "completed"
+	BillingRunStatusFailed    = "failed"
+)
+
+// Billing run item outcomes
+const (
+	BillingItemProcessing = "processing"
+	BillingItemRenewed    = "renewed"
+	BillingItemExpired    = "expired"
+	BillingItemCanceled   = "canceled"
+	BillingItemSkipped    = "skipped"
+	BillingItemFailed     = "failed"
+)
+
+// BillingRun is one pass of the billing-cycle engine over the subscriptions
+// whose period has ended
+type BillingRun struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Instance      string     `json:"instance"`                                     // host that executed the run
+	Status        string     `gorm:"not null;default:running;index" json:"status"` // running, completed, failed
+	ClaimedCount  int        `gorm:"default:0" json:"claimed_count"`
+	RenewedCount  int        `gorm:"default:0" json:"renewed_count"`
+	ExpiredCount  int        `gorm:"default:0" json:"expired_count"`
+	CanceledCount int        `gorm:"default:0" json:"canceled_count"`
+	SkippedCount  int        `gorm:"default:0" json:"skipped_count"`
+	FailedCount   int        `gorm:"default:0" json:"failed_count"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt     time.Time  `gorm:"not null;index" json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (r *BillingRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for BillingRun model
+func (BillingRun) TableName() string {
+	return "billing_runs"
+}
+
+// BillingRunItem claims one billing period of one subscription. The unique
+// (subscription_id, period_end) index makes processing idempotent per period:
+// only the engine that inserts or reclaims the row may act on it.
+type BillingRunItem struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	RunID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"run_id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_billing_run_items_period" json:"subscription_id"`
+	PeriodEnd      time.Time  `gorm:"not null;uniqueIndex:idx_billing_run_items_period" json:"period_end"`
+	Outcome        string     `gorm:"not null;default:processing;index" json:"outcome"` // processing, renewed, expired, canceled, skipped, failed
+	Attempts       int        `gorm:"not null;default:1" json:"attempts"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // when a failed claim may be retried
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (i *BillingRunItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for BillingRunItem model
+func (BillingRunItem) TableName() string {
+	return "billing_run_items"
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Dunning case statuses
+const (
+	DunningStatusActive    = "active"    // the payment is being retried
+	DunningStatusRecovered = "recovered" // the invoice was paid
+	DunningStatusExhausted = "exhausted" // every retry failed and the final action was taken
+	DunningStatusClosed    = "closed"    // the invoice was voided or written off outside dunning
+)
+
+// What dunning does when every retry of a payment has failed
+const (
+	DunningActionCancel            = "cancel"             // cancel the subscription immediately
+	DunningActionSuspend           = "suspend"            // suspend the subscription until the invoice is paid
+	DunningActionMarkUncollectible = "mark_uncollectible" // write the invoice off and keep the subscription active
+)
+
+// Payment attempt statuses
+const (
+	PaymentAttemptPending   = "pending" // claimed by a dunning run that is charging the invoice
+	PaymentAttemptSucceeded = "succeeded"
+	PaymentAttemptFailed    = "failed"
+)
+
+// DunningCase tracks the collection of an invoice whose payment failed. It
+// is opened by the first failed charge, retries the charge on the dunning
+// schedule and ends once the invoice is paid, or with the final action when
+// every retry has failed.
+type DunningCase struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	InvoiceID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"invoice_id"`
+	SubscriptionID *uuid.UUID `gorm:"type:uuid;index" json:"subscription_id,omitempty"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Status         string     `gorm:"not null;default:active;index:idx_dunning_cases_due" json:"status"`
+	AttemptCount   int        `gorm:"not null;default:0" json:"attempt_count"` // scheduled charges made, the first included
+	NextAttemptAt  *time.Time `gorm:"index:idx_dunning_cases_due" json:"next_attempt_at,omitempty"`
+	LastAttemptAt  time.Time  `gorm:"not null" json:"last_attempt_at"`
+	LastFailure    string     `json:"last_failure,omitempty"`
+	FinalAction    string     `json:"final_action,omitempty"` // DunningAction taken when retries ran out
+	StartedAt      time.Time  `gorm:"not null" json:"started_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Invoice Invoice `gorm:"foreignKey:InvoiceID" json:"-"`
+
+	// Attempts are the charges of the invoice, loaded separately since
+	// attempts that succeed outright have no dunning case
+	Attempts []PaymentAttempt `gorm:"-" json:"attempts,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (d *DunningCase) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for DunningCase model
+func (DunningCase) TableName() string {
+	return "dunning_cases"
+}
+
+// PaymentAttempt records one charge of an invoice to a payment method. It
+// is created pending before the charge is made, and its ID is the charge's
+// idempotency key; an invoice has at most one pending attempt at a time.
+type PaymentAttempt struct {
+	ID                uuid.UUID   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	InvoiceID         uuid.UUID   `gorm:"type:uuid;not null;index;uniqueIndex:idx_payment_attempts_pending,where:status = 'pending'" json:"invoice_id"`
+	OrganizationID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"organization_id"`
+	PaymentMethodID   *uuid.UUID  `gorm:"type:uuid" json:"payment_method_id,omitempty"` // nil when no usable payment method was on file
+	Amount            money.Money `gorm:"not null" json:"amount"`
+	Currency          string      `gorm:"not null" json:"currency"`
+	Status            string      `gorm:"not null" json:"status"` // pending, succeeded or failed
+	FailureReason     string      `json:"failure_reason,omitempty"`
+	ProviderReference string      `json:"provider_reference,omitempty"`
+	AttemptedAt       time.Time   `gorm:"not null" json:"attempted_at"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (a *PaymentAttempt) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// AfterFind attaches the currency to the amount loaded from the database
+func (a *PaymentAttempt) AfterFind(tx *gorm.DB) error {
+	a.Amount = a.Amount.Bind(a.Currency)
+	return nil
+}
+
+// TableName returns the table name for PaymentAttempt model
+func (PaymentAttempt) TableName() string {
+	return "payment_attempts"
+}
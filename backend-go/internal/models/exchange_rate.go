@@ -0,0 +1,41 @@
+package models
+
+import (
+	"go-backend/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Exchange rate sources
+const (
+	ExchangeRateSourceFile = "file"
+	ExchangeRateSourceAPI  = "api"
+)
+
+// ExchangeRate is the daily rate at which one unit of BaseCurrency converts
+// into QuoteCurrency
+type ExchangeRate struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BaseCurrency  string     `gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_pair_date" json:"base_currency"`
+	QuoteCurrency string     `gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_pair_date" json:"quote_currency"`
+	Date          time.Time  `gorm:"type:date;not null;uniqueIndex:idx_exchange_rates_pair_date" json:"date"`
+	Rate          money.Rate `gorm:"not null" json:"rate"`
+	Source        string     `gorm:"not null;default:api" json:"source"` // file, api
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (er *ExchangeRate) BeforeCreate(tx *gorm.DB) error {
+	if er.ID == uuid.Nil {
+		er.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for ExchangeRate model
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"go-backend/pkg/money"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,24 +10,27 @@ import (
 )
 
 type Invoice struct {
-	ID               uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	OrganizationID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id" validate:"required"`
-	SubscriptionID   *uuid.UUID `gorm:"type:uuid;index" json:"subscription_id"`
-	PaymentMethodID  *uuid.UUID `gorm:"type:uuid;index" json:"payment_method_id"`
-	InvoiceNumber    string     `gorm:"unique;not null" json:"invoice_number" validate:"required"`
-	Status           string     `gorm:"not null;default:draft" json:"status"` // draft, sent, paid, overdue, canceled
-	Subtotal         float64    `gorm:"not null" json:"subtotal" validate:"required,min=0"`
-	TaxAmount        float64    `gorm:"default:0" json:"tax_amount"`
-	DiscountAmount   float64    `gorm:"default:0" json:"discount_amount"`
-	Total            float64    `gorm:"not null" json:"total" validate:"required,min=0"`
-	Currency         string     `gorm:"not null;default:USD" json:"currency"`
-	IssueDate        time.Time  `gorm:"not null" json:"issue_date" validate:"required"`
-	DueDate          time.Time  `gorm:"not null" json:"due_date" validate:"required"`
-	PaidAt           *time.Time `json:"paid_at"`
-	Notes            string     `gorm:"type:text" json:"notes"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID  uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id" validate:"required"`
+	SubscriptionID  *uuid.UUID     `gorm:"type:uuid;index" json:"subscription_id"`
+	PaymentMethodID *uuid.UUID     `gorm:"type:uuid;index" json:"payment_method_id"`
+	InvoiceNumber   string         `gorm:"unique;not null" json:"invoice_number" validate:"required"`
+	Status          string         `gorm:"not null;default:draft" json:"status"` // see invoice_status.go
+	Subtotal        money.Money    `gorm:"not null" json:"subtotal" validate:"required"`
+	TaxAmount       money.Money    `gorm:"default:0" json:"tax_amount"`
+	DiscountAmount  money.Money    `gorm:"default:0" json:"discount_amount"`
+	Total           money.Money    `gorm:"not null" json:"total" validate:"required"`
+	Currency        string         `gorm:"not null;default:USD" json:"currency"`
+	IssueDate       time.Time      `gorm:"not null" json:"issue_date" validate:"required"`
+	DueDate         time.Time      `gorm:"not null" json:"due_date" validate:"required"`
+	FinalizedAt     *time.Time     `json:"finalized_at,omitempty"`
+	SentAt          *time.Time     `json:"sent_at,omitempty"`
+	PaidAt          *time.Time     `json:"paid_at"`
+	VoidedAt        *time.Time     `json:"voided_at,omitempty"`
+	Notes           string         `gorm:"type:text" json:"notes"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
 	Organization  Organization   `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -34,29 +39,114 @@ type Invoice struct {
 	Items         []InvoiceItem  `gorm:"foreignKey:InvoiceID" json:"items,omitempty"`
 }
 
-// BeforeCreate hook to generate UUID and invoice number if not provided
+// draftInvoiceNumberPrefix marks the provisional number of an invoice that
+// has not been finalized yet
+const draftInvoiceNumberPrefix = "DRAFT-"
+
+// BeforeCreate hook to generate UUID and invoice number if not provided.
+// Drafts get a provisional number made of their whole ID, so it is as unique
+// as the ID; their invoice number is taken from a sequence when they are
+// finalized.
 func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
 	if i.ID == uuid.Nil {
 		i.ID = uuid.New()
 	}
 	if i.InvoiceNumber == "" {
-		// Generate invoice number (you might want to implement a more sophisticated numbering system)
-		i.InvoiceNumber = "INV-" + time.Now().Format("20060102") + "-" + i.ID.String()[:8]
+		i.InvoiceNumber = draftInvoiceNumberPrefix + i.ID.String()
+	}
+	return nil
+}
+
+// HasDraftNumber checks if the invoice still carries its provisional number
+func (i *Invoice) HasDraftNumber() bool {
+	return IsDraftInvoiceNumber(i.InvoiceNumber)
+}
+
+// IsDraftInvoiceNumber checks if an invoice number reads as a provisional one
+func IsDraftInvoiceNumber(number string) bool {
+	return strings.HasPrefix(number, draftInvoiceNumberPrefix)
+}
+
+// BeforeSave binds every amount to the invoice currency
+func (i *Invoice) BeforeSave(tx *gorm.DB) error {
+	if code := i.Total.Currency(); code != "" && i.Currency == "" {
+		i.Currency = code
+	}
+	i.bindAmounts()
+	return nil
+}
+
+// AfterFind attaches the invoice currency to the amounts loaded from the database
+func (i *Invoice) AfterFind(tx *gorm.DB) error {
+	i.bindAmounts()
+	return nil
+}
+
+// bindAmounts attaches the invoice currency to amounts that do not carry one yet
+func (i *Invoice) bindAmounts() {
+	i.Subtotal = i.Subtotal.Bind(i.Currency)
+	i.TaxAmount = i.TaxAmount.Bind(i.Currency)
+	i.DiscountAmount = i.DiscountAmount.Bind(i.Currency)
+	i.Total = i.Total.Bind(i.Currency)
+}
+
+// ComputeTotals derives the invoice amounts from its items: the subtotal is
+// the sum of the item amounts, the discount and tax are the sums of the
+// items' discounts and taxes, and the total is what is left to pay
+func (i *Invoice) ComputeTotals() error {
+	subtotal := money.Zero(i.Currency)
+	discount := money.Zero(i.Currency)
+	tax := money.Zero(i.Currency)
+	for _, item := range i.Items {
+		var err error
+		if subtotal, err = subtotal.Add(item.Amount.Bind(i.Currency)); err != nil {
+			return err
+		}
+		if discount, err = discount.Add(item.DiscountAmount.Bind(i.Currency)); err != nil {
+			return err
+		}
+		if tax, err = tax.Add(item.TaxAmount.Bind(i.Currency)); err != nil {
+			return err
+		}
+	}
+
+	total, err := subtotal.Sub(discount)
+	if err != nil {
+		return err
 	}
+	if total, err = total.Add(tax); err != nil {
+		return err
+	}
+
+	i.Subtotal = subtotal
+	i.DiscountAmount = discount
+	i.TaxAmount = tax
+	i.Total = total
 	return nil
 }
 
 // IsPaid checks if the invoice is paid
 func (i *Invoice) IsPaid() bool {
-	return i.Status == "paid" && i.PaidAt != nil
+	return i.Status == InvoiceStatusPaid && i.PaidAt != nil
+}
+
+// IsOverdue checks if the invoice is overdue at the given time: it was
+// marked overdue, or it is open or sent and past its due date. Drafts, paid,
+// void and uncollectible invoices are never overdue.
+func (i *Invoice) IsOverdue(now time.Time) bool {
+	if i.Status == InvoiceStatusOverdue {
+		return true
+	}
+	_, ok := NextInvoiceStatus(i.Status, InvoiceEventMarkOverdue)
+	return ok && i.DueDate.Before(now)
 }
 
-// IsOverdue checks if the invoice is overdue
-func (i *Invoice) IsOverdue() bool {
-	return i.Status != "paid" && i.DueDate.Before(time.Now())
+// IsEditable checks if the invoice can still be edited; finalized invoices are locked
+func (i *Invoice) IsEditable() bool {
+	return i.Status == InvoiceStatusDraft
 }
 
 // TableName returns the table name for Invoice model
 func (Invoice) TableName() string {
 	return "invoices"
-}
\ No newline at end of file
+}
@@ -1,25 +1,37 @@
 package models
 
 import (
+	"go-backend/pkg/money"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// InvoiceItem is a line of an invoice. Its amount is before the line's
+// discount and tax; the invoice totals are derived from its items.
 type InvoiceItem struct {
-	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	InvoiceID   uuid.UUID `gorm:"type:uuid;not null;index" json:"invoice_id" validate:"required"`
-	Description string    `gorm:"not null" json:"description" validate:"required"`
-	Quantity    int       `gorm:"not null;default:1" json:"quantity" validate:"required,min=1"`
-	UnitPrice   float64   `gorm:"not null" json:"unit_price" validate:"required,min=0"`
-	Amount      float64   `gorm:"not null" json:"amount" validate:"required,min=0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	InvoiceID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"invoice_id" validate:"required"`
+	Position       int            `gorm:"not null;default:0" json:"position"` // order of the line on the invoice
+	Description    string         `gorm:"not null" json:"description" validate:"required"`
+	Quantity       int            `gorm:"not null;default:1" json:"quantity" validate:"required,min=1"`
+	UnitPrice      money.Money    `gorm:"not null" json:"unit_price" validate:"required"`
+	Amount         money.Money    `gorm:"not null" json:"amount" validate:"required"`
+	DiscountAmount money.Money    `gorm:"default:0" json:"discount_amount"`
+	TaxAmount      money.Money    `gorm:"default:0" json:"tax_amount"`
+	Currency       string         `gorm:"not null;default:USD" json:"currency"`
+	PeriodStart    *time.Time     `json:"period_start,omitempty"` // service period the line bills, when it bills one
+	PeriodEnd      *time.Time     `json:"period_end,omitempty"`
+	PlanID         *uuid.UUID     `gorm:"type:uuid;index" json:"plan_id,omitempty"`          // plan whose price the line bills
+	MeteredPriceID *uuid.UUID     `gorm:"type:uuid;index" json:"metered_price_id,omitempty"` // metered price of a usage line
+	Proration      bool           `gorm:"not null;default:false" json:"proration"`           // charges or credits part of a period
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
-	Invoice Invoice `gorm:"foreignKey:InvoiceID" json:"invoice,omitempty"`
+	Invoice Invoice `gorm:"foreignKey:InvoiceID" json:"-"`
 }
 
 // BeforeCreate hook to generate UUID and calculate amount if not provided
@@ -28,19 +40,50 @@ func (ii *InvoiceItem) BeforeCreate(tx *gorm.DB) error {
 		ii.ID = uuid.New()
 	}
 	// Calculate amount if not provided
-	if ii.Amount == 0 {
-		ii.Amount = float64(ii.Quantity) * ii.UnitPrice
+	if ii.Amount.IsZero() {
+		amount, err := ii.UnitPrice.Mul(int64(ii.Quantity))
+		if err != nil {
+			return err
+		}
+		ii.Amount = amount
 	}
 	return nil
 }
 
 // BeforeUpdate hook to recalculate amount when quantity or unit price changes
 func (ii *InvoiceItem) BeforeUpdate(tx *gorm.DB) error {
-	ii.Amount = float64(ii.Quantity) * ii.UnitPrice
+	amount, err := ii.UnitPrice.Mul(int64(ii.Quantity))
+	if err != nil {
+		return err
+	}
+	ii.Amount = amount
+	return nil
+}
+
+// BeforeSave keeps the currency column in step with the unit price
+func (ii *InvoiceItem) BeforeSave(tx *gorm.DB) error {
+	if code := ii.UnitPrice.Currency(); code != "" {
+		ii.Currency = code
+	}
+	ii.bindAmounts()
 	return nil
 }
 
+// AfterFind attaches the item currency to the amounts loaded from the database
+func (ii *InvoiceItem) AfterFind(tx *gorm.DB) error {
+	ii.bindAmounts()
+	return nil
+}
+
+// bindAmounts attaches the item currency to amounts that do not carry one yet
+func (ii *InvoiceItem) bindAmounts() {
+	ii.UnitPrice = ii.UnitPrice.Bind(ii.Currency)
+	ii.Amount = ii.Amount.Bind(ii.Currency)
+	ii.DiscountAmount = ii.DiscountAmount.Bind(ii.Currency)
+	ii.TaxAmount = ii.TaxAmount.Bind(ii.Currency)
+}
+
 // TableName returns the table name for InvoiceItem model
 func (InvoiceItem) TableName() string {
 	return "invoice_items"
-}
\ No newline at end of file
+}
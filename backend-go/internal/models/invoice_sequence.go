@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Placeholders of an invoice number template
+const (
+	InvoiceTemplatePrefix    = "{prefix}"
+	InvoiceTemplateYear      = "{yyyy}"
+	InvoiceTemplateShortYear = "{yy}"
+	InvoiceTemplateNumber    = "{number}" // the counter, zero-padded to the sequence's padding
+)
+
+// Defaults of the sequence invoices are numbered by when no other applies
+const (
+	DefaultInvoiceNumberPrefix   = "INV-"
+	DefaultInvoiceNumberTemplate = "{prefix}{yyyy}-{number}"
+	DefaultInvoiceNumberPadding  = 6
+)
+
+// InvoiceSequence numbers the invoices of the organizations assigned to it,
+// such as one organization or all organizations billed by one seller
+// entity. Organizations without a sequence use the default one. Numbers are
+// taken when an invoice is finalized, in the same transaction, so they are
+// consecutive without gaps.
+type InvoiceSequence struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name        string    `gorm:"not null" json:"name"`
+	Prefix      string    `gorm:"not null;uniqueIndex" json:"prefix"`
+	Template    string    `gorm:"not null" json:"template"`                   // e.g. {prefix}{yyyy}-{number}
+	Padding     int       `gorm:"not null;default:0" json:"padding"`          // minimum digits of the counter
+	ResetYearly bool      `gorm:"not null;default:false" json:"reset_yearly"` // restart the counter at 1 every year
+	IsDefault   bool      `gorm:"not null;default:false;uniqueIndex:idx_invoice_sequences_default,where:is_default" json:"is_default"`
+	NextNumber  int64     `gorm:"not null;default:1" json:"next_number"`
+	Year        int       `gorm:"not null;default:0" json:"year"` // year of the last number taken; 0 until the first
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relationships
+	Organizations []Organization `gorm:"foreignKey:InvoiceSequenceID" json:"organizations,omitempty"`
+}
+
+// DefaultInvoiceSequence returns the sequence created when invoices are
+// first finalized without one
+func DefaultInvoiceSequence() *InvoiceSequence {
+	return &InvoiceSequence{
+		Name:       "Default",
+		Prefix:     DefaultInvoiceNumberPrefix,
+		Template:   DefaultInvoiceNumberTemplate,
+		Padding:    DefaultInvoiceNumberPadding,
+		IsDefault:  true,
+		NextNumber: 1,
+	}
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (s *InvoiceSequence) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// Next takes the number of an invoice finalized at and advances the
+// counter. Numbers never go back in time: an invoice finalized in a year
+// before the sequence's last one is numbered in that last year.
+func (s *InvoiceSequence) Next(at time.Time) string {
+	year := at.Year()
+	if year < s.Year {
+		year = s.Year
+	}
+	if s.ResetYearly && s.Year != 0 && year > s.Year || s.NextNumber < 1 {
+		s.NextNumber = 1
+	}
+
+	number := s.Format(s.NextNumber, year)
+	s.NextNumber++
+	s.Year = year
+	return number
+}
+
+// Format renders the invoice number for counter value number in year
+func (s *InvoiceSequence) Format(number int64, year int) string {
+	return strings.NewReplacer(
+		InvoiceTemplatePrefix, s.Prefix,
+		InvoiceTemplateYear, fmt.Sprintf("%04d", year),
+		InvoiceTemplateShortYear, fmt.Sprintf("%02d", year%100),
+		InvoiceTemplateNumber, fmt.Sprintf("%0*d", s.Padding, number),
+	).Replace(s.Template)
+}
+
+// IsUsed checks if the sequence has numbered an invoice yet
+func (s *InvoiceSequence) IsUsed() bool {
+	return s.Year != 0
+}
+
+// TableName returns the table name for InvoiceSequence model
+func (InvoiceSequence) TableName() string {
+	return "invoice_sequences"
+}
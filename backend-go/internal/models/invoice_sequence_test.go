@@ -0,0 +1,119 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func finalizedIn(year int) time.Time {
+	return time.Date(year, time.June, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func TestInvoiceSequenceNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence InvoiceSequence
+		at       time.Time
+		want     string
+		next     int64
+		year     int
+	}{
+		{
+			"first number",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, NextNumber: 1},
+			finalizedIn(2027), "INV-2027-0001", 2, 2027,
+		},
+		{
+			"continues within the year",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, NextNumber: 42, Year: 2027},
+			finalizedIn(2027), "INV-2027-0042", 43, 2027,
+		},
+		{
+			"resets in a new year",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, ResetYearly: true, NextNumber: 42, Year: 2027},
+			finalizedIn(2028), "INV-2028-0001", 2, 2028,
+		},
+		{
+			"resets on the first instant of the year",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, ResetYearly: true, NextNumber: 42, Year: 2027},
+			time.Date(2028, time.January, 1, 0, 0, 0, 0, time.UTC), "INV-2028-0001", 2, 2028,
+		},
+		{
+			"does not reset on the last instant of the year",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, ResetYearly: true, NextNumber: 42, Year: 2027},
+			time.Date(2027, time.December, 31, 23, 59, 59, 999999999, time.UTC), "INV-2027-0042", 43, 2027,
+		},
+		{
+			"keeps counting across years without reset",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, NextNumber: 42, Year: 2027},
+			finalizedIn(2028), "INV-2028-0042", 43, 2028,
+		},
+		{
+			"first number of a resetting sequence starts at its counter",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, ResetYearly: true, NextNumber: 100},
+			finalizedIn(2027), "INV-2027-0100", 101, 2027,
+		},
+		{
+			// A clock behind the last number's year must not reuse an
+			// earlier year's numbers or restart the counter
+			"clock behind the last reset year",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, ResetYearly: true, NextNumber: 7, Year: 2028},
+			finalizedIn(2027), "INV-2028-0007", 8, 2028,
+		},
+		{
+			"invalid counter starts at 1",
+			InvoiceSequence{Prefix: "INV-", Template: DefaultInvoiceNumberTemplate, Padding: 4, NextNumber: 0, Year: 2027},
+			finalizedIn(2027), "INV-2027-0001", 2, 2027,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sequence := tt.sequence
+			if got := sequence.Next(tt.at); got != tt.want {
+				t.Errorf("Next = %q, want %q", got, tt.want)
+			}
+			if sequence.NextNumber != tt.next || sequence.Year != tt.year {
+				t.Errorf("sequence at %d in %d, want %d in %d", sequence.NextNumber, sequence.Year, tt.next, tt.year)
+			}
+		})
+	}
+}
+
+func TestInvoiceSequenceNextIsConsecutive(t *testing.T) {
+	sequence := InvoiceSequence{Prefix: "A", Template: "{prefix}{number}", ResetYearly: true, NextNumber: 1}
+	for _, want := range []string{"A1", "A2", "A3"} {
+		if got := sequence.Next(finalizedIn(2027)); got != want {
+			t.Fatalf("Next = %q, want %q", got, want)
+		}
+	}
+	if got := sequence.Next(finalizedIn(2028)); got != "A1" {
+		t.Errorf("Next in a new year = %q, want A1", got)
+	}
+}
+
+func TestInvoiceSequenceFormat(t *testing.T) {
+	tests := []struct {
+		template string
+		prefix   string
+		padding  int
+		number   int64
+		year     int
+		want     string
+	}{
+		{DefaultInvoiceNumberTemplate, DefaultInvoiceNumberPrefix, DefaultInvoiceNumberPadding, 17, 2027, "INV-2027-000017"},
+		{"{prefix}{yy}/{number}", "EU-", 3, 5, 2027, "EU-27/005"},
+		{"{prefix}{yy}/{number}", "EU-", 3, 5, 2005, "EU-05/005"},
+		{"{prefix}{number}", "X", 0, 123, 2027, "X123"},
+		{"{number}", "", 2, 12345, 2027, "12345"}, // padding is a minimum
+		{"{yyyy}{yy}{number}{prefix}", "P", 2, 1, 2027, "20272701P"},
+		{"{prefix}-{number}-{number}", "R", 2, 9, 2027, "R-09-09"},
+		{"INV {year} {num}", "P", 2, 1, 2027, "INV {year} {num}"}, // unknown placeholders stay as written
+		{"{yyyy}", "", 0, 1, 987, "0987"},
+	}
+	for _, tt := range tests {
+		sequence := InvoiceSequence{Prefix: tt.prefix, Template: tt.template, Padding: tt.padding}
+		if got := sequence.Format(tt.number, tt.year); got != tt.want {
+			t.Errorf("Format(%q, %d, %d) = %q, want %q", tt.template, tt.number, tt.year, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+// Invoice statuses. Only drafts can be edited; finalizing an invoice numbers
+// and locks it.
+const (
+	InvoiceStatusDraft         = "draft"
+	InvoiceStatusOpen          = "open" // finalized and waiting for payment
+	InvoiceStatusSent          = "sent" // finalized and sent to the organization
+	InvoiceStatusPaid          = "paid"
+	InvoiceStatusOverdue       = "overdue" // finalized and unpaid past its due date
+	InvoiceStatusVoid          = "void"
+	InvoiceStatusUncollectible = "uncollectible" // written off; can still be paid
+)
+
+// Invoice lifecycle events. Each event moves an invoice to a single status
+// and is only allowed from the statuses listed in invoiceTransitions.
+const (
+	InvoiceEventFinalize          = "finalize"           // draft -> open
+	InvoiceEventSend              = "send"               // open -> sent
+	InvoiceEventPay               = "pay"                // open, sent, overdue, uncollectible -> paid
+	InvoiceEventMarkOverdue       = "mark_overdue"       // open, sent -> overdue
+	InvoiceEventVoid              = "void"               // draft, open, sent, overdue, uncollectible -> void
+	InvoiceEventMarkUncollectible = "mark_uncollectible" // open, sent, overdue -> uncollectible
+)
+
+// invoiceTransitions maps each event to the statuses it may be applied in and
+// the status it leads to
+var invoiceTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	InvoiceEventFinalize:    {from: []string{InvoiceStatusDraft}, to: InvoiceStatusOpen},
+	InvoiceEventSend:        {from: []string{InvoiceStatusOpen}, to: InvoiceStatusSent},
+	InvoiceEventMarkOverdue: {from: []string{InvoiceStatusOpen, InvoiceStatusSent}, to: InvoiceStatusOverdue},
+	InvoiceEventPay: {
+		from: []string{InvoiceStatusOpen, InvoiceStatusSent, InvoiceStatusOverdue, InvoiceStatusUncollectible},
+		to:   InvoiceStatusPaid,
+	},
+	InvoiceEventVoid: {
+		from: []string{InvoiceStatusDraft, InvoiceStatusOpen, InvoiceStatusSent, InvoiceStatusOverdue, InvoiceStatusUncollectible},
+		to:   InvoiceStatusVoid,
+	},
+	InvoiceEventMarkUncollectible: {
+		from: []string{InvoiceStatusOpen, InvoiceStatusSent, InvoiceStatusOverdue},
+		to:   InvoiceStatusUncollectible,
+	},
+}
+
+// collectibleInvoiceStatuses are the statuses of invoices that can still be
+// collected. Drafts are finalized when they are first charged.
+var collectibleInvoiceStatuses = []string{InvoiceStatusDraft, InvoiceStatusOpen, InvoiceStatusSent, InvoiceStatusOverdue}
+
+// NextInvoiceStatus returns the status the event moves an invoice in status
+// from to, and whether the transition is allowed
+func NextInvoiceStatus(from, event string) (string, bool) {
+	transition, ok := invoiceTransitions[event]
+	if !ok || !containsStatus(transition.from, from) {
+		return "", false
+	}
+	return transition.to, true
+}
+
+// InvoiceStatusesAllowing returns the statuses an invoice may be in for the
+// event to apply, for bulk updates that cannot go through NextInvoiceStatus
+func InvoiceStatusesAllowing(event string) []string {
+	return append([]string(nil), invoiceTransitions[event].from...)
+}
+
+// CollectibleInvoiceStatuses returns the statuses of invoices that can still
+// be collected
+func CollectibleInvoiceStatuses() []string {
+	return append([]string(nil), collectibleInvoiceStatuses...)
+}
+
+// IsCollectibleInvoiceStatus checks if an invoice in status can still be collected
+func IsCollectibleInvoiceStatus(status string) bool {
+	return containsStatus(collectibleInvoiceStatuses, status)
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"go-backend/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Meter aggregations
+const (
+	MeterAggregationSum         = "sum"          // total of the value property
+	MeterAggregationCount       = "count"        // number of events
+	MeterAggregationMax         = "max"          // largest value property
+	MeterAggregationLast        = "last"         // value property of the latest event
+	MeterAggregationUniqueCount = "unique_count" // number of distinct value properties
+)
+
+// IsValidMeterAggregation reports whether aggregation is a known meter aggregation
+func IsValidMeterAggregation(aggregation string) bool {
+	switch aggregation {
+	case MeterAggregationSum, MeterAggregationCount, MeterAggregationMax, MeterAggregationLast, MeterAggregationUniqueCount:
+		return true
+	}
+	return false
+}
+
+// JSONMap is a JSON object stored in a jsonb column
+type JSONMap map[string]interface{}
+
+// Value encodes the map as JSON
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON object read from the database
+func (m *JSONMap) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*m = JSONMap{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("models: cannot scan JSON object")
+	}
+	return json.Unmarshal(data, m)
+}
+
+// GormDataType stores the map as jsonb
+func (JSONMap) GormDataType() string {
+	return "jsonb"
+}
+
+// Meter turns usage events into a billable quantity. Events with the meter's
+// event name whose properties contain all of Filter are aggregated over a
+// billing period, reading the number to aggregate from ValueProperty.
+type Meter struct {
+	ID            uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name          string         `gorm:"not null" json:"name"`
+	EventName     string         `gorm:"not null;index" json:"event_name"`
+	Aggregation   string         `gorm:"not null" json:"aggregation"` // sum, count, max, last, unique_count
+	ValueProperty string         `json:"value_property,omitempty"`    // event property to aggregate; unused by count
+	Filter        JSONMap        `gorm:"not null;default:'{}'" json:"filter"`
+	Description   string         `json:"description"`
+	IsActive      bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (m *Meter) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// Matches reports whether an event's properties contain the meter's filter
+func (m *Meter) Matches(properties map[string]interface{}) bool {
+	for key, want := range m.Filter {
+		got, ok := properties[key]
+		if !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// TableName returns the table name for Meter model
+func (Meter) TableName() string {
+	return "meters"
+}
+
+// UsageEvent is a single usage record reported for an organization. EventID
+// is chosen by the sender and makes ingestion idempotent; OccurredAt decides
+// the billing period the event counts towards, whenever it arrives.
+type UsageEvent struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_usage_events_org_event;index:idx_usage_events_lookup,priority:1" json:"organization_id"`
+	EventID        string    `gorm:"not null;uniqueIndex:idx_usage_events_org_event" json:"event_id"`
+	EventName      string    `gorm:"not null;index:idx_usage_events_lookup,priority:2" json:"event_name"`
+	OccurredAt     time.Time `gorm:"not null;index:idx_usage_events_lookup,priority:3" json:"timestamp"`
+	Properties     JSONMap   `gorm:"not null;default:'{}'" json:"properties"`
+	IngestedAt     time.Time `gorm:"not null" json:"ingested_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (e *UsageEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for UsageEvent model
+func (UsageEvent) TableName() string {
+	return "usage_events"
+}
+
+// MeteredPrice charges a plan's subscribers for the usage recorded by a
+// meter: UnitAmount for every PackageSize units above IncludedUnits
+type MeteredPrice struct {
+	ID            uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PlanID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"plan_id"`
+	MeterID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"meter_id"`
+	UnitAmount    money.Money    `gorm:"not null" json:"unit_amount"`
+	Currency      string         `gorm:"not null;default:USD" json:"currency"`
+	PackageSize   int64          `gorm:"not null;default:1" json:"package_size"`
+	IncludedUnits int64          `gorm:"not null;default:0" json:"included_units"`
+	Description   string         `json:"description"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Meter Meter `gorm:"foreignKey:MeterID" json:"meter,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (p *MeteredPrice) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave keeps the currency column in step with the unit amount
+func (p *MeteredPrice) BeforeSave(tx *gorm.DB) error {
+	if code := p.UnitAmount.Currency(); code != "" {
+		p.Currency = code
+	}
+	p.UnitAmount = p.UnitAmount.Bind(p.Currency)
+	return nil
+}
+
+// AfterFind attaches the currency to the unit amount loaded from the database
+func (p *MeteredPrice) AfterFind(tx *gorm.DB) error {
+	p.UnitAmount = p.UnitAmount.Bind(p.Currency)
+	return nil
+}
+
+// TableName returns the table name for MeteredPrice model
+func (MeteredPrice) TableName() string {
+	return "metered_prices"
+}
+
+// UsageSummary records the usage of one metered price billed for a closed
+// subscription period. Events for the period written after the summary was
+// last updated are billed as an adjustment when the next period closes.
+type UsageSummary struct {
+	ID             uuid.UUID   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubscriptionID uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_usage_summaries_period" json:"subscription_id"`
+	MeteredPriceID uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_usage_summaries_period" json:"metered_price_id"`
+	MeterID        uuid.UUID   `gorm:"type:uuid;not null" json:"meter_id"`
+	PeriodStart    time.Time   `gorm:"not null;uniqueIndex:idx_usage_summaries_period" json:"period_start"`
+	PeriodEnd      time.Time   `gorm:"not null" json:"period_end"`
+	Quantity       string      `gorm:"type:numeric;not null" json:"quantity"`
+	Amount         money.Money `gorm:"not null" json:"amount"`
+	Currency       string      `gorm:"not null;default:USD" json:"currency"`
+	InvoiceID      *uuid.UUID  `gorm:"type:uuid" json:"invoice_id,omitempty"` // last invoice that billed this usage
+	ClosedAt       time.Time   `gorm:"not null" json:"closed_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+
+	// Relationships
+	Meter        Meter        `gorm:"foreignKey:MeterID" json:"-"`
+	MeteredPrice MeteredPrice `gorm:"foreignKey:MeteredPriceID" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (s *UsageSummary) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave keeps the currency column in step with the amount
+func (s *UsageSummary) BeforeSave(tx *gorm.DB) error {
+	if code := s.Amount.Currency(); code != "" {
+		s.Currency = code
+	}
+	s.Amount = s.Amount.Bind(s.Currency)
+	return nil
+}
+
+// AfterFind attaches the currency to the amount loaded from the database
+func (s *UsageSummary) AfterFind(tx *gorm.DB) error {
+	s.Amount = s.Amount.Bind(s.Currency)
+	return nil
+}
+
+// TableName returns the table name for UsageSummary model
+func (UsageSummary) TableName() string {
+	return "usage_summaries"
+}
@@ -8,6 +8,8 @@ import (
 func AllModels() []interface{} {
 	return []interface{}{
 		&User{},
+		&TestClock{},
+		&InvoiceSequence{},
 		&Organization{},
 		&OrganizationMember{},
 		&Plan{},
@@ -16,6 +18,24 @@ func AllModels() []interface{} {
 		&Invoice{},
 		&InvoiceItem{},
 		&BillingAddress{},
+		&PlanMigration{},
+		&PlanMigrationItem{},
+		&ExchangeRate{},
+		&SubscriptionStatusChange{},
+		&SubscriptionSchedule{},
+		&SubscriptionSchedulePhase{},
+		&BillingRun{},
+		&BillingRunItem{},
+		&Meter{},
+		&UsageEvent{},
+		&MeteredPrice{},
+		&UsageSummary{},
+		&UsageAlert{},
+		&UsageAlertTrigger{},
+		&DunningCase{},
+		&PaymentAttempt{},
+		&RetentionOffer{},
+		&RetentionOfferPresentation{},
 	}
 }
 
@@ -48,4 +68,4 @@ func CreateIndexes(db *gorm.DB) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -16,6 +16,9 @@ type Organization struct {
 	Phone       string    `json:"phone"`
 	Email       string    `json:"email" validate:"omitempty,email"`
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	BillingAnchor BillingAnchor `gorm:"embedded;embeddedPrefix:billing_anchor_" json:"billing_anchor"` // default alignment of new subscriptions
+	TestClockID *uuid.UUID `gorm:"type:uuid;index" json:"test_clock_id,omitempty"` // sandbox organizations run on a test clock instead of real time
+	InvoiceSequenceID *uuid.UUID `gorm:"type:uuid;index" json:"invoice_sequence_id,omitempty"` // numbers its invoices; the default sequence when nil
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
@@ -37,12 +37,11 @@ func (pm *PaymentMethod) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// IsExpired checks if the payment method is expired (for cards)
-func (pm *PaymentMethod) IsExpired() bool {
+// IsExpired checks if the payment method is expired at the given time (for cards)
+func (pm *PaymentMethod) IsExpired(now time.Time) bool {
 	if pm.Type != "card" {
 		return false
 	}
-	now := time.Now()
 	return now.Year() > pm.ExpiryYear || (now.Year() == pm.ExpiryYear && int(now.Month()) > pm.ExpiryMonth)
 }
 
@@ -1,26 +1,31 @@
 package models
 
 import (
+	"go-backend/pkg/money"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// DefaultProduct is the product line of plans created without one
+const DefaultProduct = "default"
+
 type Plan struct {
-	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name" validate:"required"`
-	Slug        string    `gorm:"unique;not null" json:"slug" validate:"required"`
-	Description string    `json:"description"`
-	Price       float64   `gorm:"not null" json:"price" validate:"required,min=0"`
-	Currency    string    `gorm:"not null;default:USD" json:"currency"`
-	Interval    string    `gorm:"not null" json:"interval" validate:"required"` // monthly, yearly, weekly
-	Features    string    `gorm:"type:text" json:"features"` // JSON string of features
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
-	IsPopular   bool      `gorm:"default:false" json:"is_popular"`
-	TrialDays   int       `gorm:"default:0" json:"trial_days"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name        string         `gorm:"not null" json:"name" validate:"required"`
+	Slug        string         `gorm:"unique;not null" json:"slug" validate:"required"`
+	Description string         `json:"description"`
+	Product     string         `gorm:"not null;default:default;index" json:"product"` // product line; an organization holds at most one subscription per product
+	Price       money.Money    `gorm:"not null" json:"price" validate:"required"`
+	Currency    string         `gorm:"not null;default:USD" json:"currency"`
+	Interval    string         `gorm:"not null" json:"interval" validate:"required"` // monthly, yearly, weekly
+	Features    string         `gorm:"type:text" json:"features"`                    // JSON string of features
+	IsActive    bool           `gorm:"default:true" json:"is_active"`
+	IsPopular   bool           `gorm:"default:false" json:"is_popular"`
+	TrialDays   int            `gorm:"default:0" json:"trial_days"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
@@ -35,7 +40,22 @@ func (p *Plan) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// BeforeSave keeps the currency column in step with the price
+func (p *Plan) BeforeSave(tx *gorm.DB) error {
+	if code := p.Price.Currency(); code != "" {
+		p.Currency = code
+	}
+	p.Price = p.Price.Bind(p.Currency)
+	return nil
+}
+
+// AfterFind attaches the plan currency to the price loaded from the database
+func (p *Plan) AfterFind(tx *gorm.DB) error {
+	p.Price = p.Price.Bind(p.Currency)
+	return nil
+}
+
 // TableName returns the table name for Plan model
 func (Plan) TableName() string {
 	return "plans"
-}
\ No newline at end of file
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"go-backend/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Plan migration modes
+const (
+	PlanMigrationModeImmediate = "immediate"
+	PlanMigrationModeAtRenewal = "at_renewal"
+)
+
+// Plan migration statuses
+const (
+	PlanMigrationStatusPending   = "pending"
+	PlanMigrationStatusRunning   = "running"
+	PlanMigrationStatusCompleted = "completed"
+	PlanMigrationStatusCanceled  = "canceled"
+)
+
+// Plan migration item statuses
+const (
+	PlanMigrationItemPending   = "pending"
+	PlanMigrationItemMigrated  = "migrated"
+	PlanMigrationItemScheduled = "scheduled"
+	PlanMigrationItemSkipped   = "skipped"
+	PlanMigrationItemFailed    = "failed"
+)
+
+// PlanMigration is a background batch that moves every subscriber of a retired
+// plan onto a target plan
+type PlanMigration struct {
+	ID               uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SourcePlanID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"source_plan_id"`
+	TargetPlanID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"target_plan_id"`
+	Mode             string         `gorm:"not null" json:"mode"` // immediate, at_renewal
+	Prorate          bool           `gorm:"default:true" json:"prorate"`
+	DeactivateSource bool           `gorm:"default:true" json:"deactivate_source"`
+	Status           string         `gorm:"not null;default:pending;index" json:"status"` // pending, running, completed, canceled
+	TotalCount       int            `gorm:"default:0" json:"total_count"`
+	MigratedCount    int            `gorm:"default:0" json:"migrated_count"`
+	ScheduledCount   int            `gorm:"default:0" json:"scheduled_count"`
+	SkippedCount     int            `gorm:"default:0" json:"skipped_count"`
+	FailedCount      int            `gorm:"default:0" json:"failed_count"`
+	CreatedBy        *uuid.UUID     `gorm:"type:uuid" json:"created_by"`
+	StartedAt        *time.Time     `json:"started_at"`
+	CompletedAt      *time.Time     `json:"completed_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	SourcePlan Plan                `gorm:"foreignKey:SourcePlanID" json:"source_plan,omitempty"`
+	TargetPlan Plan                `gorm:"foreignKey:TargetPlanID" json:"target_plan,omitempty"`
+	Items      []PlanMigrationItem `gorm:"foreignKey:MigrationID" json:"items,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (pm *PlanMigration) BeforeCreate(tx *gorm.DB) error {
+	if pm.ID == uuid.Nil {
+		pm.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsFinished checks if the migration has no more work to do
+func (pm *PlanMigration) IsFinished() bool {
+	return pm.Status == PlanMigrationStatusCompleted || pm.Status == PlanMigrationStatusCanceled
+}
+
+// TableName returns the table name for PlanMigration model
+func (PlanMigration) TableName() string {
+	return "plan_migrations"
+}
+
+// PlanMigrationItem records the outcome of migrating a single subscription
+type PlanMigrationItem struct {
+	ID             uuid.UUID   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MigrationID    uuid.UUID   `gorm:"type:uuid;not null;index;uniqueIndex:idx_plan_migration_items_subscription" json:"migration_id"`
+	SubscriptionID uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_plan_migration_items_subscription" json:"subscription_id"`
+	OrganizationID uuid.UUID   `gorm:"type:uuid;not null" json:"organization_id"`
+	Status         string      `gorm:"not null;default:pending;index" json:"status"` // pending, migrated, scheduled, skipped, failed
+	CreditAmount   money.Money `gorm:"default:0" json:"credit_amount"`
+	ChargeAmount   money.Money `gorm:"default:0" json:"charge_amount"`
+	Currency       string      `gorm:"not null;default:USD" json:"currency"`
+	InvoiceID      *uuid.UUID  `gorm:"type:uuid" json:"invoice_id"`
+	EffectiveAt    *time.Time  `json:"effective_at"`
+	Message        string      `gorm:"type:text" json:"message,omitempty"` // skip reason or failure error
+	ProcessedAt    *time.Time  `json:"processed_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (pmi *PlanMigrationItem) BeforeCreate(tx *gorm.DB) error {
+	if pmi.ID == uuid.Nil {
+		pmi.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave binds the proration amounts to the item currency
+func (pmi *PlanMigrationItem) BeforeSave(tx *gorm.DB) error {
+	return pmi.AfterFind(tx)
+}
+
+// AfterFind attaches the item currency to the proration amounts
+func (pmi *PlanMigrationItem) AfterFind(tx *gorm.DB) error {
+	pmi.CreditAmount = pmi.CreditAmount.Bind(pmi.Currency)
+	pmi.ChargeAmount = pmi.ChargeAmount.Bind(pmi.Currency)
+	return nil
+}
+
+// TableName returns the table name for PlanMigrationItem model
+func (PlanMigrationItem) TableName() string {
+	return "plan_migration_items"
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cancellation reasons a customer can give
+const (
+	CancellationReasonTooExpensive    = "too_expensive"
+	CancellationReasonMissingFeatures = "missing_features"
+	CancellationReasonSwitchedService = "switched_service"
+	CancellationReasonUnused          = "unused"
+	CancellationReasonCustomerService = "customer_service"
+	CancellationReasonTechnicalIssues = "technical_issues"
+	CancellationReasonTooComplex      = "too_complex"
+	CancellationReasonOther           = "other"
+)
+
+// CancellationReasonNonPayment is recorded when dunning cancels a
+// subscription; customers cannot give it
+const CancellationReasonNonPayment = "non_payment"
+
+// IsValidCancellationReason reports whether reason is one a customer can give
+func IsValidCancellationReason(reason string) bool {
+	switch reason {
+	case CancellationReasonTooExpensive, CancellationReasonMissingFeatures, CancellationReasonSwitchedService,
+		CancellationReasonUnused, CancellationReasonCustomerService, CancellationReasonTechnicalIssues,
+		CancellationReasonTooComplex, CancellationReasonOther:
+		return true
+	}
+	return false
+}
+
+// Retention offer types
+const (
+	RetentionOfferTypeDiscount  = "discount"  // percentage off the invoices of the next months
+	RetentionOfferTypeDowngrade = "downgrade" // switch to a cheaper plan of the same product at renewal
+)
+
+// Outcomes of a retention offer presented to a customer
+const (
+	RetentionOfferPending  = "pending"
+	RetentionOfferAccepted = "accepted"
+	RetentionOfferDeclined = "declined"
+)
+
+// CancellationReasons is a list of cancellation reasons stored in a jsonb column
+type CancellationReasons []string
+
+// Value encodes the list as JSON
+func (r CancellationReasons) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON list read from the database
+func (r *CancellationReasons) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*r = CancellationReasons{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("models: cannot scan cancellation reasons")
+	}
+	return json.Unmarshal(data, r)
+}
+
+// GormDataType stores the list as jsonb
+func (CancellationReasons) GormDataType() string {
+	return "jsonb"
+}
+
+// RetentionOffer is shown to a customer who is about to cancel, who can
+// accept it instead of canceling. An offer can be limited to one product and
+// to some cancellation reasons.
+type RetentionOffer struct {
+	ID             uuid.UUID           `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name           string              `gorm:"not null" json:"name"`
+	Description    string              `gorm:"type:text" json:"description"`
+	Type           string              `gorm:"not null" json:"type"`                       // discount or downgrade
+	PercentOff     int                 `gorm:"default:0" json:"percent_off,omitempty"`     // discount offers only
+	DurationMonths int                 `gorm:"default:0" json:"duration_months,omitempty"` // discount offers only
+	PlanID         *uuid.UUID          `gorm:"type:uuid;index" json:"plan_id,omitempty"`   // downgrade offers: plan to switch to
+	Product        string              `json:"product,omitempty"`                          // only subscriptions to this product; empty for all
+	Reasons        CancellationReasons `gorm:"not null;default:'[]'" json:"reasons"`       // only these cancellation reasons; empty for all
+	IsActive       bool                `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt      `gorm:"index" json:"-"`
+
+	// Relationships
+	Plan *Plan `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+}
+
+// AppliesTo checks if the offer targets subscriptions to product canceled for reason
+func (o *RetentionOffer) AppliesTo(product, reason string) bool {
+	if o.Product != "" && o.Product != product {
+		return false
+	}
+	if len(o.Reasons) == 0 {
+		return true
+	}
+	for _, r := range o.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (o *RetentionOffer) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for RetentionOffer model
+func (RetentionOffer) TableName() string {
+	return "retention_offers"
+}
+
+// RetentionOfferPresentation records a retention offer shown to a customer
+// canceling a subscription, and whether they took it. It stays pending until
+// the customer accepts an offer or cancels anyway.
+type RetentionOfferPresentation struct {
+	ID               uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	RetentionOfferID uuid.UUID  `gorm:"type:uuid;not null;index" json:"retention_offer_id"`
+	SubscriptionID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	OrganizationID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Reason           string     `json:"reason"`                                  // cancellation reason given when the offer was shown
+	Outcome          string     `gorm:"not null;default:pending" json:"outcome"` // pending, accepted or declined
+	PresentedAt      time.Time  `gorm:"not null;index" json:"presented_at"`
+	RespondedAt      *time.Time `json:"responded_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	// Relationships
+	RetentionOffer RetentionOffer `gorm:"foreignKey:RetentionOfferID" json:"retention_offer,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (p *RetentionOfferPresentation) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for RetentionOfferPresentation model
+func (RetentionOfferPresentation) TableName() string {
+	return "retention_offer_presentations"
+}
@@ -3,25 +3,46 @@ package models
 import (
 	"time"
 
+	"go-backend/pkg/money"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Subscription struct {
-	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id" validate:"required"`
-	PlanID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"plan_id" validate:"required"`
-	Status         string     `gorm:"not null;default:active" json:"status"` // active, canceled, expired, trial
-	StartDate      time.Time  `gorm:"not null" json:"start_date" validate:"required"`
-	EndDate        *time.Time `json:"end_date"`
-	TrialEndDate   *time.Time `json:"trial_end_date"`
-	CanceledAt     *time.Time `json:"canceled_at"`
-	CurrentPeriodStart time.Time `gorm:"not null" json:"current_period_start"`
-	CurrentPeriodEnd   time.Time `gorm:"not null" json:"current_period_end"`
-	AutoRenew      bool       `gorm:"default:true" json:"auto_renew"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID     uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_subscriptions_open_product,priority:1" json:"organization_id" validate:"required"`
+	PlanID             uuid.UUID      `gorm:"type:uuid;not null;index" json:"plan_id" validate:"required"`
+	Product            string         `gorm:"not null;default:default;uniqueIndex:idx_subscriptions_open_product,priority:2,where:status <> 'canceled' AND status <> 'expired' AND deleted_at IS NULL" json:"product"` // product of the plan; an organization holds one open subscription per product
+	Status             string         `gorm:"not null;default:active" json:"status"`                                                                                                                                   // incomplete, trialing, active, past_due, suspended, paused, canceled, expired
+	StartDate          time.Time      `gorm:"not null" json:"start_date" validate:"required"`
+	EndDate            *time.Time     `json:"end_date"`
+	TrialEndDate       *time.Time     `json:"trial_end_date"`
+	CanceledAt         *time.Time     `json:"canceled_at"`
+	CurrentPeriodStart time.Time      `gorm:"not null" json:"current_period_start"`
+	CurrentPeriodEnd   time.Time      `gorm:"not null" json:"current_period_end"`
+	BillingAnchor      BillingAnchor  `gorm:"embedded;embeddedPrefix:billing_anchor_" json:"billing_anchor"` // calendar alignment of periods; unset periods follow the start date
+	AutoRenew          bool           `gorm:"default:true" json:"auto_renew"`
+	CancelAtPeriodEnd  bool           `gorm:"default:false" json:"cancel_at_period_end"` // canceled by the customer, ends when the current period does
+	Quantity           int            `gorm:"not null;default:1" json:"quantity"`
+	ScheduledQuantity  *int           `json:"scheduled_quantity"`              // quantity to switch to at next renewal
+	SyncSeats          bool           `gorm:"default:false" json:"sync_seats"` // quantity follows the organization's active member count
+	Coupon             string         `json:"coupon,omitempty"`
+	PercentOff         int            `gorm:"default:0" json:"percent_off,omitempty"`   // discount applied to each period's invoice
+	DiscountEndsAt     *time.Time     `json:"discount_ends_at,omitempty"`               // periods starting from this date are not discounted
+	CreditBalance      money.Money    `gorm:"not null;default:0" json:"credit_balance"` // credit left by mid-period changes, taken off the next invoices
+	CreditCurrency     string         `json:"credit_currency,omitempty"`
+	ScheduledPlanID    *uuid.UUID     `gorm:"type:uuid" json:"scheduled_plan_id"` // plan to switch to at next renewal
+	PausedAt           *time.Time     `json:"paused_at"`
+	ResumeAt           *time.Time     `gorm:"index" json:"resume_at"`   // automatic resume date of a paused subscription
+	PauseBehavior      string         `json:"pause_behavior,omitempty"` // void, keep_as_draft, mark_uncollectible
+	ResumePolicy       string         `json:"resume_policy,omitempty"`  // shift, reset
+	TrialReminderAt    *time.Time     `json:"trial_reminder_at"`        // when the trial-ending reminder was sent
+	CancellationReason string         `json:"cancellation_reason,omitempty"`
+	CancellationNote   string         `gorm:"type:text" json:"cancellation_note,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
 	Organization Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -37,17 +58,58 @@ func (s *Subscription) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// IsActive checks if the subscription is currently active
-func (s *Subscription) IsActive() bool {
-	return s.Status == "active" && (s.EndDate == nil || s.EndDate.After(time.Now()))
+// BeforeSave records the currency of the credit balance next to it
+func (s *Subscription) BeforeSave(tx *gorm.DB) error {
+	if currency := s.CreditBalance.Currency(); currency != "" {
+		s.CreditCurrency = currency
+	}
+	return s.AfterFind(tx)
+}
+
+// AfterFind attaches the credit currency to the credit balance
+func (s *Subscription) AfterFind(tx *gorm.DB) error {
+	s.CreditBalance = s.CreditBalance.Bind(s.CreditCurrency)
+	return nil
+}
+
+// IsActive checks if the subscription grants access at the given time; trials
+// and subscriptions whose payment is being retried are entitled like paid ones
+func (s *Subscription) IsActive(now time.Time) bool {
+	entitled := s.Status == SubscriptionStatusActive || s.Status == SubscriptionStatusTrialing || s.Status == SubscriptionStatusPastDue
+	return entitled && (s.EndDate == nil || s.EndDate.After(now))
+}
+
+// WillRenew checks if the subscription continues into another period when the current one ends
+func (s *Subscription) WillRenew() bool {
+	return s.AutoRenew && !s.CancelAtPeriodEnd
 }
 
-// IsInTrial checks if the subscription is in trial period
-func (s *Subscription) IsInTrial() bool {
-	return s.TrialEndDate != nil && s.TrialEndDate.After(time.Now())
+// IsPaused checks if the subscription is paused
+func (s *Subscription) IsPaused() bool {
+	return s.Status == SubscriptionStatusPaused
+}
+
+// IsInTrial checks if the subscription is in trial period at the given time
+func (s *Subscription) IsInTrial(now time.Time) bool {
+	return s.TrialEndDate != nil && s.TrialEndDate.After(now)
+}
+
+// DiscountApplies checks if the subscription's coupon applies to the current
+// period; a time-limited discount does not apply to periods starting once it
+// has ended
+func (s *Subscription) DiscountApplies() bool {
+	return s.DiscountEndsAt == nil || s.CurrentPeriodStart.Before(*s.DiscountEndsAt)
+}
+
+// PeriodPercentOff returns the discount applied to the current period
+func (s *Subscription) PeriodPercentOff() int {
+	if !s.DiscountApplies() {
+		return 0
+	}
+	return s.PercentOff
 }
 
 // TableName returns the table name for Subscription model
 func (Subscription) TableName() string {
 	return "subscriptions"
-}
\ No newline at end of file
+}
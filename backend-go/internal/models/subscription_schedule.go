@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Subscription schedule statuses
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusReleased  = "released"
+	ScheduleStatusCanceled  = "canceled"
+)
+
+// What happens to the subscription when the last phase of a schedule ends
+const (
+	ScheduleEndRelease = "release" // the subscription continues on the last phase's terms
+	ScheduleEndCancel  = "cancel"  // the subscription is canceled
+)
+
+// SubscriptionSchedule is an ordered list of phases a subscription moves
+// through automatically, e.g. three discounted months followed by a yearly plan
+type SubscriptionSchedule struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubscriptionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	Status         string         `gorm:"not null;default:active;index" json:"status"`  // active, completed, released, canceled
+	EndBehavior    string         `gorm:"not null;default:release" json:"end_behavior"` // release, cancel
+	CurrentPhase   int            `gorm:"not null;default:0" json:"current_phase"`      // position of the phase in effect
+	ReleasedAt     *time.Time     `json:"released_at"`
+	CompletedAt    *time.Time     `json:"completed_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Subscription Subscription                `gorm:"foreignKey:SubscriptionID" json:"-"`
+	Phases       []SubscriptionSchedulePhase `gorm:"foreignKey:ScheduleID" json:"phases"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (s *SubscriptionSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// Phase returns the phase at position, or nil when there is none
+func (s *SubscriptionSchedule) Phase(position int) *SubscriptionSchedulePhase {
+	for i := range s.Phases {
+		if s.Phases[i].Position == position {
+			return &s.Phases[i]
+		}
+	}
+	return nil
+}
+
+// IsActive checks if the schedule still controls its subscription
+func (s *SubscriptionSchedule) IsActive() bool {
+	return s.Status == ScheduleStatusActive
+}
+
+// TableName returns the table name for SubscriptionSchedule model
+func (SubscriptionSchedule) TableName() string {
+	return "subscription_schedules"
+}
+
+// SubscriptionSchedulePhase is one step of a schedule: the plan, quantity and
+// discount a subscription is billed at between StartDate and EndDate
+type SubscriptionSchedulePhase struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ScheduleID uuid.UUID  `gorm:"type:uuid;not null;index" json:"schedule_id"`
+	Position   int        `gorm:"not null" json:"position"`
+	PlanID     uuid.UUID  `gorm:"type:uuid;not null" json:"plan_id"`
+	Quantity   int        `gorm:"not null;default:1" json:"quantity"`
+	Iterations int        `gorm:"default:0" json:"iterations,omitempty"` // number of billing periods, when the end is not a fixed date
+	StartDate  time.Time  `gorm:"not null" json:"start_date"`
+	EndDate    *time.Time `json:"end_date"` // nil for an open-ended last phase
+	Coupon     string     `json:"coupon,omitempty"`
+	PercentOff int        `gorm:"default:0" json:"percent_off,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Relationships
+	Plan Plan `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (p *SubscriptionSchedulePhase) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for SubscriptionSchedulePhase model
+func (SubscriptionSchedulePhase) TableName() string {
+	return "subscription_schedule_phases"
+}
@@ -0,0 +1,110 @@
+package models
+
+// Subscription statuses
+const (
+	SubscriptionStatusIncomplete = "incomplete" // created, waiting for the first payment
+	SubscriptionStatusTrialing   = "trialing"
+	SubscriptionStatusActive     = "active"
+	SubscriptionStatusPastDue    = "past_due"  // a payment failed and is being retried
+	SubscriptionStatusSuspended  = "suspended" // every payment retry failed; access is withheld until the invoice is paid
+	SubscriptionStatusPaused     = "paused"
+	SubscriptionStatusCanceled   = "canceled"
+	SubscriptionStatusExpired    = "expired"
+)
+
+// Subscription lifecycle events. Each event moves a subscription to a single
+// status and is only allowed from the statuses listed in
+// subscriptionTransitions.
+const (
+	SubscriptionEventCreate            = "create"             // none -> incomplete, trialing or active
+	SubscriptionEventActivate          = "activate"           // incomplete -> active
+	SubscriptionEventTrialConverted    = "trial_converted"    // trialing -> active
+	SubscriptionEventTrialExpired      = "trial_expired"      // trialing -> expired
+	SubscriptionEventTrialCanceled     = "trial_canceled"     // trialing -> canceled
+	SubscriptionEventPaymentFailed     = "payment_failed"     // active -> past_due
+	SubscriptionEventPaymentRecovered  = "payment_recovered"  // past_due, suspended -> active
+	SubscriptionEventSuspend           = "suspend"            // past_due -> suspended
+	SubscriptionEventPause             = "pause"              // active -> paused
+	SubscriptionEventResume            = "resume"             // paused -> active
+	SubscriptionEventAutoResume        = "auto_resume"        // paused -> active
+	SubscriptionEventCancel            = "cancel"             // any live status -> canceled
+	SubscriptionEventPeriodEndCancel   = "period_end_cancel"  // active, past_due, suspended -> canceled
+	SubscriptionEventScheduleCompleted = "schedule_completed" // active, past_due -> canceled
+	SubscriptionEventExpire            = "expire"             // active, past_due, suspended, incomplete -> expired
+	SubscriptionEventReactivate        = "reactivate"         // canceled, expired -> active
+	SubscriptionEventReactivateTrial   = "reactivate_trial"   // canceled, expired -> trialing
+)
+
+// subscriptionTransitions maps each event to the statuses it may be applied
+// in and the status it leads to
+var subscriptionTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	SubscriptionEventActivate:          {from: []string{SubscriptionStatusIncomplete}, to: SubscriptionStatusActive},
+	SubscriptionEventTrialConverted:    {from: []string{SubscriptionStatusTrialing}, to: SubscriptionStatusActive},
+	SubscriptionEventTrialExpired:      {from: []string{SubscriptionStatusTrialing}, to: SubscriptionStatusExpired},
+	SubscriptionEventTrialCanceled:     {from: []string{SubscriptionStatusTrialing}, to: SubscriptionStatusCanceled},
+	SubscriptionEventPaymentFailed:     {from: []string{SubscriptionStatusActive}, to: SubscriptionStatusPastDue},
+	SubscriptionEventPaymentRecovered:  {from: []string{SubscriptionStatusPastDue, SubscriptionStatusSuspended}, to: SubscriptionStatusActive},
+	SubscriptionEventSuspend:           {from: []string{SubscriptionStatusPastDue}, to: SubscriptionStatusSuspended},
+	SubscriptionEventPause:             {from: []string{SubscriptionStatusActive}, to: SubscriptionStatusPaused},
+	SubscriptionEventResume:            {from: []string{SubscriptionStatusPaused}, to: SubscriptionStatusActive},
+	SubscriptionEventAutoResume:        {from: []string{SubscriptionStatusPaused}, to: SubscriptionStatusActive},
+	SubscriptionEventPeriodEndCancel:   {from: []string{SubscriptionStatusActive, SubscriptionStatusPastDue, SubscriptionStatusSuspended}, to: SubscriptionStatusCanceled},
+	SubscriptionEventScheduleCompleted: {from: []string{SubscriptionStatusActive, SubscriptionStatusPastDue}, to: SubscriptionStatusCanceled},
+	SubscriptionEventCancel: {
+		from: []string{SubscriptionStatusIncomplete, SubscriptionStatusTrialing, SubscriptionStatusActive, SubscriptionStatusPastDue, SubscriptionStatusSuspended, SubscriptionStatusPaused},
+		to:   SubscriptionStatusCanceled,
+	},
+	SubscriptionEventExpire: {
+		from: []string{SubscriptionStatusIncomplete, SubscriptionStatusActive, SubscriptionStatusPastDue, SubscriptionStatusSuspended},
+		to:   SubscriptionStatusExpired,
+	},
+	SubscriptionEventReactivate:      {from: []string{SubscriptionStatusCanceled, SubscriptionStatusExpired}, to: SubscriptionStatusActive},
+	SubscriptionEventReactivateTrial: {from: []string{SubscriptionStatusCanceled, SubscriptionStatusExpired}, to: SubscriptionStatusTrialing},
+}
+
+// initialSubscriptionStatuses are the statuses a subscription may be created in
+var initialSubscriptionStatuses = []string{SubscriptionStatusIncomplete, SubscriptionStatusTrialing, SubscriptionStatusActive}
+
+// IsInitialSubscriptionStatus checks if a subscription may be created in status
+func IsInitialSubscriptionStatus(status string) bool {
+	return containsStatus(initialSubscriptionStatuses, status)
+}
+
+// NextSubscriptionStatus returns the status the event moves a subscription in
+// status from to, and whether the transition is allowed
+func NextSubscriptionStatus(from, event string) (string, bool) {
+	transition, ok := subscriptionTransitions[event]
+	if !ok || !containsStatus(transition.from, from) {
+		return "", false
+	}
+	return transition.to, true
+}
+
+// IsValidSubscriptionStatus checks if status is a known subscription status
+func IsValidSubscriptionStatus(status string) bool {
+	switch status {
+	case SubscriptionStatusIncomplete, SubscriptionStatusTrialing, SubscriptionStatusActive,
+		SubscriptionStatusPastDue, SubscriptionStatusSuspended, SubscriptionStatusPaused, SubscriptionStatusCanceled, SubscriptionStatusExpired:
+		return true
+	}
+	return false
+}
+
+// IsTerminalSubscriptionStatus checks if a subscription in status has ended;
+// only reactivation within the reactivation window moves it out
+func IsTerminalSubscriptionStatus(status string) bool {
+	return status == SubscriptionStatusCanceled || status == SubscriptionStatusExpired
+}
+
+// containsStatus checks if status is one of statuses
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
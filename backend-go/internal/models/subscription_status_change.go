@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubscriptionStatusChange records a status transition of a subscription
+type SubscriptionStatusChange struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	FromStatus     string    `gorm:"not null" json:"from_status"`
+	ToStatus       string    `gorm:"not null" json:"to_status"`
+	Event          string    `gorm:"not null" json:"event"` // what caused the transition, e.g. pause, resume
+	Reason         string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (c *SubscriptionStatusChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for SubscriptionStatusChange model
+func (SubscriptionStatusChange) TableName() string {
+	return "subscription_status_history"
+}
@@ -0,0 +1,104 @@
+package models
+
+import "testing"
+
+func TestNextSubscriptionStatus(t *testing.T) {
+	statuses := []string{
+		SubscriptionStatusIncomplete,
+		SubscriptionStatusTrialing,
+		SubscriptionStatusActive,
+		SubscriptionStatusPastDue,
+		SubscriptionStatusSuspended,
+		SubscriptionStatusPaused,
+		SubscriptionStatusCanceled,
+		SubscriptionStatusExpired,
+	}
+
+	// Every allowed transition, by event and status it is applied in; every
+	// other pair must be rejected
+	allowed := map[string]map[string]string{
+		SubscriptionEventActivate: {
+			SubscriptionStatusIncomplete: SubscriptionStatusActive,
+		},
+		SubscriptionEventTrialConverted: {
+			SubscriptionStatusTrialing: SubscriptionStatusActive,
+		},
+		SubscriptionEventTrialExpired: {
+			SubscriptionStatusTrialing: SubscriptionStatusExpired,
+		},
+		SubscriptionEventTrialCanceled: {
+			SubscriptionStatusTrialing: SubscriptionStatusCanceled,
+		},
+		SubscriptionEventPaymentFailed: {
+			SubscriptionStatusActive: SubscriptionStatusPastDue,
+		},
+		SubscriptionEventPaymentRecovered: {
+			SubscriptionStatusPastDue:   SubscriptionStatusActive,
+			SubscriptionStatusSuspended: SubscriptionStatusActive,
+		},
+		SubscriptionEventSuspend: {
+			SubscriptionStatusPastDue: SubscriptionStatusSuspended,
+		},
+		SubscriptionEventPause: {
+			SubscriptionStatusActive: SubscriptionStatusPaused,
+		},
+		SubscriptionEventResume: {
+			SubscriptionStatusPaused: SubscriptionStatusActive,
+		},
+		SubscriptionEventAutoResume: {
+			SubscriptionStatusPaused: SubscriptionStatusActive,
+		},
+		SubscriptionEventCancel: {
+			SubscriptionStatusIncomplete: SubscriptionStatusCanceled,
+			SubscriptionStatusTrialing:   SubscriptionStatusCanceled,
+			SubscriptionStatusActive:     SubscriptionStatusCanceled,
+			SubscriptionStatusPastDue:    SubscriptionStatusCanceled,
+			SubscriptionStatusSuspended:  SubscriptionStatusCanceled,
+			SubscriptionStatusPaused:     SubscriptionStatusCanceled,
+		},
+		SubscriptionEventPeriodEndCancel: {
+			SubscriptionStatusActive:    SubscriptionStatusCanceled,
+			SubscriptionStatusPastDue:   SubscriptionStatusCanceled,
+			SubscriptionStatusSuspended: SubscriptionStatusCanceled,
+		},
+		SubscriptionEventScheduleCompleted: {
+			SubscriptionStatusActive:  SubscriptionStatusCanceled,
+			SubscriptionStatusPastDue: SubscriptionStatusCanceled,
+		},
+		SubscriptionEventExpire: {
+			SubscriptionStatusIncomplete: SubscriptionStatusExpired,
+			SubscriptionStatusActive:     SubscriptionStatusExpired,
+			SubscriptionStatusPastDue:    SubscriptionStatusExpired,
+			SubscriptionStatusSuspended:  SubscriptionStatusExpired,
+		},
+		SubscriptionEventReactivate: {
+			SubscriptionStatusCanceled: SubscriptionStatusActive,
+			SubscriptionStatusExpired:  SubscriptionStatusActive,
+		},
+		SubscriptionEventReactivateTrial: {
+			SubscriptionStatusCanceled: SubscriptionStatusTrialing,
+			SubscriptionStatusExpired:  SubscriptionStatusTrialing,
+		},
+		// Creation is not a transition out of any status
+		SubscriptionEventCreate: {},
+		"unknown":               {},
+	}
+
+	for event, next := range allowed {
+		for _, from := range statuses {
+			t.Run(event+" from "+from, func(t *testing.T) {
+				want, wantOK := next[from]
+				got, ok := NextSubscriptionStatus(from, event)
+				if ok != wantOK || got != want {
+					t.Errorf("NextSubscriptionStatus(%q, %q) = %q, %v; want %q, %v", from, event, got, ok, want, wantOK)
+				}
+			})
+		}
+	}
+
+	for event := range subscriptionTransitions {
+		if _, ok := allowed[event]; !ok {
+			t.Errorf("event %q is not covered", event)
+		}
+	}
+}
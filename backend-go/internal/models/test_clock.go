@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Test clock statuses
+const (
+	TestClockStatusReady     = "ready"
+	TestClockStatusAdvancing = "advancing"
+)
+
+// TestClock is a simulated clock for sandbox organizations. Time stands still
+// at FrozenTime for every organization attached to the clock until the clock
+// is advanced, which runs the billing jobs those organizations would have
+// seen in real time.
+type TestClock struct {
+	ID         uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name       string         `gorm:"not null" json:"name"`
+	FrozenTime time.Time      `gorm:"not null" json:"frozen_time"`
+	Status     string         `gorm:"not null;default:ready" json:"status"` // ready, advancing
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Organizations []Organization `gorm:"foreignKey:TestClockID" json:"organizations,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (c *TestClock) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for TestClock model
+func (TestClock) TableName() string {
+	return "test_clocks"
+}
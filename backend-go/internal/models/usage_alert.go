@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Usage alert types
+const (
+	UsageAlertTypeUsage = "usage" // quantity a meter records in the billing period
+	UsageAlertTypeSpend = "spend" // projected invoice amount of the billing period
+)
+
+// IsValidUsageAlertType reports whether alertType is a known usage alert type
+func IsValidUsageAlertType(alertType string) bool {
+	switch alertType {
+	case UsageAlertTypeUsage, UsageAlertTypeSpend:
+		return true
+	}
+	return false
+}
+
+// Percentages is a list of whole percentages stored in a jsonb column
+type Percentages []int
+
+// Value encodes the list as JSON
+func (p Percentages) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON list read from the database
+func (p *Percentages) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*p = Percentages{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("models: cannot scan percentages")
+	}
+	return json.Unmarshal(data, p)
+}
+
+// GormDataType stores the list as jsonb
+func (Percentages) GormDataType() string {
+	return "jsonb"
+}
+
+// UsageAlert warns an organization as its usage or spend in a billing period
+// approaches a limit. Each threshold is a percentage of Limit, which is a
+// meter quantity for usage alerts and an amount in Currency for spend
+// alerts. A hard-capped alert also reports the limit as reached once usage
+// or spend hits it, so entitlement checks can turn usage away.
+type UsageAlert struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Name           string         `gorm:"not null" json:"name"`
+	Type           string         `gorm:"not null" json:"type"`                                  // usage or spend
+	MeterID        *uuid.UUID     `gorm:"type:uuid;index" json:"meter_id,omitempty"`             // usage alerts only
+	Limit          string         `gorm:"column:limit_value;type:numeric;not null" json:"limit"` // decimal quantity or amount
+	Currency       string         `json:"currency,omitempty"`                                    // spend alerts only
+	Thresholds     Percentages    `gorm:"not null;default:'[]'" json:"thresholds"`               // ascending percentages of Limit
+	HardCap        bool           `gorm:"not null;default:false" json:"hard_cap"`
+	IsActive       bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Organization Organization `gorm:"foreignKey:OrganizationID" json:"-"`
+	Meter        *Meter       `gorm:"foreignKey:MeterID" json:"meter,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (a *UsageAlert) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for UsageAlert model
+func (UsageAlert) TableName() string {
+	return "usage_alerts"
+}
+
+// UsageAlertTrigger records a threshold of a usage alert crossed in a
+// subscription's billing period. A threshold triggers at most once per period.
+type UsageAlertTrigger struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UsageAlertID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_usage_alert_triggers_once" json:"usage_alert_id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_usage_alert_triggers_once" json:"subscription_id"`
+	PeriodStart    time.Time  `gorm:"not null;uniqueIndex:idx_usage_alert_triggers_once" json:"period_start"`
+	Threshold      int        `gorm:"not null;uniqueIndex:idx_usage_alert_triggers_once" json:"threshold"` // percentage of the limit
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	PeriodEnd      time.Time  `gorm:"not null" json:"period_end"`
+	Value          string     `gorm:"type:numeric;not null" json:"value"` // usage or spend when the threshold was crossed
+	Limit          string     `gorm:"column:limit_value;type:numeric;not null" json:"limit"`
+	Currency       string     `json:"currency,omitempty"`
+	TriggeredAt    time.Time  `gorm:"not null" json:"triggered_at"`
+	NotifiedAt     *time.Time `json:"notified_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	// Relationships
+	UsageAlert UsageAlert `gorm:"foreignKey:UsageAlertID" json:"usage_alert,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID if not provided
+func (t *UsageAlertTrigger) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for UsageAlertTrigger model
+func (UsageAlertTrigger) TableName() string {
+	return "usage_alert_triggers"
+}
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+
+	"go-backend/config"
+)
+
+// Message is an email sent to an organization
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to customers
+type Notifier interface {
+	Send(msg *Message) error
+}
+
+// New returns an SMTP notifier when an SMTP host is configured and a logging
+// notifier otherwise, so development setups need no mail server
+func New(cfg config.EmailConfig) Notifier {
+	if cfg.SMTPHost == "" {
+		return NewLogNotifier()
+	}
+	return NewSMTPNotifier(cfg)
+}
+
+// logNotifier writes messages to the application log
+type logNotifier struct{}
+
+// NewLogNotifier creates a notifier that only logs messages
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+// Send logs the message
+func (n *logNotifier) Send(msg *Message) error {
+	log.Printf("📧 Email to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
+
+// smtpNotifier sends messages through an SMTP server
+type smtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPNotifier creates a notifier that sends plain-text email over SMTP
+func NewSMTPNotifier(cfg config.EmailConfig) Notifier {
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return &smtpNotifier{
+		addr: cfg.SMTPHost + ":" + cfg.SMTPPort,
+		auth: auth,
+		from: cfg.From,
+	}
+}
+
+// Send delivers the message to every recipient
+func (n *smtpNotifier) Send(msg *Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("message %q has no recipients", msg.Subject)
+	}
+
+	var body strings.Builder
+	body.WriteString("From: " + n.from + "\r\n")
+	body.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	body.WriteString("Subject: " + msg.Subject + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(n.addr, n.auth, n.from, msg.To, []byte(body.String()))
+}
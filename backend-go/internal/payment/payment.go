@@ -0,0 +1,82 @@
+// Package payment collects invoices from customers' payment methods through
+// a payment gateway.
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-backend/config"
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+
+	"golang.org/x/text/language"
+)
+
+// ErrDeclined is returned, wrapped with the provider's reason, when a
+// payment method is declined. Other errors mean the charge could not be
+// attempted and may be retried without counting against the customer.
+var ErrDeclined = errors.New("payment declined")
+
+// Charge is a request to collect an invoice from a payment method
+type Charge struct {
+	Invoice       *models.Invoice
+	PaymentMethod *models.PaymentMethod
+	Amount        money.Money
+
+	// IdempotencyKey identifies the charge to the provider: a charge made
+	// again with the same key returns the first one's result instead of
+	// collecting the amount twice
+	IdempotencyKey string
+}
+
+// Gateway collects payments from customers through a payment provider
+type Gateway interface {
+	// Charge collects the amount and returns the provider's reference for the payment
+	Charge(charge *Charge) (string, error)
+}
+
+// GatewaySimulated is the PAYMENT_GATEWAY value that selects the simulated gateway
+const GatewaySimulated = "simulated"
+
+// New returns the gateway configured to collect invoices, or nil when none
+// is configured and invoices are not charged. No provider is integrated
+// yet, so the only gateway is the simulated one, which must be asked for
+// explicitly since it approves charges without collecting anything.
+func New(cfg config.PaymentConfig) (Gateway, error) {
+	switch cfg.Gateway {
+	case "":
+		return nil, nil
+	case GatewaySimulated:
+		return NewSimulatedGateway(), nil
+	}
+	return nil, fmt.Errorf("unknown payment gateway %q", cfg.Gateway)
+}
+
+// declinePrefix marks provider IDs of payment methods the simulated gateway declines
+const declinePrefix = "decline"
+
+// simulatedGateway approves every charge except those to payment methods
+// whose provider ID starts with "decline", so failed payments can be
+// exercised without a provider
+type simulatedGateway struct{}
+
+// NewSimulatedGateway creates a gateway that only logs charges
+func NewSimulatedGateway() Gateway {
+	return &simulatedGateway{}
+}
+
+// Charge approves or declines the charge and logs it. The reference is
+// derived from the idempotency key, so a repeated charge gets the same one.
+func (g *simulatedGateway) Charge(charge *Charge) (string, error) {
+	amount := charge.Amount.Format(language.English)
+	if strings.HasPrefix(charge.PaymentMethod.ProviderID, declinePrefix) {
+		log.Printf("💳 Declined %s for invoice %s", amount, charge.Invoice.InvoiceNumber)
+		return "", fmt.Errorf("%w: card declined", ErrDeclined)
+	}
+
+	log.Printf("💳 Charged %s for invoice %s", amount, charge.Invoice.InvoiceNumber)
+	return "sim_" + charge.IdempotencyKey, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BillingAddressRepository interface defines methods for billing address data operations
+type BillingAddressRepository interface {
+	GetDefaultByOrganizationID(orgID uuid.UUID) (*models.BillingAddress, error)
+}
+
+// billingAddressRepository implements BillingAddressRepository interface
+type billingAddressRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingAddressRepository creates a new billing address repository
+func NewBillingAddressRepository(db *gorm.DB) BillingAddressRepository {
+	return &billingAddressRepository{db: db}
+}
+
+// GetDefaultByOrganizationID retrieves the address an organization is billed
+// at: its default address, or its newest one when none is the default
+func (r *billingAddressRepository) GetDefaultByOrganizationID(orgID uuid.UUID) (*models.BillingAddress, error) {
+	var address models.BillingAddress
+	err := r.db.Where("organization_id = ?", orgID).
+		Order("is_default DESC, created_at DESC").
+		First(&address).Error
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BillingRunRepository interface defines methods for billing-cycle engine runs
+type BillingRunRepository interface {
+	CreateRun(run *models.BillingRun) error
+	UpdateRun(run *models.BillingRun) error
+	GetRun(id uuid.UUID) (*models.BillingRun, error)
+	ListRuns(limit, offset int) ([]*models.BillingRun, error)
+	CountRuns() (int64, error)
+	ClaimDue(runID uuid.UUID, now time.Time, limit int, staleBefore time.Time, testClockID *uuid.UUID) ([]*models.BillingRunItem, error)
+	UpdateItem(item *models.BillingRunItem) error
+	GetItems(runID uuid.UUID, outcome string, limit, offset int) ([]*models.BillingRunItem, error)
+	CountItems(runID uuid.UUID, outcome string) (int64, error)
+}
+
+// billingRunRepository implements BillingRunRepository interface
+type billingRunRepository struct {
+	db *gorm.DB
+}
+
+// NewBillingRunRepository creates a new billing run repository
+func NewBillingRunRepository(db *gorm.DB) BillingRunRepository {
+	return &billingRunRepository{db: db}
+}
+
+// CreateRun records the start of a run
+func (r *billingRunRepository) CreateRun(run *models.BillingRun) error {
+	return r.db.Create(run).Error
+}
+
+// UpdateRun updates an existing run
+func (r *billingRunRepository) UpdateRun(run *models.BillingRun) error {
+	return r.db.Save(run).Error
+}
+
+// GetRun retrieves a run by ID
+func (r *billingRunRepository) GetRun(id uuid.UUID) (*models.BillingRun, error) {
+	var run models.BillingRun
+	err := r.db.Where("id = ?", id).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns retrieves runs, newest first
+func (r *billingRunRepository) ListRuns(limit, offset int) ([]*models.BillingRun, error) {
+	var runs []*models.BillingRun
+	err := r.db.Order("started_at DESC").Limit(limit).Offset(offset).Find(&runs).Error
+	return runs, err
+}
+
+// CountRuns returns the total number of runs
+func (r *billingRunRepository) CountRuns() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.BillingRun{}).Count(&count).Error
+	return count, err
+}
+
+// claimDueQuery selects active, past-due and suspended subscriptions whose
+// period has ended and whose period has not been claimed yet, or whose claim
+// failed and is due for another attempt, or was left processing by an engine
+// that died. Rows locked by another engine are skipped rather than waited
+// for. Only organizations on the given test clock are claimed, or those on
+// real time when it is NULL; sandbox organizations are billed when their
+// clock advances.
+const claimDueQuery = `
+SELECT s.id AS subscription_id, s.current_period_end AS period_end
+FROM subscriptions s
+WHERE s.deleted_at IS NULL
+  AND s.status IN ('active', 'past_due', 'suspended')
+  AND s.current_period_end <= @now
+  AND s.organization_id IN (SELECT o.id FROM organizations o WHERE o.test_clock_id IS NOT DISTINCT FROM @test_clock_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM billing_run_items i
+    WHERE i.subscription_id = s.id
+      AND i.period_end = s.current_period_end
+      AND NOT ((i.outcome = 'failed' AND (i.next_attempt_at IS NULL OR i.next_attempt_at <= @now))
+            OR (i.outcome = 'processing' AND i.updated_at < @stale_before))
+  )
+ORDER BY s.current_period_end
+LIMIT @limit
+FOR UPDATE OF s SKIP LOCKED`
+
+// claimItemQuery inserts the claim for one period, or takes over a retryable
+// one. It returns no row when another engine holds the claim.
+const claimItemQuery = `
+INSERT INTO billing_run_items (id, run_id, subscription_id, period_end, outcome, attempts, created_at, updated_at)
+VALUES (@id, @run_id, @subscription_id, @period_end, 'processing', 1, @now, @now)
+ON CONFLICT (subscription_id, period_end) DO UPDATE
+SET run_id = EXCLUDED.run_id, outcome = 'processing', attempts = billing_run_items.attempts + 1,
+    error = '', next_attempt_at = NULL, updated_at = EXCLUDED.updated_at
+WHERE (billing_run_items.outcome = 'failed'
+       AND (billing_run_items.next_attempt_at IS NULL OR billing_run_items.next_attempt_at <= @now))
+   OR (billing_run_items.outcome = 'processing' AND billing_run_items.updated_at < @stale_before)
+RETURNING id, attempts, created_at`
+
+// ClaimDue claims up to limit ended subscription periods for a run in a
+// single transaction, so concurrent engines on other replicas never claim the
+// same period. Periods ending at or before now are due. It covers the
+// organizations of the given test clock, or those on real time when
+// testClockID is nil.
+func (r *billingRunRepository) ClaimDue(runID uuid.UUID, now time.Time, limit int, staleBefore time.Time, testClockID *uuid.UUID) ([]*models.BillingRunItem, error) {
+	var claimed []*models.BillingRunItem
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var due []struct {
+			SubscriptionID uuid.UUID
+			PeriodEnd      time.Time
+		}
+		params := map[string]interface{}{
+			"now":           now,
+			"limit":         limit,
+			"stale_before":  staleBefore,
+			"test_clock_id": testClockID,
+		}
+		if err := tx.Raw(claimDueQuery, params).Scan(&due).Error; err != nil {
+			return err
+		}
+
+		for _, row := range due {
+			item := &models.BillingRunItem{
+				ID:             uuid.New(),
+				RunID:          runID,
+				SubscriptionID: row.SubscriptionID,
+				PeriodEnd:      row.PeriodEnd,
+				Outcome:        models.BillingItemProcessing,
+				UpdatedAt:      now,
+			}
+
+			var result []struct {
+				ID        uuid.UUID
+				Attempts  int
+				CreatedAt time.Time
+			}
+			err := tx.Raw(claimItemQuery, map[string]interface{}{
+				"id":              item.ID,
+				"run_id":          runID,
+				"subscription_id": row.SubscriptionID,
+				"period_end":      row.PeriodEnd,
+				"now":             now,
+				"stale_before":    staleBefore,
+			}).Scan(&result).Error
+			if err != nil {
+				return err
+			}
+			if len(result) == 0 {
+				continue
+			}
+
+			item.ID = result[0].ID
+			item.Attempts = result[0].Attempts
+			item.CreatedAt = result[0].CreatedAt
+			claimed = append(claimed, item)
+		}
+		return nil
+	})
+
+	return claimed, err
+}
+
+// UpdateItem records the outcome of a claimed period as of item.UpdatedAt,
+// with when it may be retried if it failed
+func (r *billingRunRepository) UpdateItem(item *models.BillingRunItem) error {
+	return r.db.Model(item).
+		Select("outcome", "error", "next_attempt_at", "updated_at").
+		Updates(map[string]interface{}{
+			"outcome":         item.Outcome,
+			"error":           item.Error,
+			"next_attempt_at": item.NextAttemptAt,
+			"updated_at":      item.UpdatedAt,
+		}).Error
+}
+
+// GetItems retrieves the periods processed by a run, optionally filtered by outcome
+func (r *billingRunRepository) GetItems(runID uuid.UUID, outcome string, limit, offset int) ([]*models.BillingRunItem, error) {
+	var items []*models.BillingRunItem
+	query := r.db.Where("run_id = ?", runID)
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	err := query.Order("updated_at ASC").Limit(limit).Offset(offset).Find(&items).Error
+	return items, err
+}
+
+// CountItems counts the periods processed by a run, optionally filtered by outcome
+func (r *billingRunRepository) CountItems(runID uuid.UUID, outcome string) (int64, error) {
+	var count int64
+	query := r.db.Model(&models.BillingRunItem{}).Where("run_id = ?", runID)
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"time"
+
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DunningRepository interface defines methods for dunning case and payment attempt data operations
+type DunningRepository interface {
+	GetCollectible(now, staleBefore time.Time, limit int, testClockID *uuid.UUID) ([]*models.Invoice, error)
+	GetDue(now, staleBefore time.Time, limit int, testClockID *uuid.UUID) ([]*models.DunningCase, error)
+	ClaimAttempt(attempt *models.PaymentAttempt, staleBefore time.Time) (bool, error)
+	ReleaseAttempt(attempt *models.PaymentAttempt) error
+	RecordAttempt(attempt *models.PaymentAttempt, invoice *InvoiceStatusUpdate, dunning *models.DunningCase) (bool, error)
+	Update(dunning *models.DunningCase) error
+	CountUnresolvedBySubscriptionID(subscriptionID uuid.UUID) (int64, error)
+	GetByOrganizationID(orgID uuid.UUID, status string, limit, offset int) ([]*models.DunningCase, error)
+	CountByOrganizationID(orgID uuid.UUID, status string) (int64, error)
+}
+
+// dunningRepository implements DunningRepository interface
+type dunningRepository struct {
+	db *gorm.DB
+}
+
+// NewDunningRepository creates a new dunning repository
+func NewDunningRepository(db *gorm.DB) DunningRepository {
+	return &dunningRepository{db: db}
+}
+
+// openInvoiceStatuses are the statuses of invoices that can still be collected
+var openInvoiceStatuses = models.CollectibleInvoiceStatuses()
+
+// GetCollectible retrieves the open invoices due by now that have not been
+// charged yet, oldest due first, with their organizations. Invoices of
+// paused subscriptions are left alone, as are invoices with nothing to
+// collect. An invoice whose first charge was claimed before staleBefore and
+// never recorded is charged again.
+func (r *dunningRepository) GetCollectible(now, staleBefore time.Time, limit int, testClockID *uuid.UUID) ([]*models.Invoice, error) {
+	var invoices []*models.Invoice
+	err := r.db.Preload("Organization").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("status IN ? AND due_date <= ? AND total > 0", openInvoiceStatuses, now).
+		Where("(subscription_id IS NULL OR subscription_id NOT IN (SELECT id FROM subscriptions WHERE status = ?))", models.SubscriptionStatusPaused).
+		Where("NOT EXISTS (SELECT 1 FROM payment_attempts pa WHERE pa.invoice_id = invoices.id AND NOT (pa.status = ? AND pa.attempted_at < ?))",
+			models.PaymentAttemptPending, staleBefore).
+		Order("due_date ASC").
+		Limit(limit).
+		Find(&invoices).Error
+	return invoices, err
+}
+
+// dueDunningCondition selects the dunning cases with work to do: active
+// cases whose next retry is due, and cases that can still recover (active,
+// or suspended by the final action) when their invoice was settled outside
+// dunning or their organization's payment methods changed since the case
+// was last updated. Cases whose invoice is being charged are skipped until
+// the charge is recorded, or its claim goes stale.
+const dueDunningCondition = `(
+  dunning_cases.status = @active AND dunning_cases.next_attempt_at <= @now
+  OR (dunning_cases.status = @active OR dunning_cases.status = @exhausted AND dunning_cases.final_action = @suspend) AND (
+    EXISTS (SELECT 1 FROM invoices i WHERE i.id = dunning_cases.invoice_id AND i.status NOT IN @open)
+    OR EXISTS (
+      SELECT 1 FROM payment_methods pm
+      WHERE pm.organization_id = dunning_cases.organization_id AND pm.deleted_at IS NULL
+        AND pm.is_active AND pm.updated_at > dunning_cases.updated_at
+    )
+  )
+) AND NOT EXISTS (
+  SELECT 1 FROM payment_attempts pa
+  WHERE pa.invoice_id = dunning_cases.invoice_id AND pa.status = @pending AND pa.attempted_at >= @stale_before
+)`
+
+// GetDue retrieves the dunning cases with work due by now, oldest first,
+// with their invoices and organizations
+func (r *dunningRepository) GetDue(now, staleBefore time.Time, limit int, testClockID *uuid.UUID) ([]*models.DunningCase, error) {
+	var cases []*models.DunningCase
+	err := r.db.Preload("Invoice").Preload("Invoice.Organization").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where(dueDunningCondition, map[string]interface{}{
+			"active":       models.DunningStatusActive,
+			"exhausted":    models.DunningStatusExhausted,
+			"suspend":      models.DunningActionSuspend,
+			"now":          now,
+			"open":         openInvoiceStatuses,
+			"pending":      models.PaymentAttemptPending,
+			"stale_before": staleBefore,
+		}).
+		Order("started_at ASC").
+		Limit(limit).
+		Find(&cases).Error
+	return cases, err
+}
+
+// takeOverAttemptQuery takes over the pending attempt of an invoice whose
+// claim went stale, keeping its ID so the charge is repeated with the same
+// idempotency key
+const takeOverAttemptQuery = `
+UPDATE payment_attempts SET attempted_at = @now
+WHERE invoice_id = @invoice_id AND status = @pending AND attempted_at < @stale_before
+RETURNING id`
+
+// ClaimAttempt claims an invoice for charging by creating its pending
+// attempt, so runs on other replicas never charge it at the same time. A
+// pending attempt claimed before staleBefore, by a run that died before
+// recording the charge, is taken over instead. It reports false when
+// another run holds the claim.
+func (r *dunningRepository) ClaimAttempt(attempt *models.PaymentAttempt, staleBefore time.Time) (bool, error) {
+	attempt.Status = models.PaymentAttemptPending
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "invoice_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status = 'pending'"}}},
+		DoNothing:   true,
+	}).Create(attempt)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error == nil, result.Error
+	}
+
+	var taken []struct{ ID uuid.UUID }
+	err := r.db.Raw(takeOverAttemptQuery, map[string]interface{}{
+		"now":          attempt.AttemptedAt,
+		"invoice_id":   attempt.InvoiceID,
+		"pending":      models.PaymentAttemptPending,
+		"stale_before": staleBefore,
+	}).Scan(&taken).Error
+	if err != nil || len(taken) == 0 {
+		return false, err
+	}
+	attempt.ID = taken[0].ID
+	return true, nil
+}
+
+// ReleaseAttempt gives up the claim of an invoice that was not charged
+func (r *dunningRepository) ReleaseAttempt(attempt *models.PaymentAttempt) error {
+	return r.db.Where("status = ?", models.PaymentAttemptPending).Delete(attempt).Error
+}
+
+// RecordAttempt completes a pending payment attempt together with the
+// invoice status change and dunning case it made, in one transaction. A nil
+// invoice or dunning case is left unchanged; a dunning case without an ID is
+// created. It reports false, without changing anything, when the attempt is
+// no longer pending because another run took over its claim and recorded
+// it. Nothing is saved when the invoice is no longer in its From status; it
+// then fails with ErrInvoiceStatusChanged.
+func (r *dunningRepository) RecordAttempt(attempt *models.PaymentAttempt, invoice *InvoiceStatusUpdate, dunning *models.DunningCase) (bool, error) {
+	recorded := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(attempt).Where("status = ?", models.PaymentAttemptPending).
+			Select("*").Omit("id", "created_at").Updates(attempt)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		recorded = true
+
+		if invoice != nil {
+			saved, err := updateStatus(tx, invoice.Invoice, invoice.From)
+			if err != nil {
+				return err
+			}
+			if !saved {
+				return ErrInvoiceStatusChanged
+			}
+		}
+		if dunning != nil {
+			if err := tx.Omit(clause.Associations).Save(dunning).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		recorded = false
+	}
+	return recorded, err
+}
+
+// Update updates an existing dunning case
+func (r *dunningRepository) Update(dunning *models.DunningCase) error {
+	return r.db.Omit(clause.Associations).Save(dunning).Error
+}
+
+// CountUnresolvedBySubscriptionID counts the dunning cases holding a
+// subscription back: those still retrying and those that suspended it
+func (r *dunningRepository) CountUnresolvedBySubscriptionID(subscriptionID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.DunningCase{}).
+		Where("subscription_id = ?", subscriptionID).
+		Where("(status = ? OR status = ? AND final_action = ?)",
+			models.DunningStatusActive, models.DunningStatusExhausted, models.DunningActionSuspend).
+		Count(&count).Error
+	return count, err
+}
+
+// GetByOrganizationID retrieves the dunning cases of an organization with
+// the payment attempts of their invoices, newest first, optionally only those
+// in status, with pagination
+func (r *dunningRepository) GetByOrganizationID(orgID uuid.UUID, status string, limit, offset int) ([]*models.DunningCase, error) {
+	var cases []*models.DunningCase
+	query := r.db.Where("organization_id = ?", orgID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("started_at DESC").Limit(limit).Offset(offset).Find(&cases).Error; err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return cases, nil
+	}
+
+	invoiceIDs := make([]uuid.UUID, len(cases))
+	byInvoice := make(map[uuid.UUID]*models.DunningCase, len(cases))
+	for i, dunning := range cases {
+		invoiceIDs[i] = dunning.InvoiceID
+		byInvoice[dunning.InvoiceID] = dunning
+	}
+
+	var attempts []models.PaymentAttempt
+	err := r.db.Where("invoice_id IN ?", invoiceIDs).Order("attempted_at ASC").Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range attempts {
+		dunning := byInvoice[attempt.InvoiceID]
+		dunning.Attempts = append(dunning.Attempts, attempt)
+	}
+	return cases, nil
+}
+
+// CountByOrganizationID counts the dunning cases of an organization,
+// optionally only those in status
+func (r *dunningRepository) CountByOrganizationID(orgID uuid.UUID, status string) (int64, error) {
+	var count int64
+	query := r.db.Model(&models.DunningCase{}).Where("organization_id = ?", orgID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExchangeRateFilter narrows exchange rate listings; empty fields match everything
+type ExchangeRateFilter struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	From          *time.Time
+	To            *time.Time
+}
+
+// ExchangeRateRepository interface defines methods for exchange rate data operations
+type ExchangeRateRepository interface {
+	Upsert(rates []*models.ExchangeRate) error
+	GetEffective(baseCurrency, quoteCurrency string, date time.Time) (*models.ExchangeRate, error)
+	List(filter ExchangeRateFilter, limit, offset int) ([]*models.ExchangeRate, error)
+	Count(filter ExchangeRateFilter) (int64, error)
+}
+
+// exchangeRateRepository implements ExchangeRateRepository interface
+type exchangeRateRepository struct {
+	db *gorm.DB
+}
+
+// NewExchangeRateRepository creates a new exchange rate repository
+func NewExchangeRateRepository(db *gorm.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
+
+// Upsert stores rates, replacing any existing rate for the same pair and day
+func (r *exchangeRateRepository) Upsert(rates []*models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(rates, 500).Error
+}
+
+// GetEffective retrieves the most recent rate published on or before date
+func (r *exchangeRateRepository) GetEffective(baseCurrency, quoteCurrency string, date time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := r.db.Where("base_currency = ? AND quote_currency = ? AND date <= ?", baseCurrency, quoteCurrency, date.Format("2006-01-02")).
+		Order("date DESC").First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// List retrieves rates matching filter, newest first
+func (r *exchangeRateRepository) List(filter ExchangeRateFilter, limit, offset int) ([]*models.ExchangeRate, error) {
+	var rates []*models.ExchangeRate
+	err := r.filtered(filter).Order("date DESC, base_currency ASC, quote_currency ASC").
+		Limit(limit).Offset(offset).Find(&rates).Error
+	return rates, err
+}
+
+// Count counts rates matching filter
+func (r *exchangeRateRepository) Count(filter ExchangeRateFilter) (int64, error) {
+	var count int64
+	err := r.filtered(filter).Model(&models.ExchangeRate{}).Count(&count).Error
+	return count, err
+}
+
+// filtered applies an ExchangeRateFilter to a query
+func (r *exchangeRateRepository) filtered(filter ExchangeRateFilter) *gorm.DB {
+	query := r.db
+	if filter.BaseCurrency != "" {
+		query = query.Where("base_currency = ?", filter.BaseCurrency)
+	}
+	if filter.QuoteCurrency != "" {
+		query = query.Where("quote_currency = ?", filter.QuoteCurrency)
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query = query.Where("date <= ?", filter.To.Format("2006-01-02"))
+	}
+	return query
+}
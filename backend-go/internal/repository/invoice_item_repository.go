@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InvoiceItemRepository interface defines methods for invoice line item data operations
+type InvoiceItemRepository interface {
+	Create(items []models.InvoiceItem) error
+	GetByInvoiceID(invoiceID uuid.UUID) ([]models.InvoiceItem, error)
+}
+
+// invoiceItemRepository implements InvoiceItemRepository interface
+type invoiceItemRepository struct {
+	db *gorm.DB
+}
+
+// NewInvoiceItemRepository creates a new invoice item repository
+func NewInvoiceItemRepository(db *gorm.DB) InvoiceItemRepository {
+	return &invoiceItemRepository{db: db}
+}
+
+// Create creates the items of an invoice. Items are only written together
+// with their invoice, see createInvoice.
+func (r *invoiceItemRepository) Create(items []models.InvoiceItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.Omit("Invoice").Create(&items).Error
+}
+
+// GetByInvoiceID retrieves the items of an invoice in invoice order
+func (r *invoiceItemRepository) GetByInvoiceID(invoiceID uuid.UUID) ([]models.InvoiceItem, error) {
+	var items []models.InvoiceItem
+	err := r.db.Where("invoice_id = ?", invoiceID).Scopes(itemOrder).Find(&items).Error
+	return items, err
+}
+
+// itemOrder orders invoice items as they appear on the invoice
+func itemOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+// createInvoice writes an invoice and its items with tx. The totals are
+// computed from the items first, so they never disagree with the lines.
+func createInvoice(tx *gorm.DB, invoice *models.Invoice) error {
+	if err := invoice.ComputeTotals(); err != nil {
+		return err
+	}
+	if err := tx.Omit("Items").Create(invoice).Error; err != nil {
+		return err
+	}
+	return createItems(tx, invoice)
+}
+
+// createItems writes the items of an invoice with tx, numbered in the order
+// they appear on the invoice
+func createItems(tx *gorm.DB, invoice *models.Invoice) error {
+	for i := range invoice.Items {
+		item := &invoice.Items[i]
+		item.ID = uuid.Nil
+		item.InvoiceID = invoice.ID
+		item.Position = i + 1
+		if item.Currency == "" {
+			item.Currency = invoice.Currency
+		}
+	}
+	return NewInvoiceItemRepository(tx).Create(invoice.Items)
+}
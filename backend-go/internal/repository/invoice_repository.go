@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // InvoiceRepository interface defines methods for invoice data operations
@@ -14,15 +15,29 @@ type InvoiceRepository interface {
 	GetByID(id uuid.UUID) (*models.Invoice, error)
 	GetByInvoiceNumber(invoiceNumber string) (*models.Invoice, error)
 	Update(invoice *models.Invoice) error
+	UpdateDraft(invoice *models.Invoice) (bool, error)
+	UpdateStatus(invoice *models.Invoice, from string) (bool, error)
 	Delete(id uuid.UUID) error
 	List(limit, offset int) ([]*models.Invoice, error)
 	Count() (int64, error)
 	GetByOrganizationID(orgID uuid.UUID, limit, offset int) ([]*models.Invoice, error)
 	GetByStatus(status string, limit, offset int) ([]*models.Invoice, error)
-	GetOverdue() ([]*models.Invoice, error)
+	GetOverdue(now time.Time) ([]*models.Invoice, error)
+	MarkOverdue(now time.Time, testClockID *uuid.UUID) (int64, error)
 	GetByDateRange(startDate, endDate time.Time, limit, offset int) ([]*models.Invoice, error)
+	GetOpenBySubscriptionID(subscriptionID uuid.UUID) ([]*models.Invoice, error)
 }
 
+// invoiceDerivedColumns are written only when an invoice is created with its items
+var invoiceDerivedColumns = []string{"Items", "Subtotal", "TaxAmount", "DiscountAmount", "Total"}
+
+// invoiceStatusOmits are left out when an invoice's lifecycle changes. Omit
+// replaces earlier omissions, so they are passed in a single call.
+var invoiceStatusOmits = append(append([]string{}, invoiceDerivedColumns...), clause.Associations)
+
+// invoiceDraftColumns are the columns written when a draft invoice is edited
+var invoiceDraftColumns = []string{"DueDate", "Notes", "Subtotal", "TaxAmount", "DiscountAmount", "Total"}
+
 // invoiceRepository implements InvoiceRepository interface
 type invoiceRepository struct {
 	db *gorm.DB
@@ -33,15 +48,18 @@ func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
 	return &invoiceRepository{db: db}
 }
 
-// Create creates a new invoice
+// Create creates a new invoice and its items in a single transaction, with
+// the totals computed from the items
 func (r *invoiceRepository) Create(invoice *models.Invoice) error {
-	return r.db.Create(invoice).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return createInvoice(tx, invoice)
+	})
 }
 
 // GetByID retrieves an invoice by ID with related data
 func (r *invoiceRepository) GetByID(id uuid.UUID) (*models.Invoice, error) {
 	var invoice models.Invoice
-	err := r.db.Preload("Organization").Preload("Subscription").Preload("PaymentMethod").Preload("Items").
+	err := r.db.Preload("Organization").Preload("Subscription").Preload("PaymentMethod").Preload("Items", itemOrder).
 		Where("id = ?", id).First(&invoice).Error
 	if err != nil {
 		return nil, err
@@ -52,7 +70,7 @@ func (r *invoiceRepository) GetByID(id uuid.UUID) (*models.Invoice, error) {
 // GetByInvoiceNumber retrieves an invoice by invoice number
 func (r *invoiceRepository) GetByInvoiceNumber(invoiceNumber string) (*models.Invoice, error) {
 	var invoice models.Invoice
-	err := r.db.Preload("Organization").Preload("Subscription").Preload("PaymentMethod").Preload("Items").
+	err := r.db.Preload("Organization").Preload("Subscription").Preload("PaymentMethod").Preload("Items", itemOrder).
 		Where("invoice_number = ?", invoiceNumber).First(&invoice).Error
 	if err != nil {
 		return nil, err
@@ -60,9 +78,83 @@ func (r *invoiceRepository) GetByInvoiceNumber(invoiceNumber string) (*models.In
 	return &invoice, nil
 }
 
-// Update updates an existing invoice
+// Update updates an existing invoice. Its items and the totals derived from
+// them are left as they were created. Status changes go through UpdateStatus,
+// which numbers finalized invoices.
 func (r *invoiceRepository) Update(invoice *models.Invoice) error {
-	return r.db.Save(invoice).Error
+	return r.db.Omit(invoiceDerivedColumns...).Save(invoice).Error
+}
+
+// UpdateDraft saves the editable fields of a draft invoice and replaces its
+// items in a single transaction, with the totals computed from the new items.
+// It reports false, without changing anything, when the invoice is no longer
+// a draft.
+func (r *invoiceRepository) UpdateDraft(invoice *models.Invoice) (bool, error) {
+	if err := invoice.ComputeTotals(); err != nil {
+		return false, err
+	}
+
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(invoice).Where("status = ?", models.InvoiceStatusDraft).
+			Select(invoiceDraftColumns).Updates(invoice)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+
+		if err := tx.Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceItem{}).Error; err != nil {
+			return err
+		}
+		return createItems(tx, invoice)
+	})
+	return updated, err
+}
+
+// UpdateStatus saves an invoice whose status changed from from, taking its
+// number from its sequence in the same transaction when it was just
+// finalized. It reports false, without changing anything, when the invoice
+// is no longer in from because another change got there first.
+func (r *invoiceRepository) UpdateStatus(invoice *models.Invoice, from string) (bool, error) {
+	draftNumber := invoice.InvoiceNumber
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = updateStatus(tx, invoice, from)
+		return err
+	})
+	if err != nil {
+		updated = false
+		invoice.InvoiceNumber = draftNumber
+	}
+	return updated, err
+}
+
+// updateStatus saves an invoice's lifecycle change within tx where it is
+// still in the from status, numbering it if it was just finalized
+func updateStatus(tx *gorm.DB, invoice *models.Invoice, from string) (bool, error) {
+	result := saveStatus(tx, invoice, from)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	// The row stays locked by the update, so no other finalization numbers it
+	if invoice.FinalizedAt == nil || !invoice.HasDraftNumber() {
+		return true, nil
+	}
+	number, err := allocateInvoiceNumber(tx, invoice)
+	if err != nil {
+		return false, err
+	}
+	invoice.InvoiceNumber = number
+	return true, tx.Model(invoice).Update("invoice_number", number).Error
+}
+
+// saveStatus writes every column of the invoice except its items, the amounts
+// derived from them and its associations, where it is still in the from status
+func saveStatus(tx *gorm.DB, invoice *models.Invoice, from string) *gorm.DB {
+	return tx.Model(invoice).Where("status = ?", from).
+		Select("*").Omit(invoiceStatusOmits...).Updates(invoice)
 }
 
 // Delete soft deletes an invoice by ID
@@ -88,7 +180,7 @@ func (r *invoiceRepository) Count() (int64, error) {
 // GetByOrganizationID retrieves invoices for an organization
 func (r *invoiceRepository) GetByOrganizationID(orgID uuid.UUID, limit, offset int) ([]*models.Invoice, error) {
 	var invoices []*models.Invoice
-	err := r.db.Preload("Subscription").Preload("Items").
+	err := r.db.Preload("Subscription").Preload("Items", itemOrder).
 		Where("organization_id = ?", orgID).
 		Order("created_at DESC").Limit(limit).Offset(offset).Find(&invoices).Error
 	return invoices, err
@@ -103,15 +195,36 @@ func (r *invoiceRepository) GetByStatus(status string, limit, offset int) ([]*mo
 	return invoices, err
 }
 
-// GetOverdue retrieves overdue invoices
-func (r *invoiceRepository) GetOverdue() ([]*models.Invoice, error) {
+// GetOverdue retrieves invoices that are overdue at the given time: those
+// marked overdue, and open or sent ones past their due date
+func (r *invoiceRepository) GetOverdue(now time.Time) ([]*models.Invoice, error) {
 	var invoices []*models.Invoice
 	err := r.db.Preload("Organization").Preload("Subscription").
-		Where("status != ? AND due_date < ?", "paid", time.Now()).
+		Where("status = ? OR (status IN ? AND due_date < ?)",
+			models.InvoiceStatusOverdue, models.InvoiceStatusesAllowing(models.InvoiceEventMarkOverdue), now).
 		Order("due_date ASC").Find(&invoices).Error
 	return invoices, err
 }
 
+// MarkOverdue moves unpaid open and sent invoices due before now to overdue
+// and returns how many were moved. It covers the organizations of the given
+// test clock, or those on real time when testClockID is nil.
+func (r *invoiceRepository) MarkOverdue(now time.Time, testClockID *uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.Invoice{}).
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("status IN ? AND due_date < ?", models.InvoiceStatusesAllowing(models.InvoiceEventMarkOverdue), now).
+		Update("status", models.InvoiceStatusOverdue)
+	return result.RowsAffected, result.Error
+}
+
+// GetOpenBySubscriptionID retrieves the unpaid invoices of a subscription that can still be collected
+func (r *invoiceRepository) GetOpenBySubscriptionID(subscriptionID uuid.UUID) ([]*models.Invoice, error) {
+	var invoices []*models.Invoice
+	err := r.db.Where("subscription_id = ? AND status IN ?", subscriptionID, models.CollectibleInvoiceStatuses()).
+		Order("issue_date ASC").Find(&invoices).Error
+	return invoices, err
+}
+
 // GetByDateRange retrieves invoices within a date range
 func (r *invoiceRepository) GetByDateRange(startDate, endDate time.Time, limit, offset int) ([]*models.Invoice, error) {
 	var invoices []*models.Invoice
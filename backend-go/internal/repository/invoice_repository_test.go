@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dryRunDB builds statements without a database, so tests can inspect the SQL
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSaveStatusLeavesItemsAndAmountsAlone(t *testing.T) {
+	now := time.Now()
+	invoice := &models.Invoice{
+		ID:             uuid.New(),
+		OrganizationID: uuid.New(),
+		InvoiceNumber:  "DRAFT-" + uuid.NewString(),
+		Status:         models.InvoiceStatusOpen,
+		Subtotal:       money.MustParse("10.00", "USD"),
+		Total:          money.MustParse("10.00", "USD"),
+		Currency:       "USD",
+		FinalizedAt:    &now,
+		Items:          []models.InvoiceItem{{Description: "Pro plan"}},
+	}
+
+	result := saveStatus(dryRunDB(t), invoice, models.InvoiceStatusDraft)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	sql := result.Statement.SQL.String()
+
+	if !strings.HasPrefix(sql, `UPDATE "invoices" SET `) {
+		t.Fatalf("expected a single invoice update, got %s", sql)
+	}
+	for _, column := range []string{"status", "finalized_at", "invoice_number"} {
+		if !strings.Contains(sql, `"`+column+`"=`) {
+			t.Errorf("expected %s to be written, got %s", column, sql)
+		}
+	}
+	for _, column := range []string{"subtotal", "tax_amount", "discount_amount", "total"} {
+		if strings.Contains(sql, `"`+column+`"=`) {
+			t.Errorf("expected %s to be left alone, got %s", column, sql)
+		}
+	}
+	if !strings.Contains(sql, "status = $") {
+		t.Errorf("expected the update to be conditional on the status, got %s", sql)
+	}
+	if strings.Contains(sql, "invoice_items") {
+		t.Errorf("expected the items to be left alone, got %s", sql)
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"errors"
+
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvoiceSequenceRepository interface defines methods for invoice numbering sequence data operations
+type InvoiceSequenceRepository interface {
+	Create(sequence *models.InvoiceSequence) error
+	GetByID(id uuid.UUID) (*models.InvoiceSequence, error)
+	Update(sequence *models.InvoiceSequence) error
+	List(limit, offset int) ([]*models.InvoiceSequence, error)
+	Count() (int64, error)
+	SetDefault(id uuid.UUID) error
+}
+
+// invoiceSequenceRepository implements InvoiceSequenceRepository interface
+type invoiceSequenceRepository struct {
+	db *gorm.DB
+}
+
+// NewInvoiceSequenceRepository creates a new invoice sequence repository
+func NewInvoiceSequenceRepository(db *gorm.DB) InvoiceSequenceRepository {
+	return &invoiceSequenceRepository{db: db}
+}
+
+// Create creates a new invoice sequence
+func (r *invoiceSequenceRepository) Create(sequence *models.InvoiceSequence) error {
+	return r.db.Omit("Organizations").Create(sequence).Error
+}
+
+// GetByID retrieves an invoice sequence by ID with the organizations it numbers
+func (r *invoiceSequenceRepository) GetByID(id uuid.UUID) (*models.InvoiceSequence, error) {
+	var sequence models.InvoiceSequence
+	err := r.db.Preload("Organizations").Where("id = ?", id).First(&sequence).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sequence, nil
+}
+
+// Update updates the name and format of an invoice sequence. The counter and
+// the default flag are only changed by numbering and SetDefault.
+func (r *invoiceSequenceRepository) Update(sequence *models.InvoiceSequence) error {
+	return r.db.Omit("Organizations", "NextNumber", "Year", "IsDefault").Save(sequence).Error
+}
+
+// List retrieves invoice sequences, default first
+func (r *invoiceSequenceRepository) List(limit, offset int) ([]*models.InvoiceSequence, error) {
+	var sequences []*models.InvoiceSequence
+	err := r.db.Order("is_default DESC, created_at ASC").Limit(limit).Offset(offset).Find(&sequences).Error
+	return sequences, err
+}
+
+// Count returns the total number of invoice sequences
+func (r *invoiceSequenceRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.InvoiceSequence{}).Count(&count).Error
+	return count, err
+}
+
+// SetDefault makes a sequence the one that numbers the invoices of
+// organizations without a sequence of their own
+func (r *invoiceSequenceRepository) SetDefault(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.InvoiceSequence{}).Where("is_default = ? AND id <> ?", true, id).
+			Update("is_default", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.InvoiceSequence{}).Where("id = ?", id).Update("is_default", true).Error
+	})
+}
+
+// allocateInvoiceNumber takes the next number of the sequence that numbers
+// an invoice's organization, or of the default sequence, which is created
+// the first time it is needed. When a sequence already uses the default
+// prefix, that one numbers those invoices instead. The sequence row stays
+// locked until tx ends, so a number is only used up when the invoice is
+// saved with it. The invoice must be finalized; its number carries the year
+// it was finalized in.
+func allocateInvoiceNumber(tx *gorm.DB, invoice *models.Invoice) (string, error) {
+	var org models.Organization
+	if err := tx.Select("id", "invoice_sequence_id").Where("id = ?", invoice.OrganizationID).First(&org).Error; err != nil {
+		return "", err
+	}
+
+	var sequence models.InvoiceSequence
+	locked := func() *gorm.DB {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	if org.InvoiceSequenceID != nil {
+		if err := locked().Where("id = ?", *org.InvoiceSequenceID).First(&sequence).Error; err != nil {
+			return "", err
+		}
+	} else {
+		err := locked().Where("is_default = ?", true).First(&sequence).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The insert is skipped when another transaction creates the
+			// default first or a sequence already has its prefix, so the
+			// sequence is read back by either key
+			if err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(models.DefaultInvoiceSequence()).Error; err == nil {
+				err = locked().Where("is_default = ? OR prefix = ?", true, models.DefaultInvoiceNumberPrefix).
+					Order("is_default DESC").First(&sequence).Error
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	number := sequence.Next(*invoice.FinalizedAt)
+
+	err := tx.Model(&sequence).Updates(map[string]interface{}{
+		"next_number": sequence.NextNumber,
+		"year":        sequence.Year,
+	}).Error
+	return number, err
+}
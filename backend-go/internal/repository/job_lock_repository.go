@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// JobLockRepository interface defines methods for locks that keep a
+// background job from running on more than one replica at a time
+type JobLockRepository interface {
+	TryLock(name string) (release func(), acquired bool, err error)
+}
+
+// jobLockRepository implements JobLockRepository interface with PostgreSQL
+// session-level advisory locks
+type jobLockRepository struct {
+	db *gorm.DB
+}
+
+// NewJobLockRepository creates a new job lock repository
+func NewJobLockRepository(db *gorm.DB) JobLockRepository {
+	return &jobLockRepository{db: db}
+}
+
+// TryLock takes the advisory lock for the job name without waiting. The lock
+// belongs to a database connection that is held until release is called, so
+// it also ends when the connection does, for instance when the replica dies.
+// It reports false when another replica holds the lock.
+func (r *jobLockRepository) TryLock(name string) (func(), bool, error) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+			log.Printf("Warning: failed to release lock of job %s: %v", name, err)
+			// Drop the connection so the lock is not left behind in the pool
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
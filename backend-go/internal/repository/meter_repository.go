@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MeterRepository interface defines methods for meter and metered price data operations
+type MeterRepository interface {
+	Create(meter *models.Meter) error
+	GetByID(id uuid.UUID) (*models.Meter, error)
+	Update(meter *models.Meter) error
+	List(limit, offset int) ([]*models.Meter, error)
+	Count() (int64, error)
+	GetActive() ([]*models.Meter, error)
+	GetActiveByEventName(eventName string) ([]*models.Meter, error)
+	CreatePrice(price *models.MeteredPrice) error
+	GetPriceByID(id uuid.UUID) (*models.MeteredPrice, error)
+	DeletePrice(id uuid.UUID) error
+	GetPricesByPlanID(planID uuid.UUID) ([]*models.MeteredPrice, error)
+	GetPricesByMeterID(meterID uuid.UUID) ([]*models.MeteredPrice, error)
+}
+
+// meterRepository implements MeterRepository interface
+type meterRepository struct {
+	db *gorm.DB
+}
+
+// NewMeterRepository creates a new meter repository
+func NewMeterRepository(db *gorm.DB) MeterRepository {
+	return &meterRepository{db: db}
+}
+
+// Create creates a new meter
+func (r *meterRepository) Create(meter *models.Meter) error {
+	return r.db.Create(meter).Error
+}
+
+// GetByID retrieves a meter by ID
+func (r *meterRepository) GetByID(id uuid.UUID) (*models.Meter, error) {
+	var meter models.Meter
+	err := r.db.Where("id = ?", id).First(&meter).Error
+	if err != nil {
+		return nil, err
+	}
+	return &meter, nil
+}
+
+// Update updates an existing meter
+func (r *meterRepository) Update(meter *models.Meter) error {
+	return r.db.Save(meter).Error
+}
+
+// List retrieves meters with pagination, by name
+func (r *meterRepository) List(limit, offset int) ([]*models.Meter, error) {
+	var meters []*models.Meter
+	err := r.db.Order("name ASC").Limit(limit).Offset(offset).Find(&meters).Error
+	return meters, err
+}
+
+// Count returns the total number of meters
+func (r *meterRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Meter{}).Count(&count).Error
+	return count, err
+}
+
+// GetActive retrieves every active meter
+func (r *meterRepository) GetActive() ([]*models.Meter, error) {
+	var meters []*models.Meter
+	err := r.db.Where("is_active = ?", true).Find(&meters).Error
+	return meters, err
+}
+
+// GetActiveByEventName retrieves the active meters that aggregate an event
+func (r *meterRepository) GetActiveByEventName(eventName string) ([]*models.Meter, error) {
+	var meters []*models.Meter
+	err := r.db.Where("event_name = ? AND is_active = ?", eventName, true).Find(&meters).Error
+	return meters, err
+}
+
+// CreatePrice creates a new metered price
+func (r *meterRepository) CreatePrice(price *models.MeteredPrice) error {
+	return r.db.Create(price).Error
+}
+
+// GetPriceByID retrieves a metered price by ID with its meter
+func (r *meterRepository) GetPriceByID(id uuid.UUID) (*models.MeteredPrice, error) {
+	var price models.MeteredPrice
+	err := r.db.Preload("Meter").Where("id = ?", id).First(&price).Error
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// DeletePrice soft deletes a metered price by ID
+func (r *meterRepository) DeletePrice(id uuid.UUID) error {
+	return r.db.Delete(&models.MeteredPrice{}, id).Error
+}
+
+// GetPricesByPlanID retrieves the metered prices of a plan with their meters
+func (r *meterRepository) GetPricesByPlanID(planID uuid.UUID) ([]*models.MeteredPrice, error) {
+	var prices []*models.MeteredPrice
+	err := r.db.Preload("Meter").Where("plan_id = ?", planID).Order("created_at ASC").Find(&prices).Error
+	return prices, err
+}
+
+// GetPricesByMeterID retrieves the metered prices that bill a meter
+func (r *meterRepository) GetPricesByMeterID(meterID uuid.UUID) ([]*models.MeteredPrice, error) {
+	var prices []*models.MeteredPrice
+	err := r.db.Where("meter_id = ?", meterID).Order("created_at ASC").Find(&prices).Error
+	return prices, err
+}
@@ -17,6 +17,7 @@ type OrganizationRepository interface {
 	List(limit, offset int) ([]*models.Organization, error)
 	Count() (int64, error)
 	GetByUserID(userID uuid.UUID) ([]*models.Organization, error)
+	CountActiveMembers(orgID uuid.UUID) (int64, error)
 }
 
 // organizationRepository implements OrganizationRepository interface
@@ -85,4 +86,13 @@ func (r *organizationRepository) GetByUserID(userID uuid.UUID) ([]*models.Organi
 		Where("organization_members.user_id = ? AND organization_members.is_active = ?", userID, true).
 		Find(&orgs).Error
 	return orgs, err
+}
+
+// CountActiveMembers counts the active members of an organization
+func (r *organizationRepository) CountActiveMembers(orgID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND is_active = ?", orgID, true).
+		Count(&count).Error
+	return count, err
 }
\ No newline at end of file
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentMethodRepository interface defines methods for payment method data operations
+type PaymentMethodRepository interface {
+	Create(paymentMethod *models.PaymentMethod) error
+	GetByID(id uuid.UUID) (*models.PaymentMethod, error)
+	Update(paymentMethod *models.PaymentMethod) error
+	GetActiveByOrganizationID(orgID uuid.UUID) ([]*models.PaymentMethod, error)
+}
+
+// paymentMethodRepository implements PaymentMethodRepository interface
+type paymentMethodRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentMethodRepository creates a new payment method repository
+func NewPaymentMethodRepository(db *gorm.DB) PaymentMethodRepository {
+	return &paymentMethodRepository{db: db}
+}
+
+// Create creates a new payment method
+func (r *paymentMethodRepository) Create(paymentMethod *models.PaymentMethod) error {
+	return r.db.Create(paymentMethod).Error
+}
+
+// GetByID retrieves a payment method by ID
+func (r *paymentMethodRepository) GetByID(id uuid.UUID) (*models.PaymentMethod, error) {
+	var paymentMethod models.PaymentMethod
+	err := r.db.Where("id = ?", id).First(&paymentMethod).Error
+	if err != nil {
+		return nil, err
+	}
+	return &paymentMethod, nil
+}
+
+// Update updates an existing payment method
+func (r *paymentMethodRepository) Update(paymentMethod *models.PaymentMethod) error {
+	return r.db.Save(paymentMethod).Error
+}
+
+// GetActiveByOrganizationID retrieves the active payment methods of an organization, default first
+func (r *paymentMethodRepository) GetActiveByOrganizationID(orgID uuid.UUID) ([]*models.PaymentMethod, error) {
+	var paymentMethods []*models.PaymentMethod
+	err := r.db.Where("organization_id = ? AND is_active = ?", orgID, true).
+		Order("is_default DESC, created_at DESC").
+		Find(&paymentMethods).Error
+	return paymentMethods, err
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PlanMigrationRepository interface defines methods for plan migration data operations
+type PlanMigrationRepository interface {
+	Create(migration *models.PlanMigration, items []*models.PlanMigrationItem) error
+	GetByID(id uuid.UUID) (*models.PlanMigration, error)
+	Update(migration *models.PlanMigration) error
+	UpdateProgress(migration *models.PlanMigration) error
+	MarkCompleted(id uuid.UUID, completedAt time.Time) error
+	List(limit, offset int) ([]*models.PlanMigration, error)
+	Count() (int64, error)
+	GetUnfinished() ([]*models.PlanMigration, error)
+	GetItems(migrationID uuid.UUID, status string, limit, offset int) ([]*models.PlanMigrationItem, error)
+	CountItems(migrationID uuid.UUID, status string) (int64, error)
+	GetPendingItems(migrationID uuid.UUID, limit int) ([]*models.PlanMigrationItem, error)
+	UpdateItem(item *models.PlanMigrationItem) error
+}
+
+// planMigrationRepository implements PlanMigrationRepository interface
+type planMigrationRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanMigrationRepository creates a new plan migration repository
+func NewPlanMigrationRepository(db *gorm.DB) PlanMigrationRepository {
+	return &planMigrationRepository{db: db}
+}
+
+// Create stores a migration and its items atomically
+func (r *planMigrationRepository) Create(migration *models.PlanMigration, items []*models.PlanMigrationItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(migration).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			item.MigrationID = migration.ID
+		}
+		return tx.CreateInBatches(items, 500).Error
+	})
+}
+
+// GetByID retrieves a migration by ID with its plans
+func (r *planMigrationRepository) GetByID(id uuid.UUID) (*models.PlanMigration, error) {
+	var migration models.PlanMigration
+	err := r.db.Preload("SourcePlan").Preload("TargetPlan").Where("id = ?", id).First(&migration).Error
+	if err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+// Update updates an existing migration
+func (r *planMigrationRepository) Update(migration *models.PlanMigration) error {
+	return r.db.Omit("SourcePlan", "TargetPlan", "Items").Save(migration).Error
+}
+
+// UpdateProgress saves only the outcome counters, leaving status untouched so
+// a concurrent cancel is not overwritten
+func (r *planMigrationRepository) UpdateProgress(migration *models.PlanMigration) error {
+	return r.db.Model(migration).
+		Select("migrated_count", "scheduled_count", "skipped_count", "failed_count").
+		Updates(migration).Error
+}
+
+// MarkCompleted completes a migration unless it was canceled in the meantime
+func (r *planMigrationRepository) MarkCompleted(id uuid.UUID, completedAt time.Time) error {
+	return r.db.Model(&models.PlanMigration{}).
+		Where("id = ? AND status IN ?", id, []string{models.PlanMigrationStatusPending, models.PlanMigrationStatusRunning}).
+		Updates(map[string]interface{}{"status": models.PlanMigrationStatusCompleted, "completed_at": completedAt}).Error
+}
+
+// List retrieves migrations with pagination, newest first
+func (r *planMigrationRepository) List(limit, offset int) ([]*models.PlanMigration, error) {
+	var migrations []*models.PlanMigration
+	err := r.db.Preload("SourcePlan").Preload("TargetPlan").
+		Order("created_at DESC").Limit(limit).Offset(offset).Find(&migrations).Error
+	return migrations, err
+}
+
+// Count returns the total number of migrations
+func (r *planMigrationRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PlanMigration{}).Count(&count).Error
+	return count, err
+}
+
+// GetUnfinished retrieves migrations that still have work to do
+func (r *planMigrationRepository) GetUnfinished() ([]*models.PlanMigration, error) {
+	var migrations []*models.PlanMigration
+	err := r.db.Where("status IN ?", []string{models.PlanMigrationStatusPending, models.PlanMigrationStatusRunning}).
+		Order("created_at ASC").Find(&migrations).Error
+	return migrations, err
+}
+
+// GetItems retrieves migration items, optionally filtered by status
+func (r *planMigrationRepository) GetItems(migrationID uuid.UUID, status string, limit, offset int) ([]*models.PlanMigrationItem, error) {
+	var items []*models.PlanMigrationItem
+	query := r.db.Where("migration_id = ?", migrationID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&items).Error
+	return items, err
+}
+
+// CountItems counts migration items, optionally filtered by status
+func (r *planMigrationRepository) CountItems(migrationID uuid.UUID, status string) (int64, error) {
+	var count int64
+	query := r.db.Model(&models.PlanMigrationItem{}).Where("migration_id = ?", migrationID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// GetPendingItems retrieves the next batch of unprocessed items
+func (r *planMigrationRepository) GetPendingItems(migrationID uuid.UUID, limit int) ([]*models.PlanMigrationItem, error) {
+	var items []*models.PlanMigrationItem
+	err := r.db.Where("migration_id = ? AND status = ?", migrationID, models.PlanMigrationItemPending).
+		Order("created_at ASC, id ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// UpdateItem updates a migration item
+func (r *planMigrationRepository) UpdateItem(item *models.PlanMigrationItem) error {
+	return r.db.Save(item).Error
+}
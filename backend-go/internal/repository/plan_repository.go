@@ -15,6 +15,7 @@ type PlanRepository interface {
 	Update(plan *models.Plan) error
 	Delete(id uuid.UUID) error
 	List(limit, offset int) ([]*models.Plan, error)
+	GetAll() ([]*models.Plan, error)
 	Count() (int64, error)
 	GetActive() ([]*models.Plan, error)
 	GetPopular() ([]*models.Plan, error)
@@ -72,6 +73,13 @@ func (r *planRepository) List(limit, offset int) ([]*models.Plan, error) {
 	return plans, err
 }
 
+// GetAll retrieves every plan ordered by slug
+func (r *planRepository) GetAll() ([]*models.Plan, error) {
+	var plans []*models.Plan
+	err := r.db.Order("slug ASC").Find(&plans).Error
+	return plans, err
+}
+
 // Count returns the total number of plans
 func (r *planRepository) Count() (int64, error) {
 	var count int64
@@ -91,4 +99,4 @@ func (r *planRepository) GetPopular() ([]*models.Plan, error) {
 	var plans []*models.Plan
 	err := r.db.Where("is_active = ? AND is_popular = ?", true, true).Order("price ASC").Find(&plans).Error
 	return plans, err
-}
\ No newline at end of file
+}
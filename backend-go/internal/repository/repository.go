@@ -6,20 +6,52 @@ import (
 
 // Repositories holds all repository interfaces
 type Repositories struct {
-	User         UserRepository
-	Organization OrganizationRepository
-	Plan         PlanRepository
-	Subscription SubscriptionRepository
-	Invoice      InvoiceRepository
+	User                 UserRepository
+	Organization         OrganizationRepository
+	Plan                 PlanRepository
+	Subscription         SubscriptionRepository
+	Invoice              InvoiceRepository
+	InvoiceItem          InvoiceItemRepository
+	InvoiceSequence      InvoiceSequenceRepository
+	PlanMigration        PlanMigrationRepository
+	ExchangeRate         ExchangeRateRepository
+	PaymentMethod        PaymentMethodRepository
+	SubscriptionHistory  SubscriptionHistoryRepository
+	SubscriptionSchedule SubscriptionScheduleRepository
+	BillingRun           BillingRunRepository
+	JobLock              JobLockRepository
+	TestClock            TestClockRepository
+	Meter                MeterRepository
+	Usage                UsageRepository
+	UsageAlert           UsageAlertRepository
+	Dunning              DunningRepository
+	Retention            RetentionRepository
+	BillingAddress       BillingAddressRepository
 }
 
 // NewRepositories creates and returns all repositories
 func NewRepositories(db *gorm.DB) *Repositories {
 	return &Repositories{
-		User:         NewUserRepository(db),
-		Organization: NewOrganizationRepository(db),
-		Plan:         NewPlanRepository(db),
-		Subscription: NewSubscriptionRepository(db),
-		Invoice:      NewInvoiceRepository(db),
+		User:                 NewUserRepository(db),
+		Organization:         NewOrganizationRepository(db),
+		Plan:                 NewPlanRepository(db),
+		Subscription:         NewSubscriptionRepository(db),
+		Invoice:              NewInvoiceRepository(db),
+		InvoiceItem:          NewInvoiceItemRepository(db),
+		InvoiceSequence:      NewInvoiceSequenceRepository(db),
+		PlanMigration:        NewPlanMigrationRepository(db),
+		ExchangeRate:         NewExchangeRateRepository(db),
+		PaymentMethod:        NewPaymentMethodRepository(db),
+		SubscriptionHistory:  NewSubscriptionHistoryRepository(db),
+		SubscriptionSchedule: NewSubscriptionScheduleRepository(db),
+		BillingRun:           NewBillingRunRepository(db),
+		JobLock:              NewJobLockRepository(db),
+		TestClock:            NewTestClockRepository(db),
+		Meter:                NewMeterRepository(db),
+		Usage:                NewUsageRepository(db),
+		UsageAlert:           NewUsageAlertRepository(db),
+		Dunning:              NewDunningRepository(db),
+		Retention:            NewRetentionRepository(db),
+		BillingAddress:       NewBillingAddressRepository(db),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionRepository interface defines methods for retention offer and offer presentation data operations
+type RetentionRepository interface {
+	Create(offer *models.RetentionOffer) error
+	GetByID(id uuid.UUID) (*models.RetentionOffer, error)
+	Update(offer *models.RetentionOffer) error
+	Delete(id uuid.UUID) error
+	GetAll(limit, offset int) ([]*models.RetentionOffer, error)
+	Count() (int64, error)
+	GetActive() ([]*models.RetentionOffer, error)
+	CreatePresentation(presentation *models.RetentionOfferPresentation) error
+	GetPendingPresentations(subscriptionID uuid.UUID) ([]*models.RetentionOfferPresentation, error)
+	ResolvePresentations(subscriptionID uuid.UUID, acceptedOfferID *uuid.UUID, at time.Time) error
+	GetOfferStats(from, to time.Time) ([]RetentionOfferStats, error)
+	CountCancellations(from, to time.Time) ([]CancellationReasonCount, error)
+}
+
+// RetentionOfferStats counts how often a retention offer was shown and what
+// the customers did with it
+type RetentionOfferStats struct {
+	RetentionOfferID uuid.UUID
+	Name             string
+	Type             string
+	Presented        int64
+	Accepted         int64
+	Declined         int64
+}
+
+// CancellationReasonCount counts the subscriptions canceled for one reason
+type CancellationReasonCount struct {
+	Reason string
+	Count  int64
+}
+
+// RetentionAcceptance is a retention offer a subscription change accepts
+type RetentionAcceptance struct {
+	OfferID uuid.UUID
+	At      time.Time
+}
+
+// ErrRetentionOfferAnswered is returned when an accepted retention offer is no
+// longer pending because it was answered concurrently
+var ErrRetentionOfferAnswered = errors.New("retention offer was already answered")
+
+// retentionRepository implements RetentionRepository interface
+type retentionRepository struct {
+	db *gorm.DB
+}
+
+// NewRetentionRepository creates a new retention repository
+func NewRetentionRepository(db *gorm.DB) RetentionRepository {
+	return &retentionRepository{db: db}
+}
+
+// Create creates a new retention offer
+func (r *retentionRepository) Create(offer *models.RetentionOffer) error {
+	return r.db.Omit("Plan").Create(offer).Error
+}
+
+// GetByID retrieves a retention offer by ID with its downgrade plan
+func (r *retentionRepository) GetByID(id uuid.UUID) (*models.RetentionOffer, error) {
+	var offer models.RetentionOffer
+	err := r.db.Preload("Plan").Where("id = ?", id).First(&offer).Error
+	if err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// Update updates an existing retention offer
+func (r *retentionRepository) Update(offer *models.RetentionOffer) error {
+	return r.db.Omit("Plan").Save(offer).Error
+}
+
+// Delete soft deletes a retention offer by ID
+func (r *retentionRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.RetentionOffer{}, id).Error
+}
+
+// GetAll retrieves retention offers with their downgrade plans, with pagination
+func (r *retentionRepository) GetAll(limit, offset int) ([]*models.RetentionOffer, error) {
+	var offers []*models.RetentionOffer
+	err := r.db.Preload("Plan").Order("created_at ASC").Limit(limit).Offset(offset).Find(&offers).Error
+	return offers, err
+}
+
+// Count counts the retention offers
+func (r *retentionRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RetentionOffer{}).Count(&count).Error
+	return count, err
+}
+
+// GetActive retrieves the active retention offers with their downgrade plans
+func (r *retentionRepository) GetActive() ([]*models.RetentionOffer, error) {
+	var offers []*models.RetentionOffer
+	err := r.db.Preload("Plan").Where("is_active = ?", true).Order("created_at ASC").Find(&offers).Error
+	return offers, err
+}
+
+// CreatePresentation records a retention offer shown to a customer
+func (r *retentionRepository) CreatePresentation(presentation *models.RetentionOfferPresentation) error {
+	return r.db.Omit("RetentionOffer").Create(presentation).Error
+}
+
+// GetPendingPresentations retrieves the offers shown for a subscription that
+// the customer has not answered yet
+func (r *retentionRepository) GetPendingPresentations(subscriptionID uuid.UUID) ([]*models.RetentionOfferPresentation, error) {
+	var presentations []*models.RetentionOfferPresentation
+	err := r.db.Where("subscription_id = ? AND outcome = ?", subscriptionID, models.RetentionOfferPending).
+		Order("presented_at ASC").
+		Find(&presentations).Error
+	return presentations, err
+}
+
+// ResolvePresentations answers the pending offers of a subscription: the
+// accepted offer, if any, is marked accepted and the others declined
+func (r *retentionRepository) ResolvePresentations(subscriptionID uuid.UUID, acceptedOfferID *uuid.UUID, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		_, err := resolvePresentations(tx, subscriptionID, acceptedOfferID, at)
+		return err
+	})
+}
+
+// resolvePresentations answers the pending offers of a subscription within tx
+// and reports whether the accepted offer, if any, was still pending
+func resolvePresentations(tx *gorm.DB, subscriptionID uuid.UUID, acceptedOfferID *uuid.UUID, at time.Time) (bool, error) {
+	pending := func() *gorm.DB {
+		return tx.Model(&models.RetentionOfferPresentation{}).
+			Where("subscription_id = ? AND outcome = ?", subscriptionID, models.RetentionOfferPending)
+	}
+
+	accepted := true
+	if acceptedOfferID != nil {
+		result := pending().Where("retention_offer_id = ?", *acceptedOfferID).
+			Updates(map[string]interface{}{"outcome": models.RetentionOfferAccepted, "responded_at": at})
+		if result.Error != nil {
+			return false, result.Error
+		}
+		accepted = result.RowsAffected > 0
+	}
+	err := pending().Updates(map[string]interface{}{"outcome": models.RetentionOfferDeclined, "responded_at": at}).Error
+	return accepted, err
+}
+
+// GetOfferStats counts, per retention offer, the presentations made between
+// from and to by outcome. Offers deleted since are included.
+func (r *retentionRepository) GetOfferStats(from, to time.Time) ([]RetentionOfferStats, error) {
+	var stats []RetentionOfferStats
+	err := r.db.Table("retention_offer_presentations p").
+		Joins("JOIN retention_offers o ON o.id = p.retention_offer_id").
+		Select("p.retention_offer_id, o.name, o.type, COUNT(*) AS presented, "+
+			"COUNT(*) FILTER (WHERE p.outcome = ?) AS accepted, "+
+			"COUNT(*) FILTER (WHERE p.outcome = ?) AS declined",
+			models.RetentionOfferAccepted, models.RetentionOfferDeclined).
+		Where("p.presented_at >= ? AND p.presented_at <= ?", from, to).
+		Group("p.retention_offer_id, o.name, o.type").
+		Order("presented DESC").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// CountCancellations counts the subscriptions canceled between from and to by
+// cancellation reason; subscriptions canceled without a reason count under an
+// empty one
+func (r *retentionRepository) CountCancellations(from, to time.Time) ([]CancellationReasonCount, error) {
+	var counts []CancellationReasonCount
+	err := r.db.Model(&models.Subscription{}).
+		Select("COALESCE(cancellation_reason, '') AS reason, COUNT(*) AS count").
+		Where("canceled_at >= ? AND canceled_at <= ?", from, to).
+		Group("COALESCE(cancellation_reason, '')").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubscriptionHistoryRepository interface defines methods for subscription status history
+type SubscriptionHistoryRepository interface {
+	Create(change *models.SubscriptionStatusChange) error
+	GetBySubscriptionID(subscriptionID uuid.UUID, limit, offset int) ([]*models.SubscriptionStatusChange, error)
+	CountBySubscriptionID(subscriptionID uuid.UUID) (int64, error)
+}
+
+// subscriptionHistoryRepository implements SubscriptionHistoryRepository interface
+type subscriptionHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewSubscriptionHistoryRepository creates a new subscription history repository
+func NewSubscriptionHistoryRepository(db *gorm.DB) SubscriptionHistoryRepository {
+	return &subscriptionHistoryRepository{db: db}
+}
+
+// Create records a status change
+func (r *subscriptionHistoryRepository) Create(change *models.SubscriptionStatusChange) error {
+	return r.db.Create(change).Error
+}
+
+// GetBySubscriptionID retrieves the status changes of a subscription, oldest first
+func (r *subscriptionHistoryRepository) GetBySubscriptionID(subscriptionID uuid.UUID, limit, offset int) ([]*models.SubscriptionStatusChange, error) {
+	var changes []*models.SubscriptionStatusChange
+	err := r.db.Where("subscription_id = ?", subscriptionID).
+		Order("created_at ASC").Limit(limit).Offset(offset).
+		Find(&changes).Error
+	return changes, err
+}
+
+// CountBySubscriptionID counts the status changes of a subscription
+func (r *subscriptionHistoryRepository) CountBySubscriptionID(subscriptionID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.SubscriptionStatusChange{}).
+		Where("subscription_id = ?", subscriptionID).
+		Count(&count).Error
+	return count, err
+}
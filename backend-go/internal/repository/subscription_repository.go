@@ -1,27 +1,85 @@
 package repository
 
 import (
+	"errors"
 	"go-backend/internal/models"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SubscriptionRepository interface defines methods for subscription data operations
 type SubscriptionRepository interface {
 	Create(subscription *models.Subscription) error
+	Start(subscription *models.Subscription, change *models.SubscriptionStatusChange, invoice *models.Invoice) error
 	GetByID(id uuid.UUID) (*models.Subscription, error)
 	Update(subscription *models.Subscription) error
+	Transition(subscription *models.Subscription, from string, status *models.SubscriptionStatusChange, change SubscriptionChange) (bool, error)
+	TransitionUpdatingInvoices(subscription *models.Subscription, from string, change *models.SubscriptionStatusChange, invoices []InvoiceStatusUpdate) (bool, error)
+	ApplyChange(subscription *models.Subscription, readAt time.Time, change SubscriptionChange) (bool, error)
 	Delete(id uuid.UUID) error
 	List(limit, offset int) ([]*models.Subscription, error)
 	Count() (int64, error)
 	GetByOrganizationID(orgID uuid.UUID) ([]*models.Subscription, error)
-	GetActiveByOrganizationID(orgID uuid.UUID) (*models.Subscription, error)
-	GetExpiring(days int) ([]*models.Subscription, error)
+	GetActiveByOrganizationID(orgID uuid.UUID, now time.Time) ([]*models.Subscription, error)
+	GetByOrganizationAndProduct(orgID uuid.UUID, product string, statuses []string) (*models.Subscription, error)
+	GetExpiring(now time.Time, days int) ([]*models.Subscription, error)
 	GetByStatus(status string, limit, offset int) ([]*models.Subscription, error)
+	GetByPlanID(planID uuid.UUID, statuses []string) ([]*models.Subscription, error)
+	CountByPlanID(planID uuid.UUID, statuses []string) (int64, error)
+	GetTrialsEndingBefore(before time.Time, testClockID *uuid.UUID) ([]*models.Subscription, error)
+	GetPausedResumingBefore(before time.Time, testClockID *uuid.UUID) ([]*models.Subscription, error)
+	GetSeatSynced(testClockID *uuid.UUID) ([]*models.Subscription, error)
 }
 
+// EntitledStatuses are the subscription statuses that grant access to the
+// plan. Past-due subscriptions keep access while their payment is retried;
+// suspended ones lose it.
+var EntitledStatuses = []string{models.SubscriptionStatusActive, models.SubscriptionStatusTrialing, models.SubscriptionStatusPastDue}
+
+// OpenStatuses are the statuses of subscriptions that have not ended
+var OpenStatuses = []string{
+	models.SubscriptionStatusIncomplete,
+	models.SubscriptionStatusTrialing,
+	models.SubscriptionStatusActive,
+	models.SubscriptionStatusPastDue,
+	models.SubscriptionStatusSuspended,
+	models.SubscriptionStatusPaused,
+}
+
+// SubscriptionChange is what a change writes besides the subscription: the
+// usage of a period it ends or cuts short, the invoice for what it
+// charges, the schedule whose first phase it enters and the retention offer
+// it accepts. Any may be nil.
+type SubscriptionChange struct {
+	Usage     *UsageClosing
+	Invoice   *models.Invoice
+	Schedule  *models.SubscriptionSchedule // created with its phases
+	Retention *RetentionAcceptance         // resolves the subscription's pending offers
+}
+
+// InvoiceStatusUpdate is an invoice whose status a subscription change moved
+// on from From
+type InvoiceStatusUpdate struct {
+	Invoice *models.Invoice
+	From    string
+}
+
+// ErrInvoiceStatusChanged is returned when an invoice a subscription change
+// moves on is no longer in the status it was loaded in
+var ErrInvoiceStatusChanged = errors.New("invoice status changed concurrently")
+
+// ErrOpenSubscriptionExists is returned when a subscription would become the
+// organization's second open subscription to a product
+var ErrOpenSubscriptionExists = errors.New("organization already has an open subscription to this product")
+
+// openProductIndex is the unique index allowing one open subscription per
+// organization and product
+const openProductIndex = "idx_subscriptions_open_product"
+
 // subscriptionRepository implements SubscriptionRepository interface
 type subscriptionRepository struct {
 	db *gorm.DB
@@ -37,6 +95,36 @@ func (r *subscriptionRepository) Create(subscription *models.Subscription) error
 	return r.db.Create(subscription).Error
 }
 
+// Start creates a new subscription together with its first status change
+// and, unless it is nil, its first invoice in one transaction. It fails with
+// ErrOpenSubscriptionExists, without creating anything, when the
+// organization already holds an open subscription to the product.
+func (r *subscriptionRepository) Start(subscription *models.Subscription, change *models.SubscriptionStatusChange, invoice *models.Invoice) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(subscription).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		if invoice == nil {
+			return nil
+		}
+		return createInvoice(tx, invoice)
+	})
+	return openSubscriptionConflict(err)
+}
+
+// openSubscriptionConflict turns a violation of the one open subscription
+// per product index into ErrOpenSubscriptionExists
+func openSubscriptionConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == openProductIndex {
+		return ErrOpenSubscriptionExists
+	}
+	return err
+}
+
 // GetByID retrieves a subscription by ID with related data
 func (r *subscriptionRepository) GetByID(id uuid.UUID) (*models.Subscription, error) {
 	var subscription models.Subscription
@@ -47,9 +135,136 @@ func (r *subscriptionRepository) GetByID(id uuid.UUID) (*models.Subscription, er
 	return &subscription, nil
 }
 
-// Update updates an existing subscription
+// Update updates an existing subscription. Its status is left alone: it only
+// changes through Transition, which checks that no other change got there
+// first, so a save based on an older read cannot undo a transition.
 func (r *subscriptionRepository) Update(subscription *models.Subscription) error {
-	return r.db.Save(subscription).Error
+	return r.db.Omit("Status").Save(subscription).Error
+}
+
+// Transition saves a subscription whose status changed from from, together
+// with the change in its status history and what else the change writes, in
+// one transaction. It reports false, without changing
+// anything, when the subscription is no longer in from or was otherwise
+// changed since it was read because another change got there first, and
+// fails with ErrOpenSubscriptionExists when the change would reopen it beside
+// another open subscription to its product. The subscription takes the
+// updated_at the database stored, so it can be changed again.
+func (r *subscriptionRepository) Transition(subscription *models.Subscription, from string, status *models.SubscriptionStatusChange, change SubscriptionChange) (bool, error) {
+	readAt := subscription.UpdatedAt
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if updated, err = transition(tx, subscription, from, status); err != nil || !updated {
+			return err
+		}
+		return writeChange(tx, subscription, change)
+	})
+	if err != nil {
+		updated = false
+		subscription.UpdatedAt = readAt
+	}
+	return updated, openSubscriptionConflict(err)
+}
+
+// TransitionUpdatingInvoices saves a subscription whose status changed from
+// from like Transition, together with the status changes of the invoices the
+// change moves on. Nothing is saved when an invoice is no longer in its From
+// status; it then fails with ErrInvoiceStatusChanged.
+func (r *subscriptionRepository) TransitionUpdatingInvoices(subscription *models.Subscription, from string, change *models.SubscriptionStatusChange, invoices []InvoiceStatusUpdate) (bool, error) {
+	readAt := subscription.UpdatedAt
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if updated, err = transition(tx, subscription, from, change); err != nil || !updated {
+			return err
+		}
+		for _, update := range invoices {
+			saved, err := updateStatus(tx, update.Invoice, update.From)
+			if err != nil {
+				return err
+			}
+			if !saved {
+				return ErrInvoiceStatusChanged
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		updated = false
+		subscription.UpdatedAt = readAt
+	}
+	return updated, openSubscriptionConflict(err)
+}
+
+// transition saves a subscription within tx where it is still in the from
+// status and unchanged since it was read, and records the change in its
+// status history
+func transition(tx *gorm.DB, subscription *models.Subscription, from string, change *models.SubscriptionStatusChange) (bool, error) {
+	readAt := subscription.UpdatedAt
+	result := tx.Model(subscription).Where("status = ? AND updated_at = ?", from, readAt).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "updated_at"}}}).
+		Select("*").Omit(clause.Associations).Updates(subscription)
+	if result.Error != nil || result.RowsAffected == 0 {
+		subscription.UpdatedAt = readAt
+		return false, result.Error
+	}
+	return true, tx.Create(change).Error
+}
+
+// ApplyChange saves a subscription changed mid-period together with what
+// else the change writes in one transaction. The save only applies to the
+// subscription as it was read, last updated at readAt; it reports false,
+// without changing anything, when another change got there first. It fails
+// with ErrRetentionOfferAnswered, saving nothing, when the offer the change
+// accepts is no longer pending.
+// The subscription takes the updated_at the database stored, so it can be
+// changed again. Like Update it leaves the status alone.
+func (r *subscriptionRepository) ApplyChange(subscription *models.Subscription, readAt time.Time, change SubscriptionChange) (bool, error) {
+	stored := subscription.UpdatedAt
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(subscription).Where("updated_at = ?", readAt).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "updated_at"}}}).
+			Select("*").Omit("Status", clause.Associations).Updates(subscription)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return writeChange(tx, subscription, change)
+	})
+	if err != nil || !updated {
+		updated = false
+		subscription.UpdatedAt = stored
+	}
+	return updated, err
+}
+
+// writeChange writes within tx what a change of subscription writes besides it
+func writeChange(tx *gorm.DB, subscription *models.Subscription, change SubscriptionChange) error {
+	if change.Usage != nil {
+		if err := closeUsage(tx, change.Usage); err != nil {
+			return err
+		}
+	}
+	if change.Schedule != nil {
+		if err := tx.Create(change.Schedule).Error; err != nil {
+			return err
+		}
+	}
+	if change.Retention != nil {
+		accepted, err := resolvePresentations(tx, subscription.ID, &change.Retention.OfferID, change.Retention.At)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return ErrRetentionOfferAnswered
+		}
+	}
+	if change.Invoice == nil {
+		return nil
+	}
+	return createInvoice(tx, change.Invoice)
 }
 
 // Delete soft deletes a subscription by ID
@@ -78,12 +293,25 @@ func (r *subscriptionRepository) GetByOrganizationID(orgID uuid.UUID) ([]*models
 	return subscriptions, err
 }
 
-// GetActiveByOrganizationID retrieves the active subscription for an organization
-func (r *subscriptionRepository) GetActiveByOrganizationID(orgID uuid.UUID) (*models.Subscription, error) {
+// GetActiveByOrganizationID retrieves the subscriptions of an organization that are active or trialing at the given time, ordered by product
+func (r *subscriptionRepository) GetActiveByOrganizationID(orgID uuid.UUID, now time.Time) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.db.Preload("Plan").
+		Joins("JOIN plans ON plans.id = subscriptions.plan_id").
+		Where("subscriptions.organization_id = ? AND subscriptions.status IN ?", orgID, EntitledStatuses).
+		Where("(subscriptions.end_date IS NULL OR subscriptions.end_date > ?)", now).
+		Order("plans.product ASC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetByOrganizationAndProduct retrieves an organization's subscription in one of the given statuses to a plan of the product
+func (r *subscriptionRepository) GetByOrganizationAndProduct(orgID uuid.UUID, product string, statuses []string) (*models.Subscription, error) {
 	var subscription models.Subscription
 	err := r.db.Preload("Plan").
-		Where("organization_id = ? AND status = ?", orgID, "active").
-		Where("(end_date IS NULL OR end_date > ?)", time.Now()).
+		Joins("JOIN plans ON plans.id = subscriptions.plan_id").
+		Where("subscriptions.organization_id = ? AND subscriptions.status IN ? AND plans.product = ?", orgID, statuses, product).
+		Order("subscriptions.created_at DESC").
 		First(&subscription).Error
 	if err != nil {
 		return nil, err
@@ -91,12 +319,12 @@ func (r *subscriptionRepository) GetActiveByOrganizationID(orgID uuid.UUID) (*mo
 	return &subscription, nil
 }
 
-// GetExpiring retrieves subscriptions expiring within the specified number of days
-func (r *subscriptionRepository) GetExpiring(days int) ([]*models.Subscription, error) {
+// GetExpiring retrieves subscriptions expiring within the specified number of days after now
+func (r *subscriptionRepository) GetExpiring(now time.Time, days int) ([]*models.Subscription, error) {
 	var subscriptions []*models.Subscription
-	expiryDate := time.Now().AddDate(0, 0, days)
+	expiryDate := now.AddDate(0, 0, days)
 	err := r.db.Preload("Organization").Preload("Plan").
-		Where("status = ? AND current_period_end <= ? AND current_period_end > ?", "active", expiryDate, time.Now()).
+		Where("status IN ? AND current_period_end <= ? AND current_period_end > ?", EntitledStatuses, expiryDate, now).
 		Find(&subscriptions).Error
 	return subscriptions, err
 }
@@ -109,4 +337,55 @@ func (r *subscriptionRepository) GetByStatus(status string, limit, offset int) (
 		Limit(limit).Offset(offset).
 		Find(&subscriptions).Error
 	return subscriptions, err
-}
\ No newline at end of file
+}
+
+// GetByPlanID retrieves subscriptions on a plan, restricted to the given statuses
+func (r *subscriptionRepository) GetByPlanID(planID uuid.UUID, statuses []string) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.db.Preload("Organization").
+		Where("plan_id = ? AND status IN ?", planID, statuses).
+		Order("current_period_end ASC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// CountByPlanID counts subscriptions on a plan, restricted to the given statuses
+func (r *subscriptionRepository) CountByPlanID(planID uuid.UUID, statuses []string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Subscription{}).
+		Where("plan_id = ? AND status IN ?", planID, statuses).
+		Count(&count).Error
+	return count, err
+}
+
+// GetTrialsEndingBefore retrieves trialing subscriptions whose trial ends at or before the given time
+func (r *subscriptionRepository) GetTrialsEndingBefore(before time.Time, testClockID *uuid.UUID) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.db.Preload("Organization").Preload("Plan").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("status = ? AND trial_end_date IS NOT NULL AND trial_end_date <= ?", models.SubscriptionStatusTrialing, before).
+		Order("trial_end_date ASC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetSeatSynced retrieves entitled subscriptions whose quantity follows the organization's active member count
+func (r *subscriptionRepository) GetSeatSynced(testClockID *uuid.UUID) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.db.Preload("Organization").Preload("Plan").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("status IN ? AND sync_seats = ?", EntitledStatuses, true).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetPausedResumingBefore retrieves paused subscriptions due to resume automatically at or before the given time
+func (r *subscriptionRepository) GetPausedResumingBefore(before time.Time, testClockID *uuid.UUID) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.db.Preload("Organization").Preload("Plan").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("status = ? AND resume_at IS NOT NULL AND resume_at <= ?", models.SubscriptionStatusPaused, before).
+		Order("resume_at ASC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/logger"
+)
+
+// statementLogger records the SQL of every statement a dry run builds
+type statementLogger struct {
+	logger.Interface
+	statements []string
+}
+
+func (l *statementLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.statements = append(l.statements, sql)
+}
+
+func TestUpdateLeavesSubscriptionStatusAlone(t *testing.T) {
+	recorder := &statementLogger{Interface: logger.Discard}
+	db := dryRunDB(t)
+	db.Logger = recorder
+
+	now := time.Now()
+	subscription := &models.Subscription{
+		ID:                 uuid.New(),
+		OrganizationID:     uuid.New(),
+		PlanID:             uuid.New(),
+		Status:             models.SubscriptionStatusActive,
+		StartDate:          now,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now.AddDate(0, 1, 0),
+		Quantity:           3,
+	}
+	if err := NewSubscriptionRepository(db).Update(subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	var update string
+	for _, sql := range recorder.statements {
+		if strings.HasPrefix(sql, `UPDATE "subscriptions" SET `) {
+			update = sql
+		}
+	}
+	if update == "" {
+		t.Fatalf("expected a subscription update, got %v", recorder.statements)
+	}
+	if !strings.Contains(update, `"quantity"=3`) {
+		t.Errorf("expected the quantity to be written, got %s", update)
+	}
+	if strings.Contains(update, `"status"=`) {
+		t.Errorf("expected the status to be left alone, got %s", update)
+	}
+}
+
+func TestTransitionRequiresTheSubscriptionAsRead(t *testing.T) {
+	recorder := &statementLogger{Interface: logger.Discard}
+	db := dryRunDB(t)
+	db.Logger = recorder
+
+	readAt := time.Date(2027, time.March, 1, 12, 0, 0, 0, time.UTC)
+	subscription := &models.Subscription{
+		ID:        uuid.New(),
+		Status:    models.SubscriptionStatusCanceled,
+		UpdatedAt: readAt,
+	}
+	change := &models.SubscriptionStatusChange{SubscriptionID: subscription.ID}
+	// A dry run updates no rows, so the transition reports a conflict
+	updated, err := transition(db, subscription, models.SubscriptionStatusActive, change)
+	if err != nil || updated {
+		t.Fatalf("transition = %v, %v; want false, nil", updated, err)
+	}
+
+	var update string
+	for _, sql := range recorder.statements {
+		if strings.HasPrefix(sql, `UPDATE "subscriptions" SET `) {
+			update = sql
+		}
+	}
+	if !strings.Contains(update, `status = 'active' AND updated_at = '2027-03-01 12:00:00`) {
+		t.Errorf("expected the update to require the status and updated_at as read, got %s", update)
+	}
+	if !subscription.UpdatedAt.Equal(readAt) {
+		t.Errorf("UpdatedAt = %v after a conflict, want %v", subscription.UpdatedAt, readAt)
+	}
+}
+
+func TestOpenSubscriptionConflict(t *testing.T) {
+	other := &pgconn.PgError{Code: "23505", ConstraintName: "subscriptions_pkey"}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{&pgconn.PgError{Code: "23505", ConstraintName: openProductIndex}, ErrOpenSubscriptionExists},
+		{fmt.Errorf("create: %w", &pgconn.PgError{Code: "23505", ConstraintName: openProductIndex}), ErrOpenSubscriptionExists},
+		{other, other}, // another unique index
+	}
+	for _, c := range cases {
+		if got := openSubscriptionConflict(c.err); got != c.want {
+			t.Errorf("openSubscriptionConflict(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubscriptionScheduleRepository interface defines methods for subscription schedule data operations
+type SubscriptionScheduleRepository interface {
+	Create(schedule *models.SubscriptionSchedule) error
+	GetByID(id uuid.UUID) (*models.SubscriptionSchedule, error)
+	GetActiveBySubscriptionID(subscriptionID uuid.UUID) (*models.SubscriptionSchedule, error)
+	Update(schedule *models.SubscriptionSchedule) error
+	ReplacePhases(schedule *models.SubscriptionSchedule, fromPosition int, phases []models.SubscriptionSchedulePhase) error
+	GetDue(before time.Time, testClockID *uuid.UUID) ([]*models.SubscriptionSchedule, error)
+}
+
+// subscriptionScheduleRepository implements SubscriptionScheduleRepository interface
+type subscriptionScheduleRepository struct {
+	db *gorm.DB
+}
+
+// NewSubscriptionScheduleRepository creates a new subscription schedule repository
+func NewSubscriptionScheduleRepository(db *gorm.DB) SubscriptionScheduleRepository {
+	return &subscriptionScheduleRepository{db: db}
+}
+
+// Create creates a schedule together with its phases
+func (r *subscriptionScheduleRepository) Create(schedule *models.SubscriptionSchedule) error {
+	return r.db.Create(schedule).Error
+}
+
+// GetByID retrieves a schedule with its phases in order
+func (r *subscriptionScheduleRepository) GetByID(id uuid.UUID) (*models.SubscriptionSchedule, error) {
+	var schedule models.SubscriptionSchedule
+	err := r.withPhases().Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetActiveBySubscriptionID retrieves the schedule currently controlling a subscription
+func (r *subscriptionScheduleRepository) GetActiveBySubscriptionID(subscriptionID uuid.UUID) (*models.SubscriptionSchedule, error) {
+	var schedule models.SubscriptionSchedule
+	err := r.withPhases().
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.ScheduleStatusActive).
+		First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// Update updates the schedule row; phases are changed through ReplacePhases
+func (r *subscriptionScheduleRepository) Update(schedule *models.SubscriptionSchedule) error {
+	return r.db.Omit("Phases", "Subscription").Save(schedule).Error
+}
+
+// ReplacePhases deletes the phases at or after fromPosition and inserts the
+// given ones in a single transaction
+func (r *subscriptionScheduleRepository) ReplacePhases(schedule *models.SubscriptionSchedule, fromPosition int, phases []models.SubscriptionSchedulePhase) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ? AND position >= ?", schedule.ID, fromPosition).
+			Delete(&models.SubscriptionSchedulePhase{}).Error; err != nil {
+			return err
+		}
+		for i := range phases {
+			phases[i].ScheduleID = schedule.ID
+		}
+		if len(phases) > 0 {
+			if err := tx.Omit("Plan").Create(&phases).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Phases", "Subscription").Save(schedule).Error
+	})
+}
+
+// GetDue retrieves active schedules whose current phase ends at or before the given time
+func (r *subscriptionScheduleRepository) GetDue(before time.Time, testClockID *uuid.UUID) ([]*models.SubscriptionSchedule, error) {
+	var schedules []*models.SubscriptionSchedule
+	subscriptions := r.db.Model(&models.Subscription{}).Select("id").Scopes(onTestClock("organization_id", testClockID))
+	err := r.withPhases().
+		Joins("JOIN subscription_schedule_phases AS current ON current.schedule_id = subscription_schedules.id AND current.position = subscription_schedules.current_phase").
+		Where("subscription_schedules.subscription_id IN (?)", subscriptions).
+		Where("subscription_schedules.status = ? AND current.end_date IS NOT NULL AND current.end_date <= ?", models.ScheduleStatusActive, before).
+		Order("current.end_date ASC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// withPhases preloads phases in position order together with their plans
+func (r *subscriptionScheduleRepository) withPhases() *gorm.DB {
+	return r.db.Preload("Phases", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Phases.Plan")
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestClockRepository interface defines methods for test clock data operations
+type TestClockRepository interface {
+	Create(clock *models.TestClock) error
+	GetByID(id uuid.UUID) (*models.TestClock, error)
+	Update(clock *models.TestClock) error
+	Delete(id uuid.UUID) error
+	List(limit, offset int) ([]*models.TestClock, error)
+	Count() (int64, error)
+	StartAdvance(id uuid.UUID) (bool, error)
+	NextEventAt(id uuid.UUID, after, until time.Time) (*time.Time, error)
+}
+
+// testClockRepository implements TestClockRepository interface
+type testClockRepository struct {
+	db *gorm.DB
+}
+
+// NewTestClockRepository creates a new test clock repository
+func NewTestClockRepository(db *gorm.DB) TestClockRepository {
+	return &testClockRepository{db: db}
+}
+
+// onTestClock scopes a query to the organizations attached to the given test
+// clock, or to the organizations running on real time when testClockID is
+// nil. column names the organization ID column of the queried table.
+func onTestClock(column string, testClockID *uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if testClockID == nil {
+			return db.Where(column + " IN (SELECT id FROM organizations WHERE test_clock_id IS NULL)")
+		}
+		return db.Where(column+" IN (SELECT id FROM organizations WHERE test_clock_id = ?)", *testClockID)
+	}
+}
+
+// Create creates a new test clock
+func (r *testClockRepository) Create(clock *models.TestClock) error {
+	return r.db.Create(clock).Error
+}
+
+// GetByID retrieves a test clock by ID with its organizations
+func (r *testClockRepository) GetByID(id uuid.UUID) (*models.TestClock, error) {
+	var clock models.TestClock
+	err := r.db.Preload("Organizations").Where("id = ?", id).First(&clock).Error
+	if err != nil {
+		return nil, err
+	}
+	return &clock, nil
+}
+
+// Update updates an existing test clock
+func (r *testClockRepository) Update(clock *models.TestClock) error {
+	return r.db.Omit("Organizations").Save(clock).Error
+}
+
+// Delete soft deletes a test clock by ID
+func (r *testClockRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.TestClock{}, id).Error
+}
+
+// List retrieves test clocks, newest first
+func (r *testClockRepository) List(limit, offset int) ([]*models.TestClock, error) {
+	var clocks []*models.TestClock
+	err := r.db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&clocks).Error
+	return clocks, err
+}
+
+// Count returns the total number of test clocks
+func (r *testClockRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TestClock{}).Count(&count).Error
+	return count, err
+}
+
+// StartAdvance marks a ready clock as advancing and reports whether it did,
+// so a clock is never advanced twice at once
+func (r *testClockRepository) StartAdvance(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.TestClock{}).
+		Where("id = ? AND status = ?", id, models.TestClockStatusReady).
+		Update("status", models.TestClockStatusAdvancing)
+	return result.RowsAffected > 0, result.Error
+}
+
+// nextEventQuery finds the earliest moment in (after, until] at which a
+// billing job has work for the organizations of a test clock: a trial ending,
+// a period ending, a paused subscription resuming, a schedule phase ending,
+// an invoice falling due or becoming overdue, or a failed payment's retry.
+const nextEventQuery = `
+WITH clock_subscriptions AS (
+  SELECT s.* FROM subscriptions s
+  WHERE s.deleted_at IS NULL
+    AND s.organization_id IN (SELECT o.id FROM organizations o WHERE o.test_clock_id = @clock_id)
+)
+SELECT MIN(at) AS at FROM (
+  SELECT trial_end_date AS at FROM clock_subscriptions WHERE status = 'trialing'
+  UNION ALL
+  SELECT current_period_end FROM clock_subscriptions WHERE status = 'active'
+  UNION ALL
+  SELECT resume_at FROM clock_subscriptions WHERE status = 'paused'
+  UNION ALL
+  SELECT p.end_date FROM subscription_schedules ss
+  JOIN subscription_schedule_phases p ON p.schedule_id = ss.id AND p.position = ss.current_phase
+  WHERE ss.status = 'active' AND ss.subscription_id IN (SELECT id FROM clock_subscriptions)
+  UNION ALL
+  SELECT i.due_date + INTERVAL '1 second' FROM invoices i
+  WHERE i.deleted_at IS NULL AND i.status IN ('draft', 'sent')
+    AND i.organization_id IN (SELECT o.id FROM organizations o WHERE o.test_clock_id = @clock_id)
+  UNION ALL
+  SELECT d.next_attempt_at FROM dunning_cases d
+  WHERE d.status = 'active'
+    AND d.organization_id IN (SELECT o.id FROM organizations o WHERE o.test_clock_id = @clock_id)
+) events
+WHERE at > @after AND at <= @until`
+
+// NextEventAt returns the earliest moment after after and no later than until
+// at which the organizations of a test clock have billing work due, or nil
+// when nothing is due in that window
+func (r *testClockRepository) NextEventAt(id uuid.UUID, after, until time.Time) (*time.Time, error) {
+	var next struct {
+		At *time.Time
+	}
+	err := r.db.Raw(nextEventQuery, map[string]interface{}{
+		"clock_id": id,
+		"after":    after,
+		"until":    until,
+	}).Scan(&next).Error
+	return next.At, err
+}
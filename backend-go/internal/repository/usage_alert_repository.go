@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UsageAlertRepository interface defines methods for usage alert and alert trigger data operations
+type UsageAlertRepository interface {
+	Create(alert *models.UsageAlert) error
+	GetByID(id uuid.UUID) (*models.UsageAlert, error)
+	Update(alert *models.UsageAlert) error
+	Delete(id uuid.UUID) error
+	GetByOrganizationID(orgID uuid.UUID) ([]*models.UsageAlert, error)
+	GetActiveByOrganizationID(orgID uuid.UUID) ([]*models.UsageAlert, error)
+	GetActive(testClockID *uuid.UUID) ([]*models.UsageAlert, error)
+	CreateTrigger(trigger *models.UsageAlertTrigger) (bool, error)
+	UpdateTrigger(trigger *models.UsageAlertTrigger) error
+	GetTriggersByOrganizationID(orgID uuid.UUID, limit, offset int) ([]*models.UsageAlertTrigger, error)
+	CountTriggersByOrganizationID(orgID uuid.UUID) (int64, error)
+}
+
+// usageAlertRepository implements UsageAlertRepository interface
+type usageAlertRepository struct {
+	db *gorm.DB
+}
+
+// NewUsageAlertRepository creates a new usage alert repository
+func NewUsageAlertRepository(db *gorm.DB) UsageAlertRepository {
+	return &usageAlertRepository{db: db}
+}
+
+// Create creates a new usage alert
+func (r *usageAlertRepository) Create(alert *models.UsageAlert) error {
+	return r.db.Omit("Organization", "Meter").Create(alert).Error
+}
+
+// GetByID retrieves a usage alert by ID with its meter
+func (r *usageAlertRepository) GetByID(id uuid.UUID) (*models.UsageAlert, error) {
+	var alert models.UsageAlert
+	err := r.db.Preload("Meter").Where("id = ?", id).First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// Update updates an existing usage alert
+func (r *usageAlertRepository) Update(alert *models.UsageAlert) error {
+	return r.db.Omit("Organization", "Meter").Save(alert).Error
+}
+
+// Delete soft deletes a usage alert by ID
+func (r *usageAlertRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.UsageAlert{}, id).Error
+}
+
+// GetByOrganizationID retrieves the usage alerts of an organization with their meters
+func (r *usageAlertRepository) GetByOrganizationID(orgID uuid.UUID) ([]*models.UsageAlert, error) {
+	var alerts []*models.UsageAlert
+	err := r.db.Preload("Meter").Where("organization_id = ?", orgID).Order("created_at ASC").Find(&alerts).Error
+	return alerts, err
+}
+
+// GetActiveByOrganizationID retrieves the active usage alerts of an organization with their meters
+func (r *usageAlertRepository) GetActiveByOrganizationID(orgID uuid.UUID) ([]*models.UsageAlert, error) {
+	var alerts []*models.UsageAlert
+	err := r.db.Preload("Meter").
+		Where("organization_id = ? AND is_active = ?", orgID, true).
+		Order("created_at ASC").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// GetActive retrieves every active usage alert with its organization and
+// meter, ordered by organization
+func (r *usageAlertRepository) GetActive(testClockID *uuid.UUID) ([]*models.UsageAlert, error) {
+	var alerts []*models.UsageAlert
+	err := r.db.Preload("Organization").Preload("Meter").
+		Scopes(onTestClock("organization_id", testClockID)).
+		Where("is_active = ?", true).
+		Order("organization_id ASC, created_at ASC").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// CreateTrigger records a crossed threshold unless it was already recorded
+// for the period, and reports whether it was recorded
+func (r *usageAlertRepository) CreateTrigger(trigger *models.UsageAlertTrigger) (bool, error) {
+	result := r.db.Omit("UsageAlert").Clauses(clause.OnConflict{DoNothing: true}).Create(trigger)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateTrigger updates an existing alert trigger
+func (r *usageAlertRepository) UpdateTrigger(trigger *models.UsageAlertTrigger) error {
+	return r.db.Omit("UsageAlert").Save(trigger).Error
+}
+
+// GetTriggersByOrganizationID retrieves the alert triggers of an organization
+// with their alerts, newest first, with pagination
+func (r *usageAlertRepository) GetTriggersByOrganizationID(orgID uuid.UUID, limit, offset int) ([]*models.UsageAlertTrigger, error) {
+	var triggers []*models.UsageAlertTrigger
+	err := r.db.Preload("UsageAlert", unscoped).Preload("UsageAlert.Meter", unscoped).
+		Where("organization_id = ?", orgID).
+		Order("triggered_at DESC, threshold DESC").
+		Limit(limit).Offset(offset).
+		Find(&triggers).Error
+	return triggers, err
+}
+
+// CountTriggersByOrganizationID counts the alert triggers of an organization
+func (r *usageAlertRepository) CountTriggersByOrganizationID(orgID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UsageAlertTrigger{}).Where("organization_id = ?", orgID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UsageRepository interface defines methods for usage event and usage summary data operations
+type UsageRepository interface {
+	CreateEvent(event *models.UsageEvent) (bool, error)
+	WriteEvents(ctx context.Context, events []*models.UsageEvent) (int64, error)
+	Aggregate(meter *models.Meter, organizationID uuid.UUID, start, end time.Time) (string, error)
+	GetSummaries(subscriptionID uuid.UUID, periodStart time.Time) ([]*models.UsageSummary, error)
+	GetLateSummaries(subscriptionID uuid.UUID) ([]*models.UsageSummary, error)
+}
+
+// UsageClosing is what closing a period of metered usage writes: the usage
+// invoice, when anything is billed, and the summaries of the periods it bills
+type UsageClosing struct {
+	Invoice *models.Invoice
+	Created []*models.UsageSummary
+	Updated []*models.UsageSummary
+}
+
+// usageRepository implements UsageRepository interface
+type usageRepository struct {
+	db *gorm.DB
+}
+
+// NewUsageRepository creates a new usage repository
+func NewUsageRepository(db *gorm.DB) UsageRepository {
+	return &usageRepository{db: db}
+}
+
+// CreateEvent stores a usage event unless the organization already reported
+// one with the same event ID, and reports whether it was stored
+func (r *usageRepository) CreateEvent(event *models.UsageEvent) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	return result.RowsAffected > 0, result.Error
+}
+
+// usageEventColumns are the usage_events columns written by WriteEvents
+var usageEventColumns = []string{"id", "organization_id", "event_id", "event_name", "occurred_at", "properties", "ingested_at", "created_at"}
+
+// WriteEvents stores a batch of usage events and returns how many were new.
+// The batch is streamed with COPY into a temporary table and moved into
+// usage_events with a single INSERT that skips event IDs already stored, so
+// retried batches and repeated events are written once. Databases not
+// reached through pgx fall back to batched inserts.
+func (r *usageRepository) WriteEvents(ctx context.Context, events []*models.UsageEvent) (int64, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	for _, event := range events {
+		if event.ID == uuid.Nil {
+			event.ID = uuid.New()
+		}
+	}
+
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return 0, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var written int64
+	copied := false
+	err = conn.Raw(func(driverConn interface{}) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return nil
+		}
+		copied = true
+		var copyErr error
+		written, copyErr = copyEvents(ctx, pgxConn.Conn(), events)
+		return copyErr
+	})
+	if err != nil || copied {
+		return written, err
+	}
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(events, 1000)
+	return result.RowsAffected, result.Error
+}
+
+// copyEvents writes events through a temporary staging table in one transaction
+func copyEvents(ctx context.Context, conn *pgx.Conn, events []*models.UsageEvent) (int64, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "CREATE TEMP TABLE usage_events_staging (LIKE usage_events INCLUDING DEFAULTS) ON COMMIT DROP"); err != nil {
+		return 0, err
+	}
+
+	rows := pgx.CopyFromSlice(len(events), func(i int) ([]interface{}, error) {
+		event := events[i]
+		properties, err := json.Marshal(event.Properties)
+		if err != nil {
+			return nil, err
+		}
+		if event.Properties == nil {
+			properties = []byte("{}")
+		}
+		return []interface{}{
+			pgtype.UUID{Bytes: event.ID, Valid: true},
+			pgtype.UUID{Bytes: event.OrganizationID, Valid: true},
+			event.EventID,
+			event.EventName,
+			event.OccurredAt,
+			string(properties),
+			event.IngestedAt,
+			event.CreatedAt,
+		}, nil
+	})
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"usage_events_staging"}, usageEventColumns, rows); err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `INSERT INTO usage_events (id, organization_id, event_id, event_name, occurred_at, properties, ingested_at, created_at)
+		SELECT id, organization_id, event_id, event_name, occurred_at, properties, ingested_at, created_at FROM usage_events_staging
+		ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// aggregateExpressions computes each meter aggregation over the value
+// property, which is bound as the only argument where it is used
+var aggregateExpressions = map[string]string{
+	models.MeterAggregationSum:         "COALESCE(SUM((properties->>?)::numeric), 0)",
+	models.MeterAggregationCount:       "COUNT(*)",
+	models.MeterAggregationMax:         "COALESCE(MAX((properties->>?)::numeric), 0)",
+	models.MeterAggregationLast:        "COALESCE((ARRAY_AGG((properties->>?)::numeric ORDER BY occurred_at DESC, ingested_at DESC))[1], 0)",
+	models.MeterAggregationUniqueCount: "COUNT(DISTINCT properties->>?)",
+}
+
+// Aggregate returns the decimal quantity a meter records for an organization
+// from the events that occurred in [start, end)
+func (r *usageRepository) Aggregate(meter *models.Meter, organizationID uuid.UUID, start, end time.Time) (string, error) {
+	expression, ok := aggregateExpressions[meter.Aggregation]
+	if !ok {
+		return "", fmt.Errorf("unknown meter aggregation %q", meter.Aggregation)
+	}
+
+	var args []interface{}
+	if meter.Aggregation != models.MeterAggregationCount {
+		args = append(args, meter.ValueProperty)
+	}
+
+	query := r.db.Model(&models.UsageEvent{}).
+		Select("CAST("+expression+" AS TEXT) AS quantity", args...).
+		Where("organization_id = ? AND event_name = ? AND occurred_at >= ? AND occurred_at < ?", organizationID, meter.EventName, start, end)
+	if len(meter.Filter) > 0 {
+		query = query.Where("properties @> ?::jsonb", meter.Filter)
+	}
+
+	var result struct {
+		Quantity string
+	}
+	if err := query.Scan(&result).Error; err != nil {
+		return "", err
+	}
+	return result.Quantity, nil
+}
+
+// GetSummaries retrieves the usage billed for a subscription period
+func (r *usageRepository) GetSummaries(subscriptionID uuid.UUID, periodStart time.Time) ([]*models.UsageSummary, error) {
+	var summaries []*models.UsageSummary
+	err := r.db.Where("subscription_id = ? AND period_start = ?", subscriptionID, periodStart).Find(&summaries).Error
+	return summaries, err
+}
+
+// unscoped preloads meters and prices even when they were deleted after billing
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// GetLateSummaries retrieves the closed periods of a subscription that
+// received events for their meter after they were billed. Events are compared
+// by when they were written, not when they were accepted, so events still
+// queued for writing while a period closed are caught as late.
+func (r *usageRepository) GetLateSummaries(subscriptionID uuid.UUID) ([]*models.UsageSummary, error) {
+	var summaries []*models.UsageSummary
+	err := r.db.Preload("Meter", unscoped).Preload("MeteredPrice", unscoped).
+		Where("subscription_id = ?", subscriptionID).
+		Where(`EXISTS (
+			SELECT 1 FROM usage_events e
+			WHERE e.organization_id = (SELECT organization_id FROM subscriptions WHERE id = usage_summaries.subscription_id)
+			  AND e.event_name = (SELECT event_name FROM meters WHERE id = usage_summaries.meter_id)
+			  AND e.occurred_at >= usage_summaries.period_start AND e.occurred_at < usage_summaries.period_end
+			  AND e.created_at > usage_summaries.updated_at
+		)`).
+		Order("period_start ASC").
+		Find(&summaries).Error
+	return summaries, err
+}
+
+// closeUsage creates the usage invoice, when there is one, together with the
+// summaries of the periods it bills within tx, so a period is never billed
+// without being marked closed or closed without being billed
+func closeUsage(tx *gorm.DB, closing *UsageClosing) error {
+	if closing.Invoice != nil {
+		if err := createInvoice(tx, closing.Invoice); err != nil {
+			return err
+		}
+	}
+	for _, summary := range closing.Created {
+		if err := tx.Omit("Meter", "MeteredPrice").Create(summary).Error; err != nil {
+			return err
+		}
+	}
+	for _, summary := range closing.Updated {
+		if err := tx.Omit("Meter", "MeteredPrice").Save(summary).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
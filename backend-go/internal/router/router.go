@@ -47,6 +47,16 @@ func SetupRouter(handlers *handlers.Handlers, jwtManager *utils.JWTManager) *gin
 	registerPlanRoutes(v1, handlers.Plan, authMiddleware)
 	registerSubscriptionRoutes(v1, handlers.Subscription, authMiddleware)
 	registerInvoiceRoutes(v1, handlers.Invoice, authMiddleware)
+	registerCurrencyRoutes(v1, handlers.Currency)
+	registerExchangeRateRoutes(v1, handlers.ExchangeRate, authMiddleware)
+	registerAnalyticsRoutes(v1, handlers.Analytics, authMiddleware)
+	registerBillingRoutes(v1, handlers.Billing, authMiddleware)
+	registerTestClockRoutes(v1, handlers.TestClock, authMiddleware)
+	registerMeteringRoutes(v1, handlers.Metering, authMiddleware)
+	registerUsageAlertRoutes(v1, handlers.UsageAlert, authMiddleware)
+	registerDunningRoutes(v1, handlers.Dunning, authMiddleware)
+	registerRetentionRoutes(v1, handlers.Retention, authMiddleware)
+	registerInvoiceSequenceRoutes(v1, handlers.InvoiceSequence, authMiddleware)
 
 	// Protected routes that require authentication
 	protected := v1.Group("", authMiddleware)
@@ -68,7 +78,6 @@ func SetupRouter(handlers *handlers.Handlers, jwtManager *utils.JWTManager) *gin
 		admin.GET("/users", getUsers)
 		admin.GET("/organizations", getAllOrganizations)
 		admin.GET("/subscriptions", getAllSubscriptions)
-		admin.GET("/analytics", getAnalytics)
 	}
 
 	// 404 handler
@@ -99,6 +108,56 @@ func registerInvoiceRoutes(router *gin.RouterGroup, invoiceHandler *handlers.Inv
 	invoiceHandler.RegisterRoutes(router, authMiddleware)
 }
 
+// registerCurrencyRoutes registers currency routes
+func registerCurrencyRoutes(router *gin.RouterGroup, currencyHandler *handlers.CurrencyHandler) {
+	currencyHandler.RegisterRoutes(router)
+}
+
+// registerExchangeRateRoutes registers exchange rate routes
+func registerExchangeRateRoutes(router *gin.RouterGroup, exchangeRateHandler *handlers.ExchangeRateHandler, authMiddleware gin.HandlerFunc) {
+	exchangeRateHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerAnalyticsRoutes registers analytics and export routes
+func registerAnalyticsRoutes(router *gin.RouterGroup, analyticsHandler *handlers.AnalyticsHandler, authMiddleware gin.HandlerFunc) {
+	analyticsHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerBillingRoutes registers billing engine routes
+func registerBillingRoutes(router *gin.RouterGroup, billingHandler *handlers.BillingHandler, authMiddleware gin.HandlerFunc) {
+	billingHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerTestClockRoutes registers test clock routes
+func registerTestClockRoutes(router *gin.RouterGroup, testClockHandler *handlers.TestClockHandler, authMiddleware gin.HandlerFunc) {
+	testClockHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerMeteringRoutes registers metering and usage ingestion routes
+func registerMeteringRoutes(router *gin.RouterGroup, meteringHandler *handlers.MeteringHandler, authMiddleware gin.HandlerFunc) {
+	meteringHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerUsageAlertRoutes registers usage alert and usage limit routes
+func registerUsageAlertRoutes(router *gin.RouterGroup, usageAlertHandler *handlers.UsageAlertHandler, authMiddleware gin.HandlerFunc) {
+	usageAlertHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerDunningRoutes registers dunning routes
+func registerDunningRoutes(router *gin.RouterGroup, dunningHandler *handlers.DunningHandler, authMiddleware gin.HandlerFunc) {
+	dunningHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerRetentionRoutes registers retention offer routes
+func registerRetentionRoutes(router *gin.RouterGroup, retentionHandler *handlers.RetentionHandler, authMiddleware gin.HandlerFunc) {
+	retentionHandler.RegisterRoutes(router, authMiddleware)
+}
+
+// registerInvoiceSequenceRoutes registers invoice numbering sequence routes
+func registerInvoiceSequenceRoutes(router *gin.RouterGroup, invoiceSequenceHandler *handlers.InvoiceSequenceHandler, authMiddleware gin.HandlerFunc) {
+	invoiceSequenceHandler.RegisterRoutes(router, authMiddleware)
+}
+
 // Placeholder handlers for endpoints not yet implemented
 
 // getProfile gets user profile
@@ -172,9 +231,9 @@ func getOrganization(c *gin.Context) {
 
 	// TODO: Implement organization retrieval
 	utils.SuccessResponse(c, http.StatusOK, "Organization retrieved successfully", gin.H{
-		"user_id":        userID,
+		"user_id":         userID,
 		"organization_id": orgID,
-		"message":        "Organization endpoint not yet implemented",
+		"message":         "Organization endpoint not yet implemented",
 	})
 }
 
@@ -189,9 +248,9 @@ func updateOrganization(c *gin.Context) {
 
 	// TODO: Implement organization update
 	utils.SuccessResponse(c, http.StatusOK, "Organization updated successfully", gin.H{
-		"user_id":        userID,
+		"user_id":         userID,
 		"organization_id": orgID,
-		"message":        "Organization update endpoint not yet implemented",
+		"message":         "Organization update endpoint not yet implemented",
 	})
 }
 
@@ -220,11 +279,3 @@ func getAllSubscriptions(c *gin.Context) {
 		"message": "Admin subscriptions endpoint not yet implemented",
 	})
 }
-
-// getAnalytics gets system analytics (admin only)
-func getAnalytics(c *gin.Context) {
-	// TODO: Implement analytics
-	utils.SuccessResponse(c, http.StatusOK, "Analytics retrieved successfully", gin.H{
-		"message": "Admin analytics endpoint not yet implemented",
-	})
-}
\ No newline at end of file
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go-backend/config"
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BillingEngine renews, cancels and expires subscriptions whose period has
+// ended. Each period is claimed in billing_run_items before it is processed,
+// so running the engine on several replicas at once is safe and a period is
+// never billed twice.
+type BillingEngine struct {
+	runRepo          repository.BillingRunRepository
+	subscriptionRepo repository.SubscriptionRepository
+	subscriptions    *SubscriptionService
+	batchSize        int
+	workers          int
+	maxAttempts      int
+	retryBackoff     time.Duration
+	retryBackoffMax  time.Duration
+	claimStaleness   time.Duration
+	instance         string
+	clock            clock.Clock
+	testClockID      *uuid.UUID // set when the engine bills the organizations of a test clock
+}
+
+// NewBillingEngine creates a new billing engine
+func NewBillingEngine(
+	runRepo repository.BillingRunRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	subscriptions *SubscriptionService,
+	billing config.BillingConfig,
+	clk clock.Clock,
+) *BillingEngine {
+	instance, _ := os.Hostname()
+
+	engine := &BillingEngine{
+		runRepo:          runRepo,
+		subscriptionRepo: subscriptionRepo,
+		subscriptions:    subscriptions,
+		batchSize:        billing.BatchSize,
+		workers:          billing.Workers,
+		maxAttempts:      billing.MaxAttempts,
+		retryBackoff:     billing.RetryBackoff,
+		retryBackoffMax:  billing.RetryBackoffMax,
+		claimStaleness:   billing.ClaimStaleness,
+		instance:         instance,
+		clock:            clk,
+	}
+	if engine.batchSize <= 0 {
+		engine.batchSize = 100
+	}
+	if engine.workers <= 0 {
+		engine.workers = 1
+	}
+	if engine.maxAttempts <= 0 {
+		engine.maxAttempts = 1
+	}
+	if engine.retryBackoff <= 0 {
+		engine.retryBackoff = 15 * time.Minute
+	}
+	if engine.retryBackoffMax < engine.retryBackoff {
+		engine.retryBackoffMax = engine.retryBackoff
+	}
+	if engine.claimStaleness <= 0 {
+		engine.claimStaleness = 15 * time.Minute
+	}
+	return engine
+}
+
+// ProcessDue runs the engine as a background job
+func (e *BillingEngine) ProcessDue() error {
+	_, err := e.Run()
+	return err
+}
+
+// Run claims and processes batches of ended periods until none are left and
+// records the run with its outcome counts
+func (e *BillingEngine) Run() (*models.BillingRun, error) {
+	run, err := e.createRun()
+	if err != nil {
+		return nil, err
+	}
+	return run, e.finishRun(run, e.process(run))
+}
+
+// StartRun records a new run and processes it in the background, returning
+// the run as it was created
+func (e *BillingEngine) StartRun() (*models.BillingRun, error) {
+	run, err := e.createRun()
+	if err != nil {
+		return nil, err
+	}
+
+	started := *run
+	go func() {
+		if err := e.finishRun(run, e.process(run)); err != nil {
+			log.Printf("Billing run %s failed: %v", run.ID, err)
+		}
+	}()
+	return &started, nil
+}
+
+// createRun records the start of a run
+func (e *BillingEngine) createRun() (*models.BillingRun, error) {
+	run := &models.BillingRun{
+		Instance:  e.instance,
+		Status:    models.BillingRunStatusRunning,
+		StartedAt: e.clock.Now(),
+	}
+	if err := e.runRepo.CreateRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// finishRun records the end of a run and returns runErr, or the error saving the run
+func (e *BillingEngine) finishRun(run *models.BillingRun, runErr error) error {
+	finishedAt := e.clock.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.BillingRunStatusCompleted
+	if runErr != nil {
+		run.Status = models.BillingRunStatusFailed
+		run.Error = runErr.Error()
+	}
+	if err := e.runRepo.UpdateRun(run); err != nil {
+		return err
+	}
+
+	if run.ClaimedCount > 0 || runErr != nil {
+		log.Printf("Billing run %s %s: %d claimed, %d renewed, %d expired, %d canceled, %d skipped, %d failed",
+			run.ID, run.Status, run.ClaimedCount, run.RenewedCount, run.ExpiredCount, run.CanceledCount, run.SkippedCount, run.FailedCount)
+	}
+	return runErr
+}
+
+// process claims batches until nothing is due. Renewing a subscription that
+// is several periods behind makes its next period due, so it is claimed again
+// by a later batch of the same run.
+func (e *BillingEngine) process(run *models.BillingRun) error {
+	for {
+		now := e.clock.Now()
+		items, err := e.runRepo.ClaimDue(run.ID, now, e.batchSize, now.Add(-e.claimStaleness), e.testClockID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		run.ClaimedCount += len(items)
+		e.processBatch(run, items)
+
+		if err := e.runRepo.UpdateRun(run); err != nil {
+			return err
+		}
+	}
+}
+
+// processBatch processes claimed items with at most e.workers running at once
+func (e *BillingEngine) processBatch(run *models.BillingRun, items []*models.BillingRunItem) {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, e.workers)
+	)
+
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item *models.BillingRunItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			e.processItem(item)
+
+			mu.Lock()
+			countOutcome(run, item.Outcome)
+			mu.Unlock()
+		}(item)
+	}
+
+	wg.Wait()
+}
+
+// processItem carries out the period end of a claimed subscription and
+// records the outcome on the item
+func (e *BillingEngine) processItem(item *models.BillingRunItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Billing run item %s panicked: %v", item.ID, r)
+			e.fail(item, errors.New("panic during processing"))
+			e.saveItem(item)
+		}
+	}()
+
+	outcome, err := e.processPeriod(item)
+	if err != nil {
+		e.fail(item, err)
+	} else {
+		item.Outcome = outcome
+		item.Error = ""
+		item.NextAttemptAt = nil
+	}
+
+	e.saveItem(item)
+}
+
+// fail records a failed attempt on item and when the period may be retried.
+// Once every attempt has failed, an active subscription is moved to past_due:
+// it keeps access while the renewal goes on being retried at the longest
+// backoff, and dunning returns it to active once its invoices are settled.
+func (e *BillingEngine) fail(item *models.BillingRunItem, err error) {
+	item.Outcome = models.BillingItemFailed
+	item.Error = err.Error()
+	nextAttemptAt := e.clock.Now().Add(e.backoff(item.Attempts))
+	item.NextAttemptAt = &nextAttemptAt
+
+	if item.Attempts < e.maxAttempts {
+		return
+	}
+	subscription, getErr := e.subscriptionRepo.GetByID(item.SubscriptionID)
+	if getErr != nil {
+		return
+	}
+	if failErr := e.subscriptions.paymentFailed(subscription, "renewal failed: "+err.Error()); failErr != nil {
+		log.Printf("Failed to move subscription %s to past_due: %v", subscription.ID, failErr)
+	}
+}
+
+// backoff returns how long to wait before retrying a period after its
+// attempts-th failed attempt: the first backoff, doubled for every further
+// attempt, up to the longest backoff
+func (e *BillingEngine) backoff(attempts int) time.Duration {
+	wait := e.retryBackoff
+	for i := 1; i < attempts && wait < e.retryBackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > e.retryBackoffMax {
+		wait = e.retryBackoffMax
+	}
+	return wait
+}
+
+// processPeriod reloads the subscription and processes its period end unless
+// the period has changed since it was claimed
+func (e *BillingEngine) processPeriod(item *models.BillingRunItem) (string, error) {
+	subscription, err := e.subscriptionRepo.GetByID(item.SubscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.BillingItemSkipped, nil
+		}
+		return "", err
+	}
+
+	if !subscription.CurrentPeriodEnd.Equal(item.PeriodEnd) {
+		return models.BillingItemSkipped, nil
+	}
+
+	return e.subscriptions.ProcessPeriodEnd(subscription)
+}
+
+// saveItem records an item's outcome, logging failures since the claim will
+// be taken over once it goes stale
+func (e *BillingEngine) saveItem(item *models.BillingRunItem) {
+	item.UpdatedAt = e.clock.Now()
+	if err := e.runRepo.UpdateItem(item); err != nil {
+		log.Printf("Failed to record billing run item %s: %v", item.ID, err)
+	}
+}
+
+// countOutcome adds an item's outcome to the run totals
+func countOutcome(run *models.BillingRun, outcome string) {
+	switch outcome {
+	case models.BillingItemRenewed:
+		run.RenewedCount++
+	case models.BillingItemExpired:
+		run.ExpiredCount++
+	case models.BillingItemCanceled:
+		run.CanceledCount++
+	case models.BillingItemSkipped:
+		run.SkippedCount++
+	case models.BillingItemFailed:
+		run.FailedCount++
+	}
+}
+
+// ListRuns returns billing runs, newest first
+func (e *BillingEngine) ListRuns(page, limit int) ([]*models.BillingRun, int64, error) {
+	offset := (page - 1) * limit
+
+	runs, err := e.runRepo.ListRuns(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := e.runRepo.CountRuns()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// GetRun returns a billing run
+func (e *BillingEngine) GetRun(idStr string) (*models.BillingRun, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errors.New("invalid billing run ID")
+	}
+
+	run, err := e.runRepo.GetRun(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("billing run not found")
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRunItems returns the periods processed by a billing run, optionally filtered by outcome
+func (e *BillingEngine) GetRunItems(idStr, outcome string, page, limit int) ([]*models.BillingRunItem, int64, error) {
+	run, err := e.GetRun(idStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+
+	items, err := e.runRepo.GetItems(run.ID, outcome, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := e.runRepo.CountItems(run.ID, outcome)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
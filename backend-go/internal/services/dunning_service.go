@@ -0,0 +1,596 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/payment"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// dunningBatchSize limits the invoices charged and the dunning cases worked
+// on per run; the rest are picked up by the next run
+const dunningBatchSize = 200
+
+// DunningPolicy configures how failed payments are retried
+type DunningPolicy struct {
+	RetryDays      []int         // days after the first failure that the payment is retried, ascending
+	FinalAction    string        // models.DunningAction* taken once every retry failed
+	ClaimStaleness time.Duration // how long a charge may stay pending before another run makes it again
+}
+
+// errChargeClaimed is returned when another dunning run is charging the invoice
+var errChargeClaimed = errors.New("invoice is being charged by another run")
+
+// IsValidDunningAction reports whether action is a supported final dunning action
+func IsValidDunningAction(action string) bool {
+	switch action {
+	case models.DunningActionCancel, models.DunningActionSuspend, models.DunningActionMarkUncollectible:
+		return true
+	}
+	return false
+}
+
+// IsValidDunningSchedule reports whether days is an ascending list of
+// positive day offsets
+func IsValidDunningSchedule(days []int) bool {
+	previous := 0
+	for _, day := range days {
+		if day <= previous {
+			return false
+		}
+		previous = day
+	}
+	return true
+}
+
+// DunningService charges invoices when they fall due and runs dunning for
+// those whose payment fails: the payment is retried on the policy's
+// schedule with escalating emails, the subscription is past due meanwhile,
+// and the final action is taken when every retry has failed. Dunning ends as
+// soon as the invoice is paid, including by a retry made because the
+// organization changed its payment methods.
+type DunningService struct {
+	dunningRepo       repository.DunningRepository
+	invoiceRepo       repository.InvoiceRepository
+	subscriptionRepo  repository.SubscriptionRepository
+	paymentMethodRepo repository.PaymentMethodRepository
+	orgRepo           repository.OrganizationRepository
+	subscriptions     *SubscriptionService
+	gateway           payment.Gateway
+	policy            DunningPolicy
+	clock             clock.Clock
+	testClockID       *uuid.UUID // set on copies that process the organizations of one test clock
+}
+
+// NewDunningService creates a new dunning service
+func NewDunningService(
+	dunningRepo repository.DunningRepository,
+	invoiceRepo repository.InvoiceRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	paymentMethodRepo repository.PaymentMethodRepository,
+	orgRepo repository.OrganizationRepository,
+	subscriptions *SubscriptionService,
+	gateway payment.Gateway,
+	policy DunningPolicy,
+	clk clock.Clock,
+) *DunningService {
+	return &DunningService{
+		dunningRepo:       dunningRepo,
+		invoiceRepo:       invoiceRepo,
+		subscriptionRepo:  subscriptionRepo,
+		paymentMethodRepo: paymentMethodRepo,
+		orgRepo:           orgRepo,
+		subscriptions:     subscriptions,
+		gateway:           gateway,
+		policy:            policy,
+		clock:             clk,
+	}
+}
+
+// GetDunningCases lists the dunning cases of an organization with their
+// payment attempts, optionally only those in status
+func (s *DunningService) GetDunningCases(orgIDStr, status string, page, limit int) ([]*models.DunningCase, int64, error) {
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return nil, 0, errors.New("invalid organization ID")
+	}
+	switch status {
+	case "", models.DunningStatusActive, models.DunningStatusRecovered, models.DunningStatusExhausted, models.DunningStatusClosed:
+	default:
+		return nil, 0, errors.New("invalid dunning status")
+	}
+
+	if _, err := s.orgRepo.GetByID(orgID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, errors.New("organization not found")
+		}
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	cases, err := s.dunningRepo.GetByOrganizationID(orgID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.dunningRepo.CountByOrganizationID(orgID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cases, total, nil
+}
+
+// ProcessDunning charges the invoices that fell due and works on the
+// dunning cases with a retry due or a change to react to. It is run
+// periodically by the job scheduler, and does nothing when no payment
+// gateway is configured.
+func (s *DunningService) ProcessDunning() error {
+	if s.gateway == nil {
+		return nil
+	}
+
+	paid, failed, err := s.CollectDueInvoices()
+	if paid > 0 || failed > 0 {
+		log.Printf("Invoices charged: %d paid, %d failed", paid, failed)
+	}
+
+	worked, recovered, retryErr := s.RetryPayments()
+	if worked > 0 {
+		log.Printf("Dunning processed: %d cases, %d recovered", worked, recovered)
+	}
+
+	return errors.Join(err, retryErr)
+}
+
+// CollectDueInvoices charges every open invoice that fell due and has not
+// been charged yet. A failed charge opens a dunning case. One failing
+// invoice does not stop the others, and invoices another run is charging
+// are skipped.
+func (s *DunningService) CollectDueInvoices() (paid, failed int, err error) {
+	now := s.clock.Now()
+	invoices, err := s.dunningRepo.GetCollectible(now, now.Add(-s.policy.ClaimStaleness), dunningBatchSize, s.testClockID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, invoice := range invoices {
+		ok, err := s.charge(invoice, nil)
+		if errors.Is(err, errChargeClaimed) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+			continue
+		}
+		if ok {
+			paid++
+		} else {
+			failed++
+		}
+	}
+	return paid, failed, errors.Join(errs...)
+}
+
+// RetryPayments retries the payments whose next attempt is due, retries at
+// once when an organization changed its payment methods, and ends the cases
+// of invoices that were settled outside dunning
+func (s *DunningService) RetryPayments() (worked, recovered int, err error) {
+	now := s.clock.Now()
+	cases, err := s.dunningRepo.GetDue(now, now.Add(-s.policy.ClaimStaleness), dunningBatchSize, s.testClockID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, dunning := range cases {
+		invoice := &dunning.Invoice
+
+		var ok bool
+		if isOpenInvoice(invoice) {
+			ok, err = s.charge(invoice, dunning)
+		} else {
+			ok, err = true, s.settle(dunning)
+		}
+		if errors.Is(err, errChargeClaimed) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dunning case %s: %w", dunning.ID, err))
+			continue
+		}
+
+		worked++
+		if ok {
+			recovered++
+		}
+	}
+	return worked, recovered, errors.Join(errs...)
+}
+
+// charge collects an invoice from its organization's payment method and
+// reports whether it was paid. dunning is the invoice's case, or nil for its
+// first charge. The invoice is claimed with a pending attempt first, whose
+// ID is the charge's idempotency key, and errChargeClaimed is returned when
+// another run holds the claim. A draft is finalized next so only numbered
+// invoices are collected. Declines and a missing payment method are failed
+// attempts. Other gateway errors leave the attempt pending, since the charge
+// may have gone through; it is made again with the same key once the claim
+// is stale.
+func (s *DunningService) charge(invoice *models.Invoice, dunning *models.DunningCase) (bool, error) {
+	now := s.clock.Now()
+
+	attempt := &models.PaymentAttempt{
+		InvoiceID:      invoice.ID,
+		OrganizationID: invoice.OrganizationID,
+		Amount:         invoice.Total,
+		Currency:       invoice.Currency,
+		AttemptedAt:    now,
+	}
+	claimed, err := s.dunningRepo.ClaimAttempt(attempt, now.Add(-s.policy.ClaimStaleness))
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return false, errChargeClaimed
+	}
+
+	paymentMethod, err := s.prepareCharge(invoice, now)
+	if err != nil {
+		if releaseErr := s.dunningRepo.ReleaseAttempt(attempt); releaseErr != nil {
+			log.Printf("Warning: failed to release the charge of invoice %s: %v", invoice.ID, releaseErr)
+		}
+		return false, err
+	}
+
+	if paymentMethod == nil {
+		return false, s.failed(invoice, dunning, attempt, "no usable payment method", now)
+	}
+
+	attempt.PaymentMethodID = &paymentMethod.ID
+	reference, err := s.gateway.Charge(&payment.Charge{
+		Invoice:        invoice,
+		PaymentMethod:  paymentMethod,
+		Amount:         invoice.Total,
+		IdempotencyKey: attempt.ID.String(),
+	})
+	if err != nil {
+		if !errors.Is(err, payment.ErrDeclined) {
+			return false, err
+		}
+		return false, s.failed(invoice, dunning, attempt, err.Error(), now)
+	}
+
+	attempt.ProviderReference = reference
+	return true, s.paid(invoice, dunning, attempt, paymentMethod, now)
+}
+
+// prepareCharge finalizes a draft invoice and returns the payment method it
+// is charged to, or nil when the organization has none. It fails with
+// errInvoiceStatusConflict when the draft was changed since it was loaded.
+func (s *DunningService) prepareCharge(invoice *models.Invoice, now time.Time) (*models.PaymentMethod, error) {
+	if invoice.Status == models.InvoiceStatusDraft {
+		if err := applyInvoiceEvent(invoice, models.InvoiceEventFinalize, now); err != nil {
+			return nil, err
+		}
+		updated, err := s.invoiceRepo.UpdateStatus(invoice, models.InvoiceStatusDraft)
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			return nil, errInvoiceStatusConflict
+		}
+	}
+	return s.paymentMethod(invoice.OrganizationID, now)
+}
+
+// paid records a successful charge, marks the invoice paid, ends its dunning
+// case and lets its subscription recover
+func (s *DunningService) paid(invoice *models.Invoice, dunning *models.DunningCase, attempt *models.PaymentAttempt, paymentMethod *models.PaymentMethod, now time.Time) error {
+	attempt.Status = models.PaymentAttemptSucceeded
+
+	from := invoice.Status
+	if err := applyInvoiceEvent(invoice, models.InvoiceEventPay, now); err != nil {
+		return err
+	}
+	invoice.PaymentMethodID = &paymentMethod.ID
+
+	if dunning != nil {
+		dunning.Status = models.DunningStatusRecovered
+		dunning.NextAttemptAt = nil
+		dunning.LastAttemptAt = now
+		dunning.EndedAt = &now
+	}
+
+	update := &repository.InvoiceStatusUpdate{Invoice: invoice, From: from}
+	if err := s.record(attempt, update, dunning); err != nil {
+		return err
+	}
+
+	if dunning != nil {
+		s.notify(invoice, fmt.Sprintf("Payment received for invoice %s", invoice.InvoiceNumber),
+			fmt.Sprintf("Thank you. We received your payment of %s for invoice %s, and your account is in good standing again.",
+				invoice.Total.Format(language.English), invoice.InvoiceNumber))
+	}
+	return s.recoverSubscription(invoice)
+}
+
+// failed records a failed charge. The first failure opens a dunning case and
+// moves the subscription to past_due; each scheduled failure sets the next
+// retry, and the last one takes the final action. A failed retry made
+// because the payment methods changed leaves the schedule as it is.
+func (s *DunningService) failed(invoice *models.Invoice, dunning *models.DunningCase, attempt *models.PaymentAttempt, reason string, now time.Time) error {
+	attempt.Status = models.PaymentAttemptFailed
+	attempt.FailureReason = reason
+
+	opened := dunning == nil
+	if opened {
+		dunning = &models.DunningCase{
+			InvoiceID:      invoice.ID,
+			SubscriptionID: invoice.SubscriptionID,
+			OrganizationID: invoice.OrganizationID,
+			Status:         models.DunningStatusActive,
+			StartedAt:      now,
+		}
+	}
+	scheduled := opened || dunning.Status == models.DunningStatusActive && dunning.NextAttemptAt != nil && !dunning.NextAttemptAt.After(now)
+
+	dunning.LastAttemptAt = now
+	dunning.LastFailure = reason
+
+	var writtenOff *repository.InvoiceStatusUpdate
+	if scheduled {
+		dunning.AttemptCount++
+		if retry := dunning.AttemptCount - 1; retry < len(s.policy.RetryDays) {
+			next := dunning.StartedAt.AddDate(0, 0, s.policy.RetryDays[retry])
+			dunning.NextAttemptAt = &next
+		} else {
+			dunning.Status = models.DunningStatusExhausted
+			dunning.FinalAction = s.policy.FinalAction
+			dunning.NextAttemptAt = nil
+			dunning.EndedAt = &now
+			if dunning.FinalAction == models.DunningActionMarkUncollectible {
+				writtenOff = &repository.InvoiceStatusUpdate{Invoice: invoice, From: invoice.Status}
+				if err := applyInvoiceEvent(invoice, models.InvoiceEventMarkUncollectible, now); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := s.record(attempt, writtenOff, dunning); err != nil {
+		return err
+	}
+	if !scheduled {
+		return nil
+	}
+
+	subscription, err := s.invoiceSubscription(invoice)
+	if err != nil {
+		return err
+	}
+	if opened && subscription != nil {
+		if err := s.subscriptions.paymentFailed(subscription, reason); err != nil {
+			return err
+		}
+	}
+
+	if dunning.Status == models.DunningStatusExhausted {
+		if err := s.finalAction(invoice, subscription, now); err != nil {
+			return err
+		}
+		s.notifyExhausted(invoice, subscription, dunning)
+		return nil
+	}
+	s.notifyFailure(invoice, subscription, dunning, opened)
+	return nil
+}
+
+// record completes a charge's attempt with the invoice status change and
+// dunning case it made. When the invoice left the status it was charged in,
+// because it was paid or voided while the charge was made, neither is saved:
+// the attempt is recorded as failed and errInvoiceStatusConflict returned,
+// and the invoice's dunning case is settled on the next run.
+func (s *DunningService) record(attempt *models.PaymentAttempt, invoice *repository.InvoiceStatusUpdate, dunning *models.DunningCase) error {
+	recorded, err := s.dunningRepo.RecordAttempt(attempt, invoice, dunning)
+	if errors.Is(err, repository.ErrInvoiceStatusChanged) {
+		if attempt.Status == models.PaymentAttemptSucceeded {
+			log.Printf("Warning: invoice %s changed status while charge %s went through; the payment needs a refund",
+				attempt.InvoiceID, attempt.ProviderReference)
+		}
+		attempt.Status = models.PaymentAttemptFailed
+		attempt.FailureReason = "invoice status changed during the charge"
+		if recorded, err = s.dunningRepo.RecordAttempt(attempt, nil, nil); err == nil && recorded {
+			err = errInvoiceStatusConflict
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		return errChargeClaimed
+	}
+	return nil
+}
+
+// finalAction applies the policy's final action to the subscription of an
+// invoice whose retries have all failed
+func (s *DunningService) finalAction(invoice *models.Invoice, subscription *models.Subscription, now time.Time) error {
+	const reason = "payment retries exhausted"
+
+	switch s.policy.FinalAction {
+	case models.DunningActionMarkUncollectible:
+		return s.recoverSubscription(invoice)
+	case models.DunningActionSuspend:
+		if subscription != nil {
+			return s.subscriptions.suspendForNonPayment(subscription, reason)
+		}
+	case models.DunningActionCancel:
+		if subscription != nil {
+			return s.subscriptions.cancelForNonPayment(subscription, now, reason)
+		}
+	}
+	return nil
+}
+
+// settle ends the dunning case of an invoice that was paid, voided or
+// written off outside dunning, and lets its subscription recover
+func (s *DunningService) settle(dunning *models.DunningCase) error {
+	invoice := &dunning.Invoice
+	now := s.clock.Now()
+
+	dunning.Status = models.DunningStatusClosed
+	if invoice.Status == models.InvoiceStatusPaid {
+		dunning.Status = models.DunningStatusRecovered
+	}
+	dunning.NextAttemptAt = nil
+	if dunning.EndedAt == nil {
+		dunning.EndedAt = &now
+	}
+
+	if err := s.dunningRepo.Update(dunning); err != nil {
+		return err
+	}
+	return s.recoverSubscription(invoice)
+}
+
+// recoverSubscription returns the subscription of an invoice to active
+// unless another of its invoices is still being dunned
+func (s *DunningService) recoverSubscription(invoice *models.Invoice) error {
+	if invoice.SubscriptionID == nil {
+		return nil
+	}
+
+	unresolved, err := s.dunningRepo.CountUnresolvedBySubscriptionID(*invoice.SubscriptionID)
+	if err != nil || unresolved > 0 {
+		return err
+	}
+
+	subscription, err := s.invoiceSubscription(invoice)
+	if err != nil || subscription == nil {
+		return err
+	}
+	return s.subscriptions.paymentRecovered(subscription, "invoice "+invoice.InvoiceNumber+" settled")
+}
+
+// invoiceSubscription loads the subscription an invoice bills, or returns
+// nil for invoices outside a subscription
+func (s *DunningService) invoiceSubscription(invoice *models.Invoice) (*models.Subscription, error) {
+	if invoice.SubscriptionID == nil {
+		return nil, nil
+	}
+	subscription, err := s.subscriptionRepo.GetByID(*invoice.SubscriptionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return subscription, err
+}
+
+// paymentMethod returns the payment method an organization is charged
+// with: its default, or newest, active payment method that has not expired
+func (s *DunningService) paymentMethod(orgID uuid.UUID, now time.Time) (*models.PaymentMethod, error) {
+	paymentMethods, err := s.paymentMethodRepo.GetActiveByOrganizationID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, paymentMethod := range paymentMethods {
+		if !paymentMethod.IsExpired(now) {
+			return paymentMethod, nil
+		}
+	}
+	return nil, nil
+}
+
+// notifyFailure tells the organization a payment failed and when it is
+// retried. The tone escalates from the first failure to a final notice
+// before the last retry.
+func (s *DunningService) notifyFailure(invoice *models.Invoice, subscription *models.Subscription, dunning *models.DunningCase, opened bool) {
+	amount := invoice.Total.Format(language.English)
+	retryOn := dunning.NextAttemptAt.Format("January 2, 2006")
+	lastRetry := dunning.AttemptCount == len(s.policy.RetryDays)
+
+	subject := fmt.Sprintf("Reminder: invoice %s is still unpaid", invoice.InvoiceNumber)
+	switch {
+	case opened:
+		subject = fmt.Sprintf("Payment failed for invoice %s", invoice.InvoiceNumber)
+	case lastRetry:
+		subject = fmt.Sprintf("Final notice: invoice %s is unpaid", invoice.InvoiceNumber)
+	}
+
+	body := fmt.Sprintf("We were unable to collect %s for invoice %s: %s.\n\n", amount, invoice.InvoiceNumber, dunning.LastFailure)
+	if lastRetry {
+		body += fmt.Sprintf("We will make a final attempt on %s. If it fails, %s.", retryOn, s.consequence(subscription))
+	} else {
+		body += fmt.Sprintf("We will retry the payment on %s.", retryOn)
+	}
+	body += " Please check or update your payment method; we retry as soon as it changes."
+
+	s.notify(invoice, subject, body)
+}
+
+// notifyExhausted tells the organization its payment retries have run out
+// and what followed
+func (s *DunningService) notifyExhausted(invoice *models.Invoice, subscription *models.Subscription, dunning *models.DunningCase) {
+	subject := fmt.Sprintf("We could not collect payment for invoice %s", invoice.InvoiceNumber)
+	outcome := "We have stopped retrying the payment."
+	if subscription != nil {
+		switch dunning.FinalAction {
+		case models.DunningActionCancel:
+			subject = "Your subscription has been canceled"
+			outcome = fmt.Sprintf("Your %s subscription has been canceled.", subscription.Plan.Name)
+		case models.DunningActionSuspend:
+			subject = "Your subscription has been suspended"
+			outcome = fmt.Sprintf("Your %s subscription has been suspended. Access is restored as soon as the invoice is paid; we retry the payment when you update your payment method.", subscription.Plan.Name)
+		}
+	}
+
+	body := fmt.Sprintf("We were unable to collect %s for invoice %s after %d attempts.\n\n%s",
+		invoice.Total.Format(language.English), invoice.InvoiceNumber, dunning.AttemptCount, outcome)
+	s.notify(invoice, subject, body)
+}
+
+// consequence describes the final action for the final notice
+func (s *DunningService) consequence(subscription *models.Subscription) string {
+	if subscription == nil {
+		return "we will stop retrying the payment"
+	}
+	switch s.policy.FinalAction {
+	case models.DunningActionCancel:
+		return "your subscription will be canceled"
+	case models.DunningActionSuspend:
+		return "your subscription will be suspended until the invoice is paid"
+	}
+	return "we will stop retrying the payment"
+}
+
+// notify emails the organization of an invoice; failures are logged
+func (s *DunningService) notify(invoice *models.Invoice, subject, body string) {
+	if err := s.subscriptions.notifyOrganization(&invoice.Organization, subject, body); err != nil {
+		log.Printf("Warning: failed to send %q for invoice %s: %v", subject, invoice.ID, err)
+	}
+}
+
+// isOpenInvoice reports whether an invoice can still be collected
+func isOpenInvoice(invoice *models.Invoice) bool {
+	return models.IsCollectibleInvoiceStatus(invoice.Status)
+}
+
+// onTestClock returns a copy of the service that runs at the time of the
+// given test clock and only processes the organizations attached to it
+func (s *DunningService) onTestClock(testClock *models.TestClock) *DunningService {
+	scoped := *s
+	scoped.clock = clock.Fixed(testClock.FrozenTime)
+	scoped.testClockID = &testClock.ID
+	scoped.subscriptions = s.subscriptions.onTestClock(testClock)
+	return &scoped
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/currency"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// rateDateLayout is the layout of exchange rate dates in files and requests
+const rateDateLayout = "2006-01-02"
+
+var (
+	// ErrInvalidExchangeRate wraps every validation failure of imported rates
+	ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+	// ErrExchangeRateNotFound is returned when no rate covers a conversion
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+)
+
+// ExchangeRateService manages daily exchange rates and currency conversion
+type ExchangeRateService struct {
+	rateRepo          repository.ExchangeRateRepository
+	reportingCurrency string
+}
+
+// NewExchangeRateService creates a new exchange rate service
+func NewExchangeRateService(rateRepo repository.ExchangeRateRepository, reportingCurrency string) *ExchangeRateService {
+	return &ExchangeRateService{
+		rateRepo:          rateRepo,
+		reportingCurrency: currency.Normalize(reportingCurrency),
+	}
+}
+
+// ExchangeRateInput is a single daily rate as supplied by an admin or a rates file
+type ExchangeRateInput struct {
+	Date          string      `json:"date" binding:"required"`
+	BaseCurrency  string      `json:"base_currency" binding:"required,len=3"`
+	QuoteCurrency string      `json:"quote_currency" binding:"required,len=3"`
+	Rate          json.Number `json:"rate" binding:"required"`
+}
+
+// SetExchangeRatesRequest represents rates posted through the admin endpoint
+type SetExchangeRatesRequest struct {
+	Rates []ExchangeRateInput `json:"rates" binding:"required,min=1,dive"`
+}
+
+// AppliedRate is the stored rate used for a conversion
+type AppliedRate struct {
+	Rate     money.Rate `json:"rate"`
+	RateDate string     `json:"rate_date"`
+	RateID   *uuid.UUID `json:"rate_id,omitempty"`
+	Inverted bool       `json:"inverted,omitempty"` // derived from the opposite pair
+}
+
+// Conversion is an amount converted into another currency together with the
+// rate that was applied, so every converted figure can be audited
+type Conversion struct {
+	Original  money.Money `json:"original"`
+	Converted money.Money `json:"converted"`
+	AppliedRate
+}
+
+// Apply converts amount into currency at this rate
+func (a *AppliedRate) Apply(amount money.Money, to string) (*Conversion, error) {
+	converted, err := amount.Convert(a.Rate, to, money.RoundHalfEven)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversion{Original: amount, Converted: converted, AppliedRate: *a}, nil
+}
+
+// ReportingCurrency returns the currency analytics and exports are expressed in
+func (s *ExchangeRateService) ReportingCurrency() string {
+	return s.reportingCurrency
+}
+
+// SetRates validates and stores rates, replacing existing rates for the same
+// pair and day. A pair may appear only once per day in inputs.
+func (s *ExchangeRateService) SetRates(inputs []ExchangeRateInput, source string) (int, error) {
+	rates := make([]*models.ExchangeRate, 0, len(inputs))
+	seen := map[string]int{}
+	for i, input := range inputs {
+		rate, err := parseRateInput(input)
+		if err != nil {
+			return 0, fmt.Errorf("%w: entry %d: %v", ErrInvalidExchangeRate, i+1, err)
+		}
+
+		key := rate.BaseCurrency + "/" + rate.QuoteCurrency + " on " + rate.Date.Format(rateDateLayout)
+		if first, ok := seen[key]; ok {
+			return 0, fmt.Errorf("%w: entry %d: %s is already set by entry %d", ErrInvalidExchangeRate, i+1, key, first)
+		}
+		seen[key] = i + 1
+
+		rate.Source = source
+		rates = append(rates, rate)
+	}
+
+	if err := s.rateRepo.Upsert(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// ImportRates loads rates from a CSV or JSON document. CSV files need a
+// header naming the date, base_currency, quote_currency and rate columns;
+// JSON may be an array of rates or an object with a "rates" array.
+func (s *ExchangeRateService) ImportRates(r io.Reader, format string) (int, error) {
+	var inputs []ExchangeRateInput
+	var err error
+
+	switch strings.ToLower(format) {
+	case "csv":
+		inputs, err = decodeRatesCSV(r)
+	case "json":
+		inputs, err = decodeRatesJSON(r)
+	default:
+		return 0, fmt.Errorf("%w: unsupported format %q", ErrInvalidExchangeRate, format)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("%w: file contains no rates", ErrInvalidExchangeRate)
+	}
+
+	return s.SetRates(inputs, models.ExchangeRateSourceFile)
+}
+
+// GetRates lists stored rates with pagination
+func (s *ExchangeRateService) GetRates(filter repository.ExchangeRateFilter, page, limit int) ([]*models.ExchangeRate, int64, error) {
+	filter.BaseCurrency = currency.Normalize(filter.BaseCurrency)
+	filter.QuoteCurrency = currency.Normalize(filter.QuoteCurrency)
+
+	offset := (page - 1) * limit
+	rates, err := s.rateRepo.List(filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.rateRepo.Count(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rates, total, nil
+}
+
+// EffectiveRate finds the rate from one currency to another in effect on
+// date, i.e. the latest rate published on or before it. When only the
+// opposite pair is known its inverse is used.
+func (s *ExchangeRateService) EffectiveRate(from, to string, date time.Time) (*AppliedRate, error) {
+	from = currency.Normalize(from)
+	to = currency.Normalize(to)
+	if from == to {
+		return &AppliedRate{Rate: money.OneRate(), RateDate: date.Format(rateDateLayout)}, nil
+	}
+
+	inverted := false
+	stored, err := s.rateRepo.GetEffective(from, to, date)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		stored, err = s.rateRepo.GetEffective(to, from, date)
+		inverted = true
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s to %s on %s", ErrExchangeRateNotFound, from, to, date.Format(rateDateLayout))
+		}
+		return nil, err
+	}
+
+	applied := &AppliedRate{
+		Rate:     stored.Rate,
+		RateDate: stored.Date.Format(rateDateLayout),
+		RateID:   &stored.ID,
+		Inverted: inverted,
+	}
+	if inverted {
+		applied.Rate = stored.Rate.Inverse()
+	}
+	return applied, nil
+}
+
+// Convert expresses amount in another currency using the rate in effect on date
+func (s *ExchangeRateService) Convert(amount money.Money, to string, date time.Time) (*Conversion, error) {
+	rate, err := s.EffectiveRate(amount.Currency(), to, date)
+	if err != nil {
+		return nil, err
+	}
+	return rate.Apply(amount, to)
+}
+
+// ConvertToReporting converts amount into the reporting currency at date
+func (s *ExchangeRateService) ConvertToReporting(amount money.Money, date time.Time) (*Conversion, error) {
+	return s.Convert(amount, s.reportingCurrency, date)
+}
+
+// parseRateInput validates a rate input and builds the model
+func parseRateInput(input ExchangeRateInput) (*models.ExchangeRate, error) {
+	date, err := time.Parse(rateDateLayout, strings.TrimSpace(input.Date))
+	if err != nil {
+		return nil, fmt.Errorf("date must be YYYY-MM-DD, got %q", input.Date)
+	}
+
+	base := currency.Normalize(input.BaseCurrency)
+	quote := currency.Normalize(input.QuoteCurrency)
+	if !currency.IsValid(base) {
+		return nil, fmt.Errorf("unknown base currency %q", input.BaseCurrency)
+	}
+	if !currency.IsValid(quote) {
+		return nil, fmt.Errorf("unknown quote currency %q", input.QuoteCurrency)
+	}
+	if base == quote {
+		return nil, errors.New("base and quote currency must differ")
+	}
+
+	rate, err := money.ParseRate(input.Rate.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Date:          date,
+		Rate:          rate,
+	}, nil
+}
+
+// decodeRatesCSV reads rates from a CSV document with a header row
+func decodeRatesCSV(r io.Reader) ([]ExchangeRateInput, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "base_currency", "quote_currency", "rate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: CSV header is missing the %q column", ErrInvalidExchangeRate, required)
+		}
+	}
+
+	var inputs []ExchangeRateInput
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+		}
+		inputs = append(inputs, ExchangeRateInput{
+			Date:          record[columns["date"]],
+			BaseCurrency:  record[columns["base_currency"]],
+			QuoteCurrency: record[columns["quote_currency"]],
+			Rate:          json.Number(strings.TrimSpace(record[columns["rate"]])),
+		})
+	}
+	return inputs, nil
+}
+
+// decodeRatesJSON reads rates from a JSON array or an object with a "rates" array
+func decodeRatesJSON(r io.Reader) ([]ExchangeRateInput, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []ExchangeRateInput
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &inputs)
+	} else {
+		var wrapper SetExchangeRatesRequest
+		err = json.Unmarshal(data, &wrapper)
+		inputs = wrapper.Rates
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+	}
+	return inputs, nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/notification"
+	"go-backend/pkg/money"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	// errInvalidInvoiceTransition is returned when an event is not allowed in an invoice's current status
+	errInvalidInvoiceTransition = errors.New("invalid invoice status transition")
+	// errInvoiceStatusConflict is returned when an invoice's status changed since it was loaded
+	errInvoiceStatusConflict = errors.New("invoice status changed concurrently")
+)
+
+// InvoiceItemRequest represents a line of a draft invoice. Amounts without a
+// currency are in the invoice currency.
+type InvoiceItemRequest struct {
+	Description    string      `json:"description" binding:"required"`
+	Quantity       int         `json:"quantity" binding:"required,min=1"`
+	UnitPrice      money.Money `json:"unit_price"`
+	DiscountAmount money.Money `json:"discount_amount"`
+	TaxAmount      money.Money `json:"tax_amount"`
+	PeriodStart    *time.Time  `json:"period_start,omitempty"` // service period the line bills, if any
+	PeriodEnd      *time.Time  `json:"period_end,omitempty"`
+}
+
+// UpdateInvoiceRequest represents draft invoice update data
+type UpdateInvoiceRequest struct {
+	DueDate *time.Time           `json:"due_date,omitempty"`
+	Notes   *string              `json:"notes,omitempty"`
+	Items   []InvoiceItemRequest `json:"items,omitempty" binding:"omitempty,dive"` // replaces every item when given
+}
+
+// PayInvoiceRequest represents a payment received outside the payment gateway
+type PayInvoiceRequest struct {
+	PaidAt *time.Time `json:"paid_at,omitempty"` // when the payment was received; defaults to now
+}
+
+// applyInvoiceEvent moves an invoice to the status the state machine allows
+// for the event and records when it happened. Every status change of an
+// invoice goes through here; the caller saves the invoice.
+func applyInvoiceEvent(invoice *models.Invoice, event string, now time.Time) error {
+	to, ok := models.NextInvoiceStatus(invoice.Status, event)
+	if !ok {
+		return errInvalidInvoiceTransition
+	}
+
+	invoice.Status = to
+	switch event {
+	case models.InvoiceEventFinalize:
+		invoice.FinalizedAt = &now
+	case models.InvoiceEventSend:
+		invoice.SentAt = &now
+	case models.InvoiceEventPay:
+		invoice.PaidAt = &now
+	case models.InvoiceEventVoid:
+		invoice.VoidedAt = &now
+	}
+	return nil
+}
+
+// UpdateInvoice edits a draft invoice. Given items replace the invoice's
+// items and the totals are computed from them again. Finalized invoices are
+// locked.
+func (s *InvoiceService) UpdateInvoice(invoiceIDStr string, req *UpdateInvoiceRequest) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if !invoice.IsEditable() {
+		return nil, errors.New("invoice is not a draft")
+	}
+
+	if req.DueDate != nil {
+		invoice.DueDate = *req.DueDate
+	}
+	if req.Notes != nil {
+		invoice.Notes = *req.Notes
+	}
+	if len(req.Items) > 0 {
+		if invoice.Items, err = invoiceItems(req.Items, invoice.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.invoiceRepo.UpdateDraft(invoice)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("invoice is not a draft")
+	}
+	return s.GetInvoiceByID(invoiceIDStr)
+}
+
+// FinalizeInvoice locks a draft invoice and assigns its number
+func (s *InvoiceService) FinalizeInvoice(invoiceIDStr string) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.IsEditable() && len(invoice.Items) == 0 {
+		return nil, errors.New("invoice has no items")
+	}
+	return s.applyEvent(invoice, models.InvoiceEventFinalize, s.now(invoice))
+}
+
+// SendInvoice emails a finalized invoice to its organization
+func (s *InvoiceService) SendInvoice(invoiceIDStr string) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := models.NextInvoiceStatus(invoice.Status, models.InvoiceEventSend); !ok {
+		return nil, errInvalidInvoiceTransition
+	}
+	if invoice.Organization.Email == "" {
+		return nil, errors.New("organization has no email address")
+	}
+
+	err = s.notifier.Send(&notification.Message{
+		To:      []string{invoice.Organization.Email},
+		Subject: fmt.Sprintf("Invoice %s", invoice.InvoiceNumber),
+		Body: fmt.Sprintf("Invoice %s for %s is due on %s.",
+			invoice.InvoiceNumber, invoice.Total.Format(language.English), invoice.DueDate.Format("January 2, 2006")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.applyEvent(invoice, models.InvoiceEventSend, s.now(invoice))
+}
+
+// PayInvoice records a payment received outside the payment gateway, such as
+// a bank transfer. Dunning of the invoice ends on its next run.
+func (s *InvoiceService) PayInvoice(invoiceIDStr string, req *PayInvoiceRequest) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now(invoice)
+	paidAt := now
+	if req.PaidAt != nil {
+		if req.PaidAt.After(now) {
+			return nil, errors.New("payment date is in the future")
+		}
+		paidAt = *req.PaidAt
+	}
+
+	return s.applyEvent(invoice, models.InvoiceEventPay, paidAt)
+}
+
+// VoidInvoice cancels an invoice that was issued in error; it is no longer owed
+func (s *InvoiceService) VoidInvoice(invoiceIDStr string) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyEvent(invoice, models.InvoiceEventVoid, s.now(invoice))
+}
+
+// MarkInvoiceUncollectible writes off a finalized invoice that is not
+// expected to be paid. It can still be paid later.
+func (s *InvoiceService) MarkInvoiceUncollectible(invoiceIDStr string) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(invoiceIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyEvent(invoice, models.InvoiceEventMarkUncollectible, s.now(invoice))
+}
+
+// applyEvent applies a lifecycle event to an invoice and saves it. The save
+// only applies while the invoice is still in the status it was loaded in, so
+// of two concurrent changes the second fails with errInvoiceStatusConflict.
+func (s *InvoiceService) applyEvent(invoice *models.Invoice, event string, at time.Time) (*models.Invoice, error) {
+	from := invoice.Status
+	if err := applyInvoiceEvent(invoice, event, at); err != nil {
+		return nil, err
+	}
+	updated, err := s.invoiceRepo.UpdateStatus(invoice, from)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errInvoiceStatusConflict
+	}
+	return invoice, nil
+}
+
+// now returns the current time for an invoice's organization, which is the
+// time of its test clock when it is attached to one
+func (s *InvoiceService) now(invoice *models.Invoice) time.Time {
+	if s.testClockID != nil {
+		return s.clock.Now()
+	}
+	return organizationNow(s.clock, s.testClockRepo, &invoice.Organization)
+}
+
+// invoiceItems builds the items of a draft invoice from a request, in the
+// invoice currency
+func invoiceItems(requests []InvoiceItemRequest, code string) ([]models.InvoiceItem, error) {
+	items := make([]models.InvoiceItem, 0, len(requests))
+	for _, req := range requests {
+		unitPrice := req.UnitPrice.Bind(code)
+		discount := req.DiscountAmount.Bind(code)
+		tax := req.TaxAmount.Bind(code)
+		if unitPrice.Currency() != code || discount.Currency() != code || tax.Currency() != code {
+			return nil, errors.New("item currency does not match invoice currency")
+		}
+		if discount.IsNegative() || tax.IsNegative() {
+			return nil, errors.New("item discount and tax must not be negative")
+		}
+		if (req.PeriodStart == nil) != (req.PeriodEnd == nil) ||
+			req.PeriodStart != nil && !req.PeriodEnd.After(*req.PeriodStart) {
+			return nil, errors.New("invalid item period")
+		}
+
+		amount, err := unitPrice.Mul(int64(req.Quantity))
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, models.InvoiceItem{
+			Description:    req.Description,
+			Quantity:       req.Quantity,
+			UnitPrice:      unitPrice,
+			Amount:         amount,
+			DiscountAmount: discount,
+			TaxAmount:      tax,
+			Currency:       code,
+			PeriodStart:    req.PeriodStart,
+			PeriodEnd:      req.PeriodEnd,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"go-backend/internal/blobstore"
+	"go-backend/internal/invoicepdf"
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InvoicePDFService renders invoices as PDF documents
+type InvoicePDFService struct {
+	invoiceRepo        repository.InvoiceRepository
+	billingAddressRepo repository.BillingAddressRepository
+	renderer           *invoicepdf.Renderer
+	store              blobstore.Store
+}
+
+// NewInvoicePDFService creates a new invoice PDF service
+func NewInvoicePDFService(invoiceRepo repository.InvoiceRepository, billingAddressRepo repository.BillingAddressRepository, renderer *invoicepdf.Renderer, store blobstore.Store) *InvoicePDFService {
+	return &InvoicePDFService{
+		invoiceRepo:        invoiceRepo,
+		billingAddressRepo: billingAddressRepo,
+		renderer:           renderer,
+		store:              store,
+	}
+}
+
+// GetInvoicePDF renders an invoice as a PDF. Finalized invoices no longer
+// change apart from their status, so their PDFs are cached per status and
+// branding and keep the billing address they were first rendered with.
+// Drafts are rendered every time.
+func (s *InvoicePDFService) GetInvoicePDF(invoiceIDStr string) (*models.Invoice, []byte, error) {
+	invoiceID, err := uuid.Parse(invoiceIDStr)
+	if err != nil {
+		return nil, nil, errors.New("invalid invoice ID")
+	}
+
+	invoice, err := s.invoiceRepo.GetByID(invoiceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("invoice not found")
+		}
+		return nil, nil, err
+	}
+
+	cacheable := !invoice.IsEditable()
+	key := fmt.Sprintf("invoices/%s/%s-%s.pdf", invoice.ID, invoice.Status, s.renderer.Version())
+	if cacheable {
+		data, err := s.store.Get(key)
+		if err == nil {
+			return invoice, data, nil
+		}
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			log.Printf("Failed to read cached PDF of invoice %s: %v", invoice.ID, err)
+		}
+	}
+
+	address, err := s.billingAddressRepo.GetDefaultByOrganizationID(invoice.OrganizationID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+		address = nil
+	}
+
+	var out bytes.Buffer
+	if err := s.renderer.Render(&out, invoice, address); err != nil {
+		return nil, nil, err
+	}
+
+	// A failed cache write only costs rendering the PDF again next time
+	if cacheable {
+		if err := s.store.Put(key, out.Bytes()); err != nil {
+			log.Printf("Failed to cache PDF of invoice %s: %v", invoice.ID, err)
+		}
+	}
+	return invoice, out.Bytes(), nil
+}
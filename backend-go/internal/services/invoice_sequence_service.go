@@ -0,0 +1,263 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InvoiceSequenceService manages the sequences invoice numbers are taken
+// from when invoices are finalized
+type InvoiceSequenceService struct {
+	sequenceRepo repository.InvoiceSequenceRepository
+	orgRepo      repository.OrganizationRepository
+}
+
+// NewInvoiceSequenceService creates a new invoice sequence service
+func NewInvoiceSequenceService(sequenceRepo repository.InvoiceSequenceRepository, orgRepo repository.OrganizationRepository) *InvoiceSequenceService {
+	return &InvoiceSequenceService{
+		sequenceRepo: sequenceRepo,
+		orgRepo:      orgRepo,
+	}
+}
+
+// CreateInvoiceSequenceRequest represents invoice sequence creation data
+type CreateInvoiceSequenceRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=100"`
+	Prefix      string `json:"prefix" binding:"required,max=20"`
+	Template    string `json:"template" binding:"omitempty,max=50"`                // defaults to {prefix}{yyyy}-{number}
+	Padding     *int   `json:"padding,omitempty" binding:"omitempty,min=0,max=12"` // defaults to 6
+	ResetYearly bool   `json:"reset_yearly"`
+	StartAt     int64  `json:"start_at" binding:"omitempty,min=1"` // first counter value, to continue numbering kept elsewhere
+	IsDefault   bool   `json:"is_default"`
+}
+
+// UpdateInvoiceSequenceRequest represents invoice sequence update data. The
+// number format can only change until the sequence numbers its first
+// invoice.
+type UpdateInvoiceSequenceRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Prefix      *string `json:"prefix,omitempty" binding:"omitempty,min=1,max=20"`
+	Template    *string `json:"template,omitempty" binding:"omitempty,max=50"`
+	Padding     *int    `json:"padding,omitempty" binding:"omitempty,min=0,max=12"`
+	ResetYearly *bool   `json:"reset_yearly,omitempty"`
+}
+
+// AssignInvoiceSequenceRequest represents a request to number an
+// organization's invoices from a sequence
+type AssignInvoiceSequenceRequest struct {
+	OrganizationID string `json:"organization_id" binding:"required"`
+}
+
+// CreateSequence creates an invoice sequence
+func (s *InvoiceSequenceService) CreateSequence(req *CreateInvoiceSequenceRequest) (*models.InvoiceSequence, error) {
+	sequence := &models.InvoiceSequence{
+		Name:        req.Name,
+		Prefix:      req.Prefix,
+		Template:    req.Template,
+		Padding:     models.DefaultInvoiceNumberPadding,
+		ResetYearly: req.ResetYearly,
+		NextNumber:  1,
+	}
+	if sequence.Template == "" {
+		sequence.Template = models.DefaultInvoiceNumberTemplate
+	}
+	if req.Padding != nil {
+		sequence.Padding = *req.Padding
+	}
+	if req.StartAt > 0 {
+		sequence.NextNumber = req.StartAt
+	}
+	if err := validateSequenceFormat(sequence); err != nil {
+		return nil, err
+	}
+
+	if err := s.sequenceRepo.Create(sequence); err != nil {
+		return nil, err
+	}
+	if req.IsDefault {
+		if err := s.sequenceRepo.SetDefault(sequence.ID); err != nil {
+			return nil, err
+		}
+		sequence.IsDefault = true
+	}
+	return sequence, nil
+}
+
+// GetSequences lists invoice sequences with pagination
+func (s *InvoiceSequenceService) GetSequences(page, limit int) ([]*models.InvoiceSequence, int64, error) {
+	offset := (page - 1) * limit
+
+	sequences, err := s.sequenceRepo.List(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.sequenceRepo.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sequences, total, nil
+}
+
+// GetSequence gets an invoice sequence by ID with the organizations it numbers
+func (s *InvoiceSequenceService) GetSequence(idStr string) (*models.InvoiceSequence, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errors.New("invalid invoice sequence ID")
+	}
+
+	sequence, err := s.sequenceRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invoice sequence not found")
+		}
+		return nil, err
+	}
+	return sequence, nil
+}
+
+// UpdateSequence renames an invoice sequence or, until it numbers its first
+// invoice, changes its number format
+func (s *InvoiceSequenceService) UpdateSequence(idStr string, req *UpdateInvoiceSequenceRequest) (*models.InvoiceSequence, error) {
+	sequence, err := s.GetSequence(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		sequence.Name = *req.Name
+	}
+
+	if req.Prefix != nil || req.Template != nil || req.Padding != nil || req.ResetYearly != nil {
+		if sequence.IsUsed() {
+			return nil, errors.New("invoice sequence is already in use")
+		}
+		if req.Prefix != nil {
+			sequence.Prefix = *req.Prefix
+		}
+		if req.Template != nil {
+			sequence.Template = *req.Template
+		}
+		if req.Padding != nil {
+			sequence.Padding = *req.Padding
+		}
+		if req.ResetYearly != nil {
+			sequence.ResetYearly = *req.ResetYearly
+		}
+		if err := validateSequenceFormat(sequence); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.sequenceRepo.Update(sequence); err != nil {
+		return nil, err
+	}
+	return sequence, nil
+}
+
+// SetDefaultSequence makes a sequence number the invoices of every
+// organization without a sequence of its own
+func (s *InvoiceSequenceService) SetDefaultSequence(idStr string) (*models.InvoiceSequence, error) {
+	sequence, err := s.GetSequence(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sequenceRepo.SetDefault(sequence.ID); err != nil {
+		return nil, err
+	}
+	sequence.IsDefault = true
+	return sequence, nil
+}
+
+// AssignOrganization numbers an organization's invoices from a sequence from
+// now on. Invoices already finalized keep their numbers.
+func (s *InvoiceSequenceService) AssignOrganization(idStr string, req *AssignInvoiceSequenceRequest) (*models.InvoiceSequence, error) {
+	sequence, err := s.GetSequence(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.getOrganization(req.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	org.InvoiceSequenceID = &sequence.ID
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, err
+	}
+	return s.GetSequence(idStr)
+}
+
+// UnassignOrganization returns an organization numbered by a sequence to the
+// default sequence
+func (s *InvoiceSequenceService) UnassignOrganization(idStr, orgIDStr string) (*models.InvoiceSequence, error) {
+	sequence, err := s.GetSequence(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.getOrganization(orgIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if org.InvoiceSequenceID == nil || *org.InvoiceSequenceID != sequence.ID {
+		return nil, errors.New("organization is not numbered by this invoice sequence")
+	}
+
+	org.InvoiceSequenceID = nil
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, err
+	}
+	return s.GetSequence(idStr)
+}
+
+// getOrganization parses an organization ID and loads the organization
+func (s *InvoiceSequenceService) getOrganization(orgIDStr string) (*models.Organization, error) {
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+// validateSequenceFormat checks that a sequence's numbers are unique: they
+// carry its prefix and counter, and a counter restarted every year needs the
+// year to tell the numbers apart. Its numbers must not read as the
+// provisional number of a draft either, or a finalized invoice would be
+// numbered again.
+func validateSequenceFormat(sequence *models.InvoiceSequence) error {
+	if strings.TrimSpace(sequence.Prefix) == "" {
+		return errors.New("invoice sequence prefix is required")
+	}
+	if !strings.Contains(sequence.Template, models.InvoiceTemplatePrefix) ||
+		!strings.Contains(sequence.Template, models.InvoiceTemplateNumber) {
+		return errors.New("invoice number template must contain {prefix} and {number}")
+	}
+	if sequence.ResetYearly && !strings.Contains(sequence.Template, models.InvoiceTemplateYear) &&
+		!strings.Contains(sequence.Template, models.InvoiceTemplateShortYear) {
+		return errors.New("invoice number template must contain the year to reset yearly")
+	}
+	// Only the prefix puts letters in a number, so any number shows whether
+	// the sequence's numbers start like a draft's
+	if models.IsDraftInvoiceNumber(sequence.Format(1, 2000)) {
+		return errors.New("invoice numbers must not start like a draft's provisional number")
+	}
+	return nil
+}
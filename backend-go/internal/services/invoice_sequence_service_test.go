@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"go-backend/internal/models"
+)
+
+func TestValidateSequenceFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence models.InvoiceSequence
+		valid    bool
+	}{
+		{"default format", models.InvoiceSequence{Prefix: "INV-", Template: models.DefaultInvoiceNumberTemplate}, true},
+		{"no prefix", models.InvoiceSequence{Prefix: " ", Template: models.DefaultInvoiceNumberTemplate}, false},
+		{"template without the counter", models.InvoiceSequence{Prefix: "INV-", Template: "{prefix}{yyyy}"}, false},
+		{"yearly reset without the year", models.InvoiceSequence{Prefix: "INV-", Template: "{prefix}{number}", ResetYearly: true}, false},
+		{"yearly reset with the short year", models.InvoiceSequence{Prefix: "INV-", Template: "{prefix}{yy}-{number}", ResetYearly: true}, true},
+		// Numbers reading as a draft's would be renumbered once finalized
+		{"draft prefix", models.InvoiceSequence{Prefix: "DRAFT-", Template: models.DefaultInvoiceNumberTemplate}, false},
+		{"draft text in the template", models.InvoiceSequence{Prefix: "INV", Template: "DRAFT-{prefix}{number}"}, false},
+		{"draft split across prefix and template", models.InvoiceSequence{Prefix: "DR", Template: "{prefix}AFT-{number}"}, false},
+		{"draft later in the number", models.InvoiceSequence{Prefix: "INV-DRAFT-", Template: "{prefix}{number}"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sequence := tt.sequence
+			if err := validateSequenceFormat(&sequence); (err == nil) != tt.valid {
+				t.Errorf("validateSequenceFormat = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
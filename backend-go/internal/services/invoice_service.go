@@ -3,7 +3,10 @@ package services
 import (
 	"errors"
 	"go-backend/internal/models"
+	"go-backend/internal/notification"
 	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,18 +15,34 @@ import (
 
 // InvoiceService handles invoice business logic
 type InvoiceService struct {
-	invoiceRepo repository.InvoiceRepository
-	orgRepo     repository.OrganizationRepository
+	invoiceRepo   repository.InvoiceRepository
+	orgRepo       repository.OrganizationRepository
+	testClockRepo repository.TestClockRepository
+	notifier      notification.Notifier
+	clock         clock.Clock
+	testClockID   *uuid.UUID // set on copies that process the organizations of one test clock
 }
 
 // NewInvoiceService creates a new invoice service
-func NewInvoiceService(invoiceRepo repository.InvoiceRepository, orgRepo repository.OrganizationRepository) *InvoiceService {
+func NewInvoiceService(invoiceRepo repository.InvoiceRepository, orgRepo repository.OrganizationRepository, testClockRepo repository.TestClockRepository, notifier notification.Notifier, clk clock.Clock) *InvoiceService {
 	return &InvoiceService{
-		invoiceRepo: invoiceRepo,
-		orgRepo:     orgRepo,
+		invoiceRepo:   invoiceRepo,
+		orgRepo:       orgRepo,
+		testClockRepo: testClockRepo,
+		notifier:      notifier,
+		clock:         clk,
 	}
 }
 
+// onTestClock returns a copy of the service that runs at the time of the
+// given test clock and only processes the organizations attached to it
+func (s *InvoiceService) onTestClock(testClock *models.TestClock) *InvoiceService {
+	scoped := *s
+	scoped.clock = clock.Fixed(testClock.FrozenTime)
+	scoped.testClockID = &testClock.ID
+	return &scoped
+}
+
 // GetInvoicesByOrganization gets invoices for an organization with pagination
 func (s *InvoiceService) GetInvoicesByOrganization(organizationIDStr string, page, limit int) ([]*models.Invoice, int64, error) {
 	orgID, err := uuid.Parse(organizationIDStr)
@@ -89,7 +108,17 @@ func (s *InvoiceService) GetAllInvoices(page, limit int) ([]*models.Invoice, int
 
 // GetOverdueInvoices gets all overdue invoices
 func (s *InvoiceService) GetOverdueInvoices() ([]*models.Invoice, error) {
-	return s.invoiceRepo.GetOverdue()
+	return s.invoiceRepo.GetOverdue(s.clock.Now())
+}
+
+// ProcessOverdueInvoices marks unpaid invoices past their due date as
+// overdue. It is run periodically by the job scheduler.
+func (s *InvoiceService) ProcessOverdueInvoices() error {
+	marked, err := s.invoiceRepo.MarkOverdue(s.clock.Now(), s.testClockID)
+	if marked > 0 {
+		log.Printf("Invoices marked overdue: %d", marked)
+	}
+	return err
 }
 
 // GetInvoicesByDateRange gets invoices within a date range
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go-backend/config"
+	"go-backend/internal/ingest"
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// usageClockSkew is how far in the future an event timestamp may be, to
+// tolerate senders whose clocks run slightly ahead
+const usageClockSkew = 5 * time.Minute
+
+// MeteringService manages meters and metered prices and ingests usage events
+type MeteringService struct {
+	meterRepo     repository.MeterRepository
+	usageRepo     repository.UsageRepository
+	planRepo      repository.PlanRepository
+	orgRepo       repository.OrganizationRepository
+	testClockRepo repository.TestClockRepository
+	pipeline      *ingest.Pipeline
+	usage         config.UsageConfig
+	clock         clock.Clock
+}
+
+// NewMeteringService creates a new metering service
+func NewMeteringService(
+	meterRepo repository.MeterRepository,
+	usageRepo repository.UsageRepository,
+	planRepo repository.PlanRepository,
+	orgRepo repository.OrganizationRepository,
+	testClockRepo repository.TestClockRepository,
+	pipeline *ingest.Pipeline,
+	usage config.UsageConfig,
+	clk clock.Clock,
+) *MeteringService {
+	return &MeteringService{
+		meterRepo:     meterRepo,
+		usageRepo:     usageRepo,
+		planRepo:      planRepo,
+		orgRepo:       orgRepo,
+		testClockRepo: testClockRepo,
+		pipeline:      pipeline,
+		usage:         usage,
+		clock:         clk,
+	}
+}
+
+// CreateMeterRequest represents meter creation data
+type CreateMeterRequest struct {
+	Name          string                 `json:"name" binding:"required,min=2,max=100"`
+	EventName     string                 `json:"event_name" binding:"required,max=100"`
+	Aggregation   string                 `json:"aggregation" binding:"required,oneof=sum count max last unique_count"`
+	ValueProperty string                 `json:"value_property"` // required unless aggregation is count
+	Filter        map[string]interface{} `json:"filter"`         // property values an event must have to be counted
+	Description   string                 `json:"description" binding:"max=500"`
+}
+
+// UpdateMeterRequest represents meter update data. How a meter aggregates is
+// fixed once created, so periods already billed stay reproducible.
+type UpdateMeterRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=500"`
+	IsActive    *bool   `json:"is_active,omitempty"`
+}
+
+// CreateMeteredPriceRequest represents metered price creation data
+type CreateMeteredPriceRequest struct {
+	PlanID        string      `json:"plan_id" binding:"required"`
+	UnitAmount    money.Money `json:"unit_amount"`                              // in the plan currency
+	PackageSize   int64       `json:"package_size" binding:"omitempty,min=1"`   // units per unit amount; defaults to 1
+	IncludedUnits int64       `json:"included_units" binding:"omitempty,min=0"` // free units per period
+	Description   string      `json:"description" binding:"omitempty,max=200"`  // invoice line label; defaults to the meter name
+}
+
+// RecordUsageRequest represents a usage event reported for an organization
+type RecordUsageRequest struct {
+	EventID        string                 `json:"event_id" binding:"required,max=255"` // unique per organization; repeats are ignored
+	EventName      string                 `json:"event_name" binding:"required,max=100"`
+	OrganizationID string                 `json:"organization_id" binding:"required"`
+	Timestamp      *time.Time             `json:"timestamp"` // when the usage happened; defaults to now
+	Properties     map[string]interface{} `json:"properties"`
+}
+
+// RecordUsageResult describes an ingested usage event
+type RecordUsageResult struct {
+	Event     *models.UsageEvent `json:"event"`
+	Duplicate bool               `json:"duplicate"` // the event ID was already recorded and this event was ignored
+}
+
+// CreateMeter creates a meter
+func (s *MeteringService) CreateMeter(req *CreateMeterRequest) (*models.Meter, error) {
+	if !models.IsValidMeterAggregation(req.Aggregation) {
+		return nil, errors.New("invalid meter aggregation")
+	}
+	if req.Aggregation != models.MeterAggregationCount && strings.TrimSpace(req.ValueProperty) == "" {
+		return nil, errors.New("meter value property is required")
+	}
+	for _, value := range req.Filter {
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return nil, errors.New("meter filter values must be strings, numbers or booleans")
+		}
+	}
+
+	meter := &models.Meter{
+		Name:          req.Name,
+		EventName:     req.EventName,
+		Aggregation:   req.Aggregation,
+		ValueProperty: strings.TrimSpace(req.ValueProperty),
+		Filter:        models.JSONMap(req.Filter),
+		Description:   req.Description,
+		IsActive:      true,
+	}
+	if meter.Aggregation == models.MeterAggregationCount {
+		meter.ValueProperty = ""
+	}
+	if meter.Filter == nil {
+		meter.Filter = models.JSONMap{}
+	}
+
+	if err := s.meterRepo.Create(meter); err != nil {
+		return nil, err
+	}
+	return meter, nil
+}
+
+// GetMeter gets a meter by ID
+func (s *MeteringService) GetMeter(idStr string) (*models.Meter, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errors.New("invalid meter ID")
+	}
+
+	meter, err := s.meterRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("meter not found")
+		}
+		return nil, err
+	}
+	return meter, nil
+}
+
+// ListMeters lists meters with pagination
+func (s *MeteringService) ListMeters(page, limit int) ([]*models.Meter, int64, error) {
+	offset := (page - 1) * limit
+
+	meters, err := s.meterRepo.List(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.meterRepo.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return meters, total, nil
+}
+
+// UpdateMeter updates a meter's name, description or active flag. Inactive
+// meters reject new events but their recorded usage is still billed.
+func (s *MeteringService) UpdateMeter(idStr string, req *UpdateMeterRequest) (*models.Meter, error) {
+	meter, err := s.GetMeter(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		meter.Name = *req.Name
+	}
+	if req.Description != nil {
+		meter.Description = *req.Description
+	}
+	if req.IsActive != nil {
+		meter.IsActive = *req.IsActive
+	}
+
+	if err := s.meterRepo.Update(meter); err != nil {
+		return nil, err
+	}
+	return meter, nil
+}
+
+// GetMeteredPrices lists the metered prices that bill a meter
+func (s *MeteringService) GetMeteredPrices(meterIDStr string) ([]*models.MeteredPrice, error) {
+	meter, err := s.GetMeter(meterIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.meterRepo.GetPricesByMeterID(meter.ID)
+}
+
+// CreateMeteredPrice charges the subscribers of a plan for a meter's usage.
+// The usage is billed when each subscription period closes.
+func (s *MeteringService) CreateMeteredPrice(meterIDStr string, req *CreateMeteredPriceRequest) (*models.MeteredPrice, error) {
+	meter, err := s.GetMeter(meterIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	planID, err := uuid.Parse(req.PlanID)
+	if err != nil {
+		return nil, errors.New("invalid plan ID")
+	}
+
+	plan, err := s.planRepo.GetByID(planID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("plan not found")
+		}
+		return nil, err
+	}
+
+	existing, err := s.meterRepo.GetPricesByPlanID(plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range existing {
+		if price.MeterID == meter.ID {
+			return nil, errors.New("plan already has a price for this meter")
+		}
+	}
+
+	// Usage is aggregated per organization, and an organization holds one
+	// subscription per product, so a meter billed on plans of two products
+	// would bill the same usage twice
+	billed, err := s.meterRepo.GetPricesByMeterID(meter.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range billed {
+		pricedPlan, err := s.planRepo.GetByID(price.PlanID)
+		if err != nil {
+			return nil, err
+		}
+		if pricedPlan.Product != plan.Product {
+			return nil, errors.New("meter is billed on plans of another product")
+		}
+	}
+
+	unitAmount, err := planPrice(req.UnitAmount, plan.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	packageSize := req.PackageSize
+	if packageSize < 1 {
+		packageSize = 1
+	}
+
+	price := &models.MeteredPrice{
+		PlanID:        plan.ID,
+		MeterID:       meter.ID,
+		UnitAmount:    unitAmount,
+		Currency:      unitAmount.Currency(),
+		PackageSize:   packageSize,
+		IncludedUnits: req.IncludedUnits,
+		Description:   req.Description,
+	}
+	if err := s.meterRepo.CreatePrice(price); err != nil {
+		return nil, err
+	}
+
+	price.Meter = *meter
+	return price, nil
+}
+
+// DeleteMeteredPrice stops billing a meter on a plan. Usage of periods that
+// have not closed yet is no longer charged.
+func (s *MeteringService) DeleteMeteredPrice(idStr string) error {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return errors.New("invalid metered price ID")
+	}
+
+	if _, err := s.meterRepo.GetPriceByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("metered price not found")
+		}
+		return err
+	}
+	return s.meterRepo.DeletePrice(id)
+}
+
+// RecordUsage ingests a usage event. The event counts towards the billing
+// period its timestamp falls in, including periods already billed, whose
+// late usage is charged when the subscription's next period closes. An event
+// ID the organization already reported is acknowledged without being stored
+// again.
+func (s *MeteringService) RecordUsage(req *RecordUsageRequest) (*RecordUsageResult, error) {
+	orgID, err := uuid.Parse(req.OrganizationID)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+
+	meters, err := s.meterRepo.GetActiveByEventName(req.EventName)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := usageEvent(req, org.ID, meters, organizationNow(s.clock, s.testClockRepo, org))
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.usageRepo.CreateEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordUsageResult{Event: event, Duplicate: !created}, nil
+}
+
+// usageEvent validates a usage event against the active meters for its event
+// name and builds it for storing, ingested at now
+func usageEvent(req *RecordUsageRequest, orgID uuid.UUID, meters []*models.Meter, now time.Time) (*models.UsageEvent, error) {
+	if len(meters) == 0 {
+		return nil, errors.New("no active meter for this event")
+	}
+
+	properties := req.Properties
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	for _, meter := range meters {
+		if !meter.Matches(properties) {
+			continue
+		}
+		if err := validateUsageValue(meter, properties); err != nil {
+			return nil, err
+		}
+	}
+
+	occurredAt := now
+	if req.Timestamp != nil {
+		occurredAt = *req.Timestamp
+	}
+	if occurredAt.After(now.Add(usageClockSkew)) {
+		return nil, errors.New("usage event timestamp is in the future")
+	}
+
+	return &models.UsageEvent{
+		OrganizationID: orgID,
+		EventID:        req.EventID,
+		EventName:      req.EventName,
+		OccurredAt:     occurredAt,
+		Properties:     models.JSONMap(properties),
+		IngestedAt:     now,
+	}, nil
+}
+
+// validateUsageValue checks that an event carries the property a meter aggregates
+func validateUsageValue(meter *models.Meter, properties map[string]interface{}) error {
+	if meter.Aggregation == models.MeterAggregationCount {
+		return nil
+	}
+
+	value, ok := properties[meter.ValueProperty]
+	if !ok || value == nil {
+		return errors.New("usage event is missing the meter value property")
+	}
+	if meter.Aggregation == models.MeterAggregationUniqueCount {
+		return nil
+	}
+	if _, ok := value.(float64); !ok {
+		return errors.New("usage event value property must be a number")
+	}
+	return nil
+}
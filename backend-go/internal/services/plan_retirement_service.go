@@ -0,0 +1,514 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// planMigrationBatchSize is how many subscriptions a migration processes
+// before persisting progress
+const planMigrationBatchSize = 100
+
+// migratableStatuses are the subscription statuses that are moved off a
+// retired plan: every subscription that can still renew on it
+var migratableStatuses = []string{
+	models.SubscriptionStatusActive,
+	models.SubscriptionStatusTrialing,
+	models.SubscriptionStatusPastDue,
+	models.SubscriptionStatusPaused,
+	models.SubscriptionStatusSuspended,
+}
+
+// PlanRetirementService retires plans and migrates their subscribers in the background
+type PlanRetirementService struct {
+	planRepo            repository.PlanRepository
+	subscriptionRepo    repository.SubscriptionRepository
+	migrationRepo       repository.PlanMigrationRepository
+	jobLock             repository.JobLockRepository
+	subscriptionService *SubscriptionService
+	clock               clock.Clock
+
+	// running tracks migrations that have a worker goroutine in this process
+	running sync.Map
+}
+
+// NewPlanRetirementService creates a new plan retirement service
+func NewPlanRetirementService(
+	planRepo repository.PlanRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	migrationRepo repository.PlanMigrationRepository,
+	jobLock repository.JobLockRepository,
+	subscriptionService *SubscriptionService,
+	clk clock.Clock,
+) *PlanRetirementService {
+	return &PlanRetirementService{
+		planRepo:            planRepo,
+		subscriptionRepo:    subscriptionRepo,
+		migrationRepo:       migrationRepo,
+		jobLock:             jobLock,
+		subscriptionService: subscriptionService,
+		clock:               clk,
+	}
+}
+
+// RetirePlanRequest represents plan retirement data
+type RetirePlanRequest struct {
+	TargetPlanID     string `json:"target_plan_id" binding:"required"`
+	Mode             string `json:"mode" binding:"required,oneof=immediate at_renewal"`
+	Prorate          *bool  `json:"prorate,omitempty"`
+	DeactivateSource *bool  `json:"deactivate_source,omitempty"`
+}
+
+// PlanMigrationReport summarizes a plan migration
+type PlanMigrationReport struct {
+	Migration      *models.PlanMigration       `json:"migration"`
+	TotalCredit    money.Money                 `json:"total_credit"`
+	TotalCharge    money.Money                 `json:"total_charge"`
+	InvoicesIssued int                         `json:"invoices_issued"`
+	Failures       []*models.PlanMigrationItem `json:"failures"`
+	Skipped        []*models.PlanMigrationItem `json:"skipped"`
+}
+
+// GetAffectedSubscriptions lists the subscriptions that retiring a plan would migrate
+func (s *PlanRetirementService) GetAffectedSubscriptions(planIDStr string) ([]*models.Subscription, error) {
+	planID, err := uuid.Parse(planIDStr)
+	if err != nil {
+		return nil, errors.New("invalid plan ID")
+	}
+
+	if _, err := s.planRepo.GetByID(planID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("plan not found")
+		}
+		return nil, err
+	}
+
+	return s.subscriptionRepo.GetByPlanID(planID, migratableStatuses)
+}
+
+// RetirePlan records a migration of every affected subscription onto the
+// target plan and starts processing it in the background
+func (s *PlanRetirementService) RetirePlan(planIDStr string, req *RetirePlanRequest, createdBy string) (*models.PlanMigration, error) {
+	sourceID, err := uuid.Parse(planIDStr)
+	if err != nil {
+		return nil, errors.New("invalid plan ID")
+	}
+
+	targetID, err := uuid.Parse(req.TargetPlanID)
+	if err != nil {
+		return nil, errors.New("invalid target plan ID")
+	}
+
+	if sourceID == targetID {
+		return nil, errors.New("target plan must differ from the retired plan")
+	}
+
+	source, err := s.planRepo.GetByID(sourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("plan not found")
+		}
+		return nil, err
+	}
+
+	target, err := s.planRepo.GetByID(targetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("target plan not found")
+		}
+		return nil, err
+	}
+
+	if !target.IsActive {
+		return nil, errors.New("target plan is not active")
+	}
+	if target.Product != source.Product {
+		return nil, errors.New("plan belongs to a different product")
+	}
+
+	unfinished, err := s.migrationRepo.GetUnfinished()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range unfinished {
+		if m.SourcePlanID == sourceID {
+			return nil, errors.New("plan already has a migration in progress")
+		}
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetByPlanID(sourceID, migratableStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := &models.PlanMigration{
+		SourcePlanID:     sourceID,
+		TargetPlanID:     targetID,
+		Mode:             req.Mode,
+		Prorate:          req.Prorate == nil || *req.Prorate,
+		DeactivateSource: req.DeactivateSource == nil || *req.DeactivateSource,
+		Status:           models.PlanMigrationStatusPending,
+		TotalCount:       len(subscriptions),
+	}
+	if userID, err := uuid.Parse(createdBy); err == nil {
+		migration.CreatedBy = &userID
+	}
+
+	items := make([]*models.PlanMigrationItem, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		items = append(items, &models.PlanMigrationItem{
+			SubscriptionID: subscription.ID,
+			OrganizationID: subscription.OrganizationID,
+			Status:         models.PlanMigrationItemPending,
+			Currency:       source.Currency,
+		})
+	}
+
+	if err := s.migrationRepo.Create(migration, items); err != nil {
+		return nil, err
+	}
+
+	// Stop selling the plan before its subscribers are moved off it
+	if migration.DeactivateSource && source.IsActive {
+		source.IsActive = false
+		if err := s.planRepo.Update(source); err != nil {
+			return nil, err
+		}
+	}
+
+	s.start(migration.ID)
+
+	return s.migrationRepo.GetByID(migration.ID)
+}
+
+// GetMigration gets a plan migration by ID
+func (s *PlanRetirementService) GetMigration(idStr string) (*models.PlanMigration, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errors.New("invalid migration ID")
+	}
+
+	migration, err := s.migrationRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("migration not found")
+		}
+		return nil, err
+	}
+
+	return migration, nil
+}
+
+// GetMigrations gets all plan migrations with pagination
+func (s *PlanRetirementService) GetMigrations(page, limit int) ([]*models.PlanMigration, int64, error) {
+	offset := (page - 1) * limit
+	migrations, err := s.migrationRepo.List(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.migrationRepo.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return migrations, total, nil
+}
+
+// GetMigrationItems gets per-subscription results, optionally filtered by status
+func (s *PlanRetirementService) GetMigrationItems(idStr, status string, page, limit int) ([]*models.PlanMigrationItem, int64, error) {
+	migration, err := s.GetMigration(idStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	items, err := s.migrationRepo.GetItems(migration.ID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.migrationRepo.CountItems(migration.ID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+// GetMigrationReport builds the summary report for a migration
+func (s *PlanRetirementService) GetMigrationReport(idStr string) (*PlanMigrationReport, error) {
+	migration, err := s.GetMigration(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.migrationRepo.GetItems(migration.ID, "", -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prorations are always expressed in the currency of the retired plan
+	currency := migration.SourcePlan.Currency
+	report := &PlanMigrationReport{
+		Migration:   migration,
+		TotalCredit: money.Zero(currency),
+		TotalCharge: money.Zero(currency),
+		Failures:    []*models.PlanMigrationItem{},
+		Skipped:     []*models.PlanMigrationItem{},
+	}
+	for _, item := range items {
+		if report.TotalCredit, err = report.TotalCredit.Add(item.CreditAmount); err != nil {
+			return nil, err
+		}
+		if report.TotalCharge, err = report.TotalCharge.Add(item.ChargeAmount); err != nil {
+			return nil, err
+		}
+		if item.InvoiceID != nil {
+			report.InvoicesIssued++
+		}
+		switch item.Status {
+		case models.PlanMigrationItemFailed:
+			report.Failures = append(report.Failures, item)
+		case models.PlanMigrationItemSkipped:
+			report.Skipped = append(report.Skipped, item)
+		}
+	}
+	return report, nil
+}
+
+// ResumeMigration restarts processing of an unfinished migration
+func (s *PlanRetirementService) ResumeMigration(idStr string) (*models.PlanMigration, error) {
+	migration, err := s.GetMigration(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if migration.IsFinished() {
+		return nil, errors.New("migration is already finished")
+	}
+
+	s.start(migration.ID)
+	return migration, nil
+}
+
+// RetryFailedItems puts failed items back in the queue and resumes the migration
+func (s *PlanRetirementService) RetryFailedItems(idStr string) (*models.PlanMigration, error) {
+	migration, err := s.GetMigration(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if migration.Status == models.PlanMigrationStatusCanceled {
+		return nil, errors.New("migration is canceled")
+	}
+
+	failed, err := s.migrationRepo.GetItems(migration.ID, models.PlanMigrationItemFailed, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range failed {
+		item.Status = models.PlanMigrationItemPending
+		item.Message = ""
+		item.ProcessedAt = nil
+		if err := s.migrationRepo.UpdateItem(item); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(failed) > 0 {
+		migration.Status = models.PlanMigrationStatusPending
+		migration.CompletedAt = nil
+		if err := s.migrationRepo.Update(migration); err != nil {
+			return nil, err
+		}
+		s.start(migration.ID)
+	}
+
+	return migration, nil
+}
+
+// CancelMigration stops a migration; items already processed keep their outcome
+func (s *PlanRetirementService) CancelMigration(idStr string) (*models.PlanMigration, error) {
+	migration, err := s.GetMigration(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if migration.IsFinished() {
+		return nil, errors.New("migration is already finished")
+	}
+
+	now := s.clock.Now()
+	migration.Status = models.PlanMigrationStatusCanceled
+	migration.CompletedAt = &now
+	if err := s.migrationRepo.Update(migration); err != nil {
+		return nil, err
+	}
+
+	return migration, nil
+}
+
+// ResumeUnfinishedMigrations restarts every migration that was interrupted,
+// for example by a server restart or by the replica running it going away.
+// Migrations another replica is still running are left to it.
+func (s *PlanRetirementService) ResumeUnfinishedMigrations() error {
+	migrations, err := s.migrationRepo.GetUnfinished()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		s.start(migration.ID)
+	}
+	return nil
+}
+
+// start launches a worker for the migration unless one is already running
+// here. The worker holds the migration's lock while it runs, so replicas
+// that start the same migration leave it to the one that took the lock.
+func (s *PlanRetirementService) start(id uuid.UUID) {
+	if _, loaded := s.running.LoadOrStore(id, struct{}{}); loaded {
+		return
+	}
+
+	go func() {
+		defer s.running.Delete(id)
+
+		release, acquired, err := s.jobLock.TryLock("plan-migration:" + id.String())
+		if err != nil {
+			log.Printf("❌ Plan migration %s failed to take its lock: %v", id, err)
+			return
+		}
+		if !acquired {
+			return
+		}
+		defer release()
+
+		if err := s.process(id); err != nil {
+			log.Printf("❌ Plan migration %s stopped: %v", id, err)
+		}
+	}()
+}
+
+// process works through the pending items of a migration in batches. Each
+// item is persisted as soon as it is handled, so an interrupted run picks up
+// where it left off.
+func (s *PlanRetirementService) process(id uuid.UUID) error {
+	migration, err := s.migrationRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if migration.IsFinished() {
+		return nil
+	}
+
+	target, err := s.planRepo.GetByID(migration.TargetPlanID)
+	if err != nil {
+		return err
+	}
+
+	if migration.Status == models.PlanMigrationStatusPending {
+		now := s.clock.Now()
+		migration.Status = models.PlanMigrationStatusRunning
+		if migration.StartedAt == nil {
+			migration.StartedAt = &now
+		}
+		if err := s.migrationRepo.Update(migration); err != nil {
+			return err
+		}
+	}
+
+	for {
+		// Re-read the migration so a cancel request takes effect between batches
+		current, err := s.migrationRepo.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if current.Status == models.PlanMigrationStatusCanceled {
+			return nil
+		}
+
+		items, err := s.migrationRepo.GetPendingItems(id, planMigrationBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			s.processItem(item, target, migration)
+			if err := s.migrationRepo.UpdateItem(item); err != nil {
+				return err
+			}
+		}
+
+		if err := s.refreshCounts(current); err != nil {
+			return err
+		}
+	}
+
+	if err := s.migrationRepo.MarkCompleted(id, s.clock.Now()); err != nil {
+		return err
+	}
+
+	migration, err = s.migrationRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Plan migration %s completed: %d migrated, %d scheduled, %d skipped, %d failed",
+		id, migration.MigratedCount, migration.ScheduledCount, migration.SkippedCount, migration.FailedCount)
+	return nil
+}
+
+// processItem migrates a single subscription and records the outcome on the item
+func (s *PlanRetirementService) processItem(item *models.PlanMigrationItem, target *models.Plan, migration *models.PlanMigration) {
+	now := s.clock.Now()
+	item.ProcessedAt = &now
+
+	outcome, err := s.subscriptionService.MigrateSubscriptionPlan(item.SubscriptionID, target, migration.Mode, migration.Prorate)
+	if err != nil {
+		item.Status = models.PlanMigrationItemFailed
+		item.Message = err.Error()
+		return
+	}
+
+	effectiveAt := outcome.EffectiveAt
+	item.Status = outcome.Status
+	item.Message = outcome.Reason
+	item.CreditAmount = outcome.CreditAmount
+	item.ChargeAmount = outcome.ChargeAmount
+	item.InvoiceID = outcome.InvoiceID
+	item.EffectiveAt = &effectiveAt
+}
+
+// refreshCounts recomputes the outcome counters from the stored items and saves them
+func (s *PlanRetirementService) refreshCounts(migration *models.PlanMigration) error {
+	counters := map[string]*int{
+		models.PlanMigrationItemMigrated:  &migration.MigratedCount,
+		models.PlanMigrationItemScheduled: &migration.ScheduledCount,
+		models.PlanMigrationItemSkipped:   &migration.SkippedCount,
+		models.PlanMigrationItemFailed:    &migration.FailedCount,
+	}
+	for status, counter := range counters {
+		count, err := s.migrationRepo.CountItems(migration.ID, status)
+		if err != nil {
+			return err
+		}
+		*counter = int(count)
+	}
+
+	return s.migrationRepo.UpdateProgress(migration)
+}
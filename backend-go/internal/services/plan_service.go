@@ -4,54 +4,70 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 	"go-backend/internal/models"
 	"go-backend/internal/repository"
+	"go-backend/pkg/currency"
+	"go-backend/pkg/money"
 	"go-backend/pkg/utils"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // PlanService handles plan business logic
 type PlanService struct {
-	planRepo repository.PlanRepository
+	planRepo         repository.PlanRepository
+	subscriptionRepo repository.SubscriptionRepository
+	meterRepo        repository.MeterRepository
+	currencies       *currency.Registry
 }
 
 // NewPlanService creates a new plan service
-func NewPlanService(planRepo repository.PlanRepository) *PlanService {
+func NewPlanService(planRepo repository.PlanRepository, subscriptionRepo repository.SubscriptionRepository, meterRepo repository.MeterRepository, currencies *currency.Registry) *PlanService {
 	return &PlanService{
-		planRepo: planRepo,
+		planRepo:         planRepo,
+		subscriptionRepo: subscriptionRepo,
+		meterRepo:        meterRepo,
+		currencies:       currencies,
 	}
 }
 
 // CreatePlanRequest represents plan creation data
 type CreatePlanRequest struct {
-	Name        string   `json:"name" binding:"required,min=2,max=100"`
-	Description string   `json:"description" binding:"required,min=10,max=500"`
-	Price       float64  `json:"price" binding:"required,min=0"`
-	Currency    string   `json:"currency" binding:"required,len=3"`
-	Interval    string   `json:"interval" binding:"required,oneof=weekly monthly yearly"`
-	Features    []string `json:"features" binding:"required,min=1"`
-	TrialDays   int      `json:"trial_days" binding:"min=0,max=365"`
-	IsPopular   bool     `json:"is_popular"`
+	Name        string      `json:"name" binding:"required,min=2,max=100"`
+	Slug        string      `json:"slug,omitempty" binding:"omitempty,min=2,max=100"`
+	Description string      `json:"description" binding:"required,min=10,max=500"`
+	Product     string      `json:"product,omitempty" binding:"omitempty,min=2,max=50"` // product line; defaults to "default"
+	Price       money.Money `json:"price"`
+	Currency    string      `json:"currency" binding:"required,len=3"`
+	Interval    string      `json:"interval" binding:"required,oneof=weekly monthly yearly"`
+	Features    []string    `json:"features" binding:"required,min=1"`
+	TrialDays   int         `json:"trial_days" binding:"min=0,max=365"`
+	IsPopular   bool        `json:"is_popular"`
 }
 
 // UpdatePlanRequest represents plan update data
 type UpdatePlanRequest struct {
-	Name        *string   `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
-	Description *string   `json:"description,omitempty" binding:"omitempty,min=10,max=500"`
-	Price       *float64  `json:"price,omitempty" binding:"omitempty,min=0"`
-	Currency    *string   `json:"currency,omitempty" binding:"omitempty,len=3"`
-	Interval    *string   `json:"interval,omitempty" binding:"omitempty,oneof=weekly monthly yearly"`
-	Features    *[]string `json:"features,omitempty" binding:"omitempty,min=1"`
-	TrialDays   *int      `json:"trial_days,omitempty" binding:"omitempty,min=0,max=365"`
-	IsActive    *bool     `json:"is_active,omitempty"`
-	IsPopular   *bool     `json:"is_popular,omitempty"`
+	Name        *string      `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Slug        *string      `json:"slug,omitempty" binding:"omitempty,min=2,max=100"`
+	Description *string      `json:"description,omitempty" binding:"omitempty,min=10,max=500"`
+	Product     *string      `json:"product,omitempty" binding:"omitempty,min=2,max=50"`
+	Price       *money.Money `json:"price,omitempty"`
+	Currency    *string      `json:"currency,omitempty" binding:"omitempty,len=3"`
+	Interval    *string      `json:"interval,omitempty" binding:"omitempty,oneof=weekly monthly yearly"`
+	Features    *[]string    `json:"features,omitempty" binding:"omitempty,min=1"`
+	TrialDays   *int         `json:"trial_days,omitempty" binding:"omitempty,min=0,max=365"`
+	IsActive    *bool        `json:"is_active,omitempty"`
+	IsPopular   *bool        `json:"is_popular,omitempty"`
 }
 
 // CreatePlan creates a new plan
 func (s *PlanService) CreatePlan(req *CreatePlanRequest) (*models.Plan, error) {
-	// Generate slug from name
+	// Generate slug from name unless an explicit one was supplied
 	slug := utils.GenerateSlug(req.Name)
+	if req.Slug != "" {
+		slug = utils.GenerateSlug(req.Slug)
+	}
 
 	// Check if slug already exists
 	existingPlan, err := s.planRepo.GetBySlug(slug)
@@ -63,8 +79,13 @@ func (s *PlanService) CreatePlan(req *CreatePlanRequest) (*models.Plan, error) {
 	}
 
 	// Validate currency
-	if !isValidCurrency(req.Currency) {
-		return nil, errors.New("invalid currency code")
+	if err := s.currencies.Validate(req.Currency); err != nil {
+		return nil, err
+	}
+
+	price, err := planPrice(req.Price, req.Currency)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create plan
@@ -72,8 +93,9 @@ func (s *PlanService) CreatePlan(req *CreatePlanRequest) (*models.Plan, error) {
 		Name:        req.Name,
 		Slug:        slug,
 		Description: req.Description,
-		Price:       req.Price,
-		Currency:    req.Currency,
+		Product:     planProduct(req.Product),
+		Price:       price,
+		Currency:    price.Currency(),
 		Interval:    req.Interval,
 		Features:    "", // Will be set below
 		IsActive:    true,
@@ -106,6 +128,43 @@ func (s *PlanService) GetPlanByID(idStr string) (*models.Plan, error) {
 	return s.planRepo.GetByID(id)
 }
 
+// ValidateCreatePlanRequest applies the same rules the HTTP layer enforces on
+// plan creation, for callers that do not go through request binding
+func (s *PlanService) ValidateCreatePlanRequest(req *CreatePlanRequest) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+	if err := s.currencies.Validate(req.Currency); err != nil {
+		return err
+	}
+	if _, err := planPrice(req.Price, req.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateUpdatePlanRequest applies the same rules the HTTP layer enforces on
+// plan updates, for callers that do not go through request binding
+func (s *PlanService) ValidateUpdatePlanRequest(req *UpdatePlanRequest) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+	if req.Currency != nil {
+		if err := s.currencies.Validate(*req.Currency); err != nil {
+			return err
+		}
+	}
+	if req.Price != nil && req.Price.IsNegative() {
+		return errors.New("price must not be negative")
+	}
+	return nil
+}
+
+// GetAllPlansUnpaginated gets every plan, active or not, ordered by slug
+func (s *PlanService) GetAllPlansUnpaginated() ([]*models.Plan, error) {
+	return s.planRepo.GetAll()
+}
+
 // GetPlanBySlug gets a plan by slug
 func (s *PlanService) GetPlanBySlug(slug string) (*models.Plan, error) {
 	return s.planRepo.GetBySlug(slug)
@@ -150,9 +209,21 @@ func (s *PlanService) UpdatePlan(idStr string, req *UpdatePlanRequest) (*models.
 	}
 
 	// Update fields if provided
+	if req.Slug != nil {
+		newSlug := utils.GenerateSlug(*req.Slug)
+		existingPlan, err := s.planRepo.GetBySlug(newSlug)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if existingPlan != nil && existingPlan.ID != plan.ID {
+			return nil, errors.New("plan with this slug already exists")
+		}
+		plan.Slug = newSlug
+	}
+
 	if req.Name != nil {
-		// Generate new slug if name changed
-		if *req.Name != plan.Name {
+		// Generate new slug if name changed and no explicit slug was given
+		if *req.Name != plan.Name && req.Slug == nil {
 			newSlug := utils.GenerateSlug(*req.Name)
 			// Check if new slug already exists
 			existingPlan, err := s.planRepo.GetBySlug(newSlug)
@@ -171,15 +242,46 @@ func (s *PlanService) UpdatePlan(idStr string, req *UpdatePlanRequest) (*models.
 		plan.Description = *req.Description
 	}
 
-	if req.Price != nil {
-		plan.Price = *req.Price
+	// Moving a plan with subscriptions to another product could give an
+	// organization two subscriptions to the same product
+	if req.Product != nil && planProduct(*req.Product) != plan.Product {
+		subscribed, err := s.subscriptionRepo.CountByPlanID(plan.ID, repository.OpenStatuses)
+		if err != nil {
+			return nil, err
+		}
+		if subscribed > 0 {
+			return nil, errors.New("cannot change the product of a plan with subscriptions")
+		}
+		// Its meters would then be billed on plans of two products
+		prices, err := s.meterRepo.GetPricesByPlanID(plan.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(prices) > 0 {
+			return nil, errors.New("cannot change the product of a plan with metered prices")
+		}
+		plan.Product = planProduct(*req.Product)
 	}
 
 	if req.Currency != nil {
-		if !isValidCurrency(*req.Currency) {
-			return nil, errors.New("invalid currency code")
+		if err := s.currencies.Validate(*req.Currency); err != nil {
+			return nil, err
+		}
+		plan.Currency = currency.Normalize(*req.Currency)
+	}
+
+	// Re-express the price in the (possibly new) currency
+	price := plan.Price
+	if req.Price != nil {
+		price = *req.Price
+	} else if price.Currency() != plan.Currency {
+		price, err = money.Parse(price.Decimal(), plan.Currency)
+		if err != nil {
+			return nil, err
 		}
-		plan.Currency = *req.Currency
+	}
+	if plan.Price, err = planPrice(price, plan.Currency); err != nil {
+		return nil, err
 	}
 
 	if req.Interval != nil {
@@ -225,9 +327,16 @@ func (s *PlanService) DeletePlan(idStr string) error {
 		return err
 	}
 
-	// Check if plan has active subscriptions
-	// Note: This would require a subscription repository method
-	// For now, we'll just deactivate the plan
+	// Plans with live subscribers must be retired through a migration so
+	// those subscribers are moved somewhere first
+	activeCount, err := s.subscriptionRepo.CountByPlanID(plan.ID, migratableStatuses)
+	if err != nil {
+		return err
+	}
+	if activeCount > 0 {
+		return errors.New("plan has active subscriptions")
+	}
+
 	plan.IsActive = false
 	return s.planRepo.Update(plan)
 }
@@ -294,36 +403,22 @@ func (s *PlanService) SetPopularPlan(idStr string) error {
 	return s.planRepo.Update(plan)
 }
 
-// isValidCurrency checks if a currency code is valid
-func isValidCurrency(currency string) bool {
-	validCurrencies := map[string]bool{
-		"USD": true,
-		"EUR": true,
-		"GBP": true,
-		"CAD": true,
-		"AUD": true,
-		"JPY": true,
-		"CHF": true,
-		"CNY": true,
-		"INR": true,
-		"BRL": true,
-		"MXN": true,
-		"SGD": true,
-		"HKD": true,
-		"NOK": true,
-		"SEK": true,
-		"DKK": true,
-		"PLN": true,
-		"CZK": true,
-		"HUF": true,
-		"RUB": true,
-		"ZAR": true,
-		"KRW": true,
-		"THB": true,
-		"MYR": true,
-		"PHP": true,
-		"IDR": true,
-		"VND": true,
-	}
-	return validCurrencies[currency]
-}
\ No newline at end of file
+// planPrice binds a requested price to the plan currency and rejects negative amounts
+func planPrice(price money.Money, code string) (money.Money, error) {
+	if price.Currency() != "" && price.Currency() != currency.Normalize(code) {
+		return money.Money{}, errors.New("price currency does not match plan currency")
+	}
+	price = price.Bind(code)
+	if price.IsNegative() {
+		return money.Money{}, errors.New("price must not be negative")
+	}
+	return price, nil
+}
+
+// planProduct normalizes a product line name, defaulting to models.DefaultProduct
+func planProduct(product string) string {
+	if product = utils.GenerateSlug(product); product == "" {
+		return models.DefaultProduct
+	}
+	return product
+}
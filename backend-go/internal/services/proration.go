@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+)
+
+// prorationRounding is the rounding applied to prorated amounts
+const prorationRounding = money.RoundHalfUp
+
+// prorateAmount returns the share of amount covering what remains of the period
+// [start, end) after at, clamped between zero and the full amount
+func prorateAmount(amount money.Money, start, end, at time.Time) money.Money {
+	total := end.Sub(start)
+	if total <= 0 {
+		return money.Zero(amount.Currency())
+	}
+
+	remaining := end.Sub(at)
+	if remaining <= 0 {
+		return money.Zero(amount.Currency())
+	}
+	if remaining >= total {
+		return amount
+	}
+
+	// A fraction of the amount always fits, so there is no overflow to report
+	prorated, _ := amount.MulRat(int64(remaining), int64(total), prorationRounding)
+	return prorated
+}
+
+// discounted returns amount less the discount the subscription takes off
+// its current period
+func discounted(subscription *models.Subscription, amount money.Money) (money.Money, error) {
+	discount, err := amount.Percent(int64(subscription.PeriodPercentOff())*100, prorationRounding)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return amount.Sub(discount)
+}
+
+// remainingPeriodAmount returns what quantity units of plan cost for the part
+// of the subscription's current period after at: the period is priced as it
+// is invoiced, so a shorter first period costs only its share, and the
+// subscription's discount is taken off before prorating
+func remainingPeriodAmount(subscription *models.Subscription, plan *models.Plan, quantity int, at time.Time) (money.Money, error) {
+	start, end := subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd
+	amount, err := periodPrice(subscription, plan, start, end).Mul(int64(quantity))
+	if err != nil {
+		return money.Money{}, err
+	}
+	if amount, err = discounted(subscription, amount); err != nil {
+		return money.Money{}, err
+	}
+	return prorateAmount(amount, start, end, at), nil
+}
+
+// addInterval returns the end of a billing period of the given plan interval starting at start
+func addInterval(start time.Time, interval string) (time.Time, error) {
+	switch interval {
+	case "monthly":
+		return start.AddDate(0, 1, 0), nil
+	case "yearly":
+		return start.AddDate(1, 0, 0), nil
+	case "weekly":
+		return start.AddDate(0, 0, 7), nil
+	default:
+		return time.Time{}, errors.New("invalid plan interval")
+	}
+}
+
+// intervalMonths returns the length of a calendar-aligned plan interval in
+// months; weekly plans are not aligned to a day of the month
+func intervalMonths(interval string) (int, bool) {
+	switch interval {
+	case "monthly":
+		return 1, true
+	case "yearly":
+		return 12, true
+	default:
+		return 0, false
+	}
+}
+
+// anchorDate returns the anchor day of the given month at the anchor time,
+// clamping the day to the length of the month. Months outside 1-12 roll over
+// into neighbouring years.
+func anchorDate(year int, month time.Month, anchor models.BillingAnchor, loc *time.Location, hour, minute int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := anchor.Day
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, hour, minute, 0, 0, loc)
+}
+
+// nextPeriodEnd returns the end of a subscription's billing period of the given
+// interval that starts at start. Anchored subscriptions end on the anchor: a
+// period starting on an anchor date lasts a full interval, any other period
+// is a shorter first period ending on the next anchor date.
+func nextPeriodEnd(subscription *models.Subscription, start time.Time, interval string) (time.Time, error) {
+	months, aligned := intervalMonths(interval)
+	if !subscription.BillingAnchor.IsSet() || !aligned {
+		return addInterval(start, interval)
+	}
+
+	loc, err := subscription.BillingAnchor.Location()
+	if err != nil {
+		return time.Time{}, errors.New("invalid billing timezone")
+	}
+	hour, minute, err := subscription.BillingAnchor.Clock()
+	if err != nil {
+		return time.Time{}, errors.New("invalid billing time")
+	}
+
+	local := start.In(loc)
+	current := anchorDate(local.Year(), local.Month(), subscription.BillingAnchor, loc, hour, minute)
+	switch {
+	case current.Equal(start):
+		return anchorDate(local.Year(), local.Month()+time.Month(months), subscription.BillingAnchor, loc, hour, minute), nil
+	case current.After(start):
+		return current, nil
+	default:
+		return anchorDate(local.Year(), local.Month()+1, subscription.BillingAnchor, loc, hour, minute), nil
+	}
+}
+
+// periodPrice returns the unit price of a plan for the period [start, end).
+// A shorter first period of an anchored subscription is prorated against the
+// full interval ending on the same anchor date; every other period costs the
+// full price.
+func periodPrice(subscription *models.Subscription, plan *models.Plan, start, end time.Time) money.Money {
+	months, aligned := intervalMonths(plan.Interval)
+	if !subscription.BillingAnchor.IsSet() || !aligned {
+		return plan.Price
+	}
+
+	loc, err := subscription.BillingAnchor.Location()
+	if err != nil {
+		return plan.Price
+	}
+	hour, minute, err := subscription.BillingAnchor.Clock()
+	if err != nil {
+		return plan.Price
+	}
+
+	local := end.In(loc)
+	fullStart := anchorDate(local.Year(), local.Month()-time.Month(months), subscription.BillingAnchor, loc, hour, minute)
+	if !start.After(fullStart) {
+		return plan.Price
+	}
+	return prorateAmount(plan.Price, fullStart, end, start)
+}
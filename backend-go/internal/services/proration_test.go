@@ -0,0 +1,149 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+)
+
+// anchored returns a subscription aligned to day at midnight in the named time zone
+func anchored(day int, timezone string) *models.Subscription {
+	return &models.Subscription{BillingAnchor: models.BillingAnchor{Day: day, Timezone: timezone}}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextPeriodEnd(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		subscription *models.Subscription
+		start        time.Time
+		interval     string
+		want         time.Time
+	}{
+		{"unanchored monthly", anchored(0, ""), date(2027, time.January, 31), "monthly", date(2027, time.March, 3)},
+		{"unanchored weekly", anchored(0, ""), date(2027, time.March, 28), "weekly", date(2027, time.April, 4)},
+		{"weekly ignores the anchor", anchored(1, ""), date(2027, time.March, 28), "weekly", date(2027, time.April, 4)},
+		{"short first period", anchored(1, ""), date(2027, time.March, 28), "monthly", date(2027, time.April, 1)},
+		{"full period from the anchor", anchored(1, ""), date(2027, time.March, 1), "monthly", date(2027, time.April, 1)},
+		{"day 31 clamps to February", anchored(31, ""), date(2027, time.January, 31), "monthly", date(2027, time.February, 28)},
+		{"day 31 clamps to a leap February", anchored(31, ""), date(2028, time.January, 31), "monthly", date(2028, time.February, 29)},
+		{"clamped anchor date starts a full period", anchored(31, ""), date(2027, time.February, 28), "monthly", date(2027, time.March, 31)},
+		{"stub ends on the clamped day", anchored(31, ""), date(2027, time.February, 10), "monthly", date(2027, time.February, 28)},
+		{"stub after the anchor day ends next month", anchored(15, ""), date(2027, time.March, 20), "monthly", date(2027, time.April, 15)},
+		{"yearly from the anchor", anchored(1, ""), date(2027, time.March, 1), "yearly", date(2028, time.March, 1)},
+		{"yearly stub ends on the next anchor date", anchored(1, ""), date(2027, time.March, 15), "yearly", date(2027, time.April, 1)},
+		{"yearly day 29 clamps outside leap years", anchored(29, ""), date(2028, time.February, 29), "yearly", date(2029, time.February, 28)},
+		{
+			"across the spring DST change",
+			anchored(1, "America/New_York"),
+			time.Date(2027, time.March, 1, 0, 0, 0, 0, newYork),
+			"monthly",
+			time.Date(2027, time.April, 1, 0, 0, 0, 0, newYork),
+		},
+		{
+			"across the autumn DST change",
+			anchored(1, "America/New_York"),
+			time.Date(2027, time.October, 20, 0, 0, 0, 0, newYork),
+			"monthly",
+			time.Date(2027, time.November, 1, 0, 0, 0, 0, newYork),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextPeriodEnd(tt.subscription, tt.start, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextPeriodEnd = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodPrice(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monthly := &models.Plan{Interval: "monthly", Price: money.New(3100, "USD")}
+	yearly := &models.Plan{Interval: "yearly", Price: money.New(36500, "USD")}
+
+	tests := []struct {
+		name         string
+		subscription *models.Subscription
+		plan         *models.Plan
+		start, end   time.Time
+		want         money.Money
+	}{
+		{"unanchored", anchored(0, ""), monthly, date(2027, time.March, 28), date(2027, time.April, 28), money.New(3100, "USD")},
+		{"full anchored period", anchored(1, ""), monthly, date(2027, time.March, 1), date(2027, time.April, 1), money.New(3100, "USD")},
+		{"short first period", anchored(1, ""), monthly, date(2027, time.March, 28), date(2027, time.April, 1), money.New(400, "USD")},
+		{"stub in a clamped February", anchored(31, ""), monthly, date(2027, time.February, 14), date(2027, time.February, 28), money.New(1550, "USD")},
+		{"full clamped February", anchored(31, ""), monthly, date(2027, time.January, 31), date(2027, time.February, 28), money.New(3100, "USD")},
+		{"yearly stub", anchored(1, ""), yearly, date(2027, time.March, 15), date(2027, time.April, 1), money.New(1700, "USD")},
+		{"full year", anchored(1, ""), yearly, date(2027, time.April, 1), date(2028, time.April, 1), money.New(36500, "USD")},
+		{
+			"full period across DST",
+			anchored(1, "America/New_York"),
+			monthly,
+			time.Date(2027, time.March, 1, 0, 0, 0, 0, newYork),
+			time.Date(2027, time.April, 1, 0, 0, 0, 0, newYork),
+			money.New(3100, "USD"),
+		},
+		{
+			// 31 days less the hour lost on March 14, of which the stub gets
+			// 18 days less that hour
+			"stub across DST",
+			anchored(1, "America/New_York"),
+			monthly,
+			time.Date(2027, time.March, 14, 0, 0, 0, 0, newYork),
+			time.Date(2027, time.April, 1, 0, 0, 0, 0, newYork),
+			money.New(1798, "USD"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodPrice(tt.subscription, tt.plan, tt.start, tt.end); !got.Equal(tt.want) {
+				t.Errorf("periodPrice = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemainingPeriodAmount(t *testing.T) {
+	plan := &models.Plan{Interval: "monthly", Price: money.New(3100, "USD")}
+	subscription := anchored(1, "")
+	subscription.CurrentPeriodStart = date(2027, time.March, 28)
+	subscription.CurrentPeriodEnd = date(2027, time.April, 1)
+	changedAt := date(2027, time.March, 29)
+
+	// Four days were invoiced for 4.00, three of them are left
+	got, err := remainingPeriodAmount(subscription, plan, 2, changedAt)
+	if err != nil || !got.Equal(money.New(600, "USD")) {
+		t.Errorf("remainingPeriodAmount = %v, %v; want 6.00 USD", got, err)
+	}
+
+	subscription.PercentOff = 50
+	got, err = remainingPeriodAmount(subscription, plan, 2, changedAt)
+	if err != nil || !got.Equal(money.New(300, "USD")) {
+		t.Errorf("remainingPeriodAmount with 50%% off = %v, %v; want 3.00 USD", got, err)
+	}
+
+	ended := date(2027, time.March, 28)
+	subscription.DiscountEndsAt = &ended
+	got, err = remainingPeriodAmount(subscription, plan, 2, changedAt)
+	if err != nil || !got.Equal(money.New(600, "USD")) {
+		t.Errorf("remainingPeriodAmount after the discount ended = %v, %v; want 6.00 USD", got, err)
+	}
+}
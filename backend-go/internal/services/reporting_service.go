@@ -0,0 +1,322 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// reportExcludedStatuses lists invoice statuses left out of revenue figures:
+// drafts have not been billed yet and voided invoices never will be
+var reportExcludedStatuses = map[string]bool{
+	models.InvoiceStatusDraft: true,
+	models.InvoiceStatusVoid:  true,
+}
+
+// ReportingService builds cross-currency analytics and exports, converting
+// every invoice into the reporting currency at the rate of its issue date
+type ReportingService struct {
+	invoiceRepo   repository.InvoiceRepository
+	retentionRepo repository.RetentionRepository
+	exchangeRate  *ExchangeRateService
+}
+
+// NewReportingService creates a new reporting service
+func NewReportingService(invoiceRepo repository.InvoiceRepository, retentionRepo repository.RetentionRepository, exchangeRate *ExchangeRateService) *ReportingService {
+	return &ReportingService{
+		invoiceRepo:   invoiceRepo,
+		retentionRepo: retentionRepo,
+		exchangeRate:  exchangeRate,
+	}
+}
+
+// CurrencyRevenue totals the invoices issued in one currency
+type CurrencyRevenue struct {
+	Currency          string      `json:"currency"`
+	InvoiceCount      int         `json:"invoice_count"`
+	Invoiced          money.Money `json:"invoiced"`
+	Paid              money.Money `json:"paid"`
+	InvoicedConverted money.Money `json:"invoiced_converted"`
+	PaidConverted     money.Money `json:"paid_converted"`
+}
+
+// UnconvertedInvoice is an invoice left out of the totals because no rate covered it
+type UnconvertedInvoice struct {
+	InvoiceID     uuid.UUID   `json:"invoice_id"`
+	InvoiceNumber string      `json:"invoice_number"`
+	Total         money.Money `json:"total"`
+	IssueDate     time.Time   `json:"issue_date"`
+	Reason        string      `json:"reason"`
+}
+
+// RevenueReport summarizes invoiced and paid revenue in the reporting currency
+type RevenueReport struct {
+	ReportingCurrency string               `json:"reporting_currency"`
+	From              time.Time            `json:"from"`
+	To                time.Time            `json:"to"`
+	InvoiceCount      int                  `json:"invoice_count"`
+	Invoiced          money.Money          `json:"invoiced"`
+	Paid              money.Money          `json:"paid"`
+	Outstanding       money.Money          `json:"outstanding"`
+	ByCurrency        []*CurrencyRevenue   `json:"by_currency"`
+	Unconverted       []UnconvertedInvoice `json:"unconverted"`
+}
+
+// CancellationReasonShare counts the cancellations given one reason
+type CancellationReasonShare struct {
+	Reason  string `json:"reason"` // empty for cancellations without a reason
+	Count   int64  `json:"count"`
+	Percent string `json:"percent"` // share of all cancellations
+}
+
+// RetentionOfferPerformance counts how often a retention offer was shown and taken
+type RetentionOfferPerformance struct {
+	RetentionOfferID uuid.UUID `json:"retention_offer_id"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	Presented        int64     `json:"presented"`
+	Accepted         int64     `json:"accepted"`
+	Declined         int64     `json:"declined"`
+	AcceptanceRate   string    `json:"acceptance_rate"` // percentage of presentations accepted
+}
+
+// CancellationReport summarizes why subscriptions were canceled and how
+// retention offers performed
+type CancellationReport struct {
+	From            time.Time                   `json:"from"`
+	To              time.Time                   `json:"to"`
+	Cancellations   int64                       `json:"cancellations"`
+	Reasons         []CancellationReasonShare   `json:"reasons"`
+	OffersPresented int64                       `json:"offers_presented"`
+	OffersAccepted  int64                       `json:"offers_accepted"`
+	AcceptanceRate  string                      `json:"acceptance_rate"` // percentage of all presentations accepted
+	Offers          []RetentionOfferPerformance `json:"offers"`
+}
+
+// convertedInvoice pairs an invoice with its conversion into the reporting currency
+type convertedInvoice struct {
+	invoice    *models.Invoice
+	conversion *Conversion
+	err        error
+}
+
+// GetRevenueReport totals the invoices issued between from and to
+func (s *ReportingService) GetRevenueReport(from, to time.Time) (*RevenueReport, error) {
+	rows, err := s.convertInvoices(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	reporting := s.exchangeRate.ReportingCurrency()
+	report := &RevenueReport{
+		ReportingCurrency: reporting,
+		From:              from,
+		To:                to,
+		Invoiced:          money.Zero(reporting),
+		Paid:              money.Zero(reporting),
+		Outstanding:       money.Zero(reporting),
+		ByCurrency:        []*CurrencyRevenue{},
+		Unconverted:       []UnconvertedInvoice{},
+	}
+
+	byCurrency := map[string]*CurrencyRevenue{}
+	for _, row := range rows {
+		invoice := row.invoice
+		report.InvoiceCount++
+
+		group, ok := byCurrency[invoice.Currency]
+		if !ok {
+			group = &CurrencyRevenue{
+				Currency:          invoice.Currency,
+				Invoiced:          money.Zero(invoice.Currency),
+				Paid:              money.Zero(invoice.Currency),
+				InvoicedConverted: money.Zero(reporting),
+				PaidConverted:     money.Zero(reporting),
+			}
+			byCurrency[invoice.Currency] = group
+			report.ByCurrency = append(report.ByCurrency, group)
+		}
+		group.InvoiceCount++
+		if group.Invoiced, err = group.Invoiced.Add(invoice.Total); err != nil {
+			return nil, err
+		}
+		if invoice.IsPaid() {
+			if group.Paid, err = group.Paid.Add(invoice.Total); err != nil {
+				return nil, err
+			}
+		}
+
+		if row.err != nil {
+			report.Unconverted = append(report.Unconverted, UnconvertedInvoice{
+				InvoiceID:     invoice.ID,
+				InvoiceNumber: invoice.InvoiceNumber,
+				Total:         invoice.Total,
+				IssueDate:     invoice.IssueDate,
+				Reason:        row.err.Error(),
+			})
+			continue
+		}
+
+		converted := row.conversion.Converted
+		group.InvoicedConverted, _ = group.InvoicedConverted.Add(converted)
+		report.Invoiced, _ = report.Invoiced.Add(converted)
+		if invoice.IsPaid() {
+			group.PaidConverted, _ = group.PaidConverted.Add(converted)
+			report.Paid, _ = report.Paid.Add(converted)
+		} else {
+			report.Outstanding, _ = report.Outstanding.Add(converted)
+		}
+	}
+
+	sort.Slice(report.ByCurrency, func(i, j int) bool { return report.ByCurrency[i].Currency < report.ByCurrency[j].Currency })
+	return report, nil
+}
+
+// GetCancellationReport breaks the subscriptions canceled between from and
+// to down by cancellation reason, and reports the acceptance rate of the
+// retention offers shown in that time. Subscriptions reactivated since no
+// longer count as canceled.
+func (s *ReportingService) GetCancellationReport(from, to time.Time) (*CancellationReport, error) {
+	counts, err := s.retentionRepo.CountCancellations(from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.retentionRepo.GetOfferStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CancellationReport{
+		From:    from,
+		To:      to,
+		Reasons: make([]CancellationReasonShare, 0, len(counts)),
+		Offers:  make([]RetentionOfferPerformance, 0, len(stats)),
+	}
+
+	for _, count := range counts {
+		report.Cancellations += count.Count
+	}
+	for _, count := range counts {
+		report.Reasons = append(report.Reasons, CancellationReasonShare{
+			Reason:  count.Reason,
+			Count:   count.Count,
+			Percent: percentOf(count.Count, report.Cancellations),
+		})
+	}
+
+	for _, stat := range stats {
+		report.OffersPresented += stat.Presented
+		report.OffersAccepted += stat.Accepted
+		report.Offers = append(report.Offers, RetentionOfferPerformance{
+			RetentionOfferID: stat.RetentionOfferID,
+			Name:             stat.Name,
+			Type:             stat.Type,
+			Presented:        stat.Presented,
+			Accepted:         stat.Accepted,
+			Declined:         stat.Declined,
+			AcceptanceRate:   percentOf(stat.Accepted, stat.Presented),
+		})
+	}
+	report.AcceptanceRate = percentOf(report.OffersAccepted, report.OffersPresented)
+
+	return report, nil
+}
+
+// percentOf formats part as a percentage of whole; nothing of nothing is 0%
+func percentOf(part, whole int64) string {
+	if whole == 0 {
+		return "0"
+	}
+	return formatPercent(big.NewRat(part*100, whole))
+}
+
+// ExportInvoicesCSV writes one row per invoice with its total in the original
+// and the reporting currency and the rate that was used
+func (s *ReportingService) ExportInvoicesCSV(w io.Writer, from, to time.Time) error {
+	rows, err := s.convertInvoices(from, to)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{
+		"invoice_id", "invoice_number", "organization_id", "status", "issue_date",
+		"currency", "total", "reporting_currency", "exchange_rate", "rate_date", "converted_total", "conversion_error",
+	}); err != nil {
+		return err
+	}
+
+	reporting := s.exchangeRate.ReportingCurrency()
+	for _, row := range rows {
+		invoice := row.invoice
+		record := []string{
+			invoice.ID.String(), invoice.InvoiceNumber, invoice.OrganizationID.String(), invoice.Status,
+			invoice.IssueDate.Format(rateDateLayout), invoice.Currency, invoice.Total.Decimal(), reporting,
+			"", "", "", "",
+		}
+		if row.err != nil {
+			record[11] = row.err.Error()
+		} else {
+			record[8] = row.conversion.Rate.String()
+			record[9] = row.conversion.RateDate
+			record[10] = row.conversion.Converted.Decimal()
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// convertInvoices loads the reportable invoices issued between from and to and
+// converts each total into the reporting currency. Rates are looked up once
+// per currency and day.
+func (s *ReportingService) convertInvoices(from, to time.Time) ([]convertedInvoice, error) {
+	invoices, err := s.invoiceRepo.GetByDateRange(from, to, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	reporting := s.exchangeRate.ReportingCurrency()
+	type rateResult struct {
+		rate *AppliedRate
+		err  error
+	}
+	rates := map[string]rateResult{}
+
+	rows := make([]convertedInvoice, 0, len(invoices))
+	for _, invoice := range invoices {
+		if reportExcludedStatuses[invoice.Status] {
+			continue
+		}
+
+		key := invoice.Currency + "|" + invoice.IssueDate.Format(rateDateLayout)
+		result, ok := rates[key]
+		if !ok {
+			rate, err := s.exchangeRate.EffectiveRate(invoice.Currency, reporting, invoice.IssueDate)
+			if err != nil && !errors.Is(err, ErrExchangeRateNotFound) {
+				return nil, err
+			}
+			result = rateResult{rate: rate, err: err}
+			rates[key] = result
+		}
+
+		row := convertedInvoice{invoice: invoice, err: result.err}
+		if result.err == nil {
+			row.conversion, row.err = result.rate.Apply(invoice.Total, reporting)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
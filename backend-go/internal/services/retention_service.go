@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionService manages the retention offers shown to customers who are
+// about to cancel. Subscriptions are offered them by SubscriptionService.
+type RetentionService struct {
+	retentionRepo repository.RetentionRepository
+	planRepo      repository.PlanRepository
+}
+
+// NewRetentionService creates a new retention service
+func NewRetentionService(retentionRepo repository.RetentionRepository, planRepo repository.PlanRepository) *RetentionService {
+	return &RetentionService{
+		retentionRepo: retentionRepo,
+		planRepo:      planRepo,
+	}
+}
+
+// CreateRetentionOfferRequest represents retention offer creation data
+type CreateRetentionOfferRequest struct {
+	Name           string   `json:"name" binding:"required,min=2,max=100"`
+	Description    string   `json:"description"`
+	Type           string   `json:"type" binding:"required,oneof=discount downgrade"`
+	PercentOff     int      `json:"percent_off" binding:"omitempty,min=1,max=100"`    // discount offers
+	DurationMonths int      `json:"duration_months" binding:"omitempty,min=1,max=36"` // discount offers
+	PlanID         string   `json:"plan_id"`                                          // downgrade offers: plan to switch to
+	Product        string   `json:"product"`                                          // only subscriptions to this product; defaults to the plan's product for downgrades
+	Reasons        []string `json:"reasons"`                                          // only these cancellation reasons; empty for all
+}
+
+// UpdateRetentionOfferRequest represents retention offer update data. What an
+// offer gives is fixed once created so its acceptance rate stays meaningful.
+type UpdateRetentionOfferRequest struct {
+	Name        *string  `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Description *string  `json:"description,omitempty"`
+	Reasons     []string `json:"reasons,omitempty"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
+
+// CreateOffer creates a retention offer
+func (s *RetentionService) CreateOffer(req *CreateRetentionOfferRequest) (*models.RetentionOffer, error) {
+	reasons, err := offerReasons(req.Reasons)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := &models.RetentionOffer{
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Product:     req.Product,
+		Reasons:     reasons,
+		IsActive:    true,
+	}
+
+	switch req.Type {
+	case models.RetentionOfferTypeDiscount:
+		if req.PlanID != "" {
+			return nil, errors.New("discount offers cannot have a plan")
+		}
+		if req.PercentOff < 1 || req.DurationMonths < 1 {
+			return nil, errors.New("discount offers require a percentage and a duration")
+		}
+		offer.PercentOff = req.PercentOff
+		offer.DurationMonths = req.DurationMonths
+	case models.RetentionOfferTypeDowngrade:
+		if req.PercentOff != 0 || req.DurationMonths != 0 {
+			return nil, errors.New("downgrade offers cannot have a discount")
+		}
+		if req.PlanID == "" {
+			return nil, errors.New("downgrade offers require a plan")
+		}
+		planID, err := uuid.Parse(req.PlanID)
+		if err != nil {
+			return nil, errors.New("invalid plan ID")
+		}
+		plan, err := s.planRepo.GetByID(planID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("plan not found")
+			}
+			return nil, err
+		}
+		if offer.Product == "" {
+			offer.Product = plan.Product
+		}
+		if offer.Product != plan.Product {
+			return nil, errors.New("plan belongs to a different product")
+		}
+		offer.PlanID = &plan.ID
+		offer.Plan = plan
+	default:
+		return nil, errors.New("invalid retention offer type")
+	}
+
+	if err := s.retentionRepo.Create(offer); err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// GetOffers lists retention offers with pagination
+func (s *RetentionService) GetOffers(page, limit int) ([]*models.RetentionOffer, int64, error) {
+	offset := (page - 1) * limit
+
+	offers, err := s.retentionRepo.GetAll(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.retentionRepo.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return offers, total, nil
+}
+
+// GetOffer gets a retention offer by ID
+func (s *RetentionService) GetOffer(offerIDStr string) (*models.RetentionOffer, error) {
+	offerID, err := uuid.Parse(offerIDStr)
+	if err != nil {
+		return nil, errors.New("invalid retention offer ID")
+	}
+
+	offer, err := s.retentionRepo.GetByID(offerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("retention offer not found")
+		}
+		return nil, err
+	}
+	return offer, nil
+}
+
+// UpdateOffer changes the name, description, targeted reasons or active flag
+// of a retention offer
+func (s *RetentionService) UpdateOffer(offerIDStr string, req *UpdateRetentionOfferRequest) (*models.RetentionOffer, error) {
+	offer, err := s.GetOffer(offerIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		offer.Name = *req.Name
+	}
+	if req.Description != nil {
+		offer.Description = *req.Description
+	}
+	if req.Reasons != nil {
+		if offer.Reasons, err = offerReasons(req.Reasons); err != nil {
+			return nil, err
+		}
+	}
+	if req.IsActive != nil {
+		offer.IsActive = *req.IsActive
+	}
+
+	if err := s.retentionRepo.Update(offer); err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// DeleteOffer deletes a retention offer; its presentations are kept for reporting
+func (s *RetentionService) DeleteOffer(offerIDStr string) error {
+	offer, err := s.GetOffer(offerIDStr)
+	if err != nil {
+		return err
+	}
+	return s.retentionRepo.Delete(offer.ID)
+}
+
+// offerReasons validates the cancellation reasons an offer targets
+func offerReasons(reasons []string) (models.CancellationReasons, error) {
+	result := models.CancellationReasons{}
+	for _, reason := range reasons {
+		if !models.IsValidCancellationReason(reason) {
+			return nil, errors.New("invalid cancellation reason")
+		}
+		result = append(result, reason)
+	}
+	return result, nil
+}
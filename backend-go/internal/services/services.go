@@ -1,38 +1,199 @@
 package services
 
 import (
+	"time"
+
+	"go-backend/config"
+	"go-backend/internal/blobstore"
+	"go-backend/internal/ingest"
+	"go-backend/internal/invoicepdf"
+	"go-backend/internal/jobs"
+	"go-backend/internal/notification"
+	"go-backend/internal/payment"
 	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/currency"
 	"go-backend/pkg/utils"
 )
 
 // Services holds all service instances
 type Services struct {
-	Auth         *AuthService
-	Subscription *SubscriptionService
-	Plan         *PlanService
-	Invoice      *InvoiceService
+	Auth            *AuthService
+	Subscription    *SubscriptionService
+	Plan            *PlanService
+	Invoice         *InvoiceService
+	PlanRetirement  *PlanRetirementService
+	Currencies      *currency.Registry
+	ExchangeRate    *ExchangeRateService
+	Reporting       *ReportingService
+	BillingEngine   *BillingEngine
+	TestClock       *TestClockService
+	Metering        *MeteringService
+	UsagePipeline   *ingest.Pipeline
+	UsageAlert      *UsageAlertService
+	Dunning         *DunningService
+	Retention       *RetentionService
+	InvoiceSequence *InvoiceSequenceService
+	InvoicePDF      *InvoicePDFService
+	Clock           clock.Clock
 }
 
 // NewServices creates and initializes all services
-func NewServices(repos *repository.Repositories, jwtManager *utils.JWTManager) *Services {
+func NewServices(repos *repository.Repositories, jwtManager *utils.JWTManager, currencies *currency.Registry, billing config.BillingConfig, usage config.UsageConfig, notifier notification.Notifier, gateway payment.Gateway, invoicePDF *invoicepdf.Renderer, blobs blobstore.Store, clk clock.Clock) *Services {
+	subscriptionService := NewSubscriptionService(
+		repos.Subscription,
+		repos.Plan,
+		repos.Organization,
+		repos.Invoice,
+		repos.PaymentMethod,
+		repos.SubscriptionHistory,
+		repos.SubscriptionSchedule,
+		repos.TestClock,
+		repos.Meter,
+		repos.Usage,
+		repos.Retention,
+		notifier,
+		TrialPolicy{
+			WithoutPaymentMethod: billing.TrialWithoutPaymentMethod,
+			ReminderDays:         billing.TrialReminderDays,
+		},
+		SeatPolicy{
+			DecreaseMode: billing.SeatDecreaseMode,
+		},
+		ReactivationPolicy{
+			WindowDays: billing.ReactivationWindowDays,
+		},
+		clk,
+	)
+
+	invoiceService := NewInvoiceService(repos.Invoice, repos.Organization, repos.TestClock, notifier, clk)
+
+	usageAlertService := NewUsageAlertService(
+		repos.UsageAlert,
+		repos.Meter,
+		repos.Subscription,
+		repos.Organization,
+		subscriptionService,
+	)
+
+	dunningService := NewDunningService(
+		repos.Dunning,
+		repos.Invoice,
+		repos.Subscription,
+		repos.PaymentMethod,
+		repos.Organization,
+		subscriptionService,
+		gateway,
+		DunningPolicy{
+			RetryDays:      billing.DunningRetryDays,
+			FinalAction:    billing.DunningFinalAction,
+			ClaimStaleness: billing.ClaimStaleness,
+		},
+		clk,
+	)
+
+	billingEngine := NewBillingEngine(
+		repos.BillingRun,
+		repos.Subscription,
+		subscriptionService,
+		billing,
+		clk,
+	)
+
+	exchangeRateService := NewExchangeRateService(repos.ExchangeRate, billing.ReportingCurrency)
+
+	// Batched usage events are buffered here and written by the pipeline's
+	// writers once it is started
+	usagePipeline := ingest.NewPipeline(repos.Usage, ingest.Config{
+		QueueSize:     usage.QueueSize,
+		BatchSize:     usage.BatchSize,
+		FlushInterval: usage.FlushInterval,
+		Writers:       usage.Writers,
+	}, clk)
+
 	return &Services{
 		Auth: NewAuthService(
 			repos.User,
 			repos.Organization,
 			jwtManager,
 		),
-		Subscription: NewSubscriptionService(
+		Subscription: subscriptionService,
+		Plan: NewPlanService(
+			repos.Plan,
 			repos.Subscription,
+			repos.Meter,
+			currencies,
+		),
+		Invoice: invoiceService,
+		PlanRetirement: NewPlanRetirementService(
 			repos.Plan,
-			repos.Organization,
+			repos.Subscription,
+			repos.PlanMigration,
+			repos.JobLock,
+			subscriptionService,
+			clk,
+		),
+		Currencies:   currencies,
+		ExchangeRate: exchangeRateService,
+		Reporting: NewReportingService(
 			repos.Invoice,
+			repos.Retention,
+			exchangeRateService,
 		),
-		Plan: NewPlanService(
+		Retention: NewRetentionService(
+			repos.Retention,
 			repos.Plan,
 		),
-		Invoice: NewInvoiceService(
+		InvoiceSequence: NewInvoiceSequenceService(
+			repos.InvoiceSequence,
+			repos.Organization,
+		),
+		InvoicePDF: NewInvoicePDFService(
 			repos.Invoice,
+			repos.BillingAddress,
+			invoicePDF,
+			blobs,
+		),
+		BillingEngine: billingEngine,
+		TestClock: NewTestClockService(
+			repos.TestClock,
 			repos.Organization,
+			repos.Subscription,
+			subscriptionService,
+			invoiceService,
+			usageAlertService,
+			dunningService,
+			billingEngine,
+			clk,
 		),
+		Metering: NewMeteringService(
+			repos.Meter,
+			repos.Usage,
+			repos.Plan,
+			repos.Organization,
+			repos.TestClock,
+			usagePipeline,
+			usage,
+			clk,
+		),
+		UsagePipeline: usagePipeline,
+		UsageAlert:    usageAlertService,
+		Dunning:       dunningService,
+		Clock:         clk,
+	}
+}
+
+// Jobs returns the background billing jobs, each run on the given interval
+func (s *Services) Jobs(interval time.Duration) []jobs.Job {
+	return []jobs.Job{
+		{Name: "trials", Interval: interval, Run: s.Subscription.ProcessTrials, Exclusive: true},
+		{Name: "subscription-schedules", Interval: interval, Run: s.Subscription.ProcessSchedules, Exclusive: true},
+		{Name: "scheduled-resumes", Interval: interval, Run: s.Subscription.ProcessScheduledResumes, Exclusive: true},
+		{Name: "seat-sync", Interval: interval, Run: s.Subscription.SyncSeats, Exclusive: true},
+		{Name: "billing-cycle", Interval: interval, Run: s.BillingEngine.ProcessDue},
+		{Name: "overdue-invoices", Interval: interval, Run: s.Invoice.ProcessOverdueInvoices},
+		{Name: "dunning", Interval: interval, Run: s.Dunning.ProcessDunning},
+		{Name: "usage-alerts", Interval: interval, Run: s.UsageAlert.ProcessAlerts},
+		{Name: "plan-migrations", Interval: interval, Run: s.PlanRetirement.ResumeUnfinishedMigrations},
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BillingAnchorRequest describes the calendar alignment of billing periods
+type BillingAnchorRequest struct {
+	Day      int    `json:"day" binding:"omitempty,min=1,max=31"` // 0 removes the alignment
+	Time     string `json:"time"`                                 // HH:MM, midnight when empty
+	Timezone string `json:"timezone"`                             // IANA time zone, UTC when empty
+}
+
+// anchor converts the request to a billing anchor
+func (r *BillingAnchorRequest) anchor() models.BillingAnchor {
+	return models.BillingAnchor{Day: r.Day, Time: r.Time, Timezone: r.Timezone}
+}
+
+// ChangeBillingAnchorRequest represents a request to re-anchor a subscription
+type ChangeBillingAnchorRequest struct {
+	BillingAnchorRequest
+	Preview bool `json:"preview"`
+}
+
+// OrganizationBillingAnchorRequest represents a request to change an
+// organization's default billing anchor
+type OrganizationBillingAnchorRequest struct {
+	BillingAnchorRequest
+	ApplyToExisting bool `json:"apply_to_existing"` // re-anchor the organization's active and trialing subscriptions
+}
+
+// BillingAnchorChangeResult describes an anchor change, applied or previewed
+type BillingAnchorChangeResult struct {
+	Preview      bool                 `json:"preview"`
+	FromAnchor   models.BillingAnchor `json:"from_anchor"`
+	ToAnchor     models.BillingAnchor `json:"to_anchor"`
+	EffectiveAt  time.Time            `json:"effective_at"`
+	Lines        []PlanChangeLine     `json:"lines"`
+	AmountDue    money.Money          `json:"amount_due"` // negative when the change leaves a credit
+	PeriodStart  time.Time            `json:"period_start"`
+	PeriodEnd    time.Time            `json:"period_end"`
+	InvoiceID    *uuid.UUID           `json:"invoice_id,omitempty"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+}
+
+// BillingAnchorFailure is a subscription that could not be re-anchored
+type BillingAnchorFailure struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Error          string    `json:"error"`
+}
+
+// OrganizationBillingAnchorResult describes an organization's new billing
+// anchor and the subscriptions that were re-anchored with it
+type OrganizationBillingAnchorResult struct {
+	Organization  *models.Organization         `json:"organization"`
+	Subscriptions []*BillingAnchorChangeResult `json:"subscriptions"`
+	Failed        []BillingAnchorFailure       `json:"failed"`
+}
+
+// validateBillingAnchor checks that an anchor's day, time and time zone can be used
+func validateBillingAnchor(anchor models.BillingAnchor) error {
+	if anchor.Day < 0 || anchor.Day > 31 {
+		return errors.New("invalid billing anchor day")
+	}
+	if _, err := anchor.Location(); err != nil {
+		return errors.New("invalid billing timezone")
+	}
+	if _, _, err := anchor.Clock(); err != nil {
+		return errors.New("invalid billing time")
+	}
+	return nil
+}
+
+// ChangeBillingAnchor moves a subscription to a new billing anchor. Active
+// subscriptions start a new period now that ends on the new anchor: the
+// unused part of the current period is credited and the new first period is
+// charged prorated, both after the subscription's discount. The usage of the period cut short, the invoice and the
+// new period are saved in one transaction, and a change that leaves a credit
+// adds it to the credit balance. Trials only store the anchor, which aligns
+// the first paid period when they convert. With Preview set nothing is saved.
+func (s *SubscriptionService) ChangeBillingAnchor(subscriptionIDStr string, req *ChangeBillingAnchorRequest) (*BillingAnchorChangeResult, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeBillingAnchor(subscription, req.anchor(), req.Preview)
+}
+
+// UpdateOrganizationBillingAnchor sets the billing anchor new subscriptions of
+// an organization start with and, when requested, re-anchors its active and
+// trialing subscriptions. Subscriptions already on the anchor are left alone;
+// one that cannot be re-anchored is reported without stopping the others.
+func (s *SubscriptionService) UpdateOrganizationBillingAnchor(organizationIDStr string, req *OrganizationBillingAnchorRequest) (*OrganizationBillingAnchorResult, error) {
+	orgID, err := uuid.Parse(organizationIDStr)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+
+	anchor := req.anchor()
+	if err := validateBillingAnchor(anchor); err != nil {
+		return nil, err
+	}
+
+	org.BillingAnchor = anchor
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, err
+	}
+
+	result := &OrganizationBillingAnchorResult{
+		Organization:  org,
+		Subscriptions: []*BillingAnchorChangeResult{},
+		Failed:        []BillingAnchorFailure{},
+	}
+	if !req.ApplyToExisting {
+		return result, nil
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetActiveByOrganizationID(orgID, s.now(org))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.BillingAnchor == anchor {
+			continue
+		}
+		change, err := s.changeBillingAnchor(subscription, anchor, false)
+		if err != nil {
+			log.Printf("Failed to re-anchor subscription %s: %v", subscription.ID, err)
+			result.Failed = append(result.Failed, BillingAnchorFailure{SubscriptionID: subscription.ID, Error: err.Error()})
+			continue
+		}
+		result.Subscriptions = append(result.Subscriptions, change)
+	}
+
+	return result, nil
+}
+
+// changeBillingAnchor applies or previews an anchor change
+func (s *SubscriptionService) changeBillingAnchor(subscription *models.Subscription, anchor models.BillingAnchor, preview bool) (*BillingAnchorChangeResult, error) {
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		return nil, errors.New("only active subscriptions can change billing anchor")
+	}
+	if err := validateBillingAnchor(anchor); err != nil {
+		return nil, err
+	}
+	if subscription.BillingAnchor == anchor {
+		return nil, errors.New("subscription already uses this billing anchor")
+	}
+
+	plan := subscription.Plan
+	now := s.now(&subscription.Organization)
+	result := &BillingAnchorChangeResult{
+		Preview:     preview,
+		FromAnchor:  subscription.BillingAnchor,
+		ToAnchor:    anchor,
+		EffectiveAt: now,
+		Lines:       []PlanChangeLine{},
+		AmountDue:   money.Zero(plan.Currency),
+		PeriodStart: subscription.CurrentPeriodStart,
+		PeriodEnd:   subscription.CurrentPeriodEnd,
+	}
+
+	// Trials have not been paid for; the anchor applies from conversion
+	if subscription.Status == models.SubscriptionStatusTrialing {
+		if preview {
+			return result, nil
+		}
+		subscription.BillingAnchor = anchor
+		if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+		result.Subscription = subscription
+		return result, nil
+	}
+
+	reanchored := *subscription
+	reanchored.BillingAnchor = anchor
+	periodEnd, err := nextPeriodEnd(&reanchored, now, plan.Interval)
+	if err != nil {
+		return nil, err
+	}
+	result.PeriodStart = now
+	result.PeriodEnd = periodEnd
+
+	quantity := subscriptionQuantity(subscription)
+	unused, err := remainingPeriodAmount(subscription, &plan, quantity, now)
+	if err != nil {
+		return nil, err
+	}
+	reanchored.CurrentPeriodStart = now
+	reanchored.CurrentPeriodEnd = periodEnd
+	charge, err := periodPrice(&reanchored, &plan, now, periodEnd).Mul(int64(quantity))
+	if err != nil {
+		return nil, err
+	}
+	if charge, err = discounted(&reanchored, charge); err != nil {
+		return nil, err
+	}
+	result.Lines = []PlanChangeLine{
+		{
+			Description: "Unused time on " + plan.Name,
+			Quantity:    quantity,
+			Amount:      unused.Neg(),
+			PeriodStart: now,
+			PeriodEnd:   subscription.CurrentPeriodEnd,
+			PlanID:      &plan.ID,
+			Proration:   true,
+		},
+		{
+			Description: plan.Name + " - " + plan.Interval + " subscription",
+			Quantity:    quantity,
+			Amount:      charge,
+			PeriodStart: now,
+			PeriodEnd:   periodEnd,
+			PlanID:      &plan.ID,
+		},
+	}
+	if result.AmountDue, err = result.Lines[1].Amount.Add(result.Lines[0].Amount); err != nil {
+		return nil, err
+	}
+
+	if preview {
+		return result, nil
+	}
+
+	readAt := subscription.UpdatedAt
+	var change repository.SubscriptionChange
+	if change.Usage, err = s.usageClosing(subscription, now); err != nil {
+		return nil, err
+	}
+	if change.Invoice, err = settleChange(subscription, result.Lines, "Billing anchor change for "+plan.Name, now); err != nil {
+		return nil, err
+	}
+
+	subscription.BillingAnchor = anchor
+	subscription.CurrentPeriodStart = now
+	subscription.CurrentPeriodEnd = periodEnd
+	subscription.EndDate = &periodEnd
+	if err := s.applyChange(subscription, readAt, change); err != nil {
+		return nil, err
+	}
+	if change.Invoice != nil {
+		result.InvoiceID = &change.Invoice.ID
+	}
+	result.Subscription = subscription
+	return result, nil
+}
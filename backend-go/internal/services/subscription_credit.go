@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+)
+
+var (
+	// errSubscriptionChanged is returned when a subscription was changed since it was loaded
+	errSubscriptionChanged = errors.New("subscription changed concurrently")
+	// errCreditCurrency is returned when a change would leave the credit balance in another currency than the plan
+	errCreditCurrency = errors.New("cannot change currency while the subscription has a credit balance")
+)
+
+// settleChange turns the lines of a mid-period change into what it bills. A
+// positive net is invoiced, less the credit the subscription holds; a
+// negative one is added to that credit, to be taken off the next invoices.
+// It returns nil when there is nothing to invoice.
+func settleChange(subscription *models.Subscription, lines []PlanChangeLine, description string, now time.Time) (*models.Invoice, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	amounts := make([]money.Money, 0, len(lines))
+	for _, line := range lines {
+		amounts = append(amounts, line.Amount)
+	}
+	net, err := money.Sum(lines[0].Amount.Currency(), amounts...)
+	if err != nil {
+		return nil, err
+	}
+	if net.IsNegative() {
+		return nil, addCredit(subscription, net.Neg())
+	}
+	if net.IsZero() {
+		return nil, nil
+	}
+
+	invoice, err := adjustmentInvoice(subscription, lines, description, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyCredit(subscription, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// addCredit adds credit to the subscription's credit balance
+func addCredit(subscription *models.Subscription, credit money.Money) error {
+	balance := subscription.CreditBalance
+	if balance.IsZero() {
+		balance = money.Zero(credit.Currency())
+	}
+	balance, err := balance.Add(credit)
+	if err != nil {
+		return err
+	}
+	subscription.CreditBalance = balance
+	return nil
+}
+
+// applyCredit takes the subscription's credit balance off invoice, as far as
+// the invoice total goes, with a negative line, and lowers the balance by
+// what it took. Credit in another currency than the invoice stays put.
+func applyCredit(subscription *models.Subscription, invoice *models.Invoice) error {
+	balance := subscription.CreditBalance
+	if !balance.IsPositive() || !balance.SameCurrency(money.Zero(invoice.Currency)) {
+		return nil
+	}
+	if err := invoice.ComputeTotals(); err != nil {
+		return err
+	}
+	if !invoice.Total.IsPositive() {
+		return nil
+	}
+
+	applied := balance
+	if cmp, err := balance.Cmp(invoice.Total); err != nil {
+		return err
+	} else if cmp > 0 {
+		applied = invoice.Total
+	}
+	remaining, err := balance.Sub(applied)
+	if err != nil {
+		return err
+	}
+
+	invoice.Items = append(invoice.Items, models.InvoiceItem{
+		Description: "Credit balance applied",
+		Quantity:    1,
+		UnitPrice:   applied.Neg(),
+		Amount:      applied.Neg(),
+	})
+	subscription.CreditBalance = remaining
+	return nil
+}
+
+// checkCreditCurrency rejects moving a subscription that holds credit onto a
+// plan billed in another currency, where the credit could not be used
+func checkCreditCurrency(subscription *models.Subscription, plan *models.Plan) error {
+	balance := subscription.CreditBalance
+	if balance.IsZero() || balance.SameCurrency(money.Zero(plan.Currency)) {
+		return nil
+	}
+	return errCreditCurrency
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+)
+
+// paymentFailed moves an active subscription to past_due when a payment of
+// one of its invoices fails. It keeps access while the payment is retried.
+func (s *SubscriptionService) paymentFailed(subscription *models.Subscription, reason string) error {
+	if subscription.Status != models.SubscriptionStatusActive {
+		return nil
+	}
+	return s.transition(subscription, models.SubscriptionEventPaymentFailed, reason)
+}
+
+// paymentRecovered returns a subscription held back by failed payments to
+// active once nothing is left to collect. An incomplete subscription is
+// activated by its first payment.
+func (s *SubscriptionService) paymentRecovered(subscription *models.Subscription, reason string) error {
+	switch subscription.Status {
+	case models.SubscriptionStatusPastDue, models.SubscriptionStatusSuspended:
+		return s.transition(subscription, models.SubscriptionEventPaymentRecovered, reason)
+	case models.SubscriptionStatusIncomplete:
+		return s.transition(subscription, models.SubscriptionEventActivate, reason)
+	}
+	return nil
+}
+
+// suspendForNonPayment withdraws access from a past-due subscription whose
+// payment retries have run out. It is restored by payment_recovered.
+func (s *SubscriptionService) suspendForNonPayment(subscription *models.Subscription, reason string) error {
+	if subscription.Status != models.SubscriptionStatusPastDue {
+		return nil
+	}
+	return s.transition(subscription, models.SubscriptionEventSuspend, reason)
+}
+
+// cancelForNonPayment ends a subscription whose payment retries have run out
+// at once, billing the usage of its current period as an immediate
+// cancellation does
+func (s *SubscriptionService) cancelForNonPayment(subscription *models.Subscription, now time.Time, reason string) (err error) {
+	if models.IsTerminalSubscriptionStatus(subscription.Status) {
+		return nil
+	}
+	defer restoreOnError(subscription, *subscription, &err)
+
+	usage, err := s.usageClosing(subscription, now)
+	if err != nil {
+		return err
+	}
+
+	subscription.CanceledAt = &now
+	subscription.CancellationReason = models.CancellationReasonNonPayment
+	subscription.CancellationNote = ""
+	subscription.EndDate = &now
+	if err := s.transitionWithChange(subscription, models.SubscriptionEventCancel, reason, repository.SubscriptionChange{Usage: usage}); err != nil {
+		return err
+	}
+	if err := s.cancelActiveSchedule(subscription); err != nil {
+		log.Printf("Warning: failed to cancel schedule of subscription %s: %v", subscription.ID, err)
+	}
+	return nil
+}
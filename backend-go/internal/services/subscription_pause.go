@@ -0,0 +1,254 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// What happens to the open invoices of the current period while a subscription is paused
+const (
+	// PauseInvoicesVoid voids them
+	PauseInvoicesVoid = "void"
+	// PauseInvoicesKeepAsDraft leaves them as they are; they are not collected while paused
+	PauseInvoicesKeepAsDraft = "keep_as_draft"
+	// PauseInvoicesMarkUncollectible marks them uncollectible
+	PauseInvoicesMarkUncollectible = "mark_uncollectible"
+)
+
+// How the billing period continues when a subscription resumes
+const (
+	// ResumeShiftPeriod extends the current period by the time spent paused
+	ResumeShiftPeriod = "shift"
+	// ResumeResetPeriod starts and invoices a new period at the resume date
+	ResumeResetPeriod = "reset"
+)
+
+// PauseSubscriptionRequest represents subscription pause data
+type PauseSubscriptionRequest struct {
+	ResumeAt        *time.Time `json:"resume_at"` // optional automatic resume date
+	InvoiceBehavior string     `json:"invoice_behavior" binding:"omitempty,oneof=void keep_as_draft mark_uncollectible"`
+	ResumePolicy    string     `json:"resume_policy" binding:"omitempty,oneof=shift reset"`
+	Reason          string     `json:"reason"`
+}
+
+// ResumeSubscriptionRequest represents subscription resume data
+type ResumeSubscriptionRequest struct {
+	Policy string `json:"policy" binding:"omitempty,oneof=shift reset"` // defaults to the policy chosen at pause time
+}
+
+// PauseSubscription pauses an active subscription. Entitlements are suspended
+// until it resumes, either explicitly or automatically at ResumeAt.
+func (s *SubscriptionService) PauseSubscription(subscriptionIDStr string, req *PauseSubscriptionRequest) (_ *models.Subscription, err error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.Status != models.SubscriptionStatusActive {
+		return nil, errors.New("only active subscriptions can be paused")
+	}
+
+	now := s.now(&subscription.Organization)
+	if req.ResumeAt != nil && !req.ResumeAt.After(now) {
+		return nil, errors.New("resume date must be in the future")
+	}
+
+	behavior := req.InvoiceBehavior
+	if behavior == "" {
+		behavior = PauseInvoicesKeepAsDraft
+	}
+	policy := req.ResumePolicy
+	if policy == "" {
+		policy = ResumeShiftPeriod
+	}
+
+	invoices, err := s.pauseInvoiceUpdates(subscription, behavior, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer restoreOnError(subscription, *subscription, &err)
+	subscription.PausedAt = &now
+	subscription.ResumeAt = req.ResumeAt
+	subscription.PauseBehavior = behavior
+	subscription.ResumePolicy = policy
+
+	if err := s.transitionUpdatingInvoices(subscription, models.SubscriptionEventPause, req.Reason, invoices); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// ResumeSubscription resumes a paused subscription
+func (s *SubscriptionService) ResumeSubscription(subscriptionIDStr string, req *ResumeSubscriptionRequest) (*models.Subscription, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.Status != models.SubscriptionStatusPaused {
+		return nil, errors.New("subscription is not paused")
+	}
+
+	policy := req.Policy
+	if policy == "" {
+		policy = subscription.ResumePolicy
+	}
+
+	if err := s.resume(subscription, policy, models.SubscriptionEventResume); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// ProcessScheduledResumes resumes paused subscriptions whose resume date has
+// passed. It is run periodically by the job scheduler.
+func (s *SubscriptionService) ProcessScheduledResumes() error {
+	subscriptions, err := s.subscriptionRepo.GetPausedResumingBefore(s.clock.Now(), s.testClockID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	resumed := 0
+	for _, subscription := range subscriptions {
+		if err := s.resume(subscription, subscription.ResumePolicy, models.SubscriptionEventAutoResume); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		resumed++
+	}
+	if resumed > 0 {
+		log.Printf("Paused subscriptions resumed: %d", resumed)
+	}
+
+	return errors.Join(errs...)
+}
+
+// resume reactivates a paused subscription, continuing its billing period according to policy
+func (s *SubscriptionService) resume(subscription *models.Subscription, policy, event string) (err error) {
+	defer restoreOnError(subscription, *subscription, &err)
+	now := s.now(&subscription.Organization)
+
+	var change repository.SubscriptionChange
+	switch policy {
+	case ResumeResetPeriod:
+		usage, err := s.usageClosing(subscription, now)
+		if err != nil {
+			return err
+		}
+		change.Usage = usage
+
+		// Apply a plan change that was scheduled for the next period
+		if subscription.ScheduledPlanID != nil {
+			plan, err := s.planRepo.GetByID(*subscription.ScheduledPlanID)
+			if err != nil {
+				return err
+			}
+			subscription.PlanID = plan.ID
+			subscription.Plan = *plan
+			subscription.ScheduledPlanID = nil
+		}
+		applyScheduledQuantity(subscription)
+
+		periodEnd, err := nextPeriodEnd(subscription, now, subscription.Plan.Interval)
+		if err != nil {
+			return err
+		}
+		subscription.CurrentPeriodStart = now
+		subscription.CurrentPeriodEnd = periodEnd
+
+	case ResumeShiftPeriod, "":
+		if subscription.PausedAt != nil {
+			subscription.CurrentPeriodEnd = subscription.CurrentPeriodEnd.Add(now.Sub(*subscription.PausedAt))
+		}
+
+	default:
+		return errors.New("invalid resume policy")
+	}
+
+	periodEnd := subscription.CurrentPeriodEnd
+	subscription.EndDate = &periodEnd
+	subscription.PausedAt = nil
+	subscription.ResumeAt = nil
+	subscription.PauseBehavior = ""
+	subscription.ResumePolicy = ""
+
+	// A reset period is invoiced together with the resume
+	if policy == ResumeResetPeriod {
+		plan := subscription.Plan
+		invoice, err := s.subscriptionInvoice(subscription, &plan)
+		if err != nil {
+			return err
+		}
+		change.Invoice = invoice
+	}
+	return s.transitionWithChange(subscription, event, "", change)
+}
+
+// pauseInvoiceUpdates moves the open invoices of the current period on as
+// the pause invoice behavior asks, through the invoice state machine. The
+// changes are saved with the pause.
+func (s *SubscriptionService) pauseInvoiceUpdates(subscription *models.Subscription, behavior string, now time.Time) ([]repository.InvoiceStatusUpdate, error) {
+	var events []string
+	switch behavior {
+	case PauseInvoicesVoid:
+		events = []string{models.InvoiceEventVoid}
+	case PauseInvoicesKeepAsDraft:
+		return nil, nil
+	case PauseInvoicesMarkUncollectible:
+		events = []string{models.InvoiceEventMarkUncollectible}
+	default:
+		return nil, errors.New("invalid pause invoice behavior")
+	}
+
+	invoices, err := s.invoiceRepo.GetOpenBySubscriptionID(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []repository.InvoiceStatusUpdate
+	for _, invoice := range invoices {
+		if invoice.IssueDate.Before(subscription.CurrentPeriodStart) {
+			continue
+		}
+		// Only finalized invoices can be written off
+		apply := events
+		if invoice.Status == models.InvoiceStatusDraft && behavior == PauseInvoicesMarkUncollectible {
+			apply = []string{models.InvoiceEventFinalize, models.InvoiceEventMarkUncollectible}
+		}
+		update := repository.InvoiceStatusUpdate{Invoice: invoice, From: invoice.Status}
+		for _, event := range apply {
+			if err := applyInvoiceEvent(invoice, event, now); err != nil {
+				return nil, err
+			}
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// getSubscription parses a subscription ID and loads the subscription
+func (s *SubscriptionService) getSubscription(subscriptionIDStr string) (*models.Subscription, error) {
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		return nil, errors.New("invalid subscription ID")
+	}
+
+	subscription, err := s.subscriptionRepo.GetByID(subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("subscription not found")
+		}
+		return nil, err
+	}
+	return subscription, nil
+}
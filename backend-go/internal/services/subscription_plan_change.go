@@ -0,0 +1,337 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Plan change modes
+const (
+	// PlanChangeImmediate switches plans now and prorates the current period
+	PlanChangeImmediate = "immediate"
+	// PlanChangeAtPeriodEnd switches plans when the current period ends
+	PlanChangeAtPeriodEnd = "period_end"
+	// PlanChangeNoProration switches plans now without crediting unused time;
+	// a new period started by an interval change is still charged
+	PlanChangeNoProration = "no_proration"
+)
+
+// ChangePlanRequest represents a request to move a subscription to another plan
+type ChangePlanRequest struct {
+	PlanID  string `json:"plan_id" binding:"required"`
+	Mode    string `json:"mode" binding:"omitempty,oneof=immediate period_end no_proration"`
+	Preview bool   `json:"preview"`
+}
+
+// PlanChangeLine is a single proration line of a plan change. Credits for
+// unused time are negative.
+type PlanChangeLine struct {
+	Description    string      `json:"description"`
+	Quantity       int         `json:"quantity"`
+	Amount         money.Money `json:"amount"` // total for all units
+	PeriodStart    time.Time   `json:"period_start"`
+	PeriodEnd      time.Time   `json:"period_end"`
+	PlanID         *uuid.UUID  `json:"plan_id,omitempty"`          // plan whose price the line is for
+	MeteredPriceID *uuid.UUID  `json:"metered_price_id,omitempty"` // metered price of a usage line
+	Proration      bool        `json:"proration"`                  // charges or credits part of the current period
+}
+
+// PlanChangeResult describes a plan change, applied or previewed
+type PlanChangeResult struct {
+	Preview      bool                 `json:"preview"`
+	Mode         string               `json:"mode"`
+	EffectiveAt  time.Time            `json:"effective_at"`
+	FromPlan     *models.Plan         `json:"from_plan"`
+	ToPlan       *models.Plan         `json:"to_plan"`
+	Lines        []PlanChangeLine     `json:"lines"`
+	Credit       money.Money          `json:"credit"`
+	Charge       money.Money          `json:"charge"`
+	AmountDue    money.Money          `json:"amount_due"` // negative when the change leaves a credit
+	PeriodStart  time.Time            `json:"period_start"`
+	PeriodEnd    time.Time            `json:"period_end"`
+	InvoiceID    *uuid.UUID           `json:"invoice_id,omitempty"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+}
+
+// ChangePlan upgrades or downgrades a subscription. Immediate changes credit
+// the unused part of the current plan and charge the new plan for the rest of
+// the period; when the billing interval changes a new period starts now and
+// the new plan is charged in full. With Preview set the amounts are computed
+// but nothing is saved.
+func (s *SubscriptionService) ChangePlan(subscriptionIDStr string, req *ChangePlanRequest) (*PlanChangeResult, error) {
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		return nil, errors.New("invalid subscription ID")
+	}
+
+	planID, err := uuid.Parse(req.PlanID)
+	if err != nil {
+		return nil, errors.New("invalid plan ID")
+	}
+
+	subscription, err := s.subscriptionRepo.GetByID(subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("subscription not found")
+		}
+		return nil, err
+	}
+
+	target, err := s.planRepo.GetByID(planID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("plan not found")
+		}
+		return nil, err
+	}
+
+	if !target.IsActive {
+		return nil, errors.New("plan is not active")
+	}
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		return nil, errors.New("only active subscriptions can change plan")
+	}
+	if subscription.PlanID == target.ID {
+		return nil, errors.New("subscription is already on this plan")
+	}
+	if target.Product != subscription.Plan.Product {
+		return nil, errors.New("plan belongs to a different product")
+	}
+	if err := checkCreditCurrency(subscription, target); err != nil {
+		return nil, err
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = PlanChangeImmediate
+	}
+
+	current := subscription.Plan
+	now := s.now(&subscription.Organization)
+	result := &PlanChangeResult{
+		Preview:     req.Preview,
+		Mode:        mode,
+		EffectiveAt: now,
+		FromPlan:    &current,
+		ToPlan:      target,
+		Lines:       []PlanChangeLine{},
+		Credit:      money.Zero(current.Currency),
+		Charge:      money.Zero(target.Currency),
+		AmountDue:   money.Zero(target.Currency),
+		PeriodStart: subscription.CurrentPeriodStart,
+		PeriodEnd:   subscription.CurrentPeriodEnd,
+	}
+
+	switch mode {
+	case PlanChangeAtPeriodEnd:
+		if !subscription.WillRenew() {
+			return nil, errors.New("subscription will not renew")
+		}
+		result.EffectiveAt = subscription.CurrentPeriodEnd
+		result.PeriodStart = subscription.CurrentPeriodEnd
+		if result.PeriodEnd, err = nextPeriodEnd(subscription, result.PeriodStart, target.Interval); err != nil {
+			return nil, err
+		}
+
+		if req.Preview {
+			return result, nil
+		}
+
+		subscription.ScheduledPlanID = &target.ID
+		if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+		result.Subscription = subscription
+		return result, nil
+
+	case PlanChangeImmediate, PlanChangeNoProration:
+		if err := immediateTerms(subscription, target, mode == PlanChangeImmediate, now, result); err != nil {
+			return nil, err
+		}
+
+		if req.Preview {
+			return result, nil
+		}
+
+		description := "Plan change from " + current.Name + " to " + target.Name
+		if err := s.switchPlan(subscription, target, result, description, now); err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	default:
+		return nil, errors.New("invalid plan change mode")
+	}
+}
+
+// immediateTerms fills in result for moving subscription onto target now. A
+// new period starts when the billing interval changes, and a subscription
+// that paid for its current period is charged the new plan: for the rest of
+// the current period, or in full for a new period. With prorate set it is
+// also credited for the unused part of the current plan; without it the
+// plan only changes for free when the period carries on.
+func immediateTerms(subscription *models.Subscription, target *models.Plan, prorate bool, now time.Time, result *PlanChangeResult) error {
+	current := subscription.Plan
+
+	// A different billing interval cannot share the current period
+	reset := target.Interval != current.Interval
+	if reset {
+		periodEnd, err := nextPeriodEnd(subscription, now, target.Interval)
+		if err != nil {
+			return err
+		}
+		result.PeriodStart = now
+		result.PeriodEnd = periodEnd
+	}
+
+	// Trials have not been paid for, so there is nothing to credit or charge
+	if subscription.Status != models.SubscriptionStatusActive || !prorate && !reset {
+		return nil
+	}
+
+	if !prorate {
+		charge, err := chargeLine(subscription, target, result.PeriodEnd, now)
+		if err != nil {
+			return err
+		}
+		result.Lines = []PlanChangeLine{charge}
+		result.Credit = money.Zero(target.Currency)
+		result.Charge = charge.Amount
+		result.AmountDue = charge.Amount
+		return nil
+	}
+
+	if current.Currency != target.Currency {
+		return errors.New("cannot prorate between plans with different currencies")
+	}
+	lines, err := prorationLines(subscription, &current, target, result.PeriodEnd, now)
+	if err != nil {
+		return err
+	}
+	result.Lines = lines
+	result.Credit = lines[0].Amount.Neg()
+	result.Charge = lines[1].Amount
+	result.AmountDue, err = result.Charge.Sub(result.Credit)
+	return err
+}
+
+// switchPlan moves subscription onto target now, on the terms in result. The
+// usage of a period the switch cuts short, the invoice for what it charges
+// and the subscription are written in one transaction, and only if the
+// subscription was not changed since it was loaded. A switch that leaves a
+// credit adds it to the subscription's credit balance.
+func (s *SubscriptionService) switchPlan(subscription *models.Subscription, target *models.Plan, result *PlanChangeResult, description string, now time.Time) error {
+	readAt := subscription.UpdatedAt
+	var change repository.SubscriptionChange
+	var err error
+
+	// Usage of a period cut short is billed on the plan it was recorded under
+	if subscription.Status == models.SubscriptionStatusActive && !result.PeriodStart.Equal(subscription.CurrentPeriodStart) {
+		if change.Usage, err = s.usageClosing(subscription, now); err != nil {
+			return err
+		}
+	}
+	if change.Invoice, err = settleChange(subscription, result.Lines, description, now); err != nil {
+		return err
+	}
+
+	subscription.PlanID = target.ID
+	subscription.Plan = *target
+	subscription.ScheduledPlanID = nil
+	subscription.CurrentPeriodStart = result.PeriodStart
+	subscription.CurrentPeriodEnd = result.PeriodEnd
+	subscription.EndDate = &result.PeriodEnd
+
+	if err := s.applyChange(subscription, readAt, change); err != nil {
+		return err
+	}
+	if change.Invoice != nil {
+		result.InvoiceID = &change.Invoice.ID
+	}
+	result.Subscription = subscription
+	return nil
+}
+
+// applyChange saves a subscription changed mid-period with what the change
+// writes, provided it was last updated at readAt, and fails with
+// errSubscriptionChanged otherwise
+func (s *SubscriptionService) applyChange(subscription *models.Subscription, readAt time.Time, change repository.SubscriptionChange) error {
+	updated, err := s.subscriptionRepo.ApplyChange(subscription, readAt, change)
+	if err == nil && !updated {
+		err = errSubscriptionChanged
+	}
+	return err
+}
+
+// prorationLines returns the credit for the unused part of the current plan
+// followed by the charge for the new plan up to chargeEnd, both after the
+// subscription's discount
+func prorationLines(subscription *models.Subscription, current, target *models.Plan, chargeEnd, now time.Time) ([]PlanChangeLine, error) {
+	credit, err := creditLine(subscription, current, now)
+	if err != nil {
+		return nil, err
+	}
+	charge, err := chargeLine(subscription, target, chargeEnd, now)
+	if err != nil {
+		return nil, err
+	}
+	return []PlanChangeLine{credit, charge}, nil
+}
+
+// creditLine credits the unused part of the current period on plan. The plan
+// is priced for the period as it was invoiced, so a shorter first period is
+// credited only its share.
+func creditLine(subscription *models.Subscription, plan *models.Plan, now time.Time) (PlanChangeLine, error) {
+	quantity := subscriptionQuantity(subscription)
+	unused, err := remainingPeriodAmount(subscription, plan, quantity, now)
+	if err != nil {
+		return PlanChangeLine{}, err
+	}
+	return PlanChangeLine{
+		Description: "Unused time on " + plan.Name,
+		Quantity:    quantity,
+		Amount:      unused.Neg(),
+		PeriodStart: now,
+		PeriodEnd:   subscription.CurrentPeriodEnd,
+		PlanID:      &plan.ID,
+		Proration:   true,
+	}, nil
+}
+
+// chargeLine charges plan from now up to chargeEnd after the subscription's
+// discount. When chargeEnd is the end of the current period only its
+// remaining share is charged; otherwise a new period starts at now and is
+// charged at the price of that period.
+func chargeLine(subscription *models.Subscription, plan *models.Plan, chargeEnd, now time.Time) (PlanChangeLine, error) {
+	quantity := subscriptionQuantity(subscription)
+	charge := PlanChangeLine{
+		Description: "Remaining time on " + plan.Name,
+		Quantity:    quantity,
+		PeriodStart: now,
+		PeriodEnd:   chargeEnd,
+		PlanID:      &plan.ID,
+		Proration:   true,
+	}
+
+	var err error
+	if chargeEnd.Equal(subscription.CurrentPeriodEnd) {
+		charge.Amount, err = remainingPeriodAmount(subscription, plan, quantity, now)
+	} else {
+		next := *subscription
+		next.CurrentPeriodStart = now
+		next.CurrentPeriodEnd = chargeEnd
+		charge.Description = plan.Name + " - " + plan.Interval + " subscription"
+		charge.Proration = false
+		if charge.Amount, err = periodPrice(&next, plan, now, chargeEnd).Mul(int64(quantity)); err == nil {
+			charge.Amount, err = discounted(&next, charge.Amount)
+		}
+	}
+	return charge, err
+}
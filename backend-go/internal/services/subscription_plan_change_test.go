@@ -0,0 +1,122 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/pkg/money"
+)
+
+func TestProrationLines(t *testing.T) {
+	basic := &models.Plan{Name: "Basic", Interval: "monthly", Price: money.New(3100, "USD")}
+	pro := &models.Plan{Name: "Pro", Interval: "monthly", Price: money.New(6200, "USD")}
+	starter := &models.Plan{Name: "Starter", Interval: "monthly", Price: money.New(1550, "USD")}
+	annual := &models.Plan{Name: "Pro annual", Interval: "yearly", Price: money.New(36500, "USD")}
+
+	// 21 of the 31 days of March are left
+	now := date(2027, time.March, 11)
+	periodEnd := date(2027, time.April, 1)
+	discountEnded := date(2027, time.March, 5)
+
+	tests := []struct {
+		name       string
+		target     *models.Plan
+		quantity   int
+		percentOff int
+		discountTo *time.Time
+		chargeEnd  time.Time
+		credit     money.Money
+		charge     money.Money
+	}{
+		{"upgrade", pro, 1, 0, nil, periodEnd, money.New(-2100, "USD"), money.New(4200, "USD")},
+		{"downgrade", starter, 1, 0, nil, periodEnd, money.New(-2100, "USD"), money.New(1050, "USD")},
+		{"per seat", pro, 3, 0, nil, periodEnd, money.New(-6300, "USD"), money.New(12600, "USD")},
+		{"discounted", pro, 1, 50, nil, periodEnd, money.New(-1050, "USD"), money.New(2100, "USD")},
+		{"new period", annual, 1, 0, nil, date(2028, time.March, 11), money.New(-2100, "USD"), money.New(36500, "USD")},
+		{"discounted new period", annual, 1, 50, nil, date(2028, time.March, 11), money.New(-1050, "USD"), money.New(18250, "USD")},
+		// The discount covers the current period but not one starting now
+		{"discount ending before the new period", annual, 1, 50, &discountEnded, date(2028, time.March, 11), money.New(-1050, "USD"), money.New(36500, "USD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := &models.Subscription{
+				Quantity:           tt.quantity,
+				PercentOff:         tt.percentOff,
+				DiscountEndsAt:     tt.discountTo,
+				CurrentPeriodStart: date(2027, time.March, 1),
+				CurrentPeriodEnd:   periodEnd,
+			}
+			lines, err := prorationLines(subscription, basic, tt.target, tt.chargeEnd, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 2 {
+				t.Fatalf("got %d lines, want 2", len(lines))
+			}
+			credit, charge := lines[0], lines[1]
+			if !credit.Amount.Equal(tt.credit) {
+				t.Errorf("credit = %v, want %v", credit.Amount, tt.credit)
+			}
+			if !charge.Amount.Equal(tt.charge) {
+				t.Errorf("charge = %v, want %v", charge.Amount, tt.charge)
+			}
+			if !credit.PeriodEnd.Equal(periodEnd) || !charge.PeriodEnd.Equal(tt.chargeEnd) {
+				t.Errorf("periods end %v and %v, want %v and %v", credit.PeriodEnd, charge.PeriodEnd, periodEnd, tt.chargeEnd)
+			}
+			if wantProration := tt.chargeEnd.Equal(periodEnd); charge.Proration != wantProration {
+				t.Errorf("charge proration = %v, want %v", charge.Proration, wantProration)
+			}
+		})
+	}
+}
+
+func TestImmediateTerms(t *testing.T) {
+	basic := models.Plan{Name: "Basic", Interval: "monthly", Price: money.New(3100, "USD")}
+	pro := &models.Plan{Name: "Pro", Interval: "monthly", Price: money.New(6200, "USD")}
+	annual := &models.Plan{Name: "Pro annual", Interval: "yearly", Price: money.New(36500, "USD")}
+
+	now := date(2027, time.March, 11)
+	periodEnd := date(2027, time.April, 1)
+
+	tests := []struct {
+		name      string
+		status    string
+		target    *models.Plan
+		prorate   bool
+		periodEnd time.Time
+		lines     int
+		amountDue money.Money
+	}{
+		{"prorated upgrade", models.SubscriptionStatusActive, pro, true, periodEnd, 2, money.New(2100, "USD")},
+		{"prorated interval change", models.SubscriptionStatusActive, annual, true, date(2028, time.March, 11), 2, money.New(34400, "USD")},
+		{"unprorated upgrade carries on for free", models.SubscriptionStatusActive, pro, false, periodEnd, 0, money.Money{}},
+		// A new period is charged in full; only the credit is skipped
+		{"unprorated interval change", models.SubscriptionStatusActive, annual, false, date(2028, time.March, 11), 1, money.New(36500, "USD")},
+		{"trial interval change", models.SubscriptionStatusTrialing, annual, false, date(2028, time.March, 11), 0, money.Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := &models.Subscription{
+				Status:             tt.status,
+				Plan:               basic,
+				Quantity:           1,
+				CurrentPeriodStart: date(2027, time.March, 1),
+				CurrentPeriodEnd:   periodEnd,
+			}
+			result := &PlanChangeResult{PeriodStart: subscription.CurrentPeriodStart, PeriodEnd: subscription.CurrentPeriodEnd}
+			if err := immediateTerms(subscription, tt.target, tt.prorate, now, result); err != nil {
+				t.Fatal(err)
+			}
+			if !result.PeriodEnd.Equal(tt.periodEnd) {
+				t.Errorf("period ends %v, want %v", result.PeriodEnd, tt.periodEnd)
+			}
+			if len(result.Lines) != tt.lines {
+				t.Fatalf("got %d lines, want %d", len(result.Lines), tt.lines)
+			}
+			if tt.lines > 0 && !result.AmountDue.Equal(tt.amountDue) {
+				t.Errorf("amount due = %v, want %v", result.AmountDue, tt.amountDue)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// How seat decreases are applied
+const (
+	// QuantityDecreaseImmediate lowers the quantity now and credits the unused seats
+	QuantityDecreaseImmediate = "immediate"
+	// QuantityDecreaseAtPeriodEnd lowers the quantity at the next renewal
+	QuantityDecreaseAtPeriodEnd = "period_end"
+)
+
+// SeatPolicy configures how seat quantity changes are billed
+type SeatPolicy struct {
+	DecreaseMode string // default handling of decreases: immediate or period_end
+}
+
+// IsValidQuantityDecreaseMode checks if mode is a supported decrease handling
+func IsValidQuantityDecreaseMode(mode string) bool {
+	return mode == QuantityDecreaseImmediate || mode == QuantityDecreaseAtPeriodEnd
+}
+
+// UpdateQuantityRequest represents a request to change the number of seats of a subscription
+type UpdateQuantityRequest struct {
+	Quantity     int    `json:"quantity" binding:"required,min=1"`
+	DecreaseMode string `json:"decrease_mode" binding:"omitempty,oneof=immediate period_end"`
+	Preview      bool   `json:"preview"`
+}
+
+// QuantityChangeResult describes a quantity change, applied or previewed
+type QuantityChangeResult struct {
+	Preview      bool                 `json:"preview"`
+	FromQuantity int                  `json:"from_quantity"`
+	ToQuantity   int                  `json:"to_quantity"`
+	EffectiveAt  time.Time            `json:"effective_at"`
+	Scheduled    bool                 `json:"scheduled"` // the change waits for the next renewal
+	Lines        []PlanChangeLine     `json:"lines"`
+	AmountDue    money.Money          `json:"amount_due"` // negative when seats are credited
+	InvoiceID    *uuid.UUID           `json:"invoice_id,omitempty"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+}
+
+// UpdateQuantity changes the number of seats billed for a subscription.
+// Increases take effect now and the added seats are charged for the rest of
+// the period. Decreases either take effect now with a credit for the unused
+// seats, or at the next renewal. Trials change quantity without charges.
+func (s *SubscriptionService) UpdateQuantity(subscriptionIDStr string, req *UpdateQuantityRequest) (*QuantityChangeResult, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.changeQuantity(subscription, req.Quantity, req.DecreaseMode, req.Preview)
+}
+
+// SyncSeats sets the quantity of every seat-synced subscription to its
+// organization's active member count. It is run periodically by the job
+// scheduler.
+func (s *SubscriptionService) SyncSeats() error {
+	subscriptions, err := s.subscriptionRepo.GetSeatSynced(s.testClockID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	synced := 0
+	for _, subscription := range subscriptions {
+		changed, err := s.syncSeats(subscription)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		if changed {
+			synced++
+		}
+	}
+	if synced > 0 {
+		log.Printf("Seat quantities synced: %d", synced)
+	}
+
+	return errors.Join(errs...)
+}
+
+// syncSeats updates one subscription to its organization's member count and
+// reports whether the quantity changed
+func (s *SubscriptionService) syncSeats(subscription *models.Subscription) (bool, error) {
+	seats, err := s.activeSeats(subscription.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+
+	target := subscriptionQuantity(subscription)
+	if subscription.ScheduledQuantity != nil {
+		target = *subscription.ScheduledQuantity
+	}
+	if seats == target {
+		return false, nil
+	}
+
+	_, err = s.changeQuantity(subscription, seats, "", false)
+	return err == nil, err
+}
+
+// activeSeats returns the active member count of an organization, at least one
+func (s *SubscriptionService) activeSeats(organizationID uuid.UUID) (int, error) {
+	count, err := s.orgRepo.CountActiveMembers(organizationID)
+	if err != nil {
+		return 0, err
+	}
+	if count < 1 {
+		return 1, nil
+	}
+	return int(count), nil
+}
+
+// changeQuantity applies or previews a quantity change. The change is saved
+// together with its invoice in one transaction, and only if the subscription
+// was not changed since it was loaded; removed seats credited right away are
+// added to the subscription's credit balance.
+func (s *SubscriptionService) changeQuantity(subscription *models.Subscription, quantity int, decreaseMode string, preview bool) (*QuantityChangeResult, error) {
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		return nil, errors.New("only active subscriptions can change quantity")
+	}
+	if quantity < 1 {
+		return nil, errors.New("quantity must be at least 1")
+	}
+
+	if decreaseMode == "" {
+		decreaseMode = s.seats.DecreaseMode
+	}
+	if !IsValidQuantityDecreaseMode(decreaseMode) {
+		return nil, errors.New("invalid quantity decrease mode")
+	}
+
+	readAt := subscription.UpdatedAt
+	current := subscriptionQuantity(subscription)
+	plan := subscription.Plan
+	now := s.now(&subscription.Organization)
+	result := &QuantityChangeResult{
+		Preview:      preview,
+		FromQuantity: current,
+		ToQuantity:   quantity,
+		EffectiveAt:  now,
+		Lines:        []PlanChangeLine{},
+		AmountDue:    money.Zero(plan.Currency),
+	}
+
+	if quantity == current {
+		// Setting the current quantity again drops a pending decrease
+		if subscription.ScheduledQuantity == nil {
+			return nil, errors.New("subscription already has this quantity")
+		}
+		if preview {
+			return result, nil
+		}
+		subscription.ScheduledQuantity = nil
+		if err := s.applyChange(subscription, readAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+		result.Subscription = subscription
+		return result, nil
+	}
+
+	// Trials have not been paid for, so seats change without charges
+	if subscription.Status == models.SubscriptionStatusTrialing {
+		if preview {
+			return result, nil
+		}
+		subscription.Quantity = quantity
+		subscription.ScheduledQuantity = nil
+		if err := s.applyChange(subscription, readAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+		result.Subscription = subscription
+		return result, nil
+	}
+
+	if quantity < current && decreaseMode == QuantityDecreaseAtPeriodEnd {
+		result.EffectiveAt = subscription.CurrentPeriodEnd
+		result.Scheduled = true
+		if preview {
+			return result, nil
+		}
+		subscription.ScheduledQuantity = &quantity
+		if err := s.applyChange(subscription, readAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+		result.Subscription = subscription
+		return result, nil
+	}
+
+	line, err := seatProrationLine(subscription, &plan, quantity-current, now)
+	if err != nil {
+		return nil, err
+	}
+	result.Lines = append(result.Lines, line)
+	result.AmountDue = line.Amount
+
+	if preview {
+		return result, nil
+	}
+
+	var change repository.SubscriptionChange
+	description := fmt.Sprintf("Seat change on %s from %d to %d", plan.Name, current, quantity)
+	if change.Invoice, err = settleChange(subscription, result.Lines, description, now); err != nil {
+		return nil, err
+	}
+
+	subscription.Quantity = quantity
+	subscription.ScheduledQuantity = nil
+	if err := s.applyChange(subscription, readAt, change); err != nil {
+		return nil, err
+	}
+	if change.Invoice != nil {
+		result.InvoiceID = &change.Invoice.ID
+	}
+	result.Subscription = subscription
+	return result, nil
+}
+
+// seatProrationLine charges added seats, or credits removed seats when delta
+// is negative, for what remains of the current period after the
+// subscription's discount
+func seatProrationLine(subscription *models.Subscription, plan *models.Plan, delta int, now time.Time) (PlanChangeLine, error) {
+	seats := delta
+	description := seatsLabel(seats) + " added to " + plan.Name
+	if delta < 0 {
+		seats = -delta
+		description = "Unused time on " + seatsLabel(seats) + " removed from " + plan.Name
+	}
+
+	amount, err := remainingPeriodAmount(subscription, plan, seats, now)
+	if err != nil {
+		return PlanChangeLine{}, err
+	}
+	if delta < 0 {
+		amount = amount.Neg()
+	}
+
+	return PlanChangeLine{
+		Description: description,
+		Quantity:    seats,
+		Amount:      amount,
+		PeriodStart: now,
+		PeriodEnd:   subscription.CurrentPeriodEnd,
+		PlanID:      &plan.ID,
+		Proration:   true,
+	}, nil
+}
+
+// applyScheduledQuantity moves a subscription to the quantity scheduled for its renewal
+func applyScheduledQuantity(subscription *models.Subscription) {
+	if subscription.ScheduledQuantity != nil {
+		subscription.Quantity = *subscription.ScheduledQuantity
+		subscription.ScheduledQuantity = nil
+	}
+}
+
+// seatsLabel formats a seat count for invoice descriptions
+func seatsLabel(quantity int) string {
+	if quantity == 1 {
+		return "1 seat"
+	}
+	return strconv.Itoa(quantity) + " seats"
+}
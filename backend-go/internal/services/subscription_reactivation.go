@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ReactivationPolicy configures the restart of ended subscriptions
+type ReactivationPolicy struct {
+	WindowDays int // days after a subscription ended that it can still be restarted; 0 disables restarts
+}
+
+// ReactivateSubscription undoes a cancellation. A subscription set to cancel
+// at the end of its period simply continues. A canceled or expired
+// subscription that ended within the reactivation window is restarted in
+// place, keeping its history, billing anchor and what is left of its trial.
+func (s *SubscriptionService) ReactivateSubscription(subscriptionIDStr string) (*models.Subscription, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if models.IsTerminalSubscriptionStatus(subscription.Status) {
+		if err := s.restart(subscription); err != nil {
+			return nil, err
+		}
+		return subscription, nil
+	}
+
+	if !subscription.CancelAtPeriodEnd {
+		return nil, errors.New("subscription is not canceled")
+	}
+	subscription.CancelAtPeriodEnd = false
+	subscription.CanceledAt = nil
+	subscription.CancellationReason = ""
+	subscription.CancellationNote = ""
+	if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// restart brings an ended subscription back. It resumes a trial that has
+// not run out, or continues a period that was paid for before an immediate
+// cancellation; otherwise it starts a new period on the subscription's
+// anchor and invoices it.
+func (s *SubscriptionService) restart(subscription *models.Subscription) (err error) {
+	defer restoreOnError(subscription, *subscription, &err)
+	now := s.now(&subscription.Organization)
+
+	endedAt := subscription.CurrentPeriodEnd
+	if subscription.EndDate != nil {
+		endedAt = *subscription.EndDate
+	}
+	if s.reactivation.WindowDays <= 0 || now.After(endedAt.AddDate(0, 0, s.reactivation.WindowDays)) {
+		return errors.New("reactivation window has passed")
+	}
+
+	plan := subscription.Plan
+	if !plan.IsActive {
+		return errors.New("plan is not active")
+	}
+
+	// Organizations subscribe to each product at most once
+	existing, err := s.subscriptionRepo.GetByOrganizationAndProduct(subscription.OrganizationID, plan.Product, repository.OpenStatuses)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil {
+		return errors.New("organization already has a subscription to this product")
+	}
+
+	// A subscription canceled for non-payment comes back once it is paid up
+	invoices, err := s.invoiceRepo.GetOpenBySubscriptionID(subscription.ID)
+	if err != nil {
+		return err
+	}
+	for _, invoice := range invoices {
+		if invoice.DueDate.Before(now) {
+			return errors.New("subscription has unpaid invoices")
+		}
+	}
+
+	if subscription.SyncSeats {
+		quantity, err := s.activeSeats(subscription.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if quantity < 1 {
+			quantity = 1
+		}
+		subscription.Quantity = quantity
+	}
+
+	subscription.CanceledAt = nil
+	subscription.CancelAtPeriodEnd = false
+	subscription.CancellationReason = ""
+	subscription.CancellationNote = ""
+	subscription.PausedAt = nil
+	subscription.ResumeAt = nil
+	subscription.PauseBehavior = ""
+	subscription.ResumePolicy = ""
+
+	// Usage up to the end was billed when the subscription ended, so a
+	// resumed trial or period measures usage from the restart
+	event := models.SubscriptionEventReactivate
+	newPeriod := false
+	switch {
+	case subscription.IsInTrial(now):
+		event = models.SubscriptionEventReactivateTrial
+		subscription.CurrentPeriodStart = now
+		subscription.CurrentPeriodEnd = *subscription.TrialEndDate
+	case now.Before(subscription.CurrentPeriodEnd):
+		subscription.CurrentPeriodStart = now
+	default:
+		periodEnd, err := nextPeriodEnd(subscription, now, plan.Interval)
+		if err != nil {
+			return err
+		}
+		subscription.CurrentPeriodStart = now
+		subscription.CurrentPeriodEnd = periodEnd
+		newPeriod = true
+	}
+	periodEnd := subscription.CurrentPeriodEnd
+	subscription.EndDate = &periodEnd
+
+	// A new period is invoiced together with the reactivation
+	var change repository.SubscriptionChange
+	if newPeriod {
+		if change.Invoice, err = s.subscriptionInvoice(subscription, &plan); err != nil {
+			return err
+		}
+	}
+
+	// Another subscription to the product may have been opened since the
+	// check above; the database has the last word
+	err = s.transitionWithChange(subscription, event, "reactivated", change)
+	if errors.Is(err, repository.ErrOpenSubscriptionExists) {
+		return errors.New("organization already has a subscription to this product")
+	}
+	return err
+}
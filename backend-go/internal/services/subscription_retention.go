@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionOffersRequest asks for the retention offers to show a customer
+// about to cancel a subscription
+type RetentionOffersRequest struct {
+	Reason string `json:"reason"` // the cancellation reason the customer picked
+}
+
+// GetRetentionOffers returns the active retention offers that apply to a
+// subscription canceled for reason, and records them as shown. Subscriptions
+// that are not active or trialing, or are already set to cancel, get none.
+func (s *SubscriptionService) GetRetentionOffers(subscriptionIDStr string, req *RetentionOffersRequest) ([]*models.RetentionOffer, error) {
+	if req.Reason != "" && !models.IsValidCancellationReason(req.Reason) {
+		return nil, errors.New("invalid cancellation reason")
+	}
+
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := []*models.RetentionOffer{}
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		return offers, nil
+	}
+	if subscription.CancelAtPeriodEnd {
+		return offers, nil
+	}
+
+	active, err := s.retentionRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range active {
+		if s.offerApplies(subscription, offer, req.Reason) {
+			offers = append(offers, offer)
+		}
+	}
+
+	// Showing the offers again does not count as another presentation
+	pending, err := s.retentionRepo.GetPendingPresentations(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	shown := make(map[uuid.UUID]bool, len(pending))
+	for _, presentation := range pending {
+		shown[presentation.RetentionOfferID] = true
+	}
+
+	now := s.now(&subscription.Organization)
+	for _, offer := range offers {
+		if shown[offer.ID] {
+			continue
+		}
+		presentation := &models.RetentionOfferPresentation{
+			RetentionOfferID: offer.ID,
+			SubscriptionID:   subscription.ID,
+			OrganizationID:   subscription.OrganizationID,
+			Reason:           req.Reason,
+			Outcome:          models.RetentionOfferPending,
+			PresentedAt:      now,
+		}
+		if err := s.retentionRepo.CreatePresentation(presentation); err != nil {
+			return nil, err
+		}
+	}
+
+	return offers, nil
+}
+
+// AcceptRetentionOffer applies a retention offer shown for a subscription
+// instead of canceling it. A discount takes the offer's percentage off the
+// invoices of the periods starting in the offer's number of months after
+// the current one; a downgrade moves the subscription to the offer's plan at
+// renewal. The other offers shown are declined. The offer is applied and the
+// offers resolved in one transaction, only while the subscription is as it
+// was loaded and the offer still pending, so an offer is accepted once.
+func (s *SubscriptionService) AcceptRetentionOffer(subscriptionIDStr, offerIDStr string) (*models.Subscription, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	offerID, err := uuid.Parse(offerIDStr)
+	if err != nil {
+		return nil, errors.New("invalid retention offer ID")
+	}
+
+	pending, err := s.retentionRepo.GetPendingPresentations(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	var presentation *models.RetentionOfferPresentation
+	for _, p := range pending {
+		if p.RetentionOfferID == offerID {
+			presentation = p
+			break
+		}
+	}
+	if presentation == nil {
+		return nil, errors.New("retention offer was not presented")
+	}
+
+	offer, err := s.retentionRepo.GetByID(offerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("retention offer not found")
+		}
+		return nil, err
+	}
+	if !offer.IsActive || subscription.CancelAtPeriodEnd || !s.offerApplies(subscription, offer, presentation.Reason) {
+		return nil, errors.New("retention offer is not available")
+	}
+
+	now := s.now(&subscription.Organization)
+	switch offer.Type {
+	case models.RetentionOfferTypeDiscount:
+		endsAt := subscription.CurrentPeriodEnd.AddDate(0, offer.DurationMonths, 0)
+		subscription.Coupon = offer.Name
+		subscription.PercentOff = offer.PercentOff
+		subscription.DiscountEndsAt = &endsAt
+	case models.RetentionOfferTypeDowngrade:
+		if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+			return nil, errors.New("only active subscriptions can change plan")
+		}
+		if err := checkCreditCurrency(subscription, offer.Plan); err != nil {
+			return nil, err
+		}
+		subscription.ScheduledPlanID = &offer.Plan.ID
+	default:
+		return nil, errors.New("retention offer is not available")
+	}
+
+	change := repository.SubscriptionChange{
+		Retention: &repository.RetentionAcceptance{OfferID: offer.ID, At: now},
+	}
+	if err := s.applyChange(subscription, subscription.UpdatedAt, change); err != nil {
+		if errors.Is(err, repository.ErrRetentionOfferAnswered) {
+			return nil, errors.New("retention offer was already answered")
+		}
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// offerApplies checks if a retention offer can be made to a subscription
+// canceled for reason. A discount is not offered on top of a discount that
+// still applies after the current period, and a downgrade needs an active
+// plan of the same product and a subscription that renews.
+func (s *SubscriptionService) offerApplies(subscription *models.Subscription, offer *models.RetentionOffer, reason string) bool {
+	if !offer.AppliesTo(subscription.Plan.Product, reason) {
+		return false
+	}
+
+	switch offer.Type {
+	case models.RetentionOfferTypeDiscount:
+		discounted := subscription.PercentOff > 0 &&
+			(subscription.DiscountEndsAt == nil || subscription.DiscountEndsAt.After(subscription.CurrentPeriodEnd))
+		return !discounted
+	case models.RetentionOfferTypeDowngrade:
+		plan := offer.Plan
+		return plan != nil && plan.IsActive && plan.ID != subscription.PlanID &&
+			plan.Product == subscription.Plan.Product && subscription.WillRenew()
+	}
+	return false
+}
+
+// declineRetentionOffers marks the offers shown for a subscription that is
+// canceled anyway as declined. Failures only affect reporting, so they are
+// logged rather than undoing the cancellation.
+func (s *SubscriptionService) declineRetentionOffers(subscription *models.Subscription, now time.Time) {
+	if err := s.retentionRepo.ResolvePresentations(subscription.ID, nil, now); err != nil {
+		log.Printf("Warning: failed to decline retention offers of subscription %s: %v", subscription.ID, err)
+	}
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SchedulePhaseInput describes one phase of a subscription schedule. Every
+// phase but the last needs either a number of billing periods (iterations) or
+// an end date; a last phase without either runs until the schedule is released.
+type SchedulePhaseInput struct {
+	PlanID     string     `json:"plan_id" binding:"required"`
+	Quantity   int        `json:"quantity" binding:"omitempty,min=1"`
+	Iterations int        `json:"iterations" binding:"omitempty,min=1"`
+	EndDate    *time.Time `json:"end_date"`
+	Coupon     string     `json:"coupon"`
+	PercentOff int        `json:"percent_off" binding:"omitempty,min=0,max=100"`
+}
+
+// CreateScheduleRequest represents subscription schedule creation data. The
+// first phase starts immediately.
+type CreateScheduleRequest struct {
+	EndBehavior string               `json:"end_behavior" binding:"omitempty,oneof=release cancel"`
+	Phases      []SchedulePhaseInput `json:"phases" binding:"required,min=1,dive"`
+}
+
+// AmendScheduleRequest replaces the current phase and every later one. The
+// current phase keeps its start date.
+type AmendScheduleRequest struct {
+	EndBehavior string               `json:"end_behavior" binding:"omitempty,oneof=release cancel"`
+	Phases      []SchedulePhaseInput `json:"phases" binding:"required,min=1,dive"`
+}
+
+// GetSchedule returns the active schedule of a subscription
+func (s *SubscriptionService) GetSchedule(subscriptionIDStr string) (*models.SubscriptionSchedule, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.getActiveSchedule(subscription)
+}
+
+// CreateSchedule attaches a schedule to a subscription and enters its first
+// phase. The schedule is created in the transaction that puts the
+// subscription on the phase's terms, so it is never left without them.
+func (s *SubscriptionService) CreateSchedule(subscriptionIDStr string, req *CreateScheduleRequest) (*models.SubscriptionSchedule, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		return nil, errors.New("only active subscriptions can be scheduled")
+	}
+	if subscription.CancelAtPeriodEnd {
+		return nil, errors.New("subscription is set to cancel at period end")
+	}
+
+	if _, err := s.scheduleRepo.GetActiveBySubscriptionID(subscription.ID); err == nil {
+		return nil, errors.New("subscription already has an active schedule")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := s.now(&subscription.Organization)
+	phases, err := s.buildPhases(subscription, req.Phases, now, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	endBehavior := req.EndBehavior
+	if endBehavior == "" {
+		endBehavior = models.ScheduleEndRelease
+	}
+
+	schedule := &models.SubscriptionSchedule{
+		SubscriptionID: subscription.ID,
+		Status:         models.ScheduleStatusActive,
+		EndBehavior:    endBehavior,
+		CurrentPhase:   0,
+		Phases:         phases,
+	}
+	if _, err := s.applyPhase(subscription, &schedule.Phases[0], now, schedule); err != nil {
+		return nil, err
+	}
+
+	return s.scheduleRepo.GetByID(schedule.ID)
+}
+
+// AmendSchedule replaces the phases of an active schedule from the current
+// phase onward. Changes to the current phase's terms take effect immediately.
+func (s *SubscriptionService) AmendSchedule(subscriptionIDStr string, req *AmendScheduleRequest) (*models.SubscriptionSchedule, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.getActiveSchedule(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	current := schedule.Phase(schedule.CurrentPhase)
+	if current == nil {
+		return nil, errors.New("schedule has no current phase")
+	}
+
+	now := s.now(&subscription.Organization)
+	phases, err := s.buildPhases(subscription, req.Phases, current.StartDate, schedule.CurrentPhase)
+	if err != nil {
+		return nil, err
+	}
+	if phases[0].EndDate != nil && !phases[0].EndDate.After(now) {
+		return nil, errors.New("current phase must end in the future")
+	}
+
+	if req.EndBehavior != "" {
+		schedule.EndBehavior = req.EndBehavior
+	}
+	if err := s.scheduleRepo.ReplacePhases(schedule, schedule.CurrentPhase, phases); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.applyPhase(subscription, &phases[0], now, nil); err != nil {
+		return nil, err
+	}
+
+	return s.scheduleRepo.GetByID(schedule.ID)
+}
+
+// ReleaseSchedule detaches the schedule from its subscription. The
+// subscription keeps the terms of the current phase and renews normally.
+func (s *SubscriptionService) ReleaseSchedule(subscriptionIDStr string) (*models.SubscriptionSchedule, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.getActiveSchedule(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now(&subscription.Organization)
+	schedule.Status = models.ScheduleStatusReleased
+	schedule.ReleasedAt = &now
+	if err := s.scheduleRepo.Update(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// ProcessSchedules moves subscriptions into the next phase of their schedule
+// once the current phase has ended. It is run periodically by the job scheduler.
+func (s *SubscriptionService) ProcessSchedules() error {
+	schedules, err := s.scheduleRepo.GetDue(s.clock.Now(), s.testClockID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, schedule := range schedules {
+		subscription, err := s.subscriptionRepo.GetByID(schedule.SubscriptionID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", schedule.ID, err))
+			continue
+		}
+		if _, err := s.advanceSchedule(subscription, schedule, s.clock.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", schedule.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// advanceDueSchedule advances the subscription's active schedule, if any,
+// through every phase that ends at or before at. It reports whether a new
+// billing period was started and invoiced.
+func (s *SubscriptionService) advanceDueSchedule(subscription *models.Subscription, at time.Time) (bool, error) {
+	schedule, err := s.scheduleRepo.GetActiveBySubscriptionID(subscription.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return s.advanceSchedule(subscription, schedule, at)
+}
+
+// advanceSchedule enters each phase whose predecessor ended at or before at,
+// and completes the schedule after its last phase
+func (s *SubscriptionService) advanceSchedule(subscription *models.Subscription, schedule *models.SubscriptionSchedule, at time.Time) (bool, error) {
+	started := false
+
+	for schedule.IsActive() {
+		current := schedule.Phase(schedule.CurrentPhase)
+		if current == nil || current.EndDate == nil || current.EndDate.After(at) {
+			break
+		}
+
+		// Paused subscriptions enter the next phase once they resume
+		if subscription.IsPaused() {
+			break
+		}
+
+		// The subscription ended, lost access or is ending on its own; the
+		// schedule goes with it. Past-due subscriptions carry on with it.
+		if !scheduleContinues(subscription) {
+			schedule.Status = models.ScheduleStatusCanceled
+			return started, s.scheduleRepo.Update(schedule)
+		}
+
+		next := schedule.Phase(schedule.CurrentPhase + 1)
+		if next == nil {
+			return started, s.completeSchedule(subscription, schedule, *current.EndDate)
+		}
+
+		phaseStarted, err := s.applyPhase(subscription, next, *current.EndDate, nil)
+		if err != nil {
+			return started, err
+		}
+		started = started || phaseStarted
+
+		schedule.CurrentPhase = next.Position
+		if err := s.scheduleRepo.Update(schedule); err != nil {
+			return started, err
+		}
+	}
+
+	return started, nil
+}
+
+// scheduleContinues reports whether a subscription stays on its schedule:
+// it has not ended, is not ending on its own and still has access
+func scheduleContinues(subscription *models.Subscription) bool {
+	switch subscription.Status {
+	case models.SubscriptionStatusActive, models.SubscriptionStatusTrialing, models.SubscriptionStatusPastDue:
+		return !subscription.CancelAtPeriodEnd
+	}
+	return false
+}
+
+// completeSchedule finishes a schedule after its last phase, canceling the
+// subscription when the schedule says so
+func (s *SubscriptionService) completeSchedule(subscription *models.Subscription, schedule *models.SubscriptionSchedule, endedAt time.Time) error {
+	now := s.now(&subscription.Organization)
+	schedule.Status = models.ScheduleStatusCompleted
+	schedule.CompletedAt = &now
+	if err := s.scheduleRepo.Update(schedule); err != nil {
+		return err
+	}
+
+	if schedule.EndBehavior != models.ScheduleEndCancel {
+		return nil
+	}
+
+	loaded := *subscription
+	subscription.CanceledAt = &now
+	subscription.EndDate = &endedAt
+	if err := s.transition(subscription, models.SubscriptionEventScheduleCompleted, ""); err != nil {
+		*subscription = loaded
+		return err
+	}
+	return nil
+}
+
+// applyPhase puts the subscription on the phase's terms from at. When the
+// terms change a new billing period starts at at and is invoiced, and the
+// unused part of a truncated paid period is added to the credit balance,
+// which the new period's invoice draws on. The usage, the invoice and the
+// subscription are saved in one transaction, together with the schedule
+// unless it is nil, which is then created as the phase is entered. Trials
+// only take on the new terms. It reports whether a new period was started.
+func (s *SubscriptionService) applyPhase(subscription *models.Subscription, phase *models.SubscriptionSchedulePhase, at time.Time, schedule *models.SubscriptionSchedule) (bool, error) {
+	if subscription.PlanID == phase.PlanID &&
+		subscriptionQuantity(subscription) == phase.Quantity &&
+		subscription.Coupon == phase.Coupon &&
+		subscription.PercentOff == phase.PercentOff {
+		if schedule == nil {
+			return false, nil
+		}
+		return false, s.scheduleRepo.Create(schedule)
+	}
+
+	plan := phase.Plan
+	if plan.ID != phase.PlanID {
+		loaded, err := s.planRepo.GetByID(phase.PlanID)
+		if err != nil {
+			return false, err
+		}
+		plan = *loaded
+	}
+
+	if subscription.Status == models.SubscriptionStatusTrialing {
+		subscription.PlanID = plan.ID
+		subscription.Plan = plan
+		subscription.Quantity = phase.Quantity
+		subscription.ScheduledQuantity = nil
+		subscription.Coupon = phase.Coupon
+		subscription.PercentOff = phase.PercentOff
+		subscription.DiscountEndsAt = nil
+		return false, s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{Schedule: schedule})
+	}
+
+	readAt := subscription.UpdatedAt
+	change := repository.SubscriptionChange{Schedule: schedule}
+	var err error
+	if change.Usage, err = s.usageClosing(subscription, at); err != nil {
+		return false, err
+	}
+
+	// Credit what is left of the period already invoiced on the old terms
+	if at.Before(subscription.CurrentPeriodEnd) {
+		credit, err := remainingPeriodAmount(subscription, &subscription.Plan, subscriptionQuantity(subscription), at)
+		if err != nil {
+			return false, err
+		}
+		if credit.IsPositive() {
+			if err := addCredit(subscription, credit); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	periodEnd, err := nextPeriodEnd(subscription, at, plan.Interval)
+	if err != nil {
+		return false, err
+	}
+
+	subscription.PlanID = plan.ID
+	subscription.Plan = plan
+	subscription.ScheduledPlanID = nil
+	subscription.Quantity = phase.Quantity
+	subscription.ScheduledQuantity = nil
+	subscription.Coupon = phase.Coupon
+	subscription.PercentOff = phase.PercentOff
+	subscription.DiscountEndsAt = nil
+	subscription.CurrentPeriodStart = at
+	subscription.CurrentPeriodEnd = periodEnd
+	subscription.EndDate = &periodEnd
+
+	if change.Invoice, err = s.subscriptionInvoice(subscription, &plan); err != nil {
+		return false, err
+	}
+	return true, s.applyChange(subscription, readAt, change)
+}
+
+// buildPhases validates phase inputs and lays them out back to back from start
+func (s *SubscriptionService) buildPhases(subscription *models.Subscription, inputs []SchedulePhaseInput, start time.Time, firstPosition int) ([]models.SubscriptionSchedulePhase, error) {
+	phases := make([]models.SubscriptionSchedulePhase, 0, len(inputs))
+	phaseStart := start
+
+	for i, input := range inputs {
+		planID, err := uuid.Parse(input.PlanID)
+		if err != nil {
+			return nil, errors.New("invalid plan ID")
+		}
+
+		plan, err := s.planRepo.GetByID(planID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("plan not found")
+			}
+			return nil, err
+		}
+		if !plan.IsActive && plan.ID != subscription.PlanID {
+			return nil, errors.New("plan is not active")
+		}
+		if plan.Product != subscription.Plan.Product {
+			return nil, errors.New("plan belongs to a different product")
+		}
+		if plan.Currency != subscription.Plan.Currency {
+			return nil, errors.New("schedule phases must use the subscription's currency")
+		}
+
+		last := i == len(inputs)-1
+		if input.Iterations > 0 && input.EndDate != nil {
+			return nil, fmt.Errorf("phase %d: set either iterations or end_date, not both", i+1)
+		}
+		if !last && input.Iterations == 0 && input.EndDate == nil {
+			return nil, fmt.Errorf("phase %d: only the last phase may be open-ended", i+1)
+		}
+
+		var phaseEnd *time.Time
+		switch {
+		case input.EndDate != nil:
+			if !input.EndDate.After(phaseStart) {
+				return nil, fmt.Errorf("phase %d: end_date must be after the phase start", i+1)
+			}
+			end := *input.EndDate
+			phaseEnd = &end
+		case input.Iterations > 0:
+			end := phaseStart
+			for n := 0; n < input.Iterations; n++ {
+				if end, err = nextPeriodEnd(subscription, end, plan.Interval); err != nil {
+					return nil, err
+				}
+			}
+			phaseEnd = &end
+		}
+
+		quantity := input.Quantity
+		if quantity < 1 {
+			quantity = 1
+		}
+
+		phases = append(phases, models.SubscriptionSchedulePhase{
+			Position:   firstPosition + i,
+			PlanID:     plan.ID,
+			Quantity:   quantity,
+			Iterations: input.Iterations,
+			StartDate:  phaseStart,
+			EndDate:    phaseEnd,
+			Coupon:     input.Coupon,
+			PercentOff: input.PercentOff,
+		})
+
+		if phaseEnd != nil {
+			phaseStart = *phaseEnd
+		}
+	}
+
+	return phases, nil
+}
+
+// getActiveSchedule loads the schedule controlling a subscription
+func (s *SubscriptionService) getActiveSchedule(subscription *models.Subscription) (*models.SubscriptionSchedule, error) {
+	schedule, err := s.scheduleRepo.GetActiveBySubscriptionID(subscription.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("subscription has no active schedule")
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// cancelActiveSchedule stops the schedule of a subscription that has ended
+func (s *SubscriptionService) cancelActiveSchedule(subscription *models.Subscription) error {
+	schedule, err := s.scheduleRepo.GetActiveBySubscriptionID(subscription.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	schedule.Status = models.ScheduleStatusCanceled
+	return s.scheduleRepo.Update(schedule)
+}
@@ -2,20 +2,37 @@ package services
 
 import (
 	"errors"
+	"slices"
 	"time"
 
+	"github.com/google/uuid"
 	"go-backend/internal/models"
+	"go-backend/internal/notification"
 	"go-backend/internal/repository"
-	"github.com/google/uuid"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/money"
 	"gorm.io/gorm"
 )
 
 // SubscriptionService handles subscription business logic
 type SubscriptionService struct {
-	subscriptionRepo repository.SubscriptionRepository
-	planRepo         repository.PlanRepository
-	orgRepo          repository.OrganizationRepository
-	invoiceRepo      repository.InvoiceRepository
+	subscriptionRepo  repository.SubscriptionRepository
+	planRepo          repository.PlanRepository
+	orgRepo           repository.OrganizationRepository
+	invoiceRepo       repository.InvoiceRepository
+	paymentMethodRepo repository.PaymentMethodRepository
+	historyRepo       repository.SubscriptionHistoryRepository
+	scheduleRepo      repository.SubscriptionScheduleRepository
+	testClockRepo     repository.TestClockRepository
+	meterRepo         repository.MeterRepository
+	usageRepo         repository.UsageRepository
+	retentionRepo     repository.RetentionRepository
+	notifier          notification.Notifier
+	trials            TrialPolicy
+	seats             SeatPolicy
+	reactivation      ReactivationPolicy
+	clock             clock.Clock
+	testClockID       *uuid.UUID // set on copies that process the organizations of one test clock
 }
 
 // NewSubscriptionService creates a new subscription service
@@ -24,12 +41,36 @@ func NewSubscriptionService(
 	planRepo repository.PlanRepository,
 	orgRepo repository.OrganizationRepository,
 	invoiceRepo repository.InvoiceRepository,
+	paymentMethodRepo repository.PaymentMethodRepository,
+	historyRepo repository.SubscriptionHistoryRepository,
+	scheduleRepo repository.SubscriptionScheduleRepository,
+	testClockRepo repository.TestClockRepository,
+	meterRepo repository.MeterRepository,
+	usageRepo repository.UsageRepository,
+	retentionRepo repository.RetentionRepository,
+	notifier notification.Notifier,
+	trials TrialPolicy,
+	seats SeatPolicy,
+	reactivation ReactivationPolicy,
+	clk clock.Clock,
 ) *SubscriptionService {
 	return &SubscriptionService{
-		subscriptionRepo: subscriptionRepo,
-		planRepo:         planRepo,
-		orgRepo:          orgRepo,
-		invoiceRepo:      invoiceRepo,
+		subscriptionRepo:  subscriptionRepo,
+		planRepo:          planRepo,
+		orgRepo:           orgRepo,
+		invoiceRepo:       invoiceRepo,
+		paymentMethodRepo: paymentMethodRepo,
+		historyRepo:       historyRepo,
+		scheduleRepo:      scheduleRepo,
+		testClockRepo:     testClockRepo,
+		meterRepo:         meterRepo,
+		usageRepo:         usageRepo,
+		retentionRepo:     retentionRepo,
+		notifier:          notifier,
+		trials:            trials,
+		seats:             seats,
+		reactivation:      reactivation,
+		clock:             clk,
 	}
 }
 
@@ -38,6 +79,11 @@ type CreateSubscriptionRequest struct {
 	OrganizationID string `json:"organization_id" binding:"required"`
 	PlanID         string `json:"plan_id" binding:"required"`
 	AutoRenew      bool   `json:"auto_renew"`
+	Quantity       int    `json:"quantity" binding:"omitempty,min=1"` // seats billed; defaults to 1
+	SyncSeats      bool   `json:"sync_seats"`                         // bill one seat per active organization member
+
+	// BillingAnchor aligns the subscription's periods; defaults to the organization's anchor
+	BillingAnchor *BillingAnchorRequest `json:"billing_anchor,omitempty"`
 }
 
 // SubscriptionResponse represents subscription response data
@@ -82,20 +128,19 @@ func (s *SubscriptionService) CreateSubscription(req *CreateSubscriptionRequest)
 		return nil, errors.New("plan is not active")
 	}
 
-	// Check if organization already has an active subscription
-	activeSubscription, err := s.subscriptionRepo.GetActiveByOrganizationID(orgID)
+	// Organizations subscribe to each product at most once
+	existing, err := s.subscriptionRepo.GetByOrganizationAndProduct(orgID, plan.Product, repository.OpenStatuses)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
-	if activeSubscription != nil {
-		return nil, errors.New("organization already has an active subscription")
+	if existing != nil {
+		return nil, errors.New("organization already has a subscription to this product")
 	}
 
 	// Calculate subscription dates
-	now := time.Now()
+	now := s.now(org)
 	startDate := now
-	var endDate time.Time
 	var trialEndDate *time.Time
 
 	// Set trial period if plan has trial days
@@ -104,48 +149,72 @@ func (s *SubscriptionService) CreateSubscription(req *CreateSubscriptionRequest)
 		trialEndDate = &trialEnd
 	}
 
-	// Calculate end date based on plan interval
-	switch plan.Interval {
-	case "monthly":
-		endDate = startDate.AddDate(0, 1, 0)
-	case "yearly":
-		endDate = startDate.AddDate(1, 0, 0)
-	case "weekly":
-		endDate = startDate.AddDate(0, 0, 7)
-	default:
-		return nil, errors.New("invalid plan interval")
+	anchor := org.BillingAnchor
+	if req.BillingAnchor != nil {
+		anchor = req.BillingAnchor.anchor()
+	}
+	if err := validateBillingAnchor(anchor); err != nil {
+		return nil, err
 	}
 
-	// Create subscription
-	subscription := &models.Subscription{
-		OrganizationID:       orgID,
-		PlanID:               planID,
-		Status:               "active",
-		StartDate:            startDate,
-		EndDate:              &endDate,
-		TrialEndDate:         trialEndDate,
-		CurrentPeriodStart:   startDate,
-		CurrentPeriodEnd:     endDate,
-		AutoRenew:            req.AutoRenew,
-	}
-
-	// If in trial, set status to trialing
-	if trialEndDate != nil && now.Before(*trialEndDate) {
-		subscription.Status = "trialing"
+	quantity := req.Quantity
+	if req.SyncSeats {
+		if quantity, err = s.activeSeats(orgID); err != nil {
+			return nil, err
+		}
+	}
+	if quantity < 1 {
+		quantity = 1
 	}
 
-	if err := s.subscriptionRepo.Create(subscription); err != nil {
+	// Create subscription
+	subscription := &models.Subscription{
+		ID:                 uuid.New(),
+		OrganizationID:     orgID,
+		PlanID:             planID,
+		Product:            plan.Product,
+		Status:             models.SubscriptionStatusActive,
+		StartDate:          startDate,
+		TrialEndDate:       trialEndDate,
+		CurrentPeriodStart: startDate,
+		BillingAnchor:      anchor,
+		AutoRenew:          req.AutoRenew,
+		Quantity:           quantity,
+		SyncSeats:          req.SyncSeats,
+	}
+
+	// Calculate end date based on plan interval; anchored subscriptions start
+	// with a shorter first period ending on the anchor date
+	endDate, err := nextPeriodEnd(subscription, startDate, plan.Interval)
+	if err != nil {
 		return nil, err
 	}
+	subscription.EndDate = &endDate
+	subscription.CurrentPeriodEnd = endDate
 
-	// Organization is already loaded, no need to update plan type
+	// If in trial, set status to trialing; the first paid period starts when the trial converts
+	if trialEndDate != nil && now.Before(*trialEndDate) {
+		subscription.Status = models.SubscriptionStatusTrialing
+		subscription.EndDate = trialEndDate
+		subscription.CurrentPeriodEnd = *trialEndDate
+	}
 
-	// Create initial invoice if not in trial
-	if subscription.Status == "active" {
-		if err := s.createSubscriptionInvoice(subscription, plan); err != nil {
+	// The subscription, its first status change and, unless it starts with a
+	// trial, its first invoice are written together
+	subscription.Organization = *org
+	var invoice *models.Invoice
+	if subscription.Status == models.SubscriptionStatusActive {
+		if invoice, err = s.subscriptionInvoice(subscription, plan); err != nil {
 			return nil, err
 		}
 	}
+	change := s.statusChange(subscription, "", models.SubscriptionEventCreate, "")
+	if err := s.subscriptionRepo.Start(subscription, change, invoice); err != nil {
+		if errors.Is(err, repository.ErrOpenSubscriptionExists) {
+			return nil, errors.New("organization already has a subscription to this product")
+		}
+		return nil, err
+	}
 
 	return &SubscriptionResponse{
 		Subscription: subscription,
@@ -154,37 +223,70 @@ func (s *SubscriptionService) CreateSubscription(req *CreateSubscriptionRequest)
 	}, nil
 }
 
-// CancelSubscription cancels a subscription
-func (s *SubscriptionService) CancelSubscription(subscriptionIDStr string, immediate bool) error {
+// CancelSubscriptionRequest represents subscription cancellation data
+type CancelSubscriptionRequest struct {
+	Immediate bool   `json:"immediate"`                         // end now instead of at the end of the current period
+	Reason    string `json:"reason"`                            // too_expensive, missing_features, switched_service, unused, customer_service, technical_issues, too_complex or other
+	Note      string `json:"note" binding:"omitempty,max=2000"` // the customer's own words
+}
+
+// CancelSubscription cancels a subscription, recording why. Retention offers
+// shown for it and not accepted are declined.
+func (s *SubscriptionService) CancelSubscription(subscriptionIDStr string, req *CancelSubscriptionRequest) (err error) {
 	subscriptionID, err := uuid.Parse(subscriptionIDStr)
 	if err != nil {
 		return errors.New("invalid subscription ID")
 	}
+	if req.Reason != "" && !models.IsValidCancellationReason(req.Reason) {
+		return errors.New("invalid cancellation reason")
+	}
 
 	subscription, err := s.subscriptionRepo.GetByID(subscriptionID)
 	if err != nil {
 		return err
 	}
 
-	if subscription.Status == "canceled" {
+	if subscription.Status == models.SubscriptionStatusCanceled {
 		return errors.New("subscription is already canceled")
 	}
+	if models.IsTerminalSubscriptionStatus(subscription.Status) {
+		return errors.New("subscription has already ended")
+	}
 
-	now := time.Now()
+	defer restoreOnError(subscription, *subscription, &err)
+	now := s.now(&subscription.Organization)
 	subscription.CanceledAt = &now
+	subscription.CancellationReason = req.Reason
+	subscription.CancellationNote = req.Note
 
-	if immediate {
-		subscription.Status = "canceled"
-		subscription.EndDate = &now
-	} else {
+	if !req.Immediate {
 		// Cancel at end of current period
-		subscription.AutoRenew = false
+		subscription.CancelAtPeriodEnd = true
+		if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+			return err
+		}
+		s.declineRetentionOffers(subscription, now)
+		return nil
 	}
 
-	return s.subscriptionRepo.Update(subscription)
+	usage, err := s.usageClosing(subscription, now)
+	if err != nil {
+		return err
+	}
+
+	subscription.EndDate = &now
+	if err := s.transitionWithChange(subscription, models.SubscriptionEventCancel, req.Reason, repository.SubscriptionChange{Usage: usage}); err != nil {
+		return err
+	}
+	s.declineRetentionOffers(subscription, now)
+	return s.cancelActiveSchedule(subscription)
 }
 
-// RenewSubscription renews a subscription for the next period
+// RenewSubscription renews a subscription whose current period has ended for
+// the next period. The usage of the period that ended, the new period and its
+// invoice are saved in one transaction, and only if the subscription was not
+// changed since it was loaded, so a period is renewed once even when a manual
+// renewal races the billing engine.
 func (s *SubscriptionService) RenewSubscription(subscriptionIDStr string) error {
 	subscriptionID, err := uuid.Parse(subscriptionIDStr)
 	if err != nil {
@@ -196,29 +298,48 @@ func (s *SubscriptionService) RenewSubscription(subscriptionIDStr string) error
 		return err
 	}
 
-	if subscription.Status != "active" {
-		return errors.New("only active subscriptions can be renewed")
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusPastDue {
+		return errors.New("only active or past-due subscriptions can be renewed")
+	}
+	if subscription.CancelAtPeriodEnd {
+		return errors.New("subscription is set to cancel at period end")
+	}
+	if subscription.CurrentPeriodEnd.After(s.now(&subscription.Organization)) {
+		return errors.New("current period has not ended yet")
+	}
+
+	// A schedule phase ending with this period starts the next period itself
+	started, err := s.advanceDueSchedule(subscription, subscription.CurrentPeriodEnd)
+	if err != nil || started {
+		return err
+	}
+
+	// Usage of the period that ended is billed on the plan it was recorded under
+	readAt := subscription.UpdatedAt
+	var change repository.SubscriptionChange
+	if change.Usage, err = s.usageClosing(subscription, subscription.CurrentPeriodEnd); err != nil {
+		return err
 	}
 
+	// Apply a plan or seat change that was scheduled for this renewal
+	if subscription.ScheduledPlanID != nil {
+		subscription.PlanID = *subscription.ScheduledPlanID
+		subscription.ScheduledPlanID = nil
+	}
+	applyScheduledQuantity(subscription)
+
 	// Get plan details
 	plan, err := s.planRepo.GetByID(subscription.PlanID)
 	if err != nil {
 		return err
 	}
+	subscription.Plan = *plan
 
 	// Calculate new period dates
 	newStartDate := subscription.CurrentPeriodEnd
-	var newEndDate time.Time
-
-	switch plan.Interval {
-	case "monthly":
-		newEndDate = newStartDate.AddDate(0, 1, 0)
-	case "yearly":
-		newEndDate = newStartDate.AddDate(1, 0, 0)
-	case "weekly":
-		newEndDate = newStartDate.AddDate(0, 0, 7)
-	default:
-		return errors.New("invalid plan interval")
+	newEndDate, err := nextPeriodEnd(subscription, newStartDate, plan.Interval)
+	if err != nil {
+		return err
 	}
 
 	// Update subscription
@@ -226,12 +347,16 @@ func (s *SubscriptionService) RenewSubscription(subscriptionIDStr string) error
 	subscription.CurrentPeriodEnd = newEndDate
 	subscription.EndDate = &newEndDate
 
-	if err := s.subscriptionRepo.Update(subscription); err != nil {
+	// Save the new period together with its invoice
+	if change.Invoice, err = s.subscriptionInvoice(subscription, plan); err != nil {
 		return err
 	}
+	return s.applyChange(subscription, readAt, change)
+}
 
-	// Create invoice for new period
-	return s.createSubscriptionInvoice(subscription, plan)
+// GetSubscription gets a subscription by ID
+func (s *SubscriptionService) GetSubscription(subscriptionIDStr string) (*models.Subscription, error) {
+	return s.getSubscription(subscriptionIDStr)
 }
 
 // GetSubscriptionsByOrganization gets all subscriptions for an organization
@@ -253,96 +378,312 @@ func (s *SubscriptionService) GetSubscriptionsByOrganization(organizationIDStr s
 	return subscriptions, total, nil
 }
 
-// GetActiveSubscription gets the active subscription for an organization
-func (s *SubscriptionService) GetActiveSubscription(organizationIDStr string) (*SubscriptionResponse, error) {
+// OrganizationSubscriptionsResponse lists the active subscriptions of an organization
+type OrganizationSubscriptionsResponse struct {
+	Organization  *models.Organization   `json:"organization"`
+	Subscriptions []*models.Subscription `json:"subscriptions"` // one per product, ordered by product
+}
+
+// GetActiveSubscriptions gets the active subscriptions of an organization, one per product
+func (s *SubscriptionService) GetActiveSubscriptions(organizationIDStr string) (*OrganizationSubscriptionsResponse, error) {
 	orgID, err := uuid.Parse(organizationIDStr)
 	if err != nil {
 		return nil, errors.New("invalid organization ID")
 	}
 
-	subscription, err := s.subscriptionRepo.GetActiveByOrganizationID(orgID)
+	org, err := s.orgRepo.GetByID(orgID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("no active subscription found")
+			return nil, errors.New("organization not found")
 		}
 		return nil, err
 	}
 
-	// Get plan and organization details
-	plan, err := s.planRepo.GetByID(subscription.PlanID)
+	subscriptions, err := s.subscriptionRepo.GetActiveByOrganizationID(orgID, s.now(org))
 	if err != nil {
 		return nil, err
 	}
 
+	return &OrganizationSubscriptionsResponse{
+		Organization:  org,
+		Subscriptions: subscriptions,
+	}, nil
+}
+
+// GetActiveSubscriptionForProduct gets an organization's active subscription to a product
+func (s *SubscriptionService) GetActiveSubscriptionForProduct(organizationIDStr, product string) (*SubscriptionResponse, error) {
+	orgID, err := uuid.Parse(organizationIDStr)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	subscription, err := s.subscriptionRepo.GetByOrganizationAndProduct(orgID, product, repository.EntitledStatuses)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("no active subscription found")
+		}
+		return nil, err
+	}
+
 	org, err := s.orgRepo.GetByID(subscription.OrganizationID)
 	if err != nil {
 		return nil, err
 	}
 
+	plan := subscription.Plan
 	return &SubscriptionResponse{
 		Subscription: subscription,
-		Plan:         plan,
+		Plan:         &plan,
 		Organization: org,
 	}, nil
 }
 
-// ProcessExpiredSubscriptions processes subscriptions that have expired
-func (s *SubscriptionService) ProcessExpiredSubscriptions() error {
-	// Get subscriptions expiring in 0 days (already expired)
-	expiredSubscriptions, err := s.subscriptionRepo.GetExpiring(0)
-	if err != nil {
-		return err
+// ProcessPeriodEnd carries out what is due when a subscription's current
+// period ends: a cancellation requested for the end of the period, a renewal,
+// or expiry. Past-due subscriptions renew like active ones while their
+// payment is retried; suspended ones have lost access and expire instead. It
+// returns the resulting billing outcome.
+func (s *SubscriptionService) ProcessPeriodEnd(subscription *models.Subscription) (string, error) {
+	switch subscription.Status {
+	case models.SubscriptionStatusActive, models.SubscriptionStatusPastDue, models.SubscriptionStatusSuspended:
+	default:
+		return models.BillingItemSkipped, nil
 	}
 
-	for _, subscription := range expiredSubscriptions {
-		if subscription.AutoRenew {
-			// Try to renew
-			if err := s.RenewSubscription(subscription.ID.String()); err != nil {
-				// If renewal fails, mark as expired
-				subscription.Status = "expired"
-				s.subscriptionRepo.Update(subscription)
-			}
-		} else {
-			// Mark as expired
-			subscription.Status = "expired"
-			s.subscriptionRepo.Update(subscription)
+	// The usage of the period that ended is billed with whatever comes next
+	if subscription.CancelAtPeriodEnd {
+		usage, err := s.usageClosing(subscription, subscription.CurrentPeriodEnd)
+		if err != nil {
+			return "", err
 		}
+		if err := s.transitionWithChange(subscription, models.SubscriptionEventPeriodEndCancel, "", repository.SubscriptionChange{Usage: usage}); err != nil {
+			return "", err
+		}
+		if err := s.cancelActiveSchedule(subscription); err != nil {
+			return "", err
+		}
+		return models.BillingItemCanceled, nil
+	}
+
+	if subscription.AutoRenew && subscription.Status != models.SubscriptionStatusSuspended {
+		if err := s.RenewSubscription(subscription.ID.String()); err != nil {
+			return "", err
+		}
+		return models.BillingItemRenewed, nil
 	}
 
-	return nil
+	if err := s.ExpireSubscription(subscription, ""); err != nil {
+		return "", err
+	}
+	return models.BillingItemExpired, nil
+}
+
+// ExpireSubscription marks a subscription as expired at the end of its
+// current period, billing the usage of the period with the expiry
+func (s *SubscriptionService) ExpireSubscription(subscription *models.Subscription, reason string) error {
+	usage, err := s.usageClosing(subscription, subscription.CurrentPeriodEnd)
+	if err != nil {
+		return err
+	}
+	return s.transitionWithChange(subscription, models.SubscriptionEventExpire, reason, repository.SubscriptionChange{Usage: usage})
 }
 
-// createSubscriptionInvoice creates an invoice for a subscription
-func (s *SubscriptionService) createSubscriptionInvoice(subscription *models.Subscription, plan *models.Plan) error {
+// subscriptionInvoice builds the draft invoice for a subscription's current
+// billing period, less the credit the subscription holds. Callers save the
+// subscription with the invoice, so the credit is used up exactly once.
+func (s *SubscriptionService) subscriptionInvoice(subscription *models.Subscription, plan *models.Plan) (*models.Invoice, error) {
+	quantity := subscriptionQuantity(subscription)
+	unitPrice := periodPrice(subscription, plan, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd)
+	amount, err := unitPrice.Mul(int64(quantity))
+	if err != nil {
+		return nil, err
+	}
+	discount, err := amount.Percent(int64(subscription.PeriodPercentOff())*100, prorationRounding)
+	if err != nil {
+		return nil, err
+	}
+	periodStart := subscription.CurrentPeriodStart
+	periodEnd := subscription.CurrentPeriodEnd
+
+	// Drafts carry a provisional number until finalization takes one from the sequence
 	invoice := &models.Invoice{
 		OrganizationID: subscription.OrganizationID,
 		SubscriptionID: &subscription.ID,
-		InvoiceNumber:  "INV-" + subscription.ID.String()[:8],
-		Status:         "draft",
-		Subtotal:       plan.Price,
-		Total:          plan.Price,
+		Status:         models.InvoiceStatusDraft,
 		Currency:       plan.Currency,
-		IssueDate:      time.Now(),
+		IssueDate:      s.now(&subscription.Organization),
 		DueDate:        subscription.CurrentPeriodEnd,
 		Notes:          "Subscription: " + plan.Name,
+		Items: []models.InvoiceItem{{
+			Description:    plan.Name + " - " + plan.Interval + " subscription",
+			Quantity:       quantity,
+			UnitPrice:      unitPrice,
+			Amount:         amount,
+			DiscountAmount: discount,
+			PeriodStart:    &periodStart,
+			PeriodEnd:      &periodEnd,
+			PlanID:         &plan.ID,
+		}},
+	}
+	if subscription.Coupon != "" && subscription.DiscountApplies() {
+		invoice.Notes += " (coupon " + subscription.Coupon + ")"
+	}
+	if err := applyCredit(subscription, invoice); err != nil {
+		return nil, err
 	}
+	return invoice, nil
+}
 
-	if err := s.invoiceRepo.Create(invoice); err != nil {
-		return err
+// subscriptionQuantity returns the number of units billed for a subscription
+func subscriptionQuantity(subscription *models.Subscription) int {
+	if subscription.Quantity < 1 {
+		return 1
 	}
+	return subscription.Quantity
+}
 
-	// Create invoice item
-	invoiceItem := &models.InvoiceItem{
-		InvoiceID:   invoice.ID,
-		Description: plan.Name + " - " + plan.Interval + " subscription",
-		Quantity:    1,
-		UnitPrice:   plan.Price,
-		Amount:      plan.Price,
+// PlanMigrationOutcome describes what happened when a subscription was moved to another plan
+type PlanMigrationOutcome struct {
+	Status       string      `json:"status"`
+	Reason       string      `json:"reason,omitempty"`
+	CreditAmount money.Money `json:"credit_amount"`
+	ChargeAmount money.Money `json:"charge_amount"`
+	InvoiceID    *uuid.UUID  `json:"invoice_id,omitempty"`
+	EffectiveAt  time.Time   `json:"effective_at"`
+}
+
+// MigrateSubscriptionPlan moves a subscription onto targetPlan, either right
+// away or when the subscription next renews; past-due, paused and suspended
+// subscriptions always move at renewal. Immediate moves follow
+// ChangePlan: a new period starts when the billing interval changes and is
+// charged in full, and moves of paying subscriptions can be prorated, with a
+// positive difference invoiced and a negative one added to the
+// subscription's credit balance.
+func (s *SubscriptionService) MigrateSubscriptionPlan(subscriptionID uuid.UUID, targetPlan *models.Plan, mode string, prorate bool) (*PlanMigrationOutcome, error) {
+	subscription, err := s.subscriptionRepo.GetByID(subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("subscription not found")
+		}
+		return nil, err
 	}
 
-	// Note: You would need to create an InvoiceItemRepository to save this
-	// For now, we'll skip this step
-	_ = invoiceItem
+	now := s.now(&subscription.Organization)
+	outcome := &PlanMigrationOutcome{EffectiveAt: now}
+
+	if subscription.PlanID == targetPlan.ID {
+		outcome.Status = models.PlanMigrationItemSkipped
+		outcome.Reason = "subscription is already on the target plan"
+		return outcome, nil
+	}
 
-	return nil
-}
\ No newline at end of file
+	if !slices.Contains(migratableStatuses, subscription.Status) {
+		outcome.Status = models.PlanMigrationItemSkipped
+		outcome.Reason = "subscription is " + subscription.Status
+		return outcome, nil
+	}
+	// Subscriptions behind on payment or paused keep their current period
+	// as it is and move when it renews
+	if subscription.Status != models.SubscriptionStatusActive && subscription.Status != models.SubscriptionStatusTrialing {
+		mode = models.PlanMigrationModeAtRenewal
+	}
+	if targetPlan.Product != subscription.Plan.Product {
+		return nil, errors.New("plan belongs to a different product")
+	}
+	if err := checkCreditCurrency(subscription, targetPlan); err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case models.PlanMigrationModeAtRenewal:
+		if !subscription.WillRenew() {
+			outcome.Status = models.PlanMigrationItemSkipped
+			outcome.Reason = "subscription will not renew"
+			return outcome, nil
+		}
+
+		subscription.ScheduledPlanID = &targetPlan.ID
+		if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+			return nil, err
+		}
+
+		outcome.Status = models.PlanMigrationItemScheduled
+		outcome.EffectiveAt = subscription.CurrentPeriodEnd
+		return outcome, nil
+
+	case models.PlanMigrationModeImmediate:
+		currentPlan := subscription.Plan
+		result := &PlanChangeResult{
+			PeriodStart: subscription.CurrentPeriodStart,
+			PeriodEnd:   subscription.CurrentPeriodEnd,
+		}
+		if err := immediateTerms(subscription, targetPlan, prorate, now, result); err != nil {
+			return nil, err
+		}
+		if len(result.Lines) > 0 {
+			outcome.CreditAmount = result.Credit
+			outcome.ChargeAmount = result.Charge
+		}
+
+		description := "Plan change from " + currentPlan.Name + " to " + targetPlan.Name
+		if prorate {
+			description = "Prorated change from " + currentPlan.Name + " to " + targetPlan.Name
+		}
+		if err := s.switchPlan(subscription, targetPlan, result, description, now); err != nil {
+			return nil, err
+		}
+
+		outcome.Status = models.PlanMigrationItemMigrated
+		outcome.InvoiceID = result.InvoiceID
+		return outcome, nil
+
+	default:
+		return nil, errors.New("invalid migration mode")
+	}
+}
+
+// adjustmentInvoice builds an invoice due at now with one item per line. Its
+// totals are derived from the items when it is created.
+func adjustmentInvoice(subscription *models.Subscription, lines []PlanChangeLine, description string, now time.Time) (*models.Invoice, error) {
+	currency := lines[0].Amount.Currency()
+	items := make([]models.InvoiceItem, 0, len(lines))
+	for _, line := range lines {
+		if line.Amount.Currency() != currency {
+			return nil, money.ErrCurrencyMismatch
+		}
+		quantity := line.Quantity
+		if quantity < 1 {
+			quantity = 1
+		}
+		unitPrice, err := line.Amount.MulRat(1, int64(quantity), prorationRounding)
+		if err != nil {
+			return nil, err
+		}
+		periodStart := line.PeriodStart
+		periodEnd := line.PeriodEnd
+		items = append(items, models.InvoiceItem{
+			Description:    line.Description,
+			Quantity:       quantity,
+			UnitPrice:      unitPrice,
+			Amount:         line.Amount,
+			PeriodStart:    &periodStart,
+			PeriodEnd:      &periodEnd,
+			PlanID:         line.PlanID,
+			MeteredPriceID: line.MeteredPriceID,
+			Proration:      line.Proration,
+		})
+	}
+
+	invoice := &models.Invoice{
+		OrganizationID: subscription.OrganizationID,
+		SubscriptionID: &subscription.ID,
+		Status:         models.InvoiceStatusDraft,
+		Currency:       currency,
+		IssueDate:      now,
+		DueDate:        now,
+		Notes:          description,
+		Items:          items,
+	}
+
+	return invoice, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// fakeSubscriptionRepository serves one subscription and records the change
+// applied to it; with stale set, it finds the subscription changed since it
+// was read and applies nothing
+type fakeSubscriptionRepository struct {
+	repository.SubscriptionRepository
+	subscription *models.Subscription
+	change       repository.SubscriptionChange
+	stale        bool
+}
+
+func (r *fakeSubscriptionRepository) GetByID(id uuid.UUID) (*models.Subscription, error) {
+	return r.subscription, nil
+}
+
+func (r *fakeSubscriptionRepository) ApplyChange(subscription *models.Subscription, readAt time.Time, change repository.SubscriptionChange) (bool, error) {
+	if r.stale {
+		return false, nil
+	}
+	r.change = change
+	return true, nil
+}
+
+func (r *fakeSubscriptionRepository) Transition(subscription *models.Subscription, from string, status *models.SubscriptionStatusChange, change repository.SubscriptionChange) (bool, error) {
+	if r.stale {
+		return false, nil
+	}
+	r.change = change
+	return true, nil
+}
+
+// fakeMeterRepository serves plans without metered prices
+type fakeMeterRepository struct {
+	repository.MeterRepository
+}
+
+func (fakeMeterRepository) GetPricesByPlanID(planID uuid.UUID) ([]*models.MeteredPrice, error) {
+	return nil, nil
+}
+
+// fakeUsageRepository serves subscriptions that have closed no usage
+type fakeUsageRepository struct {
+	repository.UsageRepository
+}
+
+func (fakeUsageRepository) GetSummaries(subscriptionID uuid.UUID, periodStart time.Time) ([]*models.UsageSummary, error) {
+	return nil, nil
+}
+
+func (fakeUsageRepository) GetLateSummaries(subscriptionID uuid.UUID) ([]*models.UsageSummary, error) {
+	return nil, nil
+}
+
+func TestMigrateSubscriptionPlanWithoutProration(t *testing.T) {
+	basic := models.Plan{ID: uuid.New(), Name: "Basic", Interval: "monthly", Price: money.New(3100, "USD"), Currency: "USD"}
+	pro := &models.Plan{ID: uuid.New(), Name: "Pro", Interval: "monthly", Price: money.New(6200, "USD"), Currency: "USD"}
+	annual := &models.Plan{ID: uuid.New(), Name: "Basic annual", Interval: "yearly", Price: money.New(36500, "USD"), Currency: "USD"}
+	now := date(2027, time.March, 11)
+
+	tests := []struct {
+		name      string
+		target    *models.Plan
+		periodEnd time.Time
+		charge    money.Money
+	}{
+		{"same interval keeps the paid period", pro, date(2027, time.April, 1), money.Money{}},
+		// The new period is charged in full; only the unused time is not credited
+		{"interval change charges the new period", annual, date(2028, time.March, 11), money.New(36500, "USD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriptions := &fakeSubscriptionRepository{subscription: &models.Subscription{
+				ID:                 uuid.New(),
+				Status:             models.SubscriptionStatusActive,
+				PlanID:             basic.ID,
+				Plan:               basic,
+				Quantity:           1,
+				CurrentPeriodStart: date(2027, time.March, 1),
+				CurrentPeriodEnd:   date(2027, time.April, 1),
+			}}
+			service := &SubscriptionService{
+				subscriptionRepo: subscriptions,
+				meterRepo:        fakeMeterRepository{},
+				usageRepo:        fakeUsageRepository{},
+				clock:            clock.Fixed(now),
+			}
+
+			outcome, err := service.MigrateSubscriptionPlan(subscriptions.subscription.ID, tt.target, models.PlanMigrationModeImmediate, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.Status != models.PlanMigrationItemMigrated {
+				t.Fatalf("outcome = %s, want %s", outcome.Status, models.PlanMigrationItemMigrated)
+			}
+			if end := subscriptions.subscription.CurrentPeriodEnd; !end.Equal(tt.periodEnd) {
+				t.Errorf("period ends %v, want %v", end, tt.periodEnd)
+			}
+
+			invoice := subscriptions.change.Invoice
+			if tt.charge.IsZero() {
+				if invoice != nil || outcome.InvoiceID != nil {
+					t.Errorf("got an invoice, want none")
+				}
+				return
+			}
+			if invoice == nil || outcome.InvoiceID == nil {
+				t.Fatal("got no invoice, want one")
+			}
+			if len(invoice.Items) != 1 || !invoice.Items[0].Amount.Equal(tt.charge) {
+				t.Errorf("invoice items = %+v, want one charging %v", invoice.Items, tt.charge)
+			}
+			if !outcome.ChargeAmount.Equal(tt.charge) || !outcome.CreditAmount.IsZero() {
+				t.Errorf("outcome charged %v and credited %v, want %v and nothing", outcome.ChargeAmount, outcome.CreditAmount, tt.charge)
+			}
+		})
+	}
+}
+
+func TestMigrateSubscriptionPlanAtRenewalWhenNotInGoodStanding(t *testing.T) {
+	basic := models.Plan{ID: uuid.New(), Name: "Basic", Interval: "monthly", Price: money.New(3100, "USD"), Currency: "USD"}
+	pro := &models.Plan{ID: uuid.New(), Name: "Pro", Interval: "monthly", Price: money.New(6200, "USD"), Currency: "USD"}
+
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{models.SubscriptionStatusActive, models.PlanMigrationItemMigrated},
+		{models.SubscriptionStatusPastDue, models.PlanMigrationItemScheduled},
+		{models.SubscriptionStatusPaused, models.PlanMigrationItemScheduled},
+		{models.SubscriptionStatusSuspended, models.PlanMigrationItemScheduled},
+		{models.SubscriptionStatusCanceled, models.PlanMigrationItemSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			subscriptions := &fakeSubscriptionRepository{subscription: &models.Subscription{
+				ID:                 uuid.New(),
+				Status:             tt.status,
+				PlanID:             basic.ID,
+				Plan:               basic,
+				Quantity:           1,
+				AutoRenew:          true,
+				CurrentPeriodStart: date(2027, time.March, 1),
+				CurrentPeriodEnd:   date(2027, time.April, 1),
+			}}
+			service := &SubscriptionService{
+				subscriptionRepo: subscriptions,
+				meterRepo:        fakeMeterRepository{},
+				usageRepo:        fakeUsageRepository{},
+				clock:            clock.Fixed(date(2027, time.March, 11)),
+			}
+
+			outcome, err := service.MigrateSubscriptionPlan(subscriptions.subscription.ID, pro, models.PlanMigrationModeImmediate, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.Status != tt.want {
+				t.Errorf("outcome = %s, want %s", outcome.Status, tt.want)
+			}
+			scheduled := subscriptions.subscription.ScheduledPlanID
+			if wantScheduled := tt.want == models.PlanMigrationItemScheduled; (scheduled != nil && *scheduled == pro.ID) != wantScheduled {
+				t.Errorf("scheduled plan = %v, want scheduled %v", scheduled, wantScheduled)
+			}
+		})
+	}
+}
+
+func TestCancelSubscriptionRestoresSubscriptionOnConflict(t *testing.T) {
+	for _, immediate := range []bool{true, false} {
+		loaded := models.Subscription{
+			ID:                 uuid.New(),
+			Status:             models.SubscriptionStatusActive,
+			Plan:               models.Plan{ID: uuid.New(), Interval: "monthly", Price: money.New(3100, "USD"), Currency: "USD"},
+			Quantity:           1,
+			CurrentPeriodStart: date(2027, time.March, 1),
+			CurrentPeriodEnd:   date(2027, time.April, 1),
+		}
+		subscriptions := &fakeSubscriptionRepository{subscription: &models.Subscription{}, stale: true}
+		*subscriptions.subscription = loaded
+		service := &SubscriptionService{
+			subscriptionRepo: subscriptions,
+			meterRepo:        fakeMeterRepository{},
+			usageRepo:        fakeUsageRepository{},
+			clock:            clock.Fixed(date(2027, time.March, 11)),
+		}
+
+		req := &CancelSubscriptionRequest{Immediate: immediate, Note: "moving on"}
+		if err := service.CancelSubscription(loaded.ID.String(), req); err == nil {
+			t.Fatalf("immediate %v: canceled a subscription changed since it was read", immediate)
+		}
+		if !reflect.DeepEqual(*subscriptions.subscription, loaded) {
+			t.Errorf("immediate %v: subscription = %+v, want it as loaded", immediate, *subscriptions.subscription)
+		}
+	}
+}
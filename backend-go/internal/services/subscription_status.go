@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+)
+
+var (
+	// errInvalidTransition is returned when an event is not allowed in a subscription's current status
+	errInvalidTransition = errors.New("invalid subscription status transition")
+	// errStatusConflict is returned when a subscription's status changed since it was loaded
+	errStatusConflict = errors.New("subscription status changed concurrently")
+)
+
+// transition applies a lifecycle event to a subscription: it moves the
+// subscription to the status the state machine allows for the event, and
+// saves it together with any other changes the caller made and the change
+// in its status history, in one transaction. Every status change of a
+// subscription goes through here. The save only applies while the
+// subscription is still in the status it was loaded in and unchanged since,
+// so of two concurrent changes the second fails with errStatusConflict
+// instead of undoing the first.
+func (s *SubscriptionService) transition(subscription *models.Subscription, event, reason string) error {
+	return s.transitionWithChange(subscription, event, reason, repository.SubscriptionChange{})
+}
+
+// transitionWithChange applies a lifecycle event like transition and writes
+// what else the change writes, such as the usage of the period it ends or
+// the invoice it issues, in the same transaction
+func (s *SubscriptionService) transitionWithChange(subscription *models.Subscription, event, reason string, change repository.SubscriptionChange) error {
+	return s.applyTransition(subscription, event, func(from string) (bool, error) {
+		return s.subscriptionRepo.Transition(subscription, from, s.statusChange(subscription, from, event, reason), change)
+	})
+}
+
+// transitionUpdatingInvoices applies a lifecycle event like transition and
+// saves the status changes of the invoices the change moves on in the same
+// transaction. It fails with errInvoiceStatusConflict, saving nothing, when
+// one of them changed since it was loaded.
+func (s *SubscriptionService) transitionUpdatingInvoices(subscription *models.Subscription, event, reason string, invoices []repository.InvoiceStatusUpdate) error {
+	err := s.applyTransition(subscription, event, func(from string) (bool, error) {
+		return s.subscriptionRepo.TransitionUpdatingInvoices(subscription, from, s.statusChange(subscription, from, event, reason), invoices)
+	})
+	if errors.Is(err, repository.ErrInvoiceStatusChanged) {
+		return errInvoiceStatusConflict
+	}
+	return err
+}
+
+// applyTransition moves a subscription to the status the state machine allows
+// for the event and saves it with save, leaving the subscription as it was
+// when the save fails or finds the subscription no longer in the status it
+// was loaded in. Callers that change other fields before the transition
+// restore them the same way, with restoreOnError.
+func (s *SubscriptionService) applyTransition(subscription *models.Subscription, event string, save func(from string) (bool, error)) (err error) {
+	from := subscription.Status
+	to, ok := models.NextSubscriptionStatus(from, event)
+	if !ok {
+		return errInvalidTransition
+	}
+
+	defer restoreOnError(subscription, *subscription, &err)
+	subscription.Status = to
+	updated, err := save(from)
+	if err == nil && !updated {
+		err = errStatusConflict
+	}
+	return err
+}
+
+// restoreOnError puts every field of subscription back to loaded when *err
+// is set. Deferred with a copy taken before a change, it keeps a change that
+// fails part way from leaving the subscription half changed.
+func restoreOnError(subscription *models.Subscription, loaded models.Subscription, err *error) {
+	if *err != nil {
+		*subscription = loaded
+	}
+}
+
+// GetStatusHistory returns the status transitions of a subscription, oldest first
+func (s *SubscriptionService) GetStatusHistory(subscriptionIDStr string, page, limit int) ([]*models.SubscriptionStatusChange, int64, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+
+	changes, err := s.historyRepo.GetBySubscriptionID(subscription.ID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.historyRepo.CountBySubscriptionID(subscription.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return changes, total, nil
+}
+
+// statusChange describes the move of a subscription from from to its current status
+func (s *SubscriptionService) statusChange(subscription *models.Subscription, from, event, reason string) *models.SubscriptionStatusChange {
+	return &models.SubscriptionStatusChange{
+		SubscriptionID: subscription.ID,
+		FromStatus:     from,
+		ToStatus:       subscription.Status,
+		Event:          event,
+		Reason:         reason,
+		CreatedAt:      s.now(&subscription.Organization),
+	}
+}
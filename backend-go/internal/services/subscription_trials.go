@@ -0,0 +1,256 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"go-backend/internal/models"
+	"go-backend/internal/notification"
+	"go-backend/internal/repository"
+
+	"golang.org/x/text/language"
+)
+
+// What happens to a trial that ends without a usable payment method
+const (
+	// TrialEndConvert converts the trial and invoices it anyway
+	TrialEndConvert = "convert"
+	// TrialEndExpire lets the subscription expire
+	TrialEndExpire = "expire"
+	// TrialEndCancel cancels the subscription
+	TrialEndCancel = "cancel"
+)
+
+// TrialPolicy configures the end of free trials
+type TrialPolicy struct {
+	WithoutPaymentMethod string // TrialEndConvert, TrialEndExpire or TrialEndCancel
+	ReminderDays         int    // days before the end to send a reminder; 0 disables reminders
+}
+
+// IsValidTrialEndAction reports whether action is a supported trial end policy
+func IsValidTrialEndAction(action string) bool {
+	switch action {
+	case TrialEndConvert, TrialEndExpire, TrialEndCancel:
+		return true
+	}
+	return false
+}
+
+// ProcessTrials ends every trial that is due and sends trial-ending reminders.
+// It is run periodically by the job scheduler.
+func (s *SubscriptionService) ProcessTrials() error {
+	converted, ended, err := s.ConvertEndedTrials()
+	if converted > 0 || ended > 0 {
+		log.Printf("Trials processed: %d converted, %d ended", converted, ended)
+	}
+
+	reminded, reminderErr := s.SendTrialReminders()
+	if reminded > 0 {
+		log.Printf("Trial reminders sent: %d", reminded)
+	}
+
+	return errors.Join(err, reminderErr)
+}
+
+// ConvertEndedTrials moves trials whose TrialEndDate has passed to active and
+// issues their first invoice. Trials without a usable payment method are
+// handled according to the trial policy. One failing subscription does not
+// stop the others.
+func (s *SubscriptionService) ConvertEndedTrials() (converted, ended int, err error) {
+	trials, err := s.subscriptionRepo.GetTrialsEndingBefore(s.clock.Now(), s.testClockID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, subscription := range trials {
+		// Trials canceled by the customer end without converting
+		if subscription.CancelAtPeriodEnd {
+			if err := s.endTrial(subscription, true, "canceled during trial"); err != nil {
+				errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+				continue
+			}
+			ended++
+			continue
+		}
+
+		hasPaymentMethod, err := s.hasUsablePaymentMethod(subscription)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+
+		if !hasPaymentMethod && s.trials.WithoutPaymentMethod != TrialEndConvert {
+			if err := s.endTrial(subscription, s.trials.WithoutPaymentMethod == TrialEndCancel, "no payment method"); err != nil {
+				errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+				continue
+			}
+			ended++
+			continue
+		}
+
+		if err := s.convertTrial(subscription); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		converted++
+	}
+
+	return converted, ended, errors.Join(errs...)
+}
+
+// SendTrialReminders notifies organizations whose trial ends within the
+// configured number of days. Each trial is reminded once.
+func (s *SubscriptionService) SendTrialReminders() (int, error) {
+	if s.trials.ReminderDays <= 0 {
+		return 0, nil
+	}
+
+	now := s.clock.Now()
+	trials, err := s.subscriptionRepo.GetTrialsEndingBefore(now.AddDate(0, 0, s.trials.ReminderDays), s.testClockID)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, subscription := range trials {
+		if subscription.TrialReminderAt != nil || !subscription.TrialEndDate.After(now) {
+			continue
+		}
+
+		hasPaymentMethod, err := s.hasUsablePaymentMethod(subscription)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+
+		plan := subscription.Plan
+		body := fmt.Sprintf("Your free trial of %s ends on %s.\n\n",
+			plan.Name, subscription.TrialEndDate.Format("January 2, 2006"))
+		switch {
+		case hasPaymentMethod:
+			body += fmt.Sprintf("Your subscription will then continue at %s per %s period.",
+				plan.Price.Format(language.English), plan.Interval)
+		case s.trials.WithoutPaymentMethod == TrialEndConvert:
+			body += fmt.Sprintf("Your subscription will then continue at %s per %s period and you will receive an invoice.",
+				plan.Price.Format(language.English), plan.Interval)
+		default:
+			body += "Add a payment method before then to keep your subscription."
+		}
+
+		if err := s.notifyOrganization(&subscription.Organization, "Your trial is ending soon", body); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+
+		subscription.TrialReminderAt = &now
+		if err := s.applyChange(subscription, subscription.UpdatedAt, repository.SubscriptionChange{}); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		sent++
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// convertTrial starts the first paid period at the end of the trial and
+// invoices it in the same transaction
+func (s *SubscriptionService) convertTrial(subscription *models.Subscription) (err error) {
+	defer restoreOnError(subscription, *subscription, &err)
+
+	// Apply a plan change that was scheduled for the end of the trial
+	if subscription.ScheduledPlanID != nil {
+		plan, err := s.planRepo.GetByID(*subscription.ScheduledPlanID)
+		if err != nil {
+			return err
+		}
+		subscription.PlanID = plan.ID
+		subscription.Plan = *plan
+		subscription.ScheduledPlanID = nil
+	}
+	plan := subscription.Plan
+
+	periodStart := *subscription.TrialEndDate
+	periodEnd, err := nextPeriodEnd(subscription, periodStart, plan.Interval)
+	if err != nil {
+		return err
+	}
+
+	subscription.CurrentPeriodStart = periodStart
+	subscription.CurrentPeriodEnd = periodEnd
+	subscription.EndDate = &periodEnd
+
+	invoice, err := s.subscriptionInvoice(subscription, &plan)
+	if err != nil {
+		return err
+	}
+	return s.transitionWithChange(subscription, models.SubscriptionEventTrialConverted, "", repository.SubscriptionChange{Invoice: invoice})
+}
+
+// endTrial expires or cancels a trial that ended without converting
+func (s *SubscriptionService) endTrial(subscription *models.Subscription, cancel bool, reason string) error {
+	loaded := *subscription
+	trialEnd := *subscription.TrialEndDate
+	subscription.EndDate = &trialEnd
+	subscription.AutoRenew = false
+	subscription.ScheduledPlanID = nil
+
+	event := models.SubscriptionEventTrialExpired
+	if cancel {
+		now := s.now(&subscription.Organization)
+		event = models.SubscriptionEventTrialCanceled
+		if subscription.CanceledAt == nil {
+			subscription.CanceledAt = &now
+		}
+	}
+
+	if err := s.transition(subscription, event, reason); err != nil {
+		*subscription = loaded
+		return err
+	}
+	if err := s.cancelActiveSchedule(subscription); err != nil {
+		log.Printf("Warning: failed to cancel schedule of subscription %s: %v", subscription.ID, err)
+	}
+
+	outcome := "and your subscription has ended"
+	if !subscription.CancelAtPeriodEnd {
+		outcome = "without a payment method on file, so your subscription has ended"
+	}
+	body := fmt.Sprintf("Your free trial of %s ended on %s %s.\n\nSubscribe again at any time to regain access.",
+		subscription.Plan.Name, trialEnd.Format("January 2, 2006"), outcome)
+	if err := s.notifyOrganization(&subscription.Organization, "Your trial has ended", body); err != nil {
+		log.Printf("Warning: failed to send trial end notice for subscription %s: %v", subscription.ID, err)
+	}
+	return nil
+}
+
+// hasUsablePaymentMethod reports whether the subscription's organization has an active, unexpired payment method
+func (s *SubscriptionService) hasUsablePaymentMethod(subscription *models.Subscription) (bool, error) {
+	paymentMethods, err := s.paymentMethodRepo.GetActiveByOrganizationID(subscription.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+	now := s.now(&subscription.Organization)
+	for _, paymentMethod := range paymentMethods {
+		if !paymentMethod.IsExpired(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notifyOrganization emails the organization's billing address
+func (s *SubscriptionService) notifyOrganization(org *models.Organization, subject, body string) error {
+	if org.Email == "" {
+		log.Printf("Organization %s has no email address; skipped %q", org.ID, subject)
+		return nil
+	}
+	return s.notifier.Send(&notification.Message{
+		To:      []string{org.Email},
+		Subject: subject,
+		Body:    body,
+	})
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// UsageReport is a subscription's metered usage in its current period
+type UsageReport struct {
+	SubscriptionID uuid.UUID    `json:"subscription_id"`
+	PeriodStart    time.Time    `json:"period_start"`
+	PeriodEnd      time.Time    `json:"period_end"`
+	AsOf           time.Time    `json:"as_of"`
+	Meters         []MeterUsage `json:"meters"`
+	Total          money.Money  `json:"total"` // what the usage so far would be billed at period close
+}
+
+// MeterUsage is the usage one metered price bills
+type MeterUsage struct {
+	MeteredPriceID   uuid.UUID     `json:"metered_price_id"`
+	Meter            *models.Meter `json:"meter"`
+	Quantity         string        `json:"quantity"`          // aggregated usage
+	IncludedUnits    int64         `json:"included_units"`    // free usage per period
+	BillableQuantity string        `json:"billable_quantity"` // usage above the included units
+	UnitAmount       money.Money   `json:"unit_amount"`
+	PackageSize      int64         `json:"package_size"`
+	Amount           money.Money   `json:"amount"`
+}
+
+// GetCurrentUsage reports the usage a subscription has recorded so far in its
+// current billing period and what it would be billed for it
+func (s *SubscriptionService) GetCurrentUsage(subscriptionIDStr string) (*UsageReport, error) {
+	subscription, err := s.getSubscription(subscriptionIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.usageReport(subscription)
+}
+
+// usageReport aggregates the usage of a subscription's current period so far
+func (s *SubscriptionService) usageReport(subscription *models.Subscription) (*UsageReport, error) {
+	prices, err := s.meterRepo.GetPricesByPlanID(subscription.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UsageReport{
+		SubscriptionID: subscription.ID,
+		PeriodStart:    subscription.CurrentPeriodStart,
+		PeriodEnd:      subscription.CurrentPeriodEnd,
+		AsOf:           s.now(&subscription.Organization),
+		Meters:         []MeterUsage{},
+		Total:          money.Zero(subscription.Plan.Currency),
+	}
+
+	for _, price := range prices {
+		quantity, err := s.usageRepo.Aggregate(&price.Meter, subscription.OrganizationID, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		billable, amount, err := usageCharge(price, quantity)
+		if err != nil {
+			return nil, err
+		}
+		if report.Total, err = report.Total.Add(amount); err != nil {
+			return nil, err
+		}
+
+		meter := price.Meter
+		report.Meters = append(report.Meters, MeterUsage{
+			MeteredPriceID:   price.ID,
+			Meter:            &meter,
+			Quantity:         formatQuantity(quantity),
+			IncludedUnits:    price.IncludedUnits,
+			BillableQuantity: billable,
+			UnitAmount:       price.UnitAmount,
+			PackageSize:      price.PackageSize,
+			Amount:           amount,
+		})
+	}
+
+	return report, nil
+}
+
+// usageClosing prices the metered usage of the current period up to end for
+// a change to bill and record as closed when it saves the subscription.
+// Usage that arrived for earlier closed periods after they were billed is
+// charged, or credited, on the same invoice. A period that is already closed
+// is not billed again, so this is safe to repeat. It returns nil when there
+// is nothing to close.
+func (s *SubscriptionService) usageClosing(subscription *models.Subscription, end time.Time) (*repository.UsageClosing, error) {
+	prices, err := s.meterRepo.GetPricesByPlanID(subscription.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := subscription.CurrentPeriodStart
+	closed, err := s.usageRepo.GetSummaries(subscription.ID, start)
+	if err != nil {
+		return nil, err
+	}
+	alreadyClosed := make(map[uuid.UUID]bool, len(closed))
+	for _, summary := range closed {
+		alreadyClosed[summary.MeteredPriceID] = true
+	}
+
+	now := s.now(&subscription.Organization)
+	var lines []PlanChangeLine
+	var billed, created, updated []*models.UsageSummary
+
+	for _, price := range prices {
+		if alreadyClosed[price.ID] {
+			continue
+		}
+
+		quantity, err := s.usageRepo.Aggregate(&price.Meter, subscription.OrganizationID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		billable, amount, err := usageCharge(price, quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		summary := &models.UsageSummary{
+			SubscriptionID: subscription.ID,
+			MeteredPriceID: price.ID,
+			MeterID:        price.MeterID,
+			PeriodStart:    start,
+			PeriodEnd:      end,
+			Quantity:       quantity,
+			Amount:         amount,
+			Currency:       amount.Currency(),
+			ClosedAt:       now,
+		}
+		created = append(created, summary)
+
+		if !amount.IsZero() {
+			lines = append(lines, PlanChangeLine{
+				Description:    usageLineDescription(price, billable),
+				Quantity:       1,
+				Amount:         amount,
+				PeriodStart:    start,
+				PeriodEnd:      end,
+				MeteredPriceID: &price.ID,
+			})
+			billed = append(billed, summary)
+		}
+	}
+
+	late, err := s.usageRepo.GetLateSummaries(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range late {
+		summary.MeteredPrice.Meter = summary.Meter
+		quantity, err := s.usageRepo.Aggregate(&summary.Meter, subscription.OrganizationID, summary.PeriodStart, summary.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		billable, amount, err := usageCharge(&summary.MeteredPrice, quantity)
+		if err != nil {
+			return nil, err
+		}
+		difference, err := amount.Sub(summary.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		summary.Quantity = quantity
+		summary.Amount = amount
+		summary.ClosedAt = now
+		updated = append(updated, summary)
+
+		if !difference.IsZero() {
+			lines = append(lines, PlanChangeLine{
+				Description:    "Late usage: " + usageLineDescription(&summary.MeteredPrice, billable),
+				Quantity:       1,
+				Amount:         difference,
+				PeriodStart:    summary.PeriodStart,
+				PeriodEnd:      summary.PeriodEnd,
+				MeteredPriceID: &summary.MeteredPriceID,
+			})
+			billed = append(billed, summary)
+		}
+	}
+
+	if len(created) == 0 && len(updated) == 0 {
+		return nil, nil
+	}
+
+	var invoice *models.Invoice
+	if len(lines) > 0 {
+		if invoice, err = adjustmentInvoice(subscription, lines, "Usage: "+subscription.Plan.Name, now); err != nil {
+			return nil, err
+		}
+		invoice.ID = uuid.New()
+		for _, summary := range billed {
+			summary.InvoiceID = &invoice.ID
+		}
+	}
+
+	return &repository.UsageClosing{Invoice: invoice, Created: created, Updated: updated}, nil
+}
+
+// usageCharge prices an aggregated quantity: the units above those included,
+// charged per started package. It returns the billable quantity and amount.
+func usageCharge(price *models.MeteredPrice, quantity string) (string, money.Money, error) {
+	units, ok := new(big.Rat).SetString(quantity)
+	if !ok {
+		return "", money.Money{}, fmt.Errorf("invalid usage quantity %q", quantity)
+	}
+
+	billable := units.Sub(units, big.NewRat(price.IncludedUnits, 1))
+	if billable.Sign() < 0 {
+		billable.SetInt64(0)
+	}
+
+	packages := new(big.Rat).Set(billable)
+	if price.PackageSize > 1 {
+		whole := new(big.Int).Add(billable.Num(), new(big.Int).Mul(big.NewInt(price.PackageSize), billable.Denom()))
+		whole.Sub(whole, big.NewInt(1))
+		whole.Quo(whole, new(big.Int).Mul(big.NewInt(price.PackageSize), billable.Denom()))
+		packages.SetInt(whole)
+	}
+
+	if !packages.Num().IsInt64() || !packages.Denom().IsInt64() {
+		return "", money.Money{}, errors.New("usage quantity is too large to bill")
+	}
+	amount, err := price.UnitAmount.MulRat(packages.Num().Int64(), packages.Denom().Int64(), prorationRounding)
+	if err != nil {
+		return "", money.Money{}, err
+	}
+	return formatRat(billable), amount, nil
+}
+
+// usageLineDescription labels the invoice line of a metered price
+func usageLineDescription(price *models.MeteredPrice, billable string) string {
+	label := price.Description
+	if label == "" {
+		label = price.Meter.Name
+	}
+	return label + " (" + billable + " units)"
+}
+
+// formatQuantity normalizes a decimal quantity read from the database
+func formatQuantity(quantity string) string {
+	value, ok := new(big.Rat).SetString(quantity)
+	if !ok {
+		return quantity
+	}
+	return formatRat(value)
+}
+
+// formatRat formats a quantity as a decimal without trailing zeros
+func formatRat(value *big.Rat) string {
+	if value.IsInt() {
+		return value.Num().String()
+	}
+	formatted := strings.TrimRight(value.FloatString(9), "0")
+	return strings.TrimSuffix(formatted, ".")
+}
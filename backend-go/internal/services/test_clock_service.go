@@ -0,0 +1,316 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/clock"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testClockMaxStep is the longest a test clock moves in one step. Billing jobs
+// run after every step, so work that is not tied to a due date, such as trial
+// reminders and seat syncing, happens at least daily as it would in real time.
+const testClockMaxStep = 24 * time.Hour
+
+// testClockMaxAdvance limits how far a clock moves in a single request
+const testClockMaxAdvance = 2 * 366 * 24 * time.Hour
+
+// TestClockService manages test clocks and drives the billing of their
+// sandbox organizations through simulated time
+type TestClockService struct {
+	testClockRepo    repository.TestClockRepository
+	orgRepo          repository.OrganizationRepository
+	subscriptionRepo repository.SubscriptionRepository
+	subscriptions    *SubscriptionService
+	invoices         *InvoiceService
+	usageAlerts      *UsageAlertService
+	dunning          *DunningService
+	billing          *BillingEngine
+	clock            clock.Clock
+}
+
+// NewTestClockService creates a new test clock service
+func NewTestClockService(
+	testClockRepo repository.TestClockRepository,
+	orgRepo repository.OrganizationRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	subscriptions *SubscriptionService,
+	invoices *InvoiceService,
+	usageAlerts *UsageAlertService,
+	dunning *DunningService,
+	billing *BillingEngine,
+	clk clock.Clock,
+) *TestClockService {
+	return &TestClockService{
+		testClockRepo:    testClockRepo,
+		orgRepo:          orgRepo,
+		subscriptionRepo: subscriptionRepo,
+		subscriptions:    subscriptions,
+		invoices:         invoices,
+		usageAlerts:      usageAlerts,
+		dunning:          dunning,
+		billing:          billing,
+		clock:            clk,
+	}
+}
+
+// CreateTestClockRequest represents test clock creation data
+type CreateTestClockRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	FrozenTime *time.Time `json:"frozen_time"` // defaults to now
+}
+
+// AdvanceTestClockRequest represents a request to move a test clock forward
+type AdvanceTestClockRequest struct {
+	FrozenTime time.Time `json:"frozen_time" binding:"required"`
+}
+
+// AttachOrganizationRequest represents a request to put an organization on a test clock
+type AttachOrganizationRequest struct {
+	OrganizationID string `json:"organization_id" binding:"required"`
+}
+
+// CreateTestClock creates a test clock frozen at the requested time
+func (s *TestClockService) CreateTestClock(req *CreateTestClockRequest) (*models.TestClock, error) {
+	frozenTime := s.clock.Now()
+	if req.FrozenTime != nil {
+		frozenTime = *req.FrozenTime
+	}
+
+	testClock := &models.TestClock{
+		Name:       req.Name,
+		FrozenTime: frozenTime,
+		Status:     models.TestClockStatusReady,
+	}
+	if err := s.testClockRepo.Create(testClock); err != nil {
+		return nil, err
+	}
+	return testClock, nil
+}
+
+// GetTestClock gets a test clock with its organizations
+func (s *TestClockService) GetTestClock(idStr string) (*models.TestClock, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errors.New("invalid test clock ID")
+	}
+
+	testClock, err := s.testClockRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("test clock not found")
+		}
+		return nil, err
+	}
+	return testClock, nil
+}
+
+// ListTestClocks lists test clocks with pagination, newest first
+func (s *TestClockService) ListTestClocks(page, limit int) ([]*models.TestClock, int64, error) {
+	offset := (page - 1) * limit
+
+	testClocks, err := s.testClockRepo.List(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.testClockRepo.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return testClocks, total, nil
+}
+
+// DeleteTestClock deletes a test clock that has no organizations left
+func (s *TestClockService) DeleteTestClock(idStr string) error {
+	testClock, err := s.GetTestClock(idStr)
+	if err != nil {
+		return err
+	}
+
+	if len(testClock.Organizations) > 0 {
+		return errors.New("test clock still has organizations")
+	}
+	return s.testClockRepo.Delete(testClock.ID)
+}
+
+// AttachOrganization puts a sandbox organization on a test clock. Only
+// organizations without subscriptions can be attached, so none of their
+// billing history was recorded in real time.
+func (s *TestClockService) AttachOrganization(idStr string, req *AttachOrganizationRequest) (*models.TestClock, error) {
+	testClock, err := s.GetTestClock(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	orgID, err := uuid.Parse(req.OrganizationID)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+
+	if org.TestClockID != nil {
+		return nil, errors.New("organization is already attached to a test clock")
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetByOrganizationID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) > 0 {
+		return nil, errors.New("organization already has subscriptions")
+	}
+
+	org.TestClockID = &testClock.ID
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, err
+	}
+
+	testClock.Organizations = append(testClock.Organizations, *org)
+	return testClock, nil
+}
+
+// AdvanceTestClock moves a test clock forward to the requested time. The
+// clock stops at every moment billing work falls due for its organizations,
+// and at least once a day, and runs the billing jobs at each stop, so trials
+// end, periods renew, invoices are charged and failed payments are retried in
+// the order and at the times they would in real time. An error stops the
+// clock where it failed.
+func (s *TestClockService) AdvanceTestClock(idStr string, req *AdvanceTestClockRequest) (*models.TestClock, error) {
+	testClock, err := s.GetTestClock(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.FrozenTime.After(testClock.FrozenTime) {
+		return nil, errors.New("test clock can only move forward")
+	}
+	if req.FrozenTime.Sub(testClock.FrozenTime) > testClockMaxAdvance {
+		return nil, errors.New("test clock cannot advance more than two years at once")
+	}
+
+	started, err := s.testClockRepo.StartAdvance(testClock.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, errors.New("test clock is already advancing")
+	}
+
+	advanceErr := s.advance(testClock, req.FrozenTime)
+
+	testClock.Status = models.TestClockStatusReady
+	if err := s.testClockRepo.Update(testClock); err != nil {
+		return nil, errors.Join(advanceErr, err)
+	}
+	if advanceErr != nil {
+		return nil, advanceErr
+	}
+	return testClock, nil
+}
+
+// advance steps a clock to target, running the billing jobs after each step
+func (s *TestClockService) advance(testClock *models.TestClock, target time.Time) error {
+	for testClock.FrozenTime.Before(target) {
+		step := testClock.FrozenTime.Add(testClockMaxStep)
+		if step.After(target) {
+			step = target
+		}
+
+		next, err := s.testClockRepo.NextEventAt(testClock.ID, testClock.FrozenTime, step)
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			step = *next
+		}
+
+		testClock.FrozenTime = step
+		if err := s.testClockRepo.Update(testClock); err != nil {
+			return err
+		}
+		if err := s.runJobs(testClock); err != nil {
+			log.Printf("Test clock %s stopped at %s: %v", testClock.ID, step.Format(time.RFC3339), err)
+			return err
+		}
+	}
+	return nil
+}
+
+// runJobs runs the background billing jobs for the organizations of a test
+// clock at its frozen time, in the order the job scheduler registers them
+func (s *TestClockService) runJobs(testClock *models.TestClock) error {
+	subscriptions := s.subscriptions.onTestClock(testClock)
+	invoices := s.invoices.onTestClock(testClock)
+	usageAlerts := s.usageAlerts.onTestClock(testClock)
+	dunning := s.dunning.onTestClock(testClock)
+	billing := s.billing.onTestClock(testClock)
+
+	return errors.Join(
+		subscriptions.ProcessTrials(),
+		subscriptions.ProcessSchedules(),
+		subscriptions.ProcessScheduledResumes(),
+		subscriptions.SyncSeats(),
+		billing.ProcessDue(),
+		invoices.ProcessOverdueInvoices(),
+		dunning.ProcessDunning(),
+		usageAlerts.ProcessAlerts(),
+	)
+}
+
+// onTestClock returns a copy of the engine that runs at the time of the
+// given test clock and only bills the organizations attached to it
+func (e *BillingEngine) onTestClock(testClock *models.TestClock) *BillingEngine {
+	scoped := *e
+	scoped.clock = clock.Fixed(testClock.FrozenTime)
+	scoped.testClockID = &testClock.ID
+	scoped.subscriptions = e.subscriptions.onTestClock(testClock)
+	return &scoped
+}
+
+// onTestClock returns a copy of the service that runs at the time of the
+// given test clock and only processes the organizations attached to it
+func (s *SubscriptionService) onTestClock(testClock *models.TestClock) *SubscriptionService {
+	scoped := *s
+	scoped.clock = clock.Fixed(testClock.FrozenTime)
+	scoped.testClockID = &testClock.ID
+	return &scoped
+}
+
+// now returns the current time of an organization: the frozen time of its
+// test clock when it is attached to one, otherwise the service clock
+func (s *SubscriptionService) now(org *models.Organization) time.Time {
+	if s.testClockID != nil {
+		return s.clock.Now()
+	}
+	return organizationNow(s.clock, s.testClockRepo, org)
+}
+
+// organizationNow returns the frozen time of the test clock an organization
+// is attached to, or the time of clk for organizations running on real time
+func organizationNow(clk clock.Clock, testClockRepo repository.TestClockRepository, org *models.Organization) time.Time {
+	if org.TestClockID == nil {
+		return clk.Now()
+	}
+
+	testClock, err := testClockRepo.GetByID(*org.TestClockID)
+	if err != nil {
+		log.Printf("Warning: failed to load test clock %s of organization %s: %v", *org.TestClockID, org.ID, err)
+		return clk.Now()
+	}
+	return testClock.FrozenTime
+}
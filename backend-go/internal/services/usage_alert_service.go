@@ -0,0 +1,611 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"go-backend/internal/models"
+	"go-backend/internal/repository"
+	"go-backend/pkg/currency"
+	"go-backend/pkg/money"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// defaultAlertThresholds are the percentages of the limit an alert warns at
+// unless it names its own
+var defaultAlertThresholds = models.Percentages{50, 80, 100}
+
+// UsageAlertService manages usage and spend alerts and evaluates them against
+// the current billing period of an organization's subscriptions
+type UsageAlertService struct {
+	alertRepo        repository.UsageAlertRepository
+	meterRepo        repository.MeterRepository
+	subscriptionRepo repository.SubscriptionRepository
+	orgRepo          repository.OrganizationRepository
+	subscriptions    *SubscriptionService
+	testClockID      *uuid.UUID // set on copies that process the organizations of one test clock
+}
+
+// NewUsageAlertService creates a new usage alert service
+func NewUsageAlertService(
+	alertRepo repository.UsageAlertRepository,
+	meterRepo repository.MeterRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	orgRepo repository.OrganizationRepository,
+	subscriptions *SubscriptionService,
+) *UsageAlertService {
+	return &UsageAlertService{
+		alertRepo:        alertRepo,
+		meterRepo:        meterRepo,
+		subscriptionRepo: subscriptionRepo,
+		orgRepo:          orgRepo,
+		subscriptions:    subscriptions,
+	}
+}
+
+// CreateUsageAlertRequest represents usage alert creation data
+type CreateUsageAlertRequest struct {
+	Name       string `json:"name" binding:"required,min=2,max=100"`
+	Type       string `json:"type" binding:"required,oneof=usage spend"`
+	MeterID    string `json:"meter_id"`                              // usage alerts: the meter whose quantity is watched
+	Limit      string `json:"limit" binding:"required"`              // decimal quantity, or amount in currency for spend alerts
+	Currency   string `json:"currency"`                              // spend alerts: currency of the limit
+	Thresholds []int  `json:"thresholds" binding:"omitempty,max=10"` // percentages of the limit; defaults to 50, 80 and 100
+	HardCap    bool   `json:"hard_cap"`                              // report the limit as reached at 100%
+}
+
+// UpdateUsageAlertRequest represents usage alert update data. Thresholds
+// already crossed in the current period are not announced again.
+type UpdateUsageAlertRequest struct {
+	Name       *string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Limit      *string `json:"limit,omitempty"`
+	Thresholds []int   `json:"thresholds,omitempty" binding:"omitempty,max=10"`
+	HardCap    *bool   `json:"hard_cap,omitempty"`
+	IsActive   *bool   `json:"is_active,omitempty"`
+}
+
+// UsageLimitStatus is how far an alert's usage or spend is towards its limit
+// in a subscription's current period
+type UsageLimitStatus struct {
+	UsageAlertID   uuid.UUID `json:"usage_alert_id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	Value          string    `json:"value"`
+	Limit          string    `json:"limit"`
+	Currency       string    `json:"currency,omitempty"`
+	Percent        string    `json:"percent"` // value as a percentage of the limit
+	HardCap        bool      `json:"hard_cap"`
+	LimitReached   bool      `json:"limit_reached"` // hard-capped and at or over the limit
+}
+
+// UsageLimitsResponse answers an entitlement check against an
+// organization's usage alerts
+type UsageLimitsResponse struct {
+	OrganizationID uuid.UUID          `json:"organization_id"`
+	AsOf           time.Time          `json:"as_of"`
+	LimitReached   bool               `json:"limit_reached"` // some hard cap is reached
+	Limits         []UsageLimitStatus `json:"limits"`
+}
+
+// CreateAlert creates a usage or spend alert for an organization
+func (s *UsageAlertService) CreateAlert(orgIDStr string, req *CreateUsageAlertRequest) (*models.UsageAlert, error) {
+	org, err := s.getOrganization(orgIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	alert := &models.UsageAlert{
+		OrganizationID: org.ID,
+		Name:           req.Name,
+		Type:           req.Type,
+		HardCap:        req.HardCap,
+		IsActive:       true,
+	}
+
+	switch req.Type {
+	case models.UsageAlertTypeUsage:
+		if req.MeterID == "" {
+			return nil, errors.New("usage alerts require a meter")
+		}
+		if req.Currency != "" {
+			return nil, errors.New("usage alerts cannot have a currency")
+		}
+		meterID, err := uuid.Parse(req.MeterID)
+		if err != nil {
+			return nil, errors.New("invalid meter ID")
+		}
+		meter, err := s.meterRepo.GetByID(meterID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("meter not found")
+			}
+			return nil, err
+		}
+		alert.MeterID = &meter.ID
+		alert.Meter = meter
+	case models.UsageAlertTypeSpend:
+		if req.MeterID != "" {
+			return nil, errors.New("spend alerts cannot have a meter")
+		}
+		code := strings.ToUpper(req.Currency)
+		if code == "" {
+			return nil, errors.New("spend alerts require a currency")
+		}
+		if !currency.IsValid(code) {
+			return nil, errors.New("invalid currency")
+		}
+		alert.Currency = code
+	default:
+		return nil, errors.New("invalid alert type")
+	}
+
+	if alert.Limit, err = alertLimit(req.Limit, alert.Currency); err != nil {
+		return nil, err
+	}
+	if alert.Thresholds, err = alertThresholds(req.Thresholds); err != nil {
+		return nil, err
+	}
+
+	if err := s.alertRepo.Create(alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetAlerts lists the usage alerts of an organization
+func (s *UsageAlertService) GetAlerts(orgIDStr string) ([]*models.UsageAlert, error) {
+	org, err := s.getOrganization(orgIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.alertRepo.GetByOrganizationID(org.ID)
+}
+
+// UpdateAlert changes the name, limit, thresholds, hard cap or active flag of
+// an organization's usage alert. What an alert watches is fixed once created.
+func (s *UsageAlertService) UpdateAlert(orgIDStr, alertIDStr string, req *UpdateUsageAlertRequest) (*models.UsageAlert, error) {
+	alert, err := s.getAlert(orgIDStr, alertIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		alert.Name = *req.Name
+	}
+	if req.Limit != nil {
+		if alert.Limit, err = alertLimit(*req.Limit, alert.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if req.Thresholds != nil {
+		if alert.Thresholds, err = alertThresholds(req.Thresholds); err != nil {
+			return nil, err
+		}
+	}
+	if req.HardCap != nil {
+		alert.HardCap = *req.HardCap
+	}
+	if req.IsActive != nil {
+		alert.IsActive = *req.IsActive
+	}
+
+	if err := s.alertRepo.Update(alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// DeleteAlert deletes an organization's usage alert; its triggers are kept
+func (s *UsageAlertService) DeleteAlert(orgIDStr, alertIDStr string) error {
+	alert, err := s.getAlert(orgIDStr, alertIDStr)
+	if err != nil {
+		return err
+	}
+	return s.alertRepo.Delete(alert.ID)
+}
+
+// GetTriggers lists the thresholds an organization's alerts crossed, newest first
+func (s *UsageAlertService) GetTriggers(orgIDStr string, page, limit int) ([]*models.UsageAlertTrigger, int64, error) {
+	org, err := s.getOrganization(orgIDStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * limit
+
+	triggers, err := s.alertRepo.GetTriggersByOrganizationID(org.ID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.alertRepo.CountTriggersByOrganizationID(org.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return triggers, total, nil
+}
+
+// CheckLimits reports how far an organization is towards the limits of its
+// active alerts in the current period of each subscription they apply to,
+// and whether a hard cap has been reached. It reads usage as it is now
+// rather than waiting for the alerts job.
+func (s *UsageAlertService) CheckLimits(orgIDStr string) (*UsageLimitsResponse, error) {
+	org, err := s.getOrganization(orgIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := s.alertRepo.GetActiveByOrganizationID(org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &UsageLimitsResponse{
+		OrganizationID: org.ID,
+		AsOf:           s.subscriptions.now(org),
+		Limits:         []UsageLimitStatus{},
+	}
+
+	err = s.measureOrganization(org, alerts, func(alert *models.UsageAlert, subscription *models.Subscription, value *big.Rat, display string) error {
+		status := limitStatus(alert, subscription, value)
+		response.LimitReached = response.LimitReached || status.LimitReached
+		response.Limits = append(response.Limits, status)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ProcessAlerts evaluates every active alert and announces the thresholds
+// crossed since the last run. Each threshold is announced once per period.
+// It is run periodically by the job scheduler.
+func (s *UsageAlertService) ProcessAlerts() error {
+	alerts, err := s.alertRepo.GetActive(s.testClockID)
+	if err != nil {
+		return err
+	}
+
+	// Alerts are ordered by organization, so each organization's
+	// subscriptions and usage are read once
+	triggered := 0
+	var errs []error
+	for start := 0; start < len(alerts); {
+		end := start + 1
+		for end < len(alerts) && alerts[end].OrganizationID == alerts[start].OrganizationID {
+			end++
+		}
+		org := alerts[start].Organization
+
+		n, err := s.evaluateOrganization(&org, alerts[start:end])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("organization %s: %w", org.ID, err))
+		}
+		triggered += n
+		start = end
+	}
+
+	if triggered > 0 {
+		log.Printf("Usage alerts triggered: %d", triggered)
+	}
+	return errors.Join(errs...)
+}
+
+// evaluateOrganization records and announces the thresholds an
+// organization's alerts have crossed and returns how many were recorded
+func (s *UsageAlertService) evaluateOrganization(org *models.Organization, alerts []*models.UsageAlert) (int, error) {
+	now := s.subscriptions.now(org)
+	triggered := 0
+	var errs []error
+
+	err := s.measureOrganization(org, alerts, func(alert *models.UsageAlert, subscription *models.Subscription, value *big.Rat, display string) error {
+		var crossed []*models.UsageAlertTrigger
+		for _, threshold := range alert.Thresholds {
+			if !thresholdReached(value, alert.Limit, threshold) {
+				continue
+			}
+			trigger := &models.UsageAlertTrigger{
+				UsageAlertID:   alert.ID,
+				SubscriptionID: subscription.ID,
+				PeriodStart:    subscription.CurrentPeriodStart,
+				Threshold:      threshold,
+				OrganizationID: org.ID,
+				PeriodEnd:      subscription.CurrentPeriodEnd,
+				Value:          formatRat(value),
+				Limit:          alert.Limit,
+				Currency:       alert.Currency,
+				TriggeredAt:    now,
+			}
+			created, err := s.alertRepo.CreateTrigger(trigger)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: %w", alert.ID, err))
+				continue
+			}
+			if created {
+				crossed = append(crossed, trigger)
+			}
+		}
+		if len(crossed) == 0 {
+			return nil
+		}
+		triggered += len(crossed)
+
+		// Thresholds crossed together are announced in one email, for the highest
+		highest := crossed[len(crossed)-1]
+		subject, body := alertMessage(alert, subscription, highest, display)
+		if err := s.subscriptions.notifyOrganization(org, subject, body); err != nil {
+			log.Printf("Warning: failed to send usage alert %s for subscription %s: %v", alert.ID, subscription.ID, err)
+			return nil
+		}
+		for _, trigger := range crossed {
+			trigger.NotifiedAt = &now
+			if err := s.alertRepo.UpdateTrigger(trigger); err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: %w", alert.ID, err))
+			}
+		}
+		return nil
+	})
+
+	return triggered, errors.Join(append(errs, err)...)
+}
+
+// measureOrganization measures each alert against the current period of
+// every entitled subscription of the organization it applies to. Usage
+// alerts apply to subscriptions whose plan prices their meter, spend alerts
+// to paid subscriptions in their currency. The value is passed to fn
+// together with a formatted form for messages.
+func (s *UsageAlertService) measureOrganization(org *models.Organization, alerts []*models.UsageAlert, fn func(alert *models.UsageAlert, subscription *models.Subscription, value *big.Rat, display string) error) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	subscriptions, err := s.subscriptionRepo.GetActiveByOrganizationID(org.ID, s.subscriptions.now(org))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		subscription.Organization = *org
+		report, err := s.subscriptions.usageReport(subscription)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", subscription.ID, err))
+			continue
+		}
+
+		for _, alert := range alerts {
+			value, display, applies, err := alertValue(alert, subscription, report)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: %w", alert.ID, err))
+				continue
+			}
+			if !applies {
+				continue
+			}
+			if err := fn(alert, subscription, value, display); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// alertValue measures what an alert watches in a subscription's current
+// period: the meter's quantity, or the period's recurring charge plus the
+// usage charged so far. It reports false when the alert does not apply.
+func alertValue(alert *models.UsageAlert, subscription *models.Subscription, report *UsageReport) (*big.Rat, string, bool, error) {
+	switch alert.Type {
+	case models.UsageAlertTypeUsage:
+		for _, usage := range report.Meters {
+			if alert.MeterID == nil || usage.Meter.ID != *alert.MeterID {
+				continue
+			}
+			value, ok := new(big.Rat).SetString(usage.Quantity)
+			if !ok {
+				return nil, "", false, fmt.Errorf("invalid usage quantity %q", usage.Quantity)
+			}
+			return value, usage.Quantity, true, nil
+		}
+		return nil, "", false, nil
+
+	case models.UsageAlertTypeSpend:
+		plan := subscription.Plan
+		if plan.Currency != alert.Currency || subscription.Status == models.SubscriptionStatusTrialing {
+			return nil, "", false, nil
+		}
+		subtotal, err := periodPrice(subscription, &plan, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd).
+			Mul(int64(subscriptionQuantity(subscription)))
+		if err != nil {
+			return nil, "", false, err
+		}
+		discount, err := subtotal.Percent(int64(subscription.PeriodPercentOff())*100, prorationRounding)
+		if err != nil {
+			return nil, "", false, err
+		}
+		recurring, err := subtotal.Sub(discount)
+		if err != nil {
+			return nil, "", false, err
+		}
+		total, err := recurring.Add(report.Total)
+		if err != nil {
+			return nil, "", false, err
+		}
+		value, ok := new(big.Rat).SetString(total.Decimal())
+		if !ok {
+			return nil, "", false, fmt.Errorf("invalid amount %q", total.Decimal())
+		}
+		return value, total.Format(language.English), true, nil
+	}
+
+	return nil, "", false, nil
+}
+
+// limitStatus describes an alert's value against its limit
+func limitStatus(alert *models.UsageAlert, subscription *models.Subscription, value *big.Rat) UsageLimitStatus {
+	limit, _ := new(big.Rat).SetString(alert.Limit)
+	percent := new(big.Rat).Mul(value, big.NewRat(100, 1))
+	if limit != nil && limit.Sign() > 0 {
+		percent.Quo(percent, limit)
+	}
+
+	return UsageLimitStatus{
+		UsageAlertID:   alert.ID,
+		Name:           alert.Name,
+		Type:           alert.Type,
+		SubscriptionID: subscription.ID,
+		PeriodStart:    subscription.CurrentPeriodStart,
+		PeriodEnd:      subscription.CurrentPeriodEnd,
+		Value:          formatRat(value),
+		Limit:          alert.Limit,
+		Currency:       alert.Currency,
+		Percent:        formatPercent(percent),
+		HardCap:        alert.HardCap,
+		LimitReached:   alert.HardCap && thresholdReached(value, alert.Limit, 100),
+	}
+}
+
+// alertMessage writes the email announcing a crossed threshold
+func alertMessage(alert *models.UsageAlert, subscription *models.Subscription, trigger *models.UsageAlertTrigger, display string) (string, string) {
+	subject := fmt.Sprintf("Usage alert: %s reached %d%% of its limit", alert.Name, trigger.Threshold)
+
+	var body string
+	if alert.Type == models.UsageAlertTypeSpend {
+		limit := alert.Limit
+		if amount, err := money.Parse(alert.Limit, alert.Currency); err == nil {
+			limit = amount.Format(language.English)
+		}
+		body = fmt.Sprintf("The charges for your %s subscription this billing period have reached %s, %d%% of your %s budget.",
+			subscription.Plan.Name, display, trigger.Threshold, limit)
+	} else {
+		meterName := "metered usage"
+		if alert.Meter != nil {
+			meterName = alert.Meter.Name
+		}
+		body = fmt.Sprintf("Your %s this billing period has reached %s, %d%% of your limit of %s.",
+			meterName, display, trigger.Threshold, alert.Limit)
+	}
+	body += fmt.Sprintf("\n\nThe billing period ends on %s.", subscription.CurrentPeriodEnd.Format("January 2, 2006"))
+
+	if alert.HardCap && trigger.Threshold >= 100 {
+		body += " This is a hard limit: your usage is reported as over its limit until the period ends or the limit is raised."
+	}
+	return subject, body
+}
+
+// thresholdReached reports whether value is at least threshold percent of limit
+func thresholdReached(value *big.Rat, limit string, threshold int) bool {
+	max, ok := new(big.Rat).SetString(limit)
+	if !ok {
+		return false
+	}
+	scaled := new(big.Rat).Mul(value, big.NewRat(100, 1))
+	return scaled.Cmp(max.Mul(max, big.NewRat(int64(threshold), 1))) >= 0
+}
+
+// alertLimit validates a limit: a positive decimal, with no more decimal
+// places than the currency has when it is an amount
+func alertLimit(value, code string) (string, error) {
+	limit, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || limit.Sign() <= 0 {
+		return "", errors.New("alert limit must be a positive number")
+	}
+	if code != "" {
+		amount, err := money.Parse(strings.TrimSpace(value), code)
+		if err != nil {
+			return "", errors.New("alert limit must be a positive number")
+		}
+		return amount.Decimal(), nil
+	}
+	return formatRat(limit), nil
+}
+
+// alertThresholds validates threshold percentages and sorts them
+func alertThresholds(thresholds []int) (models.Percentages, error) {
+	if len(thresholds) == 0 {
+		return append(models.Percentages{}, defaultAlertThresholds...), nil
+	}
+
+	seen := make(map[int]bool, len(thresholds))
+	result := make(models.Percentages, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		if threshold < 1 || threshold > 1000 {
+			return nil, errors.New("alert thresholds must be between 1 and 1000 percent")
+		}
+		if !seen[threshold] {
+			seen[threshold] = true
+			result = append(result, threshold)
+		}
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// formatPercent formats a percentage with at most two decimal places
+func formatPercent(value *big.Rat) string {
+	formatted := strings.TrimRight(value.FloatString(2), "0")
+	return strings.TrimSuffix(formatted, ".")
+}
+
+// getOrganization parses an organization ID and loads the organization
+func (s *UsageAlertService) getOrganization(orgIDStr string) (*models.Organization, error) {
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+// getAlert loads a usage alert of an organization
+func (s *UsageAlertService) getAlert(orgIDStr, alertIDStr string) (*models.UsageAlert, error) {
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+	alertID, err := uuid.Parse(alertIDStr)
+	if err != nil {
+		return nil, errors.New("invalid usage alert ID")
+	}
+
+	alert, err := s.alertRepo.GetByID(alertID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("usage alert not found")
+		}
+		return nil, err
+	}
+	if alert.OrganizationID != orgID {
+		return nil, errors.New("usage alert not found")
+	}
+	return alert, nil
+}
+
+// onTestClock returns a copy of the service that runs at the time of the
+// given test clock and only processes the organizations attached to it
+func (s *UsageAlertService) onTestClock(testClock *models.TestClock) *UsageAlertService {
+	scoped := *s
+	scoped.testClockID = &testClock.ID
+	scoped.subscriptions = s.subscriptions.onTestClock(testClock)
+	return &scoped
+}
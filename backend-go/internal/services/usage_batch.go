@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/internal/ingest"
+	"go-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxBatchErrors limits the rejected events reported back for one batch
+const maxBatchErrors = 100
+
+// BatchUsageError describes a rejected event of a batch
+type BatchUsageError struct {
+	Index   int    `json:"index"` // position of the event in the request, from 0
+	EventID string `json:"event_id,omitempty"`
+	Error   string `json:"error"`
+}
+
+// BatchUsageResult summarizes a batch ingestion request
+type BatchUsageResult struct {
+	Received int               `json:"received"`
+	Accepted int               `json:"accepted"` // queued for writing; event IDs already recorded are skipped when written
+	Rejected int               `json:"rejected"`
+	Errors   []BatchUsageError `json:"errors"` // the first rejections
+}
+
+// UsageBatch validates the events of one batch request and queues them on
+// the ingestion pipeline in chunks. Meters and organizations are looked up
+// once per batch instead of once per event.
+type UsageBatch struct {
+	service    *MeteringService
+	restrictTo *uuid.UUID // the only organization the caller may report usage for
+	meters     map[string][]*models.Meter
+	orgs       map[uuid.UUID]*time.Time // ingestion time of each organization seen; nil when it does not exist
+	chunk      []*models.UsageEvent
+	Result     BatchUsageResult
+}
+
+// NewUsageBatch starts a batch. A non-empty organizationID limits the batch
+// to that organization and is used for events that name none.
+func (s *MeteringService) NewUsageBatch(organizationID string) (*UsageBatch, error) {
+	batch := &UsageBatch{
+		service: s,
+		meters:  map[string][]*models.Meter{},
+		orgs:    map[uuid.UUID]*time.Time{},
+		chunk:   make([]*models.UsageEvent, 0, s.pipeline.Config().BatchSize),
+		Result:  BatchUsageResult{Errors: []BatchUsageError{}},
+	}
+
+	if organizationID != "" {
+		orgID, err := uuid.Parse(organizationID)
+		if err != nil {
+			return nil, errors.New("invalid organization ID")
+		}
+		batch.restrictTo = &orgID
+	}
+
+	meters, err := s.meterRepo.GetActive()
+	if err != nil {
+		return nil, err
+	}
+	for _, meter := range meters {
+		batch.meters[meter.EventName] = append(batch.meters[meter.EventName], meter)
+	}
+
+	return batch, nil
+}
+
+// RetryAfter is how long callers turned away by a busy pipeline should wait
+func (s *MeteringService) RetryAfter() time.Duration {
+	return s.usage.RetryAfter
+}
+
+// Add validates the event at index and queues it with the events before it
+// once a chunk is full. Invalid events are rejected without stopping the
+// batch; an error means the batch cannot continue.
+func (b *UsageBatch) Add(index int, req *RecordUsageRequest) error {
+	if max := b.service.usage.MaxRequestEvents; max > 0 && b.Result.Received >= max {
+		return errors.New("usage batch has too many events")
+	}
+	b.Result.Received++
+
+	event, err := b.event(req)
+	if err != nil {
+		if !isUsageRejection(err) {
+			return err
+		}
+		b.reject(index, req.EventID, err)
+		return nil
+	}
+
+	b.chunk = append(b.chunk, event)
+	if len(b.chunk) >= cap(b.chunk) {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Reject records an event of the batch that could not be read
+func (b *UsageBatch) Reject(index int, eventID string, err error) {
+	b.Result.Received++
+	b.reject(index, eventID, err)
+}
+
+// reject records a received event that could not be accepted
+func (b *UsageBatch) reject(index int, eventID string, err error) {
+	b.Result.Rejected++
+	if len(b.Result.Errors) < maxBatchErrors {
+		b.Result.Errors = append(b.Result.Errors, BatchUsageError{Index: index, EventID: eventID, Error: err.Error()})
+	}
+}
+
+// Flush queues the events validated since the last flush. When the pipeline
+// is full none of them are queued and "usage ingestion is busy" is returned.
+func (b *UsageBatch) Flush() error {
+	if len(b.chunk) == 0 {
+		return nil
+	}
+
+	if err := b.service.pipeline.Submit(b.chunk); err != nil {
+		switch {
+		case errors.Is(err, ingest.ErrQueueFull):
+			return errors.New("usage ingestion is busy")
+		case errors.Is(err, ingest.ErrClosed):
+			return errors.New("usage ingestion is shutting down")
+		}
+		return err
+	}
+
+	b.Result.Accepted += len(b.chunk)
+	b.chunk = make([]*models.UsageEvent, 0, cap(b.chunk))
+	return nil
+}
+
+// event validates a request and builds its usage event
+func (b *UsageBatch) event(req *RecordUsageRequest) (*models.UsageEvent, error) {
+	if req.EventID == "" || len(req.EventID) > 255 {
+		return nil, errors.New("usage event ID must be 1 to 255 characters")
+	}
+	if req.EventName == "" || len(req.EventName) > 100 {
+		return nil, errors.New("usage event name must be 1 to 100 characters")
+	}
+
+	var orgID uuid.UUID
+	switch {
+	case req.OrganizationID == "" && b.restrictTo != nil:
+		orgID = *b.restrictTo
+	default:
+		parsed, err := uuid.Parse(req.OrganizationID)
+		if err != nil {
+			return nil, errors.New("invalid organization ID")
+		}
+		orgID = parsed
+	}
+	if b.restrictTo != nil && orgID != *b.restrictTo {
+		return nil, errors.New("access denied for this organization")
+	}
+
+	now, err := b.organizationNow(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return usageEvent(req, orgID, b.meters[req.EventName], now)
+}
+
+// organizationNow returns the ingestion time of an organization, loading it
+// the first time the batch sees it
+func (b *UsageBatch) organizationNow(orgID uuid.UUID) (time.Time, error) {
+	now, seen := b.orgs[orgID]
+	if !seen {
+		org, err := b.service.orgRepo.GetByID(orgID)
+		switch {
+		case err == nil:
+			t := organizationNow(b.service.clock, b.service.testClockRepo, org)
+			now = &t
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return time.Time{}, err
+		}
+		b.orgs[orgID] = now
+	}
+
+	if now == nil {
+		return time.Time{}, errors.New("organization not found")
+	}
+	return *now, nil
+}
+
+// isUsageRejection reports whether err rejects a single event rather than
+// failing the whole batch
+func isUsageRejection(err error) bool {
+	switch err.Error() {
+	case "usage event ID must be 1 to 255 characters",
+		"usage event name must be 1 to 100 characters",
+		"invalid organization ID",
+		"access denied for this organization",
+		"organization not found",
+		"no active meter for this event",
+		"usage event is missing the meter value property",
+		"usage event value property must be a number",
+		"usage event timestamp is in the future":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+-- Rollback migration 004_subscription_status_machine
+
+DROP INDEX IF EXISTS idx_subscription_status_history_created_at;
+DROP INDEX IF EXISTS idx_subscription_status_history_subscription;
+DROP TABLE IF EXISTS subscription_status_history;
+
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_status_check;
+UPDATE subscriptions SET status = 'suspended' WHERE status = 'paused';
+UPDATE subscriptions SET status = 'active' WHERE status IN ('trialing', 'past_due');
+UPDATE subscriptions SET status = 'expired' WHERE status = 'incomplete';
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check
+    CHECK (status IN ('active', 'canceled', 'expired', 'suspended'));
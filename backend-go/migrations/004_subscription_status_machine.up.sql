@@ -0,0 +1,21 @@
+-- Align subscription statuses with the subscription state machine and record status history
+
+-- The status constraint created in 001 does not match the statuses the application writes
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_status_check;
+UPDATE subscriptions SET status = 'paused' WHERE status = 'suspended';
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check
+    CHECK (status IN ('incomplete', 'trialing', 'active', 'past_due', 'paused', 'canceled', 'expired'));
+
+-- Create subscription_status_history table
+CREATE TABLE IF NOT EXISTS subscription_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_status_history_subscription ON subscription_status_history(subscription_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_status_history_created_at ON subscription_status_history(created_at);
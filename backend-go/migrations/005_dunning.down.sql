@@ -0,0 +1,6 @@
+-- Rollback migration 005_dunning
+
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_status_check;
+UPDATE subscriptions SET status = 'past_due' WHERE status = 'suspended';
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check
+    CHECK (status IN ('incomplete', 'trialing', 'active', 'past_due', 'paused', 'canceled', 'expired'));
@@ -0,0 +1,5 @@
+-- Allow subscriptions to be suspended by dunning once every payment retry failed
+
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_status_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check
+    CHECK (status IN ('incomplete', 'trialing', 'active', 'past_due', 'suspended', 'paused', 'canceled', 'expired'));
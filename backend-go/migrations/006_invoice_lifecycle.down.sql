@@ -0,0 +1,13 @@
+-- Rollback migration 006_invoice_lifecycle
+
+ALTER TABLE invoices
+    DROP COLUMN IF EXISTS voided_at,
+    DROP COLUMN IF EXISTS sent_at,
+    DROP COLUMN IF EXISTS finalized_at;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+UPDATE invoices SET status = 'pending' WHERE status IN ('draft', 'open', 'sent', 'overdue');
+UPDATE invoices SET status = 'failed' WHERE status = 'uncollectible';
+UPDATE invoices SET status = 'canceled' WHERE status = 'void';
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('pending', 'paid', 'failed', 'canceled'));
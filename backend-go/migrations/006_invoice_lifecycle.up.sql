@@ -0,0 +1,14 @@
+-- Align invoice statuses with the invoice state machine
+
+-- The status constraint created in 001 does not match the statuses the application writes
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+UPDATE invoices SET status = 'open' WHERE status = 'pending';
+UPDATE invoices SET status = 'uncollectible' WHERE status = 'failed';
+UPDATE invoices SET status = 'void' WHERE status = 'canceled';
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('draft', 'open', 'sent', 'paid', 'overdue', 'void', 'uncollectible'));
+
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS finalized_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS sent_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMP;
@@ -0,0 +1,6 @@
+-- Rollback migration 007_invoice_sequences
+
+DROP INDEX IF EXISTS idx_organizations_invoice_sequence_id;
+ALTER TABLE organizations DROP COLUMN IF EXISTS invoice_sequence_id;
+
+DROP TABLE IF EXISTS invoice_sequences;
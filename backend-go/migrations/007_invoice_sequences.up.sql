@@ -0,0 +1,25 @@
+-- Number invoices from configurable, gapless sequences
+
+-- Create invoice_sequences table
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    padding INTEGER NOT NULL DEFAULT 0 CHECK (padding >= 0),
+    reset_yearly BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    next_number BIGINT NOT NULL DEFAULT 1 CHECK (next_number >= 1),
+    year INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_sequences_prefix ON invoice_sequences(prefix);
+-- At most one sequence numbers the invoices of organizations without their own
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_sequences_default ON invoice_sequences(is_default) WHERE is_default;
+
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS invoice_sequence_id UUID REFERENCES invoice_sequences(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_organizations_invoice_sequence_id ON organizations(invoice_sequence_id);
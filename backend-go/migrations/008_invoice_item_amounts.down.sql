@@ -0,0 +1,10 @@
+-- Rollback migration 008_invoice_item_amounts
+
+ALTER TABLE invoice_items
+    ALTER COLUMN unit_price TYPE DECIMAL(10,2),
+    ALTER COLUMN amount TYPE DECIMAL(10,2);
+
+-- Credit lines written since the migration would fail the checks, so they
+-- are only enforced for new rows
+ALTER TABLE invoice_items ADD CONSTRAINT invoice_items_unit_price_check CHECK (unit_price >= 0) NOT VALID;
+ALTER TABLE invoice_items ADD CONSTRAINT invoice_items_amount_check CHECK (amount >= 0) NOT VALID;
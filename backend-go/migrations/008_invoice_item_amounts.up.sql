@@ -0,0 +1,10 @@
+-- Allow negative invoice lines, such as proration credits and late usage corrections
+
+-- The checks created in 002 reject credit lines the application writes
+ALTER TABLE invoice_items DROP CONSTRAINT IF EXISTS invoice_items_unit_price_check;
+ALTER TABLE invoice_items DROP CONSTRAINT IF EXISTS invoice_items_amount_check;
+
+-- Store line amounts at the precision of the Money type
+ALTER TABLE invoice_items
+    ALTER COLUMN unit_price TYPE NUMERIC(19,4),
+    ALTER COLUMN amount TYPE NUMERIC(19,4);
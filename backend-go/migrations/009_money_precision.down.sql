@@ -0,0 +1,11 @@
+-- Rollback migration 009_money_precision
+
+-- Only the columns created by the SQL migrations are narrowed again; amounts
+-- with more than two decimals are rounded and larger amounts fail the rollback
+ALTER TABLE plans ALTER COLUMN price TYPE DECIMAL(10,2);
+
+ALTER TABLE invoices
+    ALTER COLUMN subtotal TYPE DECIMAL(10,2),
+    ALTER COLUMN tax_amount TYPE DECIMAL(10,2),
+    ALTER COLUMN discount_amount TYPE DECIMAL(10,2),
+    ALTER COLUMN total TYPE DECIMAL(10,2);
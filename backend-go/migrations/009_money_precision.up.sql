@@ -0,0 +1,36 @@
+-- Store every Money column as numeric(19,4), wide enough for every supported
+-- currency precision and for amounts beyond the 99,999,999.99 DECIMAL(10,2)
+-- allowed. Tables created by the application's auto-migration already use
+-- numeric(19,4); columns that do not exist yet are skipped.
+
+DO $$
+DECLARE
+    money_column RECORD;
+BEGIN
+    FOR money_column IN
+        SELECT c.table_name, c.column_name
+        FROM information_schema.columns c
+        JOIN (VALUES
+            ('plans', 'price'),
+            ('invoices', 'subtotal'),
+            ('invoices', 'tax_amount'),
+            ('invoices', 'discount_amount'),
+            ('invoices', 'total'),
+            ('invoice_items', 'unit_price'),
+            ('invoice_items', 'amount'),
+            ('invoice_items', 'discount_amount'),
+            ('invoice_items', 'tax_amount'),
+            ('payment_attempts', 'amount'),
+            ('metered_prices', 'unit_amount'),
+            ('usage_summaries', 'amount'),
+            ('plan_migration_items', 'credit_amount'),
+            ('plan_migration_items', 'charge_amount')
+        ) AS m(table_name, column_name)
+            ON m.table_name = c.table_name AND m.column_name = c.column_name
+        WHERE c.table_schema = current_schema()
+          AND NOT (c.data_type = 'numeric' AND c.numeric_precision = 19 AND c.numeric_scale = 4)
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE NUMERIC(19,4)',
+            money_column.table_name, money_column.column_name);
+    END LOOP;
+END $$;
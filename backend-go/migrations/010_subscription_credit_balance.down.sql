@@ -0,0 +1,4 @@
+-- Rollback migration 010_subscription_credit_balance
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS credit_currency;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS credit_balance;
@@ -0,0 +1,5 @@
+-- Keep the credit a mid-period downgrade leaves on the subscription, so it is
+-- taken off the next invoices instead of sitting on a negative draft
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS credit_balance NUMERIC(19,4) NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS credit_currency VARCHAR(3);
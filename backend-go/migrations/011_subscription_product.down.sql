@@ -0,0 +1,4 @@
+-- Rollback migration 011_subscription_product
+
+DROP INDEX IF EXISTS idx_subscriptions_open_product;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS product;
@@ -0,0 +1,16 @@
+-- Store the product of each subscription's plan on the subscription and let
+-- an organization hold at most one open subscription per product. Apply this
+-- before upgrading an existing database: it fills in the product of existing
+-- subscriptions from their plans. An organization holding two open
+-- subscriptions to one product must have one of them ended first.
+
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS product VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS product VARCHAR(255) NOT NULL DEFAULT 'default';
+
+UPDATE subscriptions s
+SET product = p.product
+FROM plans p
+WHERE p.id = s.plan_id AND s.product <> p.product;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_open_product ON subscriptions(organization_id, product)
+    WHERE status <> 'canceled' AND status <> 'expired' AND deleted_at IS NULL;
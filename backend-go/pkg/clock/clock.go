@@ -0,0 +1,35 @@
+// Package clock abstracts the current time so billing logic can run against
+// simulated time. Services read the time from a Clock instead of calling
+// time.Now directly; production uses the system clock and test clocks use a
+// fixed instant that is moved forward explicitly.
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the time from the operating system
+type systemClock struct{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System returns the clock backed by the operating system
+func System() Clock {
+	return systemClock{}
+}
+
+// Fixed is a clock that always returns the same instant
+type Fixed time.Time
+
+// Now returns the fixed instant
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
@@ -0,0 +1,74 @@
+// Package currency is the registry of ISO 4217 currencies. It knows each
+// currency's minor-unit exponent, symbol and name, and which currencies the
+// deployment has enabled for sale.
+package currency
+
+import (
+	"sort"
+	"strings"
+
+	xcurrency "golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// DefaultExponent is the minor-unit exponent assumed for unknown codes
+const DefaultExponent = 2
+
+// Currency describes an ISO 4217 currency
+type Currency struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Symbol     string `json:"symbol"`
+	MinorUnits int    `json:"minor_units"`
+}
+
+// byCode indexes the ISO table by code
+var byCode = func() map[string]Currency {
+	index := make(map[string]Currency, len(iso4217))
+	printer := message.NewPrinter(language.Und)
+	for _, c := range iso4217 {
+		c.Symbol = c.Code
+		if unit, err := xcurrency.ParseISO(c.Code); err == nil {
+			c.Symbol = printer.Sprint(xcurrency.NarrowSymbol(unit))
+		}
+		index[c.Code] = c
+	}
+	return index
+}()
+
+// Normalize upper-cases and trims a currency code
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Lookup returns the currency for an ISO 4217 code
+func Lookup(code string) (Currency, bool) {
+	c, ok := byCode[Normalize(code)]
+	return c, ok
+}
+
+// IsValid reports whether code is a known ISO 4217 currency
+func IsValid(code string) bool {
+	_, ok := Lookup(code)
+	return ok
+}
+
+// Exponent returns the number of minor-unit digits for a currency, e.g. 2 for
+// USD, 0 for JPY and 3 for KWD
+func Exponent(code string) int {
+	if c, ok := Lookup(code); ok {
+		return c.MinorUnits
+	}
+	return DefaultExponent
+}
+
+// All returns every known currency ordered by code
+func All() []Currency {
+	all := make([]Currency, 0, len(byCode))
+	for _, c := range byCode {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Code < all[j].Code })
+	return all
+}
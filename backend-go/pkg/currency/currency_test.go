@@ -0,0 +1,96 @@
+package currency
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code     string
+		want     string
+		exponent int
+	}{
+		{"USD", "USD", 2},
+		{" eur ", "EUR", 2},
+		{"JPY", "JPY", 0},
+		{"KWD", "KWD", 3},
+		{"CLF", "CLF", 4},
+	}
+	for _, tt := range tests {
+		c, ok := Lookup(tt.code)
+		if !ok || c.Code != tt.want || c.MinorUnits != tt.exponent {
+			t.Errorf("Lookup(%q) = %+v, %v; want %s with %d minor units", tt.code, c, ok, tt.want, tt.exponent)
+		}
+		if got := Exponent(tt.code); got != tt.exponent {
+			t.Errorf("Exponent(%q) = %d, want %d", tt.code, got, tt.exponent)
+		}
+	}
+
+	for _, code := range []string{"", "XXX", "XAU", "US"} {
+		if IsValid(code) {
+			t.Errorf("IsValid(%q) = true, want false", code)
+		}
+	}
+	if got := Exponent("XXX"); got != DefaultExponent {
+		t.Errorf("Exponent(unknown) = %d, want %d", got, DefaultExponent)
+	}
+}
+
+func TestAllIsOrderedByCode(t *testing.T) {
+	all := All()
+	if len(all) == 0 {
+		t.Fatal("All returned no currencies")
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Code >= all[i].Code {
+			t.Fatalf("All is not ordered: %s before %s", all[i-1].Code, all[i].Code)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry([]string{"usd", " EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"USD", nil},
+		{"eur", nil},
+		{"GBP", ErrCurrencyNotEnabled},
+		{"XXX", ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		if err := registry.Validate(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+
+	enabled := registry.Enabled()
+	if len(enabled) != 2 || enabled[0].Code != "EUR" || enabled[1].Code != "USD" {
+		t.Errorf("Enabled() = %+v, want EUR and USD", enabled)
+	}
+}
+
+func TestRegistryWithoutCodesEnablesEverything(t *testing.T) {
+	registry, err := NewRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !registry.IsEnabled("GBP") || registry.IsEnabled("XXX") {
+		t.Error("expected every known currency and no unknown one to be enabled")
+	}
+	if got, want := len(registry.Enabled()), len(All()); got != want {
+		t.Errorf("Enabled() has %d currencies, want %d", got, want)
+	}
+}
+
+func TestNewRegistryRejectsUnknownCodes(t *testing.T) {
+	if _, err := NewRegistry([]string{"USD", "ABC"}); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("NewRegistry = %v, want ErrUnknownCurrency", err)
+	}
+}
@@ -0,0 +1,127 @@
+package currency
+
+import (
+	"unicode"
+
+	xcurrency "golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// symbolPlacement is where a locale's standard currency format puts the symbol
+type symbolPlacement int
+
+const (
+	symbolBefore       symbolPlacement = iota // ¤#,##0.00
+	symbolBeforeSpaced                        // ¤ #,##0.00
+	symbolAfterSpaced                         // #,##0.00 ¤
+)
+
+// symbolSpace separates a symbol from the digits; CLDR uses a no-break space
+// so the two never wrap onto separate lines
+const symbolSpace = "\u00a0"
+
+// symbolPlacements follows the standard currency patterns of CLDR, keyed by
+// language or by language and region. Locales not listed put the symbol
+// first, as English does.
+var symbolPlacements = map[string]symbolPlacement{
+	"bg": symbolAfterSpaced, "ca": symbolAfterSpaced, "cs": symbolAfterSpaced,
+	"da": symbolAfterSpaced, "de": symbolAfterSpaced, "el": symbolAfterSpaced,
+	"es": symbolAfterSpaced, "et": symbolAfterSpaced, "fi": symbolAfterSpaced,
+	"fr": symbolAfterSpaced, "hr": symbolAfterSpaced, "hu": symbolAfterSpaced,
+	"is": symbolAfterSpaced, "it": symbolAfterSpaced, "lt": symbolAfterSpaced,
+	"lv": symbolAfterSpaced, "nb": symbolAfterSpaced, "no": symbolAfterSpaced,
+	"nn": symbolAfterSpaced, "pl": symbolAfterSpaced, "ro": symbolAfterSpaced,
+	"ru": symbolAfterSpaced, "sk": symbolAfterSpaced, "sl": symbolAfterSpaced,
+	"sr": symbolAfterSpaced, "sv": symbolAfterSpaced, "uk": symbolAfterSpaced,
+	"vi": symbolAfterSpaced,
+
+	"nl": symbolBeforeSpaced, "pt": symbolBeforeSpaced,
+	"de-AT": symbolBeforeSpaced, "de-CH": symbolBeforeSpaced,
+	"de-LI": symbolBeforeSpaced, "it-CH": symbolBeforeSpaced,
+
+	"es-419": symbolBefore, "es-MX": symbolBefore, "es-US": symbolBefore,
+	"pt-PT": symbolAfterSpaced,
+}
+
+// Format renders an amount given in minor units for display in the given
+// locale, with the locale's symbol, its placement, digit grouping and
+// decimal separator, e.g. "$1,234.50" for en-US, "1.234,50 €" for de and
+// "1 234,50 €" for fr. Amounts are formatted from their minor units, so every
+// digit is exact. It is meant for humans only.
+func Format(minor int64, code string, tag language.Tag) string {
+	code = Normalize(code)
+	exp := Exponent(code)
+	printer := message.NewPrinter(tag)
+
+	symbol := code
+	if unit, err := xcurrency.ParseISO(code); err == nil {
+		symbol = printer.Sprint(xcurrency.Symbol(unit))
+	}
+
+	abs := uint64(minor)
+	sign := ""
+	if minor < 0 {
+		abs = ^abs + 1
+		sign = "-"
+	}
+	digits := formatMinor(printer, abs, exp)
+
+	switch placementOf(tag) {
+	case symbolAfterSpaced:
+		return sign + digits + symbolSpace + symbol
+	case symbolBeforeSpaced:
+		return sign + symbol + symbolSpace + digits
+	}
+	// Alphabetic symbols such as "CHF" are kept apart from the digits
+	if isAlphabetic(symbol) {
+		return sign + symbol + symbolSpace + digits
+	}
+	return sign + symbol + digits
+}
+
+// formatMinor renders abs minor units as a grouped decimal with exp
+// fraction digits, using the printer's digits and separators
+func formatMinor(printer *message.Printer, abs uint64, exp int) string {
+	unit := uint64(1)
+	for i := 0; i < exp; i++ {
+		unit *= 10
+	}
+
+	whole := printer.Sprint(number.Decimal(abs / unit))
+	if exp == 0 {
+		return whole
+	}
+	fraction := printer.Sprint(number.Decimal(abs%unit, number.NoSeparator(), number.MinIntegerDigits(exp)))
+	return whole + decimalSeparator(printer) + fraction
+}
+
+// decimalSeparator returns the printer's decimal separator, read from the
+// rendering of 1.5
+func decimalSeparator(printer *message.Printer) string {
+	r := []rune(printer.Sprint(number.Decimal(1.5, number.Scale(1))))
+	return string(r[1 : len(r)-1])
+}
+
+// placementOf looks up the symbol placement of tag's language and region,
+// then of its language alone
+func placementOf(tag language.Tag) symbolPlacement {
+	base, _ := tag.Base()
+	if region, confidence := tag.Region(); confidence == language.Exact {
+		if placement, ok := symbolPlacements[base.String()+"-"+region.String()]; ok {
+			return placement
+		}
+	}
+	return symbolPlacements[base.String()]
+}
+
+// isAlphabetic reports whether s consists of letters only
+func isAlphabetic(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
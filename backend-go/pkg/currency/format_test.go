@@ -0,0 +1,39 @@
+package currency
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		minor  int64
+		code   string
+		locale string
+		want   string
+	}{
+		{123450, "USD", "en-US", "$1,234.50"},
+		{5, "usd", "en", "$0.05"},
+		{-500, "USD", "en", "-$5.00"},
+		{123450, "CHF", "en", "CHF\u00a01,234.50"},
+		{1234500, "KWD", "en", "KWD\u00a01,234.500"},
+		{123450, "EUR", "de", "1.234,50\u00a0€"},
+		{-500, "EUR", "de", "-5,00\u00a0€"},
+		{123450, "EUR", "fr", "1\u00a0234,50\u00a0€"},
+		{123450, "EUR", "nl", "€\u00a01.234,50"},
+		{123450, "CHF", "de-CH", "CHF\u00a01’234.50"},
+		{123450, "BRL", "pt-BR", "R$\u00a01.234,50"},
+		{123450, "EUR", "es", "1.234,50\u00a0€"},
+		{123450, "MXN", "es-MX", "$1,234.50"},
+		{1234, "JPY", "ja", "￥1,234"},
+		{900719925474099312, "USD", "en", "$9,007,199,254,740,993.12"},
+		{-9223372036854775808, "USD", "en", "-$92,233,720,368,547,758.08"},
+	}
+	for _, tt := range tests {
+		got := Format(tt.minor, tt.code, language.MustParse(tt.locale))
+		if got != tt.want {
+			t.Errorf("Format(%d, %s, %s) = %q, want %q", tt.minor, tt.code, tt.locale, got, tt.want)
+		}
+	}
+}
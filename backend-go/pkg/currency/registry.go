@@ -0,0 +1,71 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownCurrency is returned for codes that are not in ISO 4217
+	ErrUnknownCurrency = errors.New("invalid currency code")
+	// ErrCurrencyNotEnabled is returned for valid codes the deployment does not sell in
+	ErrCurrencyNotEnabled = errors.New("currency is not enabled")
+)
+
+// Registry restricts the ISO 4217 table to the currencies enabled for sale
+type Registry struct {
+	enabled map[string]bool
+}
+
+// NewRegistry creates a registry enabling the given codes; no codes enables every currency
+func NewRegistry(enabled []string) (*Registry, error) {
+	r := &Registry{}
+	if len(enabled) == 0 {
+		return r, nil
+	}
+
+	r.enabled = make(map[string]bool, len(enabled))
+	for _, code := range enabled {
+		code = Normalize(code)
+		if !IsValid(code) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+		}
+		r.enabled[code] = true
+	}
+	return r, nil
+}
+
+// Validate checks that code is a known currency enabled for sale
+func (r *Registry) Validate(code string) error {
+	if !IsValid(code) {
+		return ErrUnknownCurrency
+	}
+	if !r.IsEnabled(code) {
+		return ErrCurrencyNotEnabled
+	}
+	return nil
+}
+
+// IsEnabled reports whether code may be used for new prices
+func (r *Registry) IsEnabled(code string) bool {
+	if !IsValid(code) {
+		return false
+	}
+	return r.enabled == nil || r.enabled[Normalize(code)]
+}
+
+// Enabled lists the currencies enabled for sale ordered by code
+func (r *Registry) Enabled() []Currency {
+	all := All()
+	if r.enabled == nil {
+		return all
+	}
+
+	enabled := make([]Currency, 0, len(r.enabled))
+	for _, c := range all {
+		if r.enabled[c.Code] {
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,20 @@
+package money
+
+import (
+	"go-backend/pkg/currency"
+
+	"golang.org/x/text/language"
+)
+
+// Exponent returns the number of minor-unit digits for a currency code
+func Exponent(code string) int {
+	return currency.Exponent(code)
+}
+
+// Format renders the amount for display in the given locale, e.g. "$19.99"
+func (m Money) Format(tag language.Tag) string {
+	if m.currency == "" {
+		return m.Decimal()
+	}
+	return currency.Format(m.amount, m.currency, tag)
+}
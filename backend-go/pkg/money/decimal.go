@@ -0,0 +1,160 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode selects how amounts that fall between two minor units are rounded
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds ties away from zero (0.125 -> 0.13)
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds ties to the nearest even digit (0.125 -> 0.12), also known as banker's rounding
+	RoundHalfEven
+)
+
+// ParseRoundingMode reads a rounding mode name as used in configuration
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "half_up", "half-up":
+		return RoundHalfUp, nil
+	case "half_even", "half-even", "bankers":
+		return RoundHalfEven, nil
+	default:
+		return RoundHalfUp, fmt.Errorf("unknown rounding mode %q", name)
+	}
+}
+
+// divRound divides num by den and rounds the quotient with mode. The
+// quotient may not fit in an int64; callers check it with IsInt64.
+func divRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	if den.Sign() < 0 {
+		num = new(big.Int).Neg(num)
+		den = new(big.Int).Neg(den)
+	}
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// Compare twice the remainder against the divisor to find which side of half we are on
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(den)
+
+	roundAway := cmp > 0
+	if cmp == 0 {
+		switch mode {
+		case RoundHalfEven:
+			roundAway = quo.Bit(0) == 1
+		default:
+			roundAway = true
+		}
+	}
+
+	if roundAway {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+// rescale converts an amount between minor-unit exponents. Currencies have
+// at most unboundExponent digits, so amounts are only ever scaled down and
+// the result always fits.
+func rescale(amount int64, from, to int, mode RoundingMode) int64 {
+	switch {
+	case from == to:
+		return amount
+	case to > from:
+		return amount * pow10(to-from)
+	default:
+		return divRound(big.NewInt(amount), big.NewInt(pow10(from-to)), mode).Int64()
+	}
+}
+
+// parseDecimal reads a decimal string into minor units at the given exponent
+func parseDecimal(value string, exp int, mode RoundingMode) (int64, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("money: empty amount")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("money: invalid amount %q", value)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("money: invalid amount %q", value)
+		}
+	}
+
+	digits, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return 0, fmt.Errorf("money: invalid amount %q", value)
+	}
+	if negative {
+		digits.Neg(digits)
+	}
+
+	scale := len(fracPart)
+	var result *big.Int
+	if scale <= exp {
+		result = digits.Mul(digits, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp-scale)), nil))
+	} else {
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-exp)), nil)
+		result = divRound(digits, div, mode)
+	}
+
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("money: amount %q out of range", value)
+	}
+	return result.Int64(), nil
+}
+
+// formatDecimal renders minor units as a plain decimal string
+func formatDecimal(amount int64, exp int) string {
+	negative := amount < 0
+	abs := new(big.Int).Abs(big.NewInt(amount)).String()
+
+	if exp > 0 {
+		if len(abs) <= exp {
+			abs = strings.Repeat("0", exp-len(abs)+1) + abs
+		}
+		abs = abs[:len(abs)-exp] + "." + abs[len(abs)-exp:]
+	}
+
+	if negative {
+		return "-" + abs
+	}
+	return abs
+}
+
+// pow10 returns 10^n for small n
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package money
+
+import "testing"
+
+func TestMulRatRounding(t *testing.T) {
+	tests := []struct {
+		amount, num, den int64
+		halfUp, halfEven int64
+	}{
+		{125, 1, 10, 13, 12},
+		{135, 1, 10, 14, 14},
+		{-125, 1, 10, -13, -12},
+		{-135, 1, 10, -14, -14},
+		{125, 1, -10, -13, -12},
+		{126, 1, 10, 13, 13},
+		{124, 1, 10, 12, 12},
+		{100, 1, 3, 33, 33},
+		{200, 1, 3, 67, 67},
+		{1000, 0, 7, 0, 0},
+	}
+	for _, tt := range tests {
+		m := New(tt.amount, "USD")
+		if got, err := m.MulRat(tt.num, tt.den, RoundHalfUp); err != nil || got.Amount() != tt.halfUp {
+			t.Errorf("%d * %d/%d half-up = %d, %v; want %d", tt.amount, tt.num, tt.den, got.Amount(), err, tt.halfUp)
+		}
+		if got, err := m.MulRat(tt.num, tt.den, RoundHalfEven); err != nil || got.Amount() != tt.halfEven {
+			t.Errorf("%d * %d/%d half-even = %d, %v; want %d", tt.amount, tt.num, tt.den, got.Amount(), err, tt.halfEven)
+		}
+	}
+}
+
+func TestPercentRounding(t *testing.T) {
+	tax := New(1000, "USD") // 8.25% of 10.00 is 0.825
+	if got, err := tax.Percent(825, RoundHalfUp); err != nil || got.Amount() != 83 {
+		t.Errorf("half-up = %d, %v; want 83", got.Amount(), err)
+	}
+	if got, err := tax.Percent(825, RoundHalfEven); err != nil || got.Amount() != 82 {
+		t.Errorf("half-even = %d, %v; want 82", got.Amount(), err)
+	}
+}
+
+func TestParseDecimalRounding(t *testing.T) {
+	tests := []struct {
+		value            string
+		exp              int
+		halfUp, halfEven int64
+	}{
+		{"0.125", 2, 13, 12},
+		{"0.135", 2, 14, 14},
+		{"-0.125", 2, -13, -12},
+		{"2.5", 0, 3, 2},
+		{"3.5", 0, 4, 4},
+		{"19.99", 2, 1999, 1999},
+		{"19.9", 2, 1990, 1990},
+		{"+7", 3, 7000, 7000},
+		{".5", 2, 50, 50},
+		{"1.23456", 4, 12346, 12346},
+		{"1.23445", 4, 12345, 12344},
+	}
+	for _, tt := range tests {
+		up, err := parseDecimal(tt.value, tt.exp, RoundHalfUp)
+		if err != nil || up != tt.halfUp {
+			t.Errorf("parseDecimal(%q, %d, half-up) = %d, %v; want %d", tt.value, tt.exp, up, err, tt.halfUp)
+		}
+		even, err := parseDecimal(tt.value, tt.exp, RoundHalfEven)
+		if err != nil || even != tt.halfEven {
+			t.Errorf("parseDecimal(%q, %d, half-even) = %d, %v; want %d", tt.value, tt.exp, even, err, tt.halfEven)
+		}
+	}
+}
+
+func TestParseDecimalRejectsInvalidInput(t *testing.T) {
+	for _, value := range []string{"", " ", "-", ".", "1.2.3", "abc", "1e5", "12,50", "99999999999999999999", "99999999999999999999.999", "-92233720368547758.085"} {
+		if _, err := parseDecimal(value, 2, RoundHalfUp); err == nil {
+			t.Errorf("parseDecimal(%q): expected an error", value)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value, currency string
+		want            Money
+	}{
+		{"19.99", "usd", New(1999, "USD")},
+		{"0.125", "USD", New(13, "USD")},
+		{"1.5", "JPY", New(2, "JPY")},
+		{"1.2345", "KWD", New(1235, "KWD")},
+		{"-0.005", "EUR", New(-1, "EUR")},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.value, tt.currency)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("Parse(%q, %q) = %v, %v; want %v", tt.value, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseOverflow(t *testing.T) {
+	for _, value := range []string{"99999999999999999999.999", "92233720368547758.075"} {
+		if _, err := Parse(value, "USD"); err == nil {
+			t.Errorf("Parse(%q, USD): expected an error", value)
+		}
+	}
+	if got, err := Parse("92233720368547758.07", "USD"); err != nil || got.Amount() != 9223372036854775807 {
+		t.Errorf("Parse(max) = %v, %v; want the largest amount", got, err)
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		amount int64
+		exp    int
+		want   string
+	}{
+		{1999, 2, "19.99"},
+		{5, 2, "0.05"},
+		{-5, 2, "-0.05"},
+		{0, 2, "0.00"},
+		{1, 3, "0.001"},
+		{-1500, 0, "-1500"},
+		{-9223372036854775808, 2, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := formatDecimal(tt.amount, tt.exp); got != tt.want {
+			t.Errorf("formatDecimal(%d, %d) = %q, want %q", tt.amount, tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	tests := map[string]RoundingMode{
+		"":          RoundHalfUp,
+		"half_up":   RoundHalfUp,
+		"HALF-UP":   RoundHalfUp,
+		"half_even": RoundHalfEven,
+		" bankers ": RoundHalfEven,
+	}
+	for name, want := range tests {
+		got, err := ParseRoundingMode(name)
+		if err != nil || got != want {
+			t.Errorf("ParseRoundingMode(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseRoundingMode("down"); err == nil {
+		t.Error(`ParseRoundingMode("down"): expected an error`)
+	}
+}
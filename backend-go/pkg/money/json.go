@@ -0,0 +1,69 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonMoney is the wire representation of an amount
+type jsonMoney struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Value    string `json:"value"`
+}
+
+// MarshalJSON encodes the amount as {"amount": minor units, "currency": code, "value": decimal}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.amount, Currency: m.currency, Value: m.Decimal()})
+}
+
+// UnmarshalJSON accepts either the object form produced by MarshalJSON or a
+// bare decimal number or string in major units. Bare values carry no currency
+// until Bind is called.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var obj struct {
+			Amount   *int64 `json:"amount"`
+			Currency string `json:"currency"`
+			Value    string `json:"value"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		switch {
+		case obj.Amount != nil:
+			*m = New(*obj.Amount, obj.Currency)
+			return nil
+		case obj.Value != "":
+			parsed, err := Parse(obj.Value, obj.Currency)
+			if err != nil {
+				return err
+			}
+			*m = parsed
+			return nil
+		default:
+			return fmt.Errorf("money: object needs an amount or value")
+		}
+	}
+
+	raw := string(data)
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(raw, "")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
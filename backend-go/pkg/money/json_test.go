@@ -0,0 +1,76 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(1999, "USD"), `{"amount":1999,"currency":"USD","value":"19.99"}`},
+		{New(-250, "EUR"), `{"amount":-250,"currency":"EUR","value":"-2.50"}`},
+		{New(1500, "JPY"), `{"amount":1500,"currency":"JPY","value":"1500"}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.m)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("Marshal(%v) = %s, %v; want %s", tt.m, data, err, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{New(1999, "USD"), New(-1, "EUR"), New(123456, "JPY"), New(98765, "KWD"), Zero("GBP")} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Money
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(m) {
+			t.Errorf("round trip of %v through %s gave %v", m, data, decoded)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		currency string // bound after decoding
+		want     Money
+	}{
+		{"object with amount", `{"amount":1999,"currency":"usd"}`, "", New(1999, "USD")},
+		{"object with value", `{"value":"19.99","currency":"USD"}`, "", New(1999, "USD")},
+		{"amount wins over value", `{"amount":1,"currency":"USD","value":"19.99"}`, "", New(1, "USD")},
+		{"bare number", `19.99`, "USD", New(1999, "USD")},
+		{"bare string", `"0.125"`, "USD", New(13, "USD")},
+		{"bare number in a zero-decimal currency", `1500`, "JPY", New(1500, "JPY")},
+		{"null", `null`, "", Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			if err := json.Unmarshal([]byte(tt.data), &m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Bind(tt.currency); !got.Equal(tt.want) {
+				t.Errorf("decoded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONRejectsInvalidInput(t *testing.T) {
+	for _, data := range []string{`{}`, `{"currency":"USD"}`, `"abc"`, `{"value":"x","currency":"USD"}`, `true`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); err == nil {
+			t.Errorf("Unmarshal(%s): expected an error", data)
+		}
+	}
+}
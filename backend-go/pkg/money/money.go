@@ -0,0 +1,321 @@
+// Package money provides an exact monetary amount type. Amounts are held as
+// an integer number of minor units (cents, pence, ...) together with an ISO
+// 4217 currency code, so arithmetic never suffers floating-point drift.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"go-backend/pkg/currency"
+)
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrOverflow is returned when the result of arithmetic does not fit in an
+// int64 number of minor units
+var ErrOverflow = errors.New("money: amount out of range")
+
+// unboundExponent is the precision used for amounts decoded without a
+// currency (a bare DECIMAL column or JSON number) until Bind attaches one
+const unboundExponent = 4
+
+// Money is an exact amount of a currency in minor units
+type Money struct {
+	amount   int64
+	currency string
+}
+
+// New creates an amount from minor units, e.g. New(1999, "USD") is $19.99
+func New(minor int64, currency string) Money {
+	return Money{amount: minor, currency: normalizeCode(currency)}
+}
+
+// Zero returns a zero amount in the given currency
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// Parse reads a decimal string such as "19.99" in major units. Digits beyond
+// the currency's precision are rounded half-up.
+func Parse(value, currency string) (Money, error) {
+	currency = normalizeCode(currency)
+	exp := unboundExponent
+	if currency != "" {
+		exp = Exponent(currency)
+	}
+
+	amount, err := parseDecimal(value, exp, RoundHalfUp)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: amount, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on malformed input; intended for constants
+func MustParse(value, currency string) Money {
+	m, err := Parse(value, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Amount returns the amount in minor units
+func (m Money) Amount() int64 {
+	return m.amount
+}
+
+// Currency returns the ISO 4217 currency code
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// exponent returns the number of minor-unit digits for this amount
+func (m Money) exponent() int {
+	if m.currency == "" {
+		return unboundExponent
+	}
+	return Exponent(m.currency)
+}
+
+// Bind attaches a currency to an amount that was decoded without one,
+// rescaling it to the currency's precision. Amounts that already carry a
+// currency are returned unchanged.
+func (m Money) Bind(currency string) Money {
+	currency = normalizeCode(currency)
+	if m.currency != "" || currency == "" {
+		return m
+	}
+
+	return Money{
+		amount:   rescale(m.amount, unboundExponent, Exponent(currency), RoundHalfUp),
+		currency: currency,
+	}
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.amount > 0
+}
+
+// IsNegative reports whether the amount is less than zero
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+// SameCurrency reports whether both amounts are in the same currency
+func (m Money) SameCurrency(o Money) bool {
+	return m.currency == o.currency
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.assertSameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.amount + o.amount
+	if (o.amount > 0 && sum < m.amount) || (o.amount < 0 && sum > m.amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, o)
+	}
+	return Money{amount: sum, currency: m.currency}, nil
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.assertSameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	diff := m.amount - o.amount
+	if (o.amount > 0 && diff > m.amount) || (o.amount < 0 && diff < m.amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, o)
+	}
+	return Money{amount: diff, currency: m.currency}, nil
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{amount: -m.amount, currency: m.currency}
+}
+
+// Abs returns |m|
+func (m Money) Abs() Money {
+	if m.amount < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// Mul returns m multiplied by an integer quantity
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.amount * n
+	if m.amount != 0 && (product/m.amount != n || m.amount == -1 && n == math.MinInt64) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, n)
+	}
+	return Money{amount: product, currency: m.currency}, nil
+}
+
+// MulRat returns m * num / den rounded with the given mode. It is exact for
+// any int64 inputs, which makes it suitable for proration by durations, and
+// returns ErrOverflow when the result does not fit.
+func (m Money) MulRat(num, den int64, mode RoundingMode) (Money, error) {
+	if den == 0 {
+		panic("money: division by zero")
+	}
+
+	product := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	result := divRound(product, big.NewInt(den), mode)
+	if !result.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s * %d / %d", ErrOverflow, m, num, den)
+	}
+	return Money{amount: result.Int64(), currency: m.currency}, nil
+}
+
+// Percent returns m * basisPoints / 10000, e.g. Percent(825) is 8.25%
+func (m Money) Percent(basisPoints int64, mode RoundingMode) (Money, error) {
+	return m.MulRat(basisPoints, 10000, mode)
+}
+
+// Cmp compares m and o, returning -1, 0 or +1
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.assertSameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Equal reports whether both amounts have the same value and currency
+func (m Money) Equal(o Money) bool {
+	return m.amount == o.amount && m.currency == o.currency
+}
+
+// Allocate splits m across the given ratios without losing minor units. Any
+// remainder left after flooring each share goes one unit at a time to the
+// shares with the largest fractional parts.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, errors.New("money: allocate needs at least one ratio")
+	}
+
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, errors.New("money: allocation ratios must not be negative")
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, errors.New("money: allocation ratios must not all be zero")
+	}
+
+	sign := int64(1)
+	amount := m.amount
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	shares := make([]Money, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	bigTotal := big.NewInt(total)
+	allocated := int64(0)
+
+	for i, r := range ratios {
+		product := new(big.Int).Mul(big.NewInt(amount), big.NewInt(r))
+		quo, rem := new(big.Int).QuoRem(product, bigTotal, new(big.Int))
+		shares[i] = Money{amount: quo.Int64(), currency: m.currency}
+		remainders[i] = rem
+		allocated += quo.Int64()
+	}
+
+	for left := amount - allocated; left > 0; left-- {
+		best := -1
+		for i := range shares {
+			if ratios[i] == 0 {
+				continue
+			}
+			if best == -1 || remainders[i].Cmp(remainders[best]) > 0 {
+				best = i
+			}
+		}
+		shares[best].amount++
+		remainders[best].SetInt64(-1)
+	}
+
+	for i := range shares {
+		shares[i].amount *= sign
+	}
+	return shares, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, errors.New("money: split needs a positive number of parts")
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Sum adds amounts that must all be in currency
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Decimal returns the amount in major units, e.g. "19.99"
+func (m Money) Decimal() string {
+	return formatDecimal(m.amount, m.exponent())
+}
+
+// String returns the amount and currency, e.g. "19.99 USD"
+func (m Money) String() string {
+	if m.currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.currency
+}
+
+// assertSameCurrency fails with ErrCurrencyMismatch unless both amounts share a currency
+func (m Money) assertSameCurrency(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, displayCode(m.currency), displayCode(o.currency))
+	}
+	return nil
+}
+
+// normalizeCode upper-cases and trims a currency code
+func normalizeCode(code string) string {
+	return currency.Normalize(code)
+}
+
+// displayCode renders a currency code for error messages
+func displayCode(code string) string {
+	if code == "" {
+		return "<none>"
+	}
+	return code
+}
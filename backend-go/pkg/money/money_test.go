@@ -0,0 +1,143 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestArithmetic(t *testing.T) {
+	price := New(1999, "USD")
+
+	sum, err := price.Add(New(1, "USD"))
+	if err != nil || !sum.Equal(New(2000, "USD")) {
+		t.Errorf("Add = %v, %v; want 20.00 USD", sum, err)
+	}
+	diff, err := price.Sub(New(2000, "USD"))
+	if err != nil || !diff.Equal(New(-1, "USD")) {
+		t.Errorf("Sub = %v, %v; want -0.01 USD", diff, err)
+	}
+	product, err := price.Mul(3)
+	if err != nil || !product.Equal(New(5997, "USD")) {
+		t.Errorf("Mul = %v, %v; want 59.97 USD", product, err)
+	}
+	if _, err := price.Add(New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := price.Sub(New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub across currencies = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	max, min := New(math.MaxInt64, "USD"), New(math.MinInt64, "USD")
+	one, minusOne := New(1, "USD"), New(-1, "USD")
+
+	tests := []struct {
+		name     string
+		op       func() (Money, error)
+		overflow bool
+	}{
+		{"max + 1", func() (Money, error) { return max.Add(one) }, true},
+		{"min + -1", func() (Money, error) { return min.Add(minusOne) }, true},
+		{"max + -1", func() (Money, error) { return max.Add(minusOne) }, false},
+		{"min - 1", func() (Money, error) { return min.Sub(one) }, true},
+		{"max - -1", func() (Money, error) { return max.Sub(minusOne) }, true},
+		{"0 - min", func() (Money, error) { return Zero("USD").Sub(min) }, true},
+		{"min - -1", func() (Money, error) { return min.Sub(minusOne) }, false},
+		{"max * 2", func() (Money, error) { return max.Mul(2) }, true},
+		{"min * -1", func() (Money, error) { return min.Mul(-1) }, true},
+		{"-1 * min", func() (Money, error) { return minusOne.Mul(math.MinInt64) }, true},
+		{"max * -1", func() (Money, error) { return max.Mul(-1) }, false},
+		{"0 * min", func() (Money, error) { return Zero("USD").Mul(math.MinInt64) }, false},
+		{"large * large", func() (Money, error) { return New(1<<32, "USD").Mul(1 << 32) }, true},
+		{"max * 3/2", func() (Money, error) { return max.MulRat(3, 2, RoundHalfUp) }, true},
+		{"min * 1/-1", func() (Money, error) { return min.MulRat(1, -1, RoundHalfUp) }, true},
+		{"max * 2/2", func() (Money, error) { return max.MulRat(2, 2, RoundHalfUp) }, false},
+		{"max * 1/3", func() (Money, error) { return max.MulRat(1, 3, RoundHalfUp) }, false},
+		{"max percent 10001", func() (Money, error) { return max.Percent(10001, RoundHalfUp) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.op()
+			if got := errors.Is(err, ErrOverflow); got != tt.overflow {
+				t.Errorf("overflow = %v (err %v), want %v", got, err, tt.overflow)
+			}
+		})
+	}
+}
+
+func TestSumStopsAtOverflow(t *testing.T) {
+	if _, err := Sum("USD", New(math.MaxInt64, "USD"), New(1, "USD")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Sum = %v, want ErrOverflow", err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		ratios []int64
+		want   []int64
+	}{
+		{"exact", 100, []int64{70, 20, 10}, []int64{70, 20, 10}},
+		{"equal thirds", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"largest remainder first", 10, []int64{1, 2}, []int64{3, 7}},
+		{"ties go to the earlier share", 5, []int64{3, 3, 4}, []int64{2, 1, 2}},
+		{"several units left over", 11, []int64{1, 1, 1, 1, 1, 1}, []int64{2, 2, 2, 2, 2, 1}},
+		{"zero ratio gets nothing", 5, []int64{0, 1, 1}, []int64{0, 3, 2}},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"zero amount", 0, []int64{1, 2}, []int64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := New(tt.amount, "USD").Allocate(tt.ratios...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != len(tt.want) {
+				t.Fatalf("got %d shares, want %d", len(shares), len(tt.want))
+			}
+			var total int64
+			for i, share := range shares {
+				if share.Amount() != tt.want[i] || share.Currency() != "USD" {
+					t.Errorf("share %d = %v, want %d USD minor units", i, share, tt.want[i])
+				}
+				total += share.Amount()
+			}
+			if total != tt.amount {
+				t.Errorf("shares add up to %d, want %d", total, tt.amount)
+			}
+		})
+	}
+}
+
+func TestAllocateRejectsInvalidRatios(t *testing.T) {
+	for name, ratios := range map[string][]int64{
+		"none":     nil,
+		"negative": {1, -1},
+		"all zero": {0, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(100, "USD").Allocate(ratios...); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	parts, err := New(10, "EUR").Split(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{4, 3, 3}
+	for i, part := range parts {
+		if part.Amount() != want[i] {
+			t.Errorf("part %d = %d, want %d", i, part.Amount(), want[i])
+		}
+	}
+	if _, err := New(10, "EUR").Split(0); err == nil {
+		t.Error("Split(0): expected an error")
+	}
+}
@@ -0,0 +1,152 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// rateScale is the number of decimal places a rate is stored and rendered with
+const rateScale = 12
+
+// Rate is an exact exchange rate: one unit of a base currency buys Rate
+// units of a quote currency
+type Rate struct {
+	r *big.Rat
+}
+
+// ParseRate reads a positive decimal rate such as "83.1245"
+func ParseRate(value string) (Rate, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return Rate{}, fmt.Errorf("money: invalid rate %q", value)
+	}
+	if r.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("money: rate must be positive, got %q", value)
+	}
+	return Rate{r: r}, nil
+}
+
+// OneRate is the rate between a currency and itself
+func OneRate() Rate {
+	return Rate{r: big.NewRat(1, 1)}
+}
+
+// IsZero reports whether the rate is unset
+func (r Rate) IsZero() bool {
+	return r.r == nil || r.r.Sign() == 0
+}
+
+// Inverse returns the rate for the opposite direction
+func (r Rate) Inverse() Rate {
+	if r.IsZero() {
+		return r
+	}
+	return Rate{r: new(big.Rat).Inv(r.r)}
+}
+
+// String renders the rate as a decimal with trailing zeros removed
+func (r Rate) String() string {
+	if r.r == nil {
+		return "0"
+	}
+	s := r.r.FloatString(rateScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert expresses m in another currency at the given rate, rounding the
+// result to the target currency's minor units
+func (m Money) Convert(rate Rate, to string, mode RoundingMode) (Money, error) {
+	if m.currency == "" {
+		return Money{}, errors.New("money: cannot convert an amount without a currency")
+	}
+	if rate.IsZero() {
+		return Money{}, errors.New("money: cannot convert with a zero rate")
+	}
+
+	to = normalizeCode(to)
+	// amount * rate * 10^(toExp - fromExp), kept as a fraction until the final rounding
+	num := new(big.Int).Mul(big.NewInt(m.amount), rate.r.Num())
+	den := new(big.Int).Set(rate.r.Denom())
+	if diff := Exponent(to) - m.exponent(); diff > 0 {
+		num.Mul(num, big.NewInt(pow10(diff)))
+	} else if diff < 0 {
+		den.Mul(den, big.NewInt(pow10(-diff)))
+	}
+
+	result := divRound(num, den, mode)
+	if !result.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s at %s", ErrOverflow, m, rate)
+	}
+	return Money{amount: result.Int64(), currency: to}, nil
+}
+
+// Value implements driver.Valuer
+func (r Rate) Value() (driver.Value, error) {
+	if r.r == nil {
+		return nil, nil
+	}
+	return r.r.FloatString(rateScale), nil
+}
+
+// Scan implements sql.Scanner
+func (r *Rate) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("money: cannot scan %T into a rate", src)
+	}
+
+	parsed, ok := new(big.Rat).SetString(raw)
+	if !ok {
+		return fmt.Errorf("money: invalid rate %q", raw)
+	}
+	*r = Rate{r: parsed}
+	return nil
+}
+
+// GormDataType declares the generic column type for GORM
+func (Rate) GormDataType() string {
+	return "decimal"
+}
+
+// GormDBDataType declares the column type used to store rates
+func (Rate) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "numeric(24,12)"
+}
+
+// MarshalJSON encodes the rate as a decimal string so no precision is lost
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts the rate as a JSON number or string
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	parsed, err := ParseRate(number.String())
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
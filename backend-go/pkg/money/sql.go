@@ -0,0 +1,54 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Value implements driver.Valuer, storing the amount as an exact decimal in major units
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan implements sql.Scanner. The column only holds the number, so the
+// result has no currency until the owning model calls Bind with the value of
+// its currency column.
+func (m *Money) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case int64:
+		raw = strconv.FormatInt(v, 10)
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+
+	amount, err := parseDecimal(raw, unboundExponent, RoundHalfUp)
+	if err != nil {
+		return err
+	}
+	*m = Money{amount: amount}
+	return nil
+}
+
+// GormDataType declares the generic column type for GORM
+func (Money) GormDataType() string {
+	return "decimal"
+}
+
+// GormDBDataType declares a column wide enough for every supported currency precision
+func (Money) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "numeric(19,4)"
+}
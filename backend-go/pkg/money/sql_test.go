@@ -0,0 +1,109 @@
+package money
+
+import "testing"
+
+func TestScanAndBind(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      interface{}
+		currency string
+		want     Money
+	}{
+		{"numeric bytes", []byte("19.9900"), "USD", New(1999, "USD")},
+		{"string", "19.99", "usd", New(1999, "USD")},
+		{"rounded half-up to the currency", []byte("0.1250"), "USD", New(13, "USD")},
+		{"negative", []byte("-0.1250"), "USD", New(-13, "USD")},
+		{"zero-decimal currency", []byte("1500.0000"), "JPY", New(1500, "JPY")},
+		{"three-decimal currency", []byte("1.2345"), "KWD", New(1235, "KWD")},
+		{"integer", int64(5), "JPY", New(5, "JPY")},
+		{"float", 1.5, "KWD", New(1500, "KWD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			if err := m.Scan(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			if m.Currency() != "" {
+				t.Errorf("scanned amount has currency %q, want none", m.Currency())
+			}
+			if got := m.Bind(tt.currency); !got.Equal(tt.want) {
+				t.Errorf("Bind(%q) = %v, want %v", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanNull(t *testing.T) {
+	m := New(1999, "USD")
+	if err := m.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Equal(Money{}) {
+		t.Errorf("Scan(nil) = %v, want the zero value", m)
+	}
+}
+
+func TestScanRejectsInvalidInput(t *testing.T) {
+	for _, src := range []interface{}{true, []byte("abc"), "1e5"} {
+		var m Money
+		if err := m.Scan(src); err == nil {
+			t.Errorf("Scan(%#v): expected an error", src)
+		}
+	}
+}
+
+func TestBindKeepsExistingCurrency(t *testing.T) {
+	m := New(1999, "USD")
+	if got := m.Bind("EUR"); !got.Equal(m) {
+		t.Errorf("Bind(EUR) = %v, want %v", got, m)
+	}
+
+	var unbound Money
+	if err := unbound.Scan("12.3456"); err != nil {
+		t.Fatal(err)
+	}
+	if got := unbound.Bind(""); !got.Equal(unbound) {
+		t.Errorf("Bind(\"\") = %v, want %v", got, unbound)
+	}
+}
+
+func TestValue(t *testing.T) {
+	var unbound Money
+	if err := unbound.Scan("12.3456"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(1999, "USD"), "19.99"},
+		{New(-5, "JPY"), "-5"},
+		{New(1, "KWD"), "0.001"},
+		{Zero("EUR"), "0.00"},
+		{unbound, "12.3456"},
+	}
+	for _, tt := range tests {
+		got, err := tt.m.Value()
+		if err != nil || got != tt.want {
+			t.Errorf("%v.Value() = %v, %v; want %q", tt.m, got, err, tt.want)
+		}
+	}
+}
+
+func TestValueScanRoundTrip(t *testing.T) {
+	for _, m := range []Money{New(1999, "USD"), New(-1, "EUR"), New(123456, "JPY"), New(98765, "KWD"), Zero("GBP")} {
+		value, err := m.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scanned Money
+		if err := scanned.Scan(value); err != nil {
+			t.Fatal(err)
+		}
+		if got := scanned.Bind(m.Currency()); !got.Equal(m) {
+			t.Errorf("round trip of %v gave %v", m, got)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"time"
 
+	"go-backend/pkg/clock"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -21,19 +23,21 @@ type JWTClaims struct {
 type JWTManager struct {
 	secretKey  string
 	expiration time.Duration
+	clock      clock.Clock
 }
 
 // NewJWTManager creates a new JWT manager
-func NewJWTManager(secretKey string, expiration time.Duration) *JWTManager {
+func NewJWTManager(secretKey string, expiration time.Duration, clk clock.Clock) *JWTManager {
 	return &JWTManager{
 		secretKey:  secretKey,
 		expiration: expiration,
+		clock:      clk,
 	}
 }
 
 // GenerateToken generates a new JWT token for a user
 func (j *JWTManager) GenerateToken(userID uuid.UUID, email, role string, organizationID *uuid.UUID) (string, error) {
-	now := time.Now()
+	now := j.clock.Now()
 	claims := &JWTClaims{
 		UserID:         userID,
 		Email:          email,
@@ -60,7 +64,7 @@ func (j *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 			return nil, errors.New("invalid signing method")
 		}
 		return []byte(j.secretKey), nil
-	})
+	}, jwt.WithTimeFunc(j.clock.Now))
 
 	if err != nil {
 		return nil, err
@@ -81,7 +85,7 @@ func (j *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 // RefreshToken generates a new token with extended expiration
 func (j *JWTManager) RefreshToken(claims *JWTClaims) (string, error) {
 	// Check if the token is not expired by more than 24 hours (grace period)
-	if time.Now().Sub(claims.ExpiresAt.Time) > 24*time.Hour {
+	if j.clock.Now().Sub(claims.ExpiresAt.Time) > 24*time.Hour {
 		return "", errors.New("token is too old to refresh")
 	}
 